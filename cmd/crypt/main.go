@@ -2,12 +2,14 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/hex"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/nais/deploy/pkg/crypto"
+	"github.com/nais/deploy/pkg/hookd/database"
 	log "github.com/sirupsen/logrus"
 	flag "github.com/spf13/pflag"
 )
@@ -19,7 +21,17 @@ const (
 var (
 	shouldEncrypt = flag.Bool("encrypt", false, "try encrypting input data")
 	shouldDecrypt = flag.Bool("decrypt", false, "try decrypting input data")
-	encryptionKey = flag.String("key", getEnvDefault("ENCRYPTION_KEY", defaultEncryptionKey), "encryption key")
+	shouldWrap    = flag.Bool("wrap", false, "wrap hex encoded keys from input with the key encryption key")
+	shouldRotate  = flag.Bool("rotate", false, "re-encrypt all data in the hookd database with the active key")
+	encryptionKey = flag.String("key", getEnvDefault("ENCRYPTION_KEY", defaultEncryptionKey), "legacy encryption key, used for data without a key ID")
+	keys          = flag.StringSlice("keys", nil, "additional encryption keys in the form id=key, comma separated")
+	activeKey     = flag.String("active-key", "", "ID of the key used for encryption; empty means the legacy key")
+	kekFile       = flag.String("kek-file", "", "file with a hex encoded key encryption key, used to unwrap keys")
+	kekPlugin     = flag.String("kek-plugin", "", "command that wraps and unwraps keys")
+	databaseURL   = flag.String("database-url", getEnvDefault("DATABASE_URL", ""), "PostgreSQL connection information, used with --rotate")
+	batchSize     = flag.Int("batch-size", 100, "number of rows to re-encrypt in each transaction, used with --rotate")
+	purpose       = flag.String("purpose", "", "purpose the data is bound to, e.g. 'apikey'")
+	owner         = flag.String("owner", "", "owner the data is bound to, e.g. the team of an API key")
 	useHex        = flag.Bool("hex", true, "output data as hex string")
 )
 
@@ -31,12 +43,8 @@ func getEnvDefault(env string, def string) string {
 	return val
 }
 
-func decrypt(s string, key []byte) (string, error) {
-	decoded, err := hex.DecodeString(s)
-	if err != nil {
-		return "", err
-	}
-	decrypted, err := crypto.Decrypt(decoded, key)
+func decrypt(s string, keyring *crypto.Keyring) (string, error) {
+	decrypted, err := keyring.Decrypt(s, additionalData())
 	if err != nil {
 		return "", err
 	}
@@ -46,32 +54,100 @@ func decrypt(s string, key []byte) (string, error) {
 	return string(decrypted), nil
 }
 
-func encrypt(s string, key []byte) (string, error) {
-	encrypted, err := crypto.Encrypt([]byte(s), key)
+func encrypt(s string, keyring *crypto.Keyring) (string, error) {
+	return keyring.Encrypt([]byte(s), additionalData())
+}
+
+// additionalData binds ciphertexts to the purpose and owner of the data, as in the hookd database.
+func additionalData() []byte {
+	if len(*purpose) == 0 && len(*owner) == 0 {
+		return nil
+	}
+	return crypto.AdditionalData(*purpose, *owner)
+}
+
+func wrap(ctx context.Context, s string, kek crypto.KeyEncryptionKey) (string, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := kek.Wrap(ctx, key)
 	if err != nil {
 		return "", err
 	}
-	return hex.EncodeToString(encrypted), nil
+	return hex.EncodeToString(wrapped), nil
+}
+
+func rotate(ctx context.Context, keyring *crypto.Keyring) error {
+	if len(*databaseURL) == 0 {
+		return fmt.Errorf("DATABASE_URL environment variable not provided")
+	}
+
+	db, err := database.New(ctx, *databaseURL, keyring)
+	if err != nil {
+		return fmt.Errorf("connect to database: %w", err)
+	}
+
+	log.Infof("Re-encrypting database with key %q", keyring.ActiveKeyID())
+	n, err := db.Reencrypt(ctx, *batchSize)
+	log.Infof("Re-encrypted %d rows", n)
+	return err
 }
 
 func run() error {
 	errors := 0
+	ctx := context.Background()
 
 	flag.Parse()
 
-	if len(*encryptionKey) == 0 {
+	if len(*encryptionKey) == 0 && len(*keys) == 0 {
 		return fmt.Errorf("ENCRYPTION_KEY environment variable not provided")
 	}
-	key, err := crypto.KeyFromHexString(*encryptionKey)
+
+	if *shouldWrap {
+		kek, err := crypto.NewKEK(*kekFile, *kekPlugin)
+		if err != nil {
+			return err
+		}
+		if kek == nil {
+			return fmt.Errorf("--kek-file or --kek-plugin is required to wrap keys")
+		}
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			wrapped, err := wrap(ctx, strings.TrimSpace(scanner.Text()), kek)
+			if err == nil {
+				fmt.Println(wrapped)
+			} else {
+				log.Errorf("wrapping failed: %s", err)
+				errors++
+			}
+		}
+		if errors == 0 {
+			return nil
+		}
+		return fmt.Errorf("%d errors", errors)
+	}
+
+	keyring, err := crypto.KeyringConfig{
+		LegacyKey:   *encryptionKey,
+		Keys:        *keys,
+		ActiveKeyID: *activeKey,
+		KEKFile:     *kekFile,
+		KEKPlugin:   *kekPlugin,
+	}.Load(ctx)
 	if err != nil {
 		return err
 	}
 
+	if *shouldRotate {
+		return rotate(ctx, keyring)
+	}
+
 	scanner := bufio.NewScanner(os.Stdin)
 	for scanner.Scan() {
 		text := strings.TrimRight(scanner.Text(), "\n\r")
 		if *shouldDecrypt {
-			decrypted, err := decrypt(text, key)
+			decrypted, err := decrypt(text, keyring)
 			if err == nil {
 				fmt.Println(decrypted)
 			} else {
@@ -80,7 +156,7 @@ func run() error {
 			}
 		}
 		if *shouldEncrypt {
-			encrypted, err := encrypt(text, key)
+			encrypted, err := encrypt(text, keyring)
 			if err == nil {
 				fmt.Println(encrypted)
 			} else {
@@ -12,7 +12,6 @@ import (
 	"github.com/nais/deploy/pkg/version"
 	"go.opentelemetry.io/otel/attribute"
 	otrace "go.opentelemetry.io/otel/trace"
-	"google.golang.org/protobuf/encoding/protojson"
 
 	log "github.com/sirupsen/logrus"
 	flag "github.com/spf13/pflag"
@@ -44,10 +43,16 @@ func run() error {
 	// Welcome
 	log.Infof("NAIS deploy %s", version.Version())
 
+	// Explaining template variables needs neither a valid configuration nor a connection to NAIS deploy.
+	if cfg.ExplainVariables {
+		d := deployclient.Deployer{}
+		return d.ExplainVariables(cfg)
+	}
+
 	err := cfg.Validate()
 	if err != nil {
 		if !errors.Is(err, deployclient.ErrInvalidTelemetryFormat) {
-			if !cfg.DryRun {
+			if !cfg.ClientDryRun() {
 				return deployclient.ErrorWrap(deployclient.ExitInvocationFailure, err)
 			}
 			log.Warnf("Configuration did not pass validation: %s", err)
@@ -103,12 +108,6 @@ func run() error {
 		Value: attribute.StringValue(version.Version()),
 	})
 
-	// Prepare request
-	request, err := deployclient.Prepare(ctx, cfg)
-	if err != nil {
-		return err
-	}
-
 	// Set up asynchronous gRPC connection
 	grpcConnection, err := deployclient.NewGrpcConnection(*cfg)
 	if err != nil {
@@ -125,13 +124,48 @@ func run() error {
 		Client: pb.NewDeployClient(grpcConnection),
 	}
 
+	// Commands that follow a deployment report their progress as events.
+	// Human-readable logs are always written to standard error.
+	if cfg.Output == deployclient.OutputJSON {
+		switch cfg.Command {
+		case deployclient.CommandDeploy, deployclient.CommandStatus, deployclient.CommandRollback:
+			d.Events = os.Stdout
+		}
+	}
+
+	// Commands operating on existing deployments
+	switch cfg.Command {
+	case deployclient.CommandCancel:
+		return d.Cancel(ctx, cfg)
+	case deployclient.CommandStatus:
+		return d.Attach(ctx, cfg)
+	case deployclient.CommandHistory:
+		return d.History(ctx, cfg)
+	case deployclient.CommandRollback:
+		return d.Rollback(ctx, cfg)
+	}
+
+	if len(cfg.Clusters()) > 1 {
+		return d.DeployClusters(ctx, cfg)
+	}
+
+	// Prepare request
+	request, err := deployclient.Prepare(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
 	if cfg.PrintPayload {
-		fmt.Println(protojson.Format(request))
+		d.PrintPayload(request)
 	}
 
-	if cfg.DryRun {
+	if cfg.ClientDryRun() {
 		return nil
 	}
 
+	if cfg.Command == deployclient.CommandDiff {
+		return d.Diff(ctx, cfg, request)
+	}
+
 	return d.Deploy(ctx, cfg, request)
 }
@@ -131,6 +131,12 @@ func run() error {
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 
+	// The pod name identifies this instance among several deployd instances in the same cluster.
+	instance, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("get hostname: %s", err)
+	}
+
 	startupTime := time.Now()
 	statusChan := make(chan *pb.DeploymentStatus, 1024)
 	requestChan := make(chan *pb.DeploymentRequest, 1024)
@@ -143,6 +149,13 @@ func run() error {
 			deploymentStream, err := grpcClient.Deployments(programContext, &pb.GetDeploymentOpts{
 				Cluster:     cfg.Cluster,
 				StartupTime: pb.TimeAsTimestamp(startupTime),
+				Instance:    instance,
+				Actions: []pb.DeploymentAction{
+					pb.DeploymentAction_deploy,
+					pb.DeploymentAction_diff,
+					pb.DeploymentAction_cancel,
+				},
+				DryRun: true,
 			})
 			if err != nil {
 				log.Errorf("Open hookd deployment stream: %s", err)
@@ -164,19 +177,23 @@ func run() error {
 		}
 	}()
 
-	deploy := func(req *pb.DeploymentRequest) {
-		ctx, cancel := req.Context()
-		ctx = telemetry.WithTraceParent(ctx, req.TraceParent)
-		ctx, span := telemetry.Tracer().Start(ctx, "Deploy to Kubernetes", otrace.WithSpanKind(otrace.SpanKindServer))
+	operations := operation.NewRegistry()
 
-		client, err := kube.Impersonate(req.GetTeam())
+	// Diff results are only useful to a client that is still waiting for them, so they are not queued.
+	reportDiff := func(result *pb.DiffResult) {
+		logger := log.WithFields(result.GetRequest().LogFields())
+		_, err := grpcClient.ReportDiff(programContext, result)
 		if err != nil {
-			span.SetStatus(ocodes.Error, err.Error())
-			span.End()
-			cancel()
-			statusChan <- pb.NewErrorStatus(req, err)
+			logger.Errorf("Report diff result: %s", err)
 			return
 		}
+		logger.Infof("Diff result reported")
+	}
+
+	deploy := func(req *pb.DeploymentRequest) {
+		ctx, cancel := req.Context()
+		ctx = telemetry.WithTraceParent(ctx, req.TraceParent)
+		ctx, span := telemetry.Tracer().Start(ctx, "Deploy to Kubernetes", otrace.WithSpanKind(otrace.SpanKindServer))
 
 		logger := log.WithFields(req.LogFields())
 
@@ -189,9 +206,48 @@ func run() error {
 			StatusChan: statusChan,
 		}
 
+		diff := req.GetAction() == pb.DeploymentAction_diff
+
+		// The deployment has already been received, and its outcome will be reported by the operation in progress.
+		if !diff && !operations.Add(op) {
+			logger.Warnf("Ignoring deployment request that is already in progress")
+			span.End()
+			cancel()
+			return
+		}
+
+		client, err := kube.Impersonate(req.GetTeam())
+		if err != nil {
+			span.SetStatus(ocodes.Error, err.Error())
+			span.End()
+			cancel()
+			if diff {
+				reportDiff(&pb.DiffResult{Request: req, Error: err.Error()})
+				return
+			}
+			statusChan <- pb.NewErrorStatus(req, err)
+			return
+		}
+
+		if diff {
+			reportDiff(deployd.Diff(op, client))
+			return
+		}
+
 		deployd.Run(op, client)
 	}
 
+	cancelDeployment := func(req *pb.DeploymentRequest) {
+		logger := log.WithFields(req.LogFields())
+		if operations.Cancel(req.GetID()) {
+			logger.Infof("Deployment cancelled by user")
+			return
+		}
+		// Cancel requests are sent to every deployd instance in the cluster,
+		// and only the one running the deployment reports its outcome.
+		logger.Warnf("Cancel requested for deployment that is not in progress on this instance")
+	}
+
 	statusQueue := make([]*pb.DeploymentStatus, 0, 128)
 
 	report := func(st *pb.DeploymentStatus) error {
@@ -237,7 +293,11 @@ func run() error {
 	for {
 		select {
 		case req := <-requestChan:
-			go deploy(req)
+			if req.GetAction() == pb.DeploymentAction_cancel {
+				go cancelDeployment(req)
+			} else {
+				go deploy(req)
+			}
 
 		case st := <-statusChan:
 			statusQueue = append(statusQueue, st)
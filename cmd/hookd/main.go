@@ -20,6 +20,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/keepalive"
 
+	"github.com/nais/deploy/pkg/crypto"
 	"github.com/nais/deploy/pkg/grpc/deployserver"
 	"github.com/nais/deploy/pkg/grpc/dispatchserver"
 	auth_interceptor "github.com/nais/deploy/pkg/grpc/interceptor/auth"
@@ -30,7 +31,9 @@ import (
 	"github.com/nais/deploy/pkg/hookd/config"
 	"github.com/nais/deploy/pkg/hookd/database"
 	"github.com/nais/deploy/pkg/hookd/logproxy"
+	"github.com/nais/deploy/pkg/hookd/metrics"
 	"github.com/nais/deploy/pkg/hookd/middleware"
+	"github.com/nais/deploy/pkg/hookd/pubsub"
 	"github.com/nais/deploy/pkg/logging"
 	"github.com/nais/deploy/pkg/naisapi"
 	"github.com/nais/deploy/pkg/pb"
@@ -39,6 +42,7 @@ import (
 
 var maskedConfig = []string{
 	config.DatabaseEncryptionKey,
+	config.DatabaseEncryptionKeys,
 	config.DatabaseUrl,
 	config.DeploydKeys,
 	config.FrontendKeys,
@@ -47,6 +51,8 @@ var maskedConfig = []string{
 
 const (
 	databaseConnectBackoffInterval = 3 * time.Second
+	reencryptBatchSize             = 100
+	dispatchQueueExpiryInterval    = 30 * time.Second
 )
 
 func run() error {
@@ -96,15 +102,22 @@ func run() error {
 		return fmt.Errorf("provisioning pre-shared key must be a hex encoded string")
 	}
 
-	dbEncryptionKey, err := hex.DecodeString(cfg.DatabaseEncryptionKey)
+	keyring, err := crypto.KeyringConfig{
+		LegacyKey:   cfg.DatabaseEncryptionKey,
+		Keys:        cfg.DatabaseEncryptionKeys,
+		ActiveKeyID: cfg.DatabaseEncryptionKeyID,
+		KEKFile:     cfg.DatabaseKEKFile,
+		KEKPlugin:   cfg.DatabaseKEKPlugin,
+	}.Load(programContext)
 	if err != nil {
-		return err
+		return fmt.Errorf("load database encryption keys: %w", err)
 	}
+	log.Infof("Database encryption keys: %q; encrypting with %q", keyring.KeyIDs(), keyring.ActiveKeyID())
 
 	ctx, cancel := context.WithTimeout(programContext, cfg.DatabaseConnectTimeout)
 	for {
 		log.Infof("Connecting to database...")
-		db, err = database.New(ctx, cfg.DatabaseURL, dbEncryptionKey)
+		db, err = database.New(ctx, cfg.DatabaseURL, keyring)
 		if err == nil {
 			log.Infof("Database connection established.")
 			break
@@ -127,8 +140,23 @@ func run() error {
 		return fmt.Errorf("migrating database: %s", err)
 	}
 
+	go reencrypt(programContext, db, cfg.DatabaseReencryptInterval)
+
 	// Set up gRPC server
-	grpcServer, dispatchServer, err := startGrpcServer(*cfg, db, db)
+	var queue database.DispatchQueueStore
+	if cfg.DispatchQueue {
+		queue = db
+	}
+	var bus pubsub.PubSub
+	switch cfg.PubSub {
+	case config.PubSubNone:
+	case config.PubSubPostgres:
+		bus = db
+	default:
+		return fmt.Errorf("unknown pub/sub %q; must be one of %q, %q", cfg.PubSub, config.PubSubNone, config.PubSubPostgres)
+	}
+
+	grpcServer, dispatchServer, err := startGrpcServer(*cfg, db, db, db, db, queue, bus)
 	if err != nil {
 		return err
 	}
@@ -136,6 +164,22 @@ func run() error {
 
 	log.Infof("gRPC server started")
 
+	if bus != nil {
+		go dispatchServer.Run(programContext)
+	}
+
+	if queue != nil {
+		// Requests queued before a restart are still unfinished deployments.
+		queued, err := queue.QueuedDeploymentIDs(programContext)
+		if err != nil {
+			return fmt.Errorf("read dispatch queue: %w", err)
+		}
+		metrics.RestoreQueue(queued)
+		log.Infof("Dispatch queue enabled; %d deployment requests waiting for deployd", len(queued))
+
+		go expireQueue(programContext, dispatchServer)
+	}
+
 	projects, err := parseKeyVal(cfg.GoogleClusterProjects)
 	if err != nil {
 		return fmt.Errorf("unable to parse google cluster projects: %v", err)
@@ -171,8 +215,48 @@ func run() error {
 	return nil
 }
 
-func startGrpcServer(cfg config.Config, db database.DeploymentStore, apikeys database.ApiKeyStore) (*grpc.Server, dispatchserver.DispatchServer, error) {
-	dispatchServer := dispatchserver.New(db)
+// reencrypt rewrites ciphertexts that are not encrypted with the active key in the current format,
+// so that old keys can be removed after rotating the active key. Runs once at startup, and then periodically
+// if an interval is given.
+func reencrypt(ctx context.Context, db *database.Database, interval time.Duration) {
+	for {
+		n, err := db.Reencrypt(ctx, reencryptBatchSize)
+		if err != nil {
+			log.Errorf("Re-encrypt database: %s", err)
+		} else if n > 0 {
+			log.Infof("Re-encrypted %d rows with the active database encryption key", n)
+		}
+
+		if interval <= 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// expireQueue periodically fails queued deployment requests whose deadline has passed.
+func expireQueue(ctx context.Context, dispatchServer dispatchserver.DispatchServer) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(dispatchQueueExpiryInterval):
+		}
+
+		err := dispatchServer.ExpireQueuedRequests(ctx)
+		if err != nil {
+			log.Errorf("Expire dispatch queue: %s", err)
+		}
+	}
+}
+
+func startGrpcServer(cfg config.Config, db database.DeploymentStore, apikeys database.ApiKeyStore, repositoryTeams database.RepositoryTeamStore, nonces database.NonceStore, queue database.DispatchQueueStore, bus pubsub.PubSub) (*grpc.Server, dispatchserver.DispatchServer, error) {
+	dispatchServer := dispatchserver.New(db, queue, bus)
 	deployServer := deployserver.New(dispatchServer, db)
 	unaryInterceptors := make([]grpc.UnaryServerInterceptor, 0)
 	streamInterceptors := make([]grpc.StreamServerInterceptor, 0)
@@ -195,16 +279,41 @@ func startGrpcServer(cfg config.Config, db database.DeploymentStore, apikeys dat
 		interceptor.Add(pb.Dispatch_ServiceDesc.ServiceName, unauthenticatedInterceptor)
 
 		if cfg.GRPC.CliAuthentication {
-			ghValidator, err := auth_interceptor.NewGithubValidator()
+			issuers := cfg.OIDCIssuers
+			if len(issuers) == 0 {
+				issuers = []auth_interceptor.TrustedIssuer{auth_interceptor.GithubIssuer}
+			}
+			tokenValidator, err := auth_interceptor.NewOIDCValidator(context.Background(), issuers)
 			if err != nil {
-				return nil, nil, fmt.Errorf("unable to set up github validator: %w", err)
+				return nil, nil, fmt.Errorf("unable to set up token validator: %w", err)
+			}
+			for _, issuer := range issuers {
+				log.Infof("Accepting deployment tokens from %s", issuer.Issuer)
 			}
 
 			apiClient, err := naisapi.NewClient(cfg.NaisAPIAddress, cfg.NaisAPIInsecureConnection)
 			if err != nil {
 				return nil, nil, fmt.Errorf("unable to set up nais-api client: %w", err)
 			}
-			authInterceptor := auth_interceptor.NewServerInterceptor(apikeys, ghValidator, apiClient)
+			var fallback database.RepositoryTeamStore
+			if cfg.NaisAPIFallback {
+				fallback = repositoryTeams
+				log.Infof("Using team repositories table to authorize deployments while nais-api is unavailable")
+			}
+			teamsClient := naisapi.NewCachedClient(apiClient, cfg.NaisAPICacheTTL, cfg.NaisAPINegativeCacheTTL, fallback)
+			authInterceptor := auth_interceptor.NewServerInterceptor(apikeys, tokenValidator, teamsClient)
+			authInterceptor.Nonces = &auth_interceptor.DatabaseNonceCache{Store: nonces, TTL: auth_interceptor.NonceTTL}
+			authInterceptor.RejectLegacySignatures = !cfg.GRPC.LegacySignatures
+			authInterceptor.Policies, err = cfg.DeployPolicies.Compile()
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid deploy policies: %w", err)
+			}
+			if len(cfg.DeployPolicies) > 0 {
+				log.Infof("Enforcing %d deploy policies for deployments authenticated with JWT", len(cfg.DeployPolicies))
+			}
+			if cfg.GRPC.LegacySignatures {
+				log.Warnf("Accepting legacy API key signatures; disable with --%s=false when all clients are upgraded", config.GrpcLegacySignatures)
+			}
 
 			interceptor.Add(pb.Deploy_ServiceDesc.ServiceName, authInterceptor)
 			log.Infof("Authentication enabled for deployment requests")
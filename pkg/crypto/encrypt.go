@@ -17,7 +17,7 @@ func randomBytes(length int) ([]byte, error) {
 	return buf, err
 }
 
-// Generate an initialization vector for encryption.
+// Generate an initialization vector for version 1 encryption.
 // It consists of the current UNIX timestamp with nanoseconds, and four bytes of randomness.
 func iv() ([]byte, error) {
 	stor := make([]byte, 0)
@@ -41,14 +41,49 @@ func iv() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// Encrypts a plaintext with AES-256-GCM.
-// Returns 12 bytes of IV, and then N bytes of ciphertext.
+const (
+	// Version2 ciphertexts start with this byte, followed by a random 96-bit nonce and the sealed plaintext.
+	// Version 1 ciphertexts start with a nonce derived from the current time, which never starts with this byte.
+	Version2 byte = 0x02
+
+	nonceSize = 12
+)
+
+// AdditionalData binds a ciphertext to where it is stored, e.g. the team owning an API key,
+// so that it cannot be moved to another row without failing decryption.
+func AdditionalData(purpose, owner string) []byte {
+	return []byte(purpose + "\x00" + owner)
+}
+
+// Encrypts a plaintext with AES-256-GCM, without additional data.
 func Encrypt(plaintext, key []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
+	return EncryptWithAdditionalData(plaintext, key, nil)
+}
+
+// Encrypts a plaintext with AES-256-GCM.
+// Returns a version 2 ciphertext: one version byte, 12 bytes of random nonce, and then N bytes of ciphertext.
+// The additional data is authenticated, but not included in the ciphertext; it must be given again to decrypt.
+func EncryptWithAdditionalData(plaintext, key, additionalData []byte) ([]byte, error) {
+	aesgcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := randomBytes(nonceSize)
 	if err != nil {
 		return nil, err
 	}
-	aesgcm, err := cipher.NewGCM(block)
+
+	ciphertext := make([]byte, 0, 1+nonceSize+len(plaintext)+aesgcm.Overhead())
+	ciphertext = append(ciphertext, Version2)
+	ciphertext = append(ciphertext, nonce...)
+	return aesgcm.Seal(ciphertext, nonce, plaintext, additionalData), nil
+}
+
+// encryptV1 encrypts a plaintext in the version 1 format, with a time-based nonce and no additional data.
+// Only used to test backwards compatibility.
+func encryptV1(plaintext, key []byte) ([]byte, error) {
+	aesgcm, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
@@ -62,22 +97,44 @@ func Encrypt(plaintext, key []byte) ([]byte, error) {
 	return append(nonce, ciphertext...), nil
 }
 
-// Decrypts a ciphertext encrypted with AES-256-GCM.
-// The first 12 bytes of the ciphertext is assumed to be the IV.
+// Decrypts a ciphertext encrypted with AES-256-GCM, without additional data.
 func Decrypt(ciphertext, key []byte) ([]byte, error) {
-	if len(ciphertext) <= 12 {
+	return DecryptWithAdditionalData(ciphertext, key, nil)
+}
+
+// Decrypts a ciphertext encrypted with AES-256-GCM.
+// Version 2 ciphertexts must be decrypted with the same additional data they were encrypted with.
+// Version 1 ciphertexts have their first 12 bytes as the IV, and no additional data.
+func DecryptWithAdditionalData(ciphertext, key, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) <= nonceSize {
 		return nil, fmt.Errorf("string is too short")
 	}
-	block, err := aes.NewCipher(key)
+	aesgcm, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
-	aesgcm, err := cipher.NewGCM(block)
+
+	if !IsVersion2(ciphertext) {
+		return aesgcm.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], nil)
+	}
+
+	if len(ciphertext) <= 1+nonceSize {
+		return nil, fmt.Errorf("string is too short")
+	}
+	return aesgcm.Open(nil, ciphertext[1:1+nonceSize], ciphertext[1+nonceSize:], additionalData)
+}
+
+// IsVersion2 returns true if a ciphertext has a random nonce and is bound to additional data.
+func IsVersion2(ciphertext []byte) bool {
+	return len(ciphertext) > 0 && ciphertext[0] == Version2
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-
-	return aesgcm.Open(nil, ciphertext[:12], ciphertext[12:], nil)
+	return cipher.NewGCM(block)
 }
 
 func KeyFromHexString(hexstr string) ([]byte, error) {
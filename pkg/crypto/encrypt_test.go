@@ -52,6 +52,50 @@ func TestDecrypt(t *testing.T) {
 	assert.Equal(t, plaintext, decrypted)
 }
 
+// Test that ciphertexts carry the version byte and a nonce that differs from one message to the next.
+func TestEncryptVersion2(t *testing.T) {
+	first, err := crypto.Encrypt(plaintext, key)
+	assert.NoError(t, err)
+	second, err := crypto.Encrypt(plaintext, key)
+	assert.NoError(t, err)
+
+	assert.True(t, crypto.IsVersion2(first))
+	assert.Equal(t, crypto.Version2, first[0])
+	assert.NotEqual(t, first[1:13], second[1:13], "nonce re-used")
+}
+
+// Test that ciphertexts can only be decrypted with the additional data they were encrypted with.
+func TestDecryptWithAdditionalData(t *testing.T) {
+	additionalData := crypto.AdditionalData("apikey", "aura")
+	ciphertext, err := crypto.EncryptWithAdditionalData(plaintext, key, additionalData)
+	assert.NoError(t, err)
+
+	decrypted, err := crypto.DecryptWithAdditionalData(ciphertext, key, additionalData)
+	assert.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	_, err = crypto.DecryptWithAdditionalData(ciphertext, key, crypto.AdditionalData("apikey", "other"))
+	assert.Error(t, err)
+
+	_, err = crypto.Decrypt(ciphertext, key)
+	assert.Error(t, err)
+}
+
+// Test that version 1 ciphertexts can still be decrypted, regardless of additional data.
+func TestDecryptVersion1(t *testing.T) {
+	ciphertext, err := crypto.EncryptV1(plaintext, key)
+	assert.NoError(t, err)
+	assert.False(t, crypto.IsVersion2(ciphertext))
+
+	decrypted, err := crypto.Decrypt(ciphertext, key)
+	assert.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	decrypted, err = crypto.DecryptWithAdditionalData(ciphertext, key, crypto.AdditionalData("apikey", "aura"))
+	assert.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+}
+
 func BenchmarkEncrypt(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		crypto.Encrypt(plaintext, key)
@@ -0,0 +1,3 @@
+package crypto
+
+var EncryptV1 = encryptV1
@@ -0,0 +1,129 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// KeyEncryptionKey wraps data encryption keys, so that they can be stored in configuration
+// without exposing them. Only the key encryption key must be kept secret.
+type KeyEncryptionKey interface {
+	Wrap(ctx context.Context, key []byte) ([]byte, error)
+	Unwrap(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// FileKEK is a key encryption key read from a local file, e.g. a mounted secret.
+type FileKEK struct {
+	key []byte
+}
+
+var _ KeyEncryptionKey = &FileKEK{}
+
+// NewFileKEK reads a hex encoded 256-bit key from a file.
+func NewFileKEK(path string) (*FileKEK, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key encryption key: %w", err)
+	}
+	key, err := KeyFromHexString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("read key encryption key: %w", err)
+	}
+	return &FileKEK{key: key}, nil
+}
+
+func (f *FileKEK) Wrap(_ context.Context, key []byte) ([]byte, error) {
+	return Encrypt(key, f.key)
+}
+
+func (f *FileKEK) Unwrap(_ context.Context, wrapped []byte) ([]byte, error) {
+	return Decrypt(wrapped, f.key)
+}
+
+// PluginKEK delegates wrapping and unwrapping to an external program, such as a wrapper around a cloud KMS.
+// The program is run with `wrap` or `unwrap` as its last argument, is given the hex encoded key on standard input,
+// and must write the hex encoded result to standard output.
+type PluginKEK struct {
+	Command string
+	Args    []string
+}
+
+var _ KeyEncryptionKey = &PluginKEK{}
+
+func (p *PluginKEK) Wrap(ctx context.Context, key []byte) ([]byte, error) {
+	return p.run(ctx, "wrap", key)
+}
+
+func (p *PluginKEK) Unwrap(ctx context.Context, wrapped []byte) ([]byte, error) {
+	return p.run(ctx, "unwrap", wrapped)
+}
+
+func (p *PluginKEK) run(ctx context.Context, operation string, input []byte) ([]byte, error) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	args := append(append([]string{}, p.Args...), operation)
+	cmd := exec.CommandContext(ctx, p.Command, args...)
+	cmd.Stdin = strings.NewReader(hex.EncodeToString(input) + "\n")
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("key encryption plugin %s: %w: %s", operation, err, strings.TrimSpace(stderr.String()))
+	}
+
+	output, err := hex.DecodeString(strings.TrimSpace(stdout.String()))
+	if err != nil {
+		return nil, fmt.Errorf("key encryption plugin %s: output must be a hex encoded string", operation)
+	}
+	return output, nil
+}
+
+// ParseKeys parses data encryption keys in the form `id=hexkey`.
+// If a key encryption key is given, the keys are unwrapped with it.
+func ParseKeys(ctx context.Context, specs []string, kek KeyEncryptionKey) (map[string][]byte, error) {
+	keys := make(map[string][]byte)
+	for _, spec := range specs {
+		id, encoded, ok := strings.Cut(spec, "=")
+		if !ok || len(id) == 0 {
+			return nil, fmt.Errorf("keys must be given as id=key")
+		}
+		if _, exists := keys[id]; exists {
+			return nil, fmt.Errorf("key %q is configured more than once", id)
+		}
+
+		key, err := hex.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q must be a hex encoded string", id)
+		}
+		if kek != nil {
+			key, err = kek.Unwrap(ctx, key)
+			if err != nil {
+				return nil, fmt.Errorf("unwrap key %q: %w", id, err)
+			}
+		}
+		keys[id] = key
+	}
+	return keys, nil
+}
+
+// NewKEK returns a key encryption key from a file or a plugin command, or nil if neither is given.
+func NewKEK(file, plugin string) (KeyEncryptionKey, error) {
+	switch {
+	case len(file) > 0 && len(plugin) > 0:
+		return nil, fmt.Errorf("only one of key encryption key file and plugin can be used")
+	case len(file) > 0:
+		return NewFileKEK(file)
+	case len(plugin) > 0:
+		fields := strings.Fields(plugin)
+		return &PluginKEK{Command: fields[0], Args: fields[1:]}, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,151 @@
+package crypto
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LegacyKeyID identifies the key used for ciphertexts stored without a key ID.
+const LegacyKeyID = ""
+
+// keyIDSeparator separates the key ID from the hex encoded ciphertext.
+// Legacy ciphertexts are plain hex, and never contain the separator.
+const keyIDSeparator = ":"
+
+var validKeyID = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// Keyring holds several data encryption keys at once, identified by key ID.
+// New ciphertexts are encrypted with the active key, and carry its ID so that they can be decrypted
+// with the right key after the active key has been rotated.
+type Keyring struct {
+	active string
+	keys   map[string][]byte
+}
+
+// NewKeyring returns a keyring that encrypts with the active key, and decrypts with any of the keys.
+// The legacy key, with an empty key ID, decrypts ciphertexts written before key IDs were introduced.
+// If the legacy key is active, ciphertexts are written without a key ID.
+func NewKeyring(active string, keys map[string][]byte) (*Keyring, error) {
+	for id, key := range keys {
+		if id != LegacyKeyID && !validKeyID.MatchString(id) {
+			return nil, fmt.Errorf("key ID %q must only contain letters, digits and dashes", id)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %q must be 256 bits; got %d", id, len(key)*8)
+		}
+	}
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("active key %q is not configured", active)
+	}
+	return &Keyring{
+		active: active,
+		keys:   keys,
+	}, nil
+}
+
+// ActiveKeyID returns the ID of the key used for encryption.
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// KeyIDs returns the IDs of all keys in the keyring, sorted.
+func (k *Keyring) KeyIDs() []string {
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Encrypt a plaintext with the active key, bound to the additional data, see AdditionalData.
+// Returns the key ID and the hex encoded ciphertext, separated by a colon.
+func (k *Keyring) Encrypt(plaintext, additionalData []byte) (string, error) {
+	ciphertext, err := EncryptWithAdditionalData(plaintext, k.keys[k.active], additionalData)
+	if err != nil {
+		return "", err
+	}
+	encoded := hex.EncodeToString(ciphertext)
+	if k.active == LegacyKeyID {
+		return encoded, nil
+	}
+	return k.active + keyIDSeparator + encoded, nil
+}
+
+// Decrypt a ciphertext returned by Encrypt, using the key it was encrypted with.
+// The additional data must be the same as when encrypting; it is ignored for version 1 ciphertexts.
+func (k *Keyring) Decrypt(encrypted string, additionalData []byte) ([]byte, error) {
+	id, encoded := ParseKeyID(encrypted)
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("ciphertext is encrypted with unknown key %q", id)
+	}
+	decoded, err := hex.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode hex: %s", err)
+	}
+	return DecryptWithAdditionalData(decoded, key, additionalData)
+}
+
+// NeedsRotation returns true if a ciphertext is not encrypted with the active key, or is in the version 1 format.
+func (k *Keyring) NeedsRotation(encrypted string) bool {
+	return !strings.HasPrefix(encrypted, k.CurrentPrefix())
+}
+
+// CurrentPrefix is the prefix of every ciphertext that is encrypted with the active key in the current format.
+func (k *Keyring) CurrentPrefix() string {
+	prefix := hex.EncodeToString([]byte{Version2})
+	if k.active == LegacyKeyID {
+		return prefix
+	}
+	return k.active + keyIDSeparator + prefix
+}
+
+// ParseKeyID splits a ciphertext returned by Keyring.Encrypt into its key ID and hex encoded ciphertext.
+func ParseKeyID(encrypted string) (string, string) {
+	id, encoded, ok := strings.Cut(encrypted, keyIDSeparator)
+	if !ok {
+		return LegacyKeyID, encrypted
+	}
+	return id, encoded
+}
+
+// KeyringConfig describes where to find the keys of a keyring.
+type KeyringConfig struct {
+	// LegacyKey is the hex encoded key used before key IDs were introduced. Optional.
+	LegacyKey string
+	// Keys in the form `id=hexkey`, wrapped with the key encryption key if one is configured.
+	Keys []string
+	// ActiveKeyID defaults to the legacy key.
+	ActiveKeyID string
+	// KEKFile is a file with a hex encoded key encryption key.
+	KEKFile string
+	// KEKPlugin is a command that wraps and unwraps keys, see PluginKEK.
+	KEKPlugin string
+}
+
+// Load unwraps all configured keys and returns a keyring.
+func (cfg KeyringConfig) Load(ctx context.Context) (*Keyring, error) {
+	kek, err := NewKEK(cfg.KEKFile, cfg.KEKPlugin)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := ParseKeys(ctx, cfg.Keys, kek)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.LegacyKey) > 0 {
+		keys[LegacyKeyID], err = KeyFromHexString(cfg.LegacyKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return NewKeyring(cfg.ActiveKeyID, keys)
+}
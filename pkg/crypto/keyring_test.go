@@ -0,0 +1,189 @@
+package crypto_test
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nais/deploy/pkg/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKey(b byte) []byte {
+	return []byte(strings.Repeat(string([]byte{b}), 32))
+}
+
+func TestKeyring(t *testing.T) {
+	legacy, k1, k2 := newKey('a'), newKey('b'), newKey('c')
+
+	old, err := crypto.NewKeyring(crypto.LegacyKeyID, map[string][]byte{
+		crypto.LegacyKeyID: legacy,
+	})
+	assert.NoError(t, err)
+
+	current, err := crypto.NewKeyring("k2", map[string][]byte{
+		crypto.LegacyKeyID: legacy,
+		"k1":               k1,
+		"k2":               k2,
+	})
+	assert.NoError(t, err)
+
+	t.Run("legacy ciphertexts have no key ID", func(t *testing.T) {
+		encrypted, err := old.Encrypt(plaintext, nil)
+		assert.NoError(t, err)
+		assert.NotContains(t, encrypted, ":")
+
+		// Compatible with ciphertexts written before key IDs were introduced
+		decoded, err := hex.DecodeString(encrypted)
+		assert.NoError(t, err)
+		decrypted, err := crypto.Decrypt(decoded, legacy)
+		assert.NoError(t, err)
+		assert.Equal(t, plaintext, decrypted)
+
+		decrypted, err = current.Decrypt(encrypted, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, plaintext, decrypted)
+		assert.True(t, current.NeedsRotation(encrypted))
+	})
+
+	t.Run("ciphertexts carry the active key ID", func(t *testing.T) {
+		encrypted, err := current.Encrypt(plaintext, nil)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(encrypted, "k2:"))
+		assert.False(t, current.NeedsRotation(encrypted))
+
+		decrypted, err := current.Decrypt(encrypted, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, plaintext, decrypted)
+
+		_, err = old.Decrypt(encrypted, nil)
+		assert.EqualError(t, err, `ciphertext is encrypted with unknown key "k2"`)
+	})
+
+	t.Run("decrypt-only keys", func(t *testing.T) {
+		ciphertext, err := crypto.Encrypt(plaintext, k1)
+		assert.NoError(t, err)
+		encrypted := "k1:" + hex.EncodeToString(ciphertext)
+
+		decrypted, err := current.Decrypt(encrypted, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, plaintext, decrypted)
+		assert.True(t, current.NeedsRotation(encrypted))
+	})
+
+	t.Run("version 1 ciphertexts need rotation", func(t *testing.T) {
+		ciphertext, err := crypto.EncryptV1(plaintext, k2)
+		assert.NoError(t, err)
+		encrypted := "k2:" + hex.EncodeToString(ciphertext)
+
+		decrypted, err := current.Decrypt(encrypted, crypto.AdditionalData("apikey", "aura"))
+		assert.NoError(t, err)
+		assert.Equal(t, plaintext, decrypted)
+		assert.True(t, current.NeedsRotation(encrypted))
+	})
+
+	t.Run("ciphertexts are bound to additional data", func(t *testing.T) {
+		encrypted, err := current.Encrypt(plaintext, crypto.AdditionalData("apikey", "aura"))
+		assert.NoError(t, err)
+
+		decrypted, err := current.Decrypt(encrypted, crypto.AdditionalData("apikey", "aura"))
+		assert.NoError(t, err)
+		assert.Equal(t, plaintext, decrypted)
+
+		_, err = current.Decrypt(encrypted, crypto.AdditionalData("apikey", "other"))
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid keyrings", func(t *testing.T) {
+		_, err := crypto.NewKeyring("missing", map[string][]byte{"k1": k1})
+		assert.EqualError(t, err, `active key "missing" is not configured`)
+
+		_, err = crypto.NewKeyring("k1", map[string][]byte{"k1": k1[:16]})
+		assert.EqualError(t, err, `key "k1" must be 256 bits; got 128`)
+
+		_, err = crypto.NewKeyring("k_1", map[string][]byte{"k_1": k1})
+		assert.Error(t, err)
+	})
+}
+
+func TestKeyringConfig(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	kek := newKey('k')
+	kekFile := filepath.Join(dir, "kek")
+	err := os.WriteFile(kekFile, []byte(hex.EncodeToString(kek)+"\n"), 0o600)
+	assert.NoError(t, err)
+
+	fileKEK, err := crypto.NewFileKEK(kekFile)
+	assert.NoError(t, err)
+
+	key := newKey('b')
+	wrapped, err := fileKEK.Wrap(ctx, key)
+	assert.NoError(t, err)
+
+	t.Run("keys wrapped with file", func(t *testing.T) {
+		keyring, err := crypto.KeyringConfig{
+			LegacyKey:   hex.EncodeToString(newKey('a')),
+			Keys:        []string{"k1=" + hex.EncodeToString(wrapped)},
+			ActiveKeyID: "k1",
+			KEKFile:     kekFile,
+		}.Load(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"", "k1"}, keyring.KeyIDs())
+
+		encrypted, err := keyring.Encrypt(plaintext, nil)
+		assert.NoError(t, err)
+
+		direct, err := crypto.NewKeyring("k1", map[string][]byte{"k1": key})
+		assert.NoError(t, err)
+		decrypted, err := direct.Decrypt(encrypted, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, plaintext, decrypted)
+	})
+
+	t.Run("keys wrapped with plugin", func(t *testing.T) {
+		// The plugin protocol is hex in, hex out; this plugin doesn't wrap at all.
+		keyring, err := crypto.KeyringConfig{
+			Keys:        []string{"k1=" + hex.EncodeToString(key)},
+			ActiveKeyID: "k1",
+			KEKPlugin:   "sh -c cat --",
+		}.Load(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"k1"}, keyring.KeyIDs())
+	})
+
+	t.Run("failing plugin", func(t *testing.T) {
+		_, err := crypto.KeyringConfig{
+			Keys:        []string{"k1=" + hex.EncodeToString(key)},
+			ActiveKeyID: "k1",
+			KEKPlugin:   "false",
+		}.Load(ctx)
+		assert.ErrorContains(t, err, "key encryption plugin unwrap")
+	})
+
+	t.Run("wrong key encryption key", func(t *testing.T) {
+		otherFile := filepath.Join(dir, "other")
+		err := os.WriteFile(otherFile, []byte(hex.EncodeToString(newKey('x'))), 0o600)
+		assert.NoError(t, err)
+
+		_, err = crypto.KeyringConfig{
+			Keys:        []string{"k1=" + hex.EncodeToString(wrapped)},
+			ActiveKeyID: "k1",
+			KEKFile:     otherFile,
+		}.Load(ctx)
+		assert.ErrorContains(t, err, `unwrap key "k1"`)
+	})
+
+	t.Run("malformed keys", func(t *testing.T) {
+		_, err := crypto.KeyringConfig{Keys: []string{"nokeyid"}}.Load(ctx)
+		assert.EqualError(t, err, "keys must be given as id=key")
+
+		_, err = crypto.KeyringConfig{Keys: []string{"k1=zz"}, ActiveKeyID: "k1"}.Load(ctx)
+		assert.EqualError(t, err, `key "k1" must be a hex encoded string`)
+	})
+}
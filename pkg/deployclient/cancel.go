@@ -0,0 +1,47 @@
+package deployclient
+
+import (
+	"context"
+	"time"
+
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/nais/deploy/pkg/telemetry"
+	log "github.com/sirupsen/logrus"
+	ocodes "go.opentelemetry.io/otel/codes"
+)
+
+// Cancel asks NAIS deploy to abort a deployment that is still in progress.
+// The deployment ends up in the `cancelled` state once the cluster has stopped it.
+func (d *Deployer) Cancel(ctx context.Context, cfg *Config) error {
+	var cancelStatus *pb.DeploymentStatus
+	var err error
+
+	ctx, span := telemetry.Tracer().Start(ctx, "Send cancel request")
+	defer span.End()
+
+	cancelRequest := &pb.DeploymentRequest{
+		ID:          cfg.DeploymentID,
+		Cluster:     cfg.Cluster,
+		Team:        cfg.Team,
+		Time:        pb.TimeAsTimestamp(time.Now()),
+		TraceParent: telemetry.TraceParentHeader(ctx),
+	}
+
+	log.Infof("Requesting cancellation of deployment %s...", cfg.DeploymentID)
+
+	err = d.retryUnavailable(cfg.RetryInterval, cfg.Retry, func() error {
+		cancelStatus, err = d.Client.Cancel(ctx, cancelRequest)
+		return err
+	})
+	if err != nil {
+		span.SetStatus(ocodes.Error, err.Error())
+		if ctx.Err() != nil {
+			return Errorf(ExitTimeout, "cancel timed out: %s", ctx.Err())
+		}
+		return Errorf(ExitNoDeployment, formatGrpcError(err))
+	}
+
+	d.logDeployStatus(cancelStatus)
+
+	return nil
+}
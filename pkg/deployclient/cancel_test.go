@@ -0,0 +1,72 @@
+package deployclient_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nais/deploy/pkg/deployclient"
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/nais/deploy/pkg/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCancel(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		status   *pb.DeploymentStatus
+		err      error
+		exitCode deployclient.ExitCode
+	}{
+		{
+			name: "cancellation requested",
+			status: &pb.DeploymentStatus{
+				Time:    pb.TimeAsTimestamp(time.Now()),
+				State:   pb.DeploymentState_in_progress,
+				Message: "Cancellation has been requested",
+			},
+			exitCode: deployclient.ExitSuccess,
+		},
+		{
+			name:     "deployment already finished",
+			err:      status.Errorf(codes.FailedPrecondition, "deployment has already finished"),
+			exitCode: deployclient.ExitNoDeployment,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := teamConfig(deployclient.CommandCancel)
+			ctx := context.Background()
+			_, _ = telemetry.New(ctx, "test", "")
+
+			isCancelRequest := mock.MatchedBy(func(req *pb.DeploymentRequest) bool {
+				return req.GetID() == cfg.DeploymentID && req.GetTeam() == cfg.Team
+			})
+
+			client := &pb.MockDeployClient{}
+			client.On("Cancel", mock.Anything, isCancelRequest).Return(test.status, test.err).Once()
+
+			d := deployclient.Deployer{Client: client}
+			err := d.Cancel(ctx, cfg)
+
+			assert.Equal(t, test.exitCode, deployclient.ErrorExitCode(err))
+			client.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCancelValidation(t *testing.T) {
+	testTeamValidation(t, deployclient.CommandCancel, []teamValidationCase{
+		{"valid without cluster", func(cfg deployclient.Config) deployclient.Config { cfg.Cluster = ""; return cfg }, nil},
+		{"deployment ID required", func(cfg deployclient.Config) deployclient.Config { cfg.DeploymentID = ""; return cfg }, deployclient.ErrDeploymentIDRequired},
+		{"team required", func(cfg deployclient.Config) deployclient.Config { cfg.Team = ""; return cfg }, deployclient.ErrTeamRequired},
+	})
+}
+
+func TestCancelledDeploymentExitCode(t *testing.T) {
+	err := deployclient.ErrorStatus(&pb.DeploymentStatus{State: pb.DeploymentState_cancelled})
+	assert.Equal(t, deployclient.ExitDeploymentCancelled, deployclient.ErrorExitCode(err))
+	assert.True(t, pb.DeploymentState_cancelled.Finished())
+}
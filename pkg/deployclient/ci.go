@@ -0,0 +1,269 @@
+package deployclient
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Annotations describing the CI pipeline that made a deployment, regardless of CI provider.
+const (
+	CIName       = "deploy.nais.io/ci-provider"
+	CIActor      = "deploy.nais.io/actor"
+	CICommit     = "deploy.nais.io/commit"
+	CIRunURL     = "deploy.nais.io/run-url"
+	CIPipelineID = "deploy.nais.io/pipeline-id"
+)
+
+// LookupEnv returns the value of an environment variable, and whether it is set, like os.LookupEnv.
+type LookupEnv func(key string) (string, bool)
+
+// CIEnvironment describes the CI pipeline run that the deploy client is running in.
+// Fields are empty if the CI provider doesn't expose them.
+type CIEnvironment struct {
+	Provider   string
+	Actor      string
+	Commit     string
+	RunURL     string
+	PipelineID string
+	Owner      string
+	Repository string
+}
+
+// CIProvider detects a CI system from its environment variables.
+type CIProvider struct {
+	Name string
+	// Detect returns true if running in this CI system.
+	Detect func(env LookupEnv) bool
+	// Environment reads the pipeline run from environment variables.
+	Environment func(env LookupEnv) CIEnvironment
+}
+
+// CIProviders are tried in order, and the first one detected is used.
+// Providers that can only be detected from generic variable names come last.
+var CIProviders = []CIProvider{
+	{
+		// https://docs.gitlab.com/ee/ci/variables/predefined_variables.html
+		Name:   "gitlab",
+		Detect: envEquals("GITLAB_CI", "true"),
+		Environment: func(env LookupEnv) CIEnvironment {
+			return CIEnvironment{
+				Actor:      getenv(env, "GITLAB_USER_LOGIN"),
+				Commit:     getenv(env, "CI_COMMIT_SHA"),
+				RunURL:     getenv(env, "CI_PIPELINE_URL"),
+				PipelineID: getenv(env, "CI_PIPELINE_ID"),
+				Owner:      getenv(env, "CI_PROJECT_NAMESPACE"),
+				Repository: getenv(env, "CI_PROJECT_NAME"),
+			}
+		},
+	},
+	{
+		// https://learn.microsoft.com/en-us/azure/devops/pipelines/build/variables
+		Name:   "azure-pipelines",
+		Detect: envEquals("TF_BUILD", "true"),
+		Environment: func(env LookupEnv) CIEnvironment {
+			ci := CIEnvironment{
+				Actor:      getenv(env, "BUILD_REQUESTEDFOR"),
+				Commit:     getenv(env, "BUILD_SOURCEVERSION"),
+				PipelineID: getenv(env, "BUILD_BUILDID"),
+			}
+			collection, project := getenv(env, "SYSTEM_COLLECTIONURI"), getenv(env, "SYSTEM_TEAMPROJECT")
+			if len(collection) > 0 && len(project) > 0 && len(ci.PipelineID) > 0 {
+				ci.RunURL = fmt.Sprintf("%s/%s/_build/results?buildId=%s", strings.TrimSuffix(collection, "/"), url.PathEscape(project), ci.PipelineID)
+			}
+			// Repositories hosted on GitHub are named owner/repository.
+			ci.Owner, ci.Repository, _ = strings.Cut(getenv(env, "BUILD_REPOSITORY_NAME"), "/")
+			if len(ci.Repository) == 0 {
+				ci.Owner, ci.Repository = project, ci.Owner
+			}
+			return ci
+		},
+	},
+	{
+		// https://circleci.com/docs/variables/#built-in-environment-variables
+		Name:   "circleci",
+		Detect: envEquals("CIRCLECI", "true"),
+		Environment: func(env LookupEnv) CIEnvironment {
+			return CIEnvironment{
+				Actor:      getenv(env, "CIRCLE_USERNAME"),
+				Commit:     getenv(env, "CIRCLE_SHA1"),
+				RunURL:     getenv(env, "CIRCLE_BUILD_URL"),
+				PipelineID: getenv(env, "CIRCLE_WORKFLOW_ID"),
+				Owner:      getenv(env, "CIRCLE_PROJECT_USERNAME"),
+				Repository: getenv(env, "CIRCLE_PROJECT_REPONAME"),
+			}
+		},
+	},
+	{
+		// https://buildkite.com/docs/pipelines/environment-variables
+		Name:   "buildkite",
+		Detect: envEquals("BUILDKITE", "true"),
+		Environment: func(env LookupEnv) CIEnvironment {
+			ci := CIEnvironment{
+				Actor:      getenv(env, "BUILDKITE_BUILD_CREATOR"),
+				Commit:     getenv(env, "BUILDKITE_COMMIT"),
+				RunURL:     getenv(env, "BUILDKITE_BUILD_URL"),
+				PipelineID: getenv(env, "BUILDKITE_BUILD_ID"),
+			}
+			ci.Owner, ci.Repository = repositoryFromGitURL(getenv(env, "BUILDKITE_REPO"))
+			return ci
+		},
+	},
+	{
+		// Tekton does not expose anything about the pipeline run to steps by itself.
+		// Tasks must map the variables below from parameters and context,
+		// e.g. `TEKTON_PIPELINE_RUN: $(context.pipelineRun.name)`.
+		Name:   "tekton",
+		Detect: envSet("TEKTON_PIPELINE_RUN"),
+		Environment: func(env LookupEnv) CIEnvironment {
+			ci := CIEnvironment{
+				Actor:      getenv(env, "TEKTON_ACTOR"),
+				Commit:     getenv(env, "TEKTON_GIT_COMMIT"),
+				RunURL:     getenv(env, "TEKTON_PIPELINE_RUN_URL"),
+				PipelineID: getenv(env, "TEKTON_PIPELINE_RUN"),
+			}
+			ci.Owner, ci.Repository = repositoryFromGitURL(getenv(env, "TEKTON_GIT_URL"))
+			return ci
+		},
+	},
+	{
+		// https://docs.github.com/en/actions/reference/environment-variables#default-environment-variables
+		Name: "github",
+		Detect: func(env LookupEnv) bool {
+			return envEquals("GITHUB_ACTIONS", "true")(env) || envSet("GITHUB_SHA")(env)
+		},
+		Environment: func(env LookupEnv) CIEnvironment {
+			ci := CIEnvironment{
+				Actor:      getenv(env, "GITHUB_ACTOR"),
+				Commit:     getenv(env, "GITHUB_SHA"),
+				RunURL:     githubWorkflowRunURL(env),
+				PipelineID: getenv(env, "GITHUB_RUN_ID"),
+			}
+			ci.Owner, ci.Repository, _ = strings.Cut(getenv(env, "GITHUB_REPOSITORY"), "/")
+			return ci
+		},
+	},
+	{
+		// https://www.jenkins.io/doc/book/pipeline/jenkinsfile/#using-environment-variables
+		Name: "jenkins",
+		Detect: func(env LookupEnv) bool {
+			return envSet("JENKINS_URL")(env) || envSet("BUILD_URL")(env)
+		},
+		Environment: func(env LookupEnv) CIEnvironment {
+			ci := CIEnvironment{
+				// Only available with the build user vars plugin.
+				Actor:      getenv(env, "BUILD_USER_ID"),
+				Commit:     getenv(env, "GIT_COMMIT"),
+				RunURL:     getenv(env, "BUILD_URL"),
+				PipelineID: getenv(env, "BUILD_TAG"),
+			}
+			ci.Owner, ci.Repository = repositoryFromGitURL(getenv(env, "GIT_URL"))
+			return ci
+		},
+	},
+}
+
+// DetectCI returns the CI pipeline run the deploy client is running in, or nil if no CI provider is detected.
+func DetectCI(env LookupEnv) *CIEnvironment {
+	for _, provider := range CIProviders {
+		if provider.Detect(env) {
+			ci := provider.Environment(env)
+			ci.Provider = provider.Name
+			return &ci
+		}
+	}
+	return nil
+}
+
+// Annotations describing the pipeline run.
+func (ci *CIEnvironment) Annotations() map[string]string {
+	a := make(map[string]string)
+	add := func(key, value string) {
+		if len(value) > 0 {
+			a[key] = value
+		}
+	}
+	add(CIName, ci.Provider)
+	add(CIActor, ci.Actor)
+	add(CICommit, ci.Commit)
+	add(CIRunURL, ci.RunURL)
+	add(CIPipelineID, ci.PipelineID)
+	return a
+}
+
+// ChangeCause returns a value for the `kubernetes.io/change-cause` annotation,
+// or an empty string if the commit or run URL is unknown.
+func (ci *CIEnvironment) ChangeCause() string {
+	if len(ci.Commit) == 0 || len(ci.RunURL) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("nais deploy: commit %s: %s", ci.Commit, ci.RunURL)
+}
+
+// ApplyCIDefaults uses the pipeline run for the ref, owner and repository of a deployment,
+// unless they were given explicitly. The isSet function returns true if a flag was given explicitly.
+func ApplyCIDefaults(cfg *Config, ci *CIEnvironment, isSet func(flag string) bool) {
+	if ci == nil {
+		return
+	}
+	if !isSet("ref") && len(ci.Commit) > 0 {
+		cfg.Ref = ci.Commit
+	}
+	// Owner and repository belong together, so only use the detected ones if neither is given.
+	if !isSet("owner") && !isSet("repository") && len(ci.Owner) > 0 && len(ci.Repository) > 0 {
+		cfg.Owner = ci.Owner
+		cfg.Repository = ci.Repository
+	}
+}
+
+// repositoryFromGitURL returns the owner and repository name from a Git remote URL,
+// such as `git@github.com:nais/deploy.git` or `https://github.com/nais/deploy`.
+func repositoryFromGitURL(remote string) (string, string) {
+	remote = strings.TrimSuffix(strings.TrimSuffix(remote, "/"), ".git")
+	if u, err := url.Parse(remote); err == nil && len(u.Host) > 0 {
+		remote = u.Path
+	} else if _, path, ok := strings.Cut(remote, ":"); ok {
+		remote = path
+	}
+
+	parts := strings.Split(strings.Trim(remote, "/"), "/")
+	if len(parts) < 2 {
+		return "", ""
+	}
+	return parts[len(parts)-2], parts[len(parts)-1]
+}
+
+func githubWorkflowRunURL(env LookupEnv) string {
+	server, ok := env("GITHUB_SERVER_URL")
+	if !ok {
+		return ""
+	}
+	repo, ok := env("GITHUB_REPOSITORY")
+	if !ok {
+		return ""
+	}
+	runid, ok := env("GITHUB_RUN_ID")
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/actions/runs/%s", server, repo, runid)
+}
+
+func getenv(env LookupEnv, key string) string {
+	value, _ := env(key)
+	return value
+}
+
+func envSet(key string) func(LookupEnv) bool {
+	return func(env LookupEnv) bool {
+		value, ok := env(key)
+		return ok && len(value) > 0
+	}
+}
+
+// envEquals matches environment variables case-insensitively, as some CI providers use `True` rather than `true`.
+func envEquals(key, expected string) func(LookupEnv) bool {
+	return func(env LookupEnv) bool {
+		return strings.EqualFold(getenv(env, key), expected)
+	}
+}
@@ -0,0 +1,234 @@
+package deployclient_test
+
+import (
+	"testing"
+
+	"github.com/nais/deploy/pkg/deployclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func mapEnv(vars map[string]string) deployclient.LookupEnv {
+	return func(key string) (string, bool) {
+		value, ok := vars[key]
+		return value, ok
+	}
+}
+
+func TestDetectCI(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		env      map[string]string
+		expected *deployclient.CIEnvironment
+	}{
+		{
+			name:     "no ci",
+			env:      map[string]string{"HOME": "/root"},
+			expected: nil,
+		},
+		{
+			name: "gitlab",
+			env: map[string]string{
+				"GITLAB_CI":            "true",
+				"GITLAB_USER_LOGIN":    "alice",
+				"CI_COMMIT_SHA":        "abc123",
+				"CI_PIPELINE_URL":      "https://gitlab.com/navikt/myapp/-/pipelines/42",
+				"CI_PIPELINE_ID":       "42",
+				"CI_PROJECT_NAMESPACE": "navikt",
+				"CI_PROJECT_NAME":      "myapp",
+			},
+			expected: &deployclient.CIEnvironment{
+				Provider:   "gitlab",
+				Actor:      "alice",
+				Commit:     "abc123",
+				RunURL:     "https://gitlab.com/navikt/myapp/-/pipelines/42",
+				PipelineID: "42",
+				Owner:      "navikt",
+				Repository: "myapp",
+			},
+		},
+		{
+			name: "azure pipelines with azure repos",
+			env: map[string]string{
+				"TF_BUILD":              "True",
+				"BUILD_REQUESTEDFOR":    "Alice",
+				"BUILD_SOURCEVERSION":   "abc123",
+				"BUILD_BUILDID":         "42",
+				"SYSTEM_COLLECTIONURI":  "https://dev.azure.com/nav/",
+				"SYSTEM_TEAMPROJECT":    "My Project",
+				"BUILD_REPOSITORY_NAME": "myapp",
+			},
+			expected: &deployclient.CIEnvironment{
+				Provider:   "azure-pipelines",
+				Actor:      "Alice",
+				Commit:     "abc123",
+				RunURL:     "https://dev.azure.com/nav/My%20Project/_build/results?buildId=42",
+				PipelineID: "42",
+				Owner:      "My Project",
+				Repository: "myapp",
+			},
+		},
+		{
+			name: "azure pipelines with github repository",
+			env: map[string]string{
+				"TF_BUILD":              "True",
+				"BUILD_REPOSITORY_NAME": "navikt/myapp",
+			},
+			expected: &deployclient.CIEnvironment{
+				Provider:   "azure-pipelines",
+				Owner:      "navikt",
+				Repository: "myapp",
+			},
+		},
+		{
+			name: "circleci",
+			env: map[string]string{
+				"CIRCLECI":                "true",
+				"CIRCLE_USERNAME":         "alice",
+				"CIRCLE_SHA1":             "abc123",
+				"CIRCLE_BUILD_URL":        "https://circleci.com/gh/navikt/myapp/42",
+				"CIRCLE_WORKFLOW_ID":      "d7e2b2a0",
+				"CIRCLE_PROJECT_USERNAME": "navikt",
+				"CIRCLE_PROJECT_REPONAME": "myapp",
+			},
+			expected: &deployclient.CIEnvironment{
+				Provider:   "circleci",
+				Actor:      "alice",
+				Commit:     "abc123",
+				RunURL:     "https://circleci.com/gh/navikt/myapp/42",
+				PipelineID: "d7e2b2a0",
+				Owner:      "navikt",
+				Repository: "myapp",
+			},
+		},
+		{
+			name: "buildkite",
+			env: map[string]string{
+				"BUILDKITE":               "true",
+				"BUILDKITE_BUILD_CREATOR": "Alice",
+				"BUILDKITE_COMMIT":        "abc123",
+				"BUILDKITE_BUILD_URL":     "https://buildkite.com/nav/myapp/builds/42",
+				"BUILDKITE_BUILD_ID":      "f62a1b4d",
+				"BUILDKITE_REPO":          "git@github.com:navikt/myapp.git",
+			},
+			expected: &deployclient.CIEnvironment{
+				Provider:   "buildkite",
+				Actor:      "Alice",
+				Commit:     "abc123",
+				RunURL:     "https://buildkite.com/nav/myapp/builds/42",
+				PipelineID: "f62a1b4d",
+				Owner:      "navikt",
+				Repository: "myapp",
+			},
+		},
+		{
+			name: "tekton",
+			env: map[string]string{
+				"TEKTON_PIPELINE_RUN":     "myapp-run-x7k2p",
+				"TEKTON_PIPELINE_RUN_URL": "https://tekton.nav.no/#/pipelineruns/myapp-run-x7k2p",
+				"TEKTON_GIT_COMMIT":       "abc123",
+				"TEKTON_GIT_URL":          "https://github.com/navikt/myapp",
+			},
+			expected: &deployclient.CIEnvironment{
+				Provider:   "tekton",
+				Commit:     "abc123",
+				RunURL:     "https://tekton.nav.no/#/pipelineruns/myapp-run-x7k2p",
+				PipelineID: "myapp-run-x7k2p",
+				Owner:      "navikt",
+				Repository: "myapp",
+			},
+		},
+		{
+			name: "github",
+			env: map[string]string{
+				"GITHUB_ACTIONS":    "true",
+				"GITHUB_ACTOR":      "alice",
+				"GITHUB_SHA":        "abc123",
+				"GITHUB_SERVER_URL": "https://github.com",
+				"GITHUB_REPOSITORY": "navikt/myapp",
+				"GITHUB_RUN_ID":     "42",
+			},
+			expected: &deployclient.CIEnvironment{
+				Provider:   "github",
+				Actor:      "alice",
+				Commit:     "abc123",
+				RunURL:     "https://github.com/navikt/myapp/actions/runs/42",
+				PipelineID: "42",
+				Owner:      "navikt",
+				Repository: "myapp",
+			},
+		},
+		{
+			name: "jenkins",
+			env: map[string]string{
+				"JENKINS_URL": "https://jenkins.nav.no/",
+				"BUILD_URL":   "https://jenkins.nav.no/job/myapp/42/",
+				"BUILD_TAG":   "jenkins-myapp-42",
+				"GIT_COMMIT":  "abc123",
+				"GIT_URL":     "https://github.com/navikt/myapp.git",
+			},
+			expected: &deployclient.CIEnvironment{
+				Provider:   "jenkins",
+				Commit:     "abc123",
+				RunURL:     "https://jenkins.nav.no/job/myapp/42/",
+				PipelineID: "jenkins-myapp-42",
+				Owner:      "navikt",
+				Repository: "myapp",
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, deployclient.DetectCI(mapEnv(tt.env)))
+		})
+	}
+}
+
+func TestCIAnnotations(t *testing.T) {
+	ci := &deployclient.CIEnvironment{
+		Provider: "gitlab",
+		Commit:   "abc123",
+		RunURL:   "https://gitlab.com/navikt/myapp/-/pipelines/42",
+	}
+
+	assert.Equal(t, map[string]string{
+		deployclient.CIName:   "gitlab",
+		deployclient.CICommit: "abc123",
+		deployclient.CIRunURL: "https://gitlab.com/navikt/myapp/-/pipelines/42",
+	}, ci.Annotations())
+	assert.Equal(t, "nais deploy: commit abc123: https://gitlab.com/navikt/myapp/-/pipelines/42", ci.ChangeCause())
+
+	ci.RunURL = ""
+	assert.Empty(t, ci.ChangeCause())
+}
+
+func TestApplyCIDefaults(t *testing.T) {
+	ci := &deployclient.CIEnvironment{
+		Commit:     "abc123",
+		Owner:      "navikt",
+		Repository: "myapp",
+	}
+	none := func(string) bool { return false }
+
+	cfg := deployclient.NewConfig()
+	cfg.Ref = deployclient.DefaultRef
+	cfg.Owner = deployclient.DefaultOwner
+	deployclient.ApplyCIDefaults(cfg, ci, none)
+	assert.Equal(t, "abc123", cfg.Ref)
+	assert.Equal(t, "navikt", cfg.Owner)
+	assert.Equal(t, "myapp", cfg.Repository)
+
+	// Explicit values are kept.
+	cfg = deployclient.NewConfig()
+	cfg.Ref = "v1.0.0"
+	cfg.Repository = "otherapp"
+	deployclient.ApplyCIDefaults(cfg, ci, func(flag string) bool {
+		return flag == "ref" || flag == "repository"
+	})
+	assert.Equal(t, "v1.0.0", cfg.Ref)
+	assert.Equal(t, "otherapp", cfg.Repository)
+	assert.Empty(t, cfg.Owner)
+
+	// Nothing happens outside of CI.
+	cfg = deployclient.NewConfig()
+	deployclient.ApplyCIDefaults(cfg, nil, none)
+	assert.Empty(t, cfg.Ref)
+}
@@ -12,16 +12,44 @@ import (
 	flag "github.com/spf13/pflag"
 )
 
+const (
+	CommandCancel   = "cancel"
+	CommandDeploy   = "deploy"
+	CommandDiff     = "diff"
+	CommandHistory  = "history"
+	CommandRollback = "rollback"
+	CommandStatus   = "status"
+)
+
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+const (
+	DryRunNone   = ""
+	DryRunClient = "client"
+	DryRunServer = "server"
+)
+
 type Config struct {
 	APIKey                    string
 	Actions                   bool
 	Cluster                   string
+	ClusterVariablesFiles     map[string]string
+	ClusterVariablesPath      string
+	Color                     bool
+	Command                   string
 	DeployServerURL           string
-	DryRun                    bool
+	DeploymentID              string
+	DiffExitCode              bool
+	DryRun                    string
 	Environment               string
+	ExplainVariables          bool
 	GithubToken               string
 	GrpcAuthentication        bool
 	GrpcUseTLS                bool
+	Limit                     int
 	Owner                     string
 	PollInterval              time.Duration
 	PrintPayload              bool
@@ -30,16 +58,27 @@ type Config struct {
 	Repository                string
 	Resource                  []string
 	Retry                     bool
+	RollbackTo                string
 	RetryInterval             time.Duration
+	SecretVariables           []string
+	Sequential                bool
+	Since                     string
+	States                    []string
+	StrictTemplates           bool
 	Team                      string
+	TemplateEngine            string
 	Traceparent               string
 	TelemetryInput            string
 	Telemetry                 *telemetry.PipelineTimings
 	Timeout                   time.Duration
 	TracingDashboardURL       string
+	Until                     string
+	ValidateResources         bool
 	OpenTelemetryCollectorURL string
+	Output                    string
 	Variables                 []string
-	VariablesFile             string
+	VariablesEnvPrefix        string
+	VariablesFiles            []string
 	Wait                      bool
 }
 
@@ -47,31 +86,71 @@ func InitConfig(cfg *Config) {
 	flag.BoolVar(&cfg.Actions, "actions", getEnvBool("ACTIONS", false), "Use GitHub Actions compatible error and warning messages. (env ACTIONS)")
 	flag.StringVar(&cfg.GithubToken, "github-token", os.Getenv("GITHUB_TOKEN"), "Github JWT. (env GITHUB_TOKEN)")
 	flag.StringVar(&cfg.APIKey, "apikey", os.Getenv("APIKEY"), "NAIS Deploy API key. (env APIKEY)")
-	flag.StringVar(&cfg.Cluster, "cluster", os.Getenv("CLUSTER"), "NAIS cluster to deploy into. (env CLUSTER)")
+	flag.StringVar(&cfg.Cluster, "cluster", os.Getenv("CLUSTER"), "NAIS cluster to deploy into. Deploy to several clusters at once by separating them with commas. (env CLUSTER)")
+	flag.StringToStringVar(&cfg.ClusterVariablesFiles, "cluster-vars", getEnvStringMap("CLUSTER_VARS"), "Additional template variables file for a cluster, in the form CLUSTER=FILE. Can be specified multiple times. (env CLUSTER_VARS)")
+	flag.StringVar(&cfg.ClusterVariablesPath, "cluster-vars-path", os.Getenv("CLUSTER_VARS_PATH"), "Path to template variables files for each cluster, where "+ClusterPlaceholder+" is replaced with the cluster name, e.g. vars/"+ClusterPlaceholder+".yaml. Missing files are ignored. (env CLUSTER_VARS_PATH)")
+	flag.BoolVar(&cfg.Color, "color", len(os.Getenv("NO_COLOR")) == 0, "Colorize diff output. Disabled by default if NO_COLOR is set. (env NO_COLOR)")
 	flag.StringVar(&cfg.DeployServerURL, "deploy-server", getEnv("DEPLOY_SERVER", DefaultDeployServer), "URL to API server. (env DEPLOY_SERVER)")
-	flag.BoolVar(&cfg.DryRun, "dry-run", getEnvBool("DRY_RUN", false), "Run templating, but don't actually make any requests. (env DRY_RUN)")
+	flag.StringVar(&cfg.DryRun, "dry-run", getEnvDryRun("DRY_RUN"), "Run templating only (client), or validate resources against the target cluster without persisting them (server). (env DRY_RUN)")
+	flag.Lookup("dry-run").NoOptDefVal = DryRunClient
+	flag.StringVar(&cfg.DeploymentID, "id", os.Getenv("DEPLOYMENT_ID"), "ID of an existing deployment, used by the cancel and status commands. (env DEPLOYMENT_ID)")
+	flag.BoolVar(&cfg.DiffExitCode, "exit-code", getEnvBool("EXIT_CODE", false), "When running diff, exit with a non-zero exit code if any resource would be changed. (env EXIT_CODE)")
 	flag.StringVar(&cfg.Environment, "environment", os.Getenv("ENVIRONMENT"), "Environment for GitHub deployment. Autodetected from nais.yaml if not specified. (env ENVIRONMENT)")
+	flag.BoolVar(&cfg.ExplainVariables, "explain-vars", getEnvBool("EXPLAIN_VARS", false), "Print the final value of every template variable and where it came from, then exit. (env EXPLAIN_VARS)")
 	flag.BoolVar(&cfg.GrpcAuthentication, "grpc-authentication", getEnvBool("GRPC_AUTHENTICATION", true), "Use team API key to authenticate requests. (env GRPC_AUTHENTICATION)")
 	flag.BoolVar(&cfg.GrpcUseTLS, "grpc-use-tls", getEnvBool("GRPC_USE_TLS", true), "Use encrypted connection for gRPC calls. (env GRPC_USE_TLS)")
-	flag.StringVar(&cfg.Owner, "owner", getEnv("OWNER", DefaultOwner), "Owner of GitHub repository. (env OWNER)")
+	flag.IntVar(&cfg.Limit, "limit", getEnvInt("LIMIT", DefaultHistoryLimit), "Maximum number of deployments to list in history. (env LIMIT)")
+	flag.StringVar(&cfg.Output, "output", getEnv("OUTPUT", OutputText), "Output format of command results, either 'text' or 'json'. When deploying, 'json' writes deployment events to standard output as newline delimited JSON. (env OUTPUT)")
+	flag.StringVar(&cfg.Owner, "owner", getEnv("OWNER", DefaultOwner), "Owner of GitHub repository. Detected from the CI pipeline if possible. (env OWNER)")
 	flag.BoolVar(&cfg.PrintPayload, "print-payload", getEnvBool("PRINT_PAYLOAD", false), "Print templated resources to standard output. (env PRINT_PAYLOAD)")
 	flag.BoolVar(&cfg.Quiet, "quiet", getEnvBool("QUIET", false), "Suppress printing of informational messages except errors. (env QUIET)")
-	flag.StringVar(&cfg.Ref, "ref", getEnv("REF", DefaultRef), "Git commit hash, tag, or branch of the code being deployed. (env REF)")
-	flag.StringVar(&cfg.Repository, "repository", os.Getenv("REPOSITORY"), "Name of GitHub repository. (env REPOSITORY)")
-	flag.StringSliceVar(&cfg.Resource, "resource", getEnvStringSlice("RESOURCE"), "File with Kubernetes resource. Can be specified multiple times. (env RESOURCE)")
+	flag.StringVar(&cfg.Ref, "ref", getEnv("REF", DefaultRef), "Git commit hash, tag, or branch of the code being deployed. Defaults to the commit being built by the CI pipeline, if detected. (env REF)")
+	flag.StringVar(&cfg.Repository, "repository", os.Getenv("REPOSITORY"), "Name of GitHub repository. Detected from the CI pipeline if possible. (env REPOSITORY)")
+	flag.StringSliceVar(&cfg.Resource, "resource", getEnvStringSlice("RESOURCE"), "File or directory with Kubernetes resources, or - to read from standard input. Directories are searched recursively, and built with kustomize if they contain a kustomization.yaml. Can be specified multiple times. (env RESOURCE)")
 	flag.BoolVar(&cfg.Retry, "retry", getEnvBool("RETRY", true), "Retry deploy when encountering transient errors. (env RETRY)")
+	flag.StringVar(&cfg.RollbackTo, "to", os.Getenv("ROLLBACK_TO"), "ID of a previous deployment to roll back to. Defaults to the last successful deployment of the repository. (env ROLLBACK_TO)")
+	flag.StringSliceVar(&cfg.SecretVariables, "secret-var", getEnvStringSlice("SECRET_VAR"), "Sensitive template variable in the form KEY=VALUE, which is redacted from logs and printed payloads. Variables given with --var can also be marked as sensitive using the prefix 'secret:', and so can variables in the 'secrets' section of a variables file. Can be specified multiple times. (env SECRET_VAR)")
+	flag.BoolVar(&cfg.Sequential, "sequential", getEnvBool("SEQUENTIAL", false), "When deploying to several clusters, deploy to one cluster at a time and stop at the first failure. (env SEQUENTIAL)")
+	flag.StringVar(&cfg.Since, "since", os.Getenv("SINCE"), "When listing history, only show deployments made after this RFC 3339 timestamp or duration ago, e.g. 24h. (env SINCE)")
+	flag.StringSliceVar(&cfg.States, "state", getEnvStringSlice("STATE"), "When listing history, only show deployments in this state. Can be specified multiple times. (env STATE)")
+	flag.BoolVar(&cfg.StrictTemplates, "strict", getEnvBool("STRICT", false), "Fail if resources reference undefined template variables, or if any template variable is never used. (env STRICT)")
 	flag.StringVar(&cfg.Team, "team", os.Getenv("TEAM"), "Team making the deployment. Auto-detected from nais.yaml if possible. (env TEAM)")
+	flag.StringVar(&cfg.TemplateEngine, "template-engine", getEnv("TEMPLATE_ENGINE", TemplateEngineHandlebars), "Template engine used to render resources; one of 'handlebars', 'go', 'envsubst' or 'jsonnet'. (env TEMPLATE_ENGINE)")
 	flag.StringVar(&cfg.OpenTelemetryCollectorURL, "otel-collector-endpoint", getEnv("OTEL_COLLECTOR_ENDPOINT", DefaultOtelCollectorEndpoint), "OpenTelemetry collector endpoint. (env OTEL_COLLECTOR_ENDPOINT)")
 	flag.StringVar(&cfg.Traceparent, "traceparent", os.Getenv("TRACEPARENT"), "The W3C Trace Context traceparent value for the workflow run. (env TRACEPARENT)")
 	flag.StringVar(&cfg.TelemetryInput, "telemetry", os.Getenv("TELEMETRY"), "Telemetry data from CI pipeline. (env TELEMETRY)")
 	flag.DurationVar(&cfg.Timeout, "timeout", getEnvDuration("TIMEOUT", DefaultDeployTimeout), "Time to wait for successful deployment. (env TIMEOUT)")
+	flag.StringVar(&cfg.Until, "until", os.Getenv("UNTIL"), "When listing history, only show deployments made before this RFC 3339 timestamp or duration ago. (env UNTIL)")
 	flag.StringVar(&cfg.TracingDashboardURL, "tracing-dashboard-url", getEnv("TRACING_DASHBOARD_URL", DefaultTracingDashboardURL), "Base URL to Grafana tracing dashboard onto which the trace ID can be appended (env TRACING_DASHBOARD_URL)")
+	flag.BoolVar(&cfg.ValidateResources, "validate", getEnvBool("VALIDATE", true), "Validate NAIS resources against their schemas before sending them, and report errors with file and line number. (env VALIDATE)")
 	flag.StringSliceVar(&cfg.Variables, "var", getEnvStringSlice("VAR"), "Template variable in the form KEY=VALUE. Can be specified multiple times. (env VAR)")
-	flag.StringVar(&cfg.VariablesFile, "vars", os.Getenv("VARS"), "File containing template variables. (env VARS)")
+	flag.StringSliceVar(&cfg.VariablesFiles, "vars", getEnvStringSlice("VARS"), "File containing template variables. Can be specified multiple times; later files are deeply merged into earlier ones. (env VARS)")
+	flag.StringVar(&cfg.VariablesEnvPrefix, "vars-env-prefix", os.Getenv("VARS_ENV_PREFIX"), "Import environment variables with this prefix as template variables, e.g. DEPLOY_VAR_. The prefix is removed from the variable name. (env VARS_ENV_PREFIX)")
 	flag.BoolVar(&cfg.Wait, "wait", getEnvBool("WAIT", false), "Block until deployment reaches final state (success, failure, error). (env WAIT)")
 
 	flag.Parse()
 
+	cfg.DryRun = normalizeDryRun(cfg.DryRun)
+
+	cfg.Command = flag.Arg(0)
+	if len(cfg.Command) == 0 {
+		cfg.Command = CommandDeploy
+	}
+
+	// Allow the deployment ID to be given as a positional argument, e.g. `deploy status <id>`.
+	if len(cfg.DeploymentID) == 0 {
+		cfg.DeploymentID = flag.Arg(1)
+	}
+
+	// Fill in the ref, owner and repository from the CI pipeline, unless given explicitly.
+	ApplyCIDefaults(cfg, DetectCI(os.LookupEnv), func(name string) bool {
+		if flag.CommandLine.Changed(name) {
+			return true
+		}
+		_, ok := os.LookupEnv(strings.ToUpper(name))
+		return ok
+	})
+
 	// Both owner and repository must be set in a valid request, but they are not required
 	if len(cfg.Owner) == 0 || len(cfg.Repository) == 0 {
 		cfg.Owner = ""
@@ -83,7 +162,11 @@ func InitConfig(cfg *Config) {
 // Values will be resolved with the following precedence: flags > environment variables > default values.
 func NewConfig() *Config {
 	return &Config{
-		RetryInterval: time.Second * 5,
+		Command:        CommandDeploy,
+		Limit:          DefaultHistoryLimit,
+		Output:         OutputText,
+		TemplateEngine: TemplateEngineHandlebars,
+		RetryInterval:  time.Second * 5,
 	}
 }
 
@@ -105,6 +188,28 @@ func getEnvDuration(key string, fallback time.Duration) time.Duration {
 	return fallback
 }
 
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		i, err := strconv.Atoi(value)
+		if err == nil {
+			return i
+		}
+	}
+	return fallback
+}
+
+// getEnvStringMap parses a comma-separated list of KEY=VALUE pairs.
+func getEnvStringMap(key string) map[string]string {
+	m := make(map[string]string)
+	for _, keyval := range getEnvStringSlice(key) {
+		tokens := strings.SplitN(keyval, "=", 2)
+		if len(tokens) == 2 {
+			m[tokens[0]] = tokens[1]
+		}
+	}
+	return m
+}
+
 func getEnvStringSlice(key string) []string {
 	if value, ok := os.LookupEnv(key); ok {
 		return strings.Split(value, ",")
@@ -113,6 +218,23 @@ func getEnvStringSlice(key string) []string {
 	return []string{}
 }
 
+// getEnvDryRun returns the dry-run mode from the environment.
+// Boolean values are accepted for backwards compatibility, where true means client-side dry run.
+func getEnvDryRun(key string) string {
+	return normalizeDryRun(os.Getenv(key))
+}
+
+func normalizeDryRun(mode string) string {
+	b, err := strconv.ParseBool(mode)
+	if err != nil {
+		return mode
+	}
+	if b {
+		return DryRunClient
+	}
+	return DryRunNone
+}
+
 func getEnvBool(key string, def bool) bool {
 	b, err := strconv.ParseBool(os.Getenv(key))
 	if err != nil {
@@ -122,13 +244,91 @@ func getEnvBool(key string, def bool) bool {
 	return b
 }
 
+// Clusters returns each cluster in a comma-separated list of clusters.
+func (cfg *Config) Clusters() []string {
+	clusters := make([]string, 0)
+	for _, cluster := range strings.Split(cfg.Cluster, ",") {
+		cluster = strings.TrimSpace(cluster)
+		if len(cluster) > 0 {
+			clusters = append(clusters, cluster)
+		}
+	}
+	return clusters
+}
+
+// ClientDryRun returns true if the request should be templated, but never sent.
+func (cfg *Config) ClientDryRun() bool {
+	return cfg.DryRun == DryRunClient
+}
+
+// ServerDryRun returns true if the request should be validated by the target cluster, but not persisted.
+func (cfg *Config) ServerDryRun() bool {
+	return cfg.DryRun == DryRunServer
+}
+
 func (cfg *Config) Validate() error {
-	if len(cfg.Resource) == 0 {
-		return ErrResourceRequired
+	switch cfg.Command {
+	case CommandCancel, CommandDeploy, CommandDiff, CommandHistory, CommandRollback, CommandStatus:
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidCommand, cfg.Command)
+	}
+
+	switch cfg.Output {
+	case OutputText, OutputJSON:
+	default:
+		return ErrInvalidOutput
 	}
 
-	if len(cfg.Cluster) == 0 {
-		return ErrClusterRequired
+	switch cfg.DryRun {
+	case DryRunNone, DryRunClient, DryRunServer:
+	default:
+		return ErrInvalidDryRun
+	}
+
+	if _, err := NewTemplateEngine(cfg.TemplateEngine); err != nil {
+		return err
+	}
+
+	switch cfg.Command {
+	case CommandCancel, CommandDiff, CommandRollback, CommandStatus:
+		if len(cfg.Clusters()) > 1 {
+			return fmt.Errorf("%w: %s", ErrSingleClusterRequired, cfg.Command)
+		}
+	}
+
+	switch cfg.Command {
+	case CommandCancel, CommandStatus:
+		if len(cfg.DeploymentID) == 0 {
+			return ErrDeploymentIDRequired
+		}
+		if len(cfg.Team) == 0 {
+			return ErrTeamRequired
+		}
+	case CommandRollback:
+		if len(cfg.Team) == 0 {
+			return ErrTeamRequired
+		}
+		if len(cfg.Cluster) == 0 {
+			return ErrClusterRequired
+		}
+		if len(cfg.RollbackTo) == 0 && len(cfg.Repository) == 0 {
+			return ErrRollbackTargetRequired
+		}
+	case CommandHistory:
+		if len(cfg.Team) == 0 {
+			return ErrTeamRequired
+		}
+		if _, err := MakeListDeploymentsRequest(*cfg, time.Now()); err != nil {
+			return err
+		}
+	default:
+		if len(cfg.Resource) == 0 {
+			return ErrResourceRequired
+		}
+
+		if len(cfg.Clusters()) == 0 {
+			return ErrClusterRequired
+		}
 	}
 
 	if len(cfg.APIKey) == 0 && len(cfg.GithubToken) == 0 {
@@ -1,10 +1,12 @@
 package deployclient
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"time"
@@ -12,7 +14,9 @@ import (
 	log "github.com/sirupsen/logrus"
 	ocodes "go.opentelemetry.io/otel/codes"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
 
+	auth_interceptor "github.com/nais/deploy/pkg/grpc/interceptor/auth"
 	"github.com/nais/deploy/pkg/hookd/logproxy"
 	"github.com/nais/deploy/pkg/pb"
 	"github.com/nais/deploy/pkg/telemetry"
@@ -27,6 +31,7 @@ const (
 	DefaultOtelCollectorEndpoint = "https://collector-internet.external.prod-gcp.nav.cloud.nais.io"
 	DefaultTracingDashboardURL   = "https://grafana.nav.cloud.nais.io/d/cdxgyzr3rikn4a/deploy-tracing-drilldown?var-trace_id="
 	DefaultDeployTimeout         = time.Minute * 10
+	DefaultHistoryLimit          = 30
 )
 
 var (
@@ -35,65 +40,127 @@ var (
 	ErrClusterRequired        = errors.New("cluster required; see reference section in the documentation for available environments")
 	ErrMalformedAPIKey        = errors.New("API key must be a hex encoded string")
 	ErrInvalidTelemetryFormat = errors.New("telemetry input format malformed")
+	ErrInvalidDryRun          = errors.New("dry run mode must be one of 'client' or 'server'")
+	ErrDeploymentIDRequired   = errors.New("deployment ID required")
+	ErrTeamRequired           = errors.New("team required")
+	ErrInvalidCommand         = errors.New("unknown command")
+	ErrInvalidOutput          = errors.New("output format must be one of 'text' or 'json'")
+	ErrInvalidState           = errors.New("unknown deployment state")
+	ErrInvalidTimeFilter      = errors.New("time filter must be an RFC 3339 timestamp or a duration")
+	ErrRollbackTargetRequired = errors.New("repository or deployment ID to roll back to required")
+	ErrInvalidTemplateEngine  = errors.New("template engine must be one of 'handlebars', 'go', 'envsubst' or 'jsonnet'")
+	ErrSingleClusterRequired  = errors.New("only one cluster can be specified for this command")
 )
 
 type Deployer struct {
 	Client pb.DeployClient
+	Stdout io.Writer
+	Log    log.FieldLogger
+	// Events receives machine-readable deployment events as newline delimited JSON, if set.
+	Events io.Writer
+}
+
+func (d *Deployer) logger() log.FieldLogger {
+	if d.Log == nil {
+		return log.StandardLogger()
+	}
+	return d.Log
+}
+
+func (d *Deployer) stdout() io.Writer {
+	if d.Stdout == nil {
+		return os.Stdout
+	}
+	return d.Stdout
 }
 
 func Prepare(ctx context.Context, cfg *Config) (*pb.DeploymentRequest, error) {
-	var err error
-	templateVariables := make(TemplateVariables)
+	files, err := ReadResourceFiles(cfg.Resource, os.Stdin)
+	if err != nil {
+		return nil, ErrorWrap(ExitInvocationFailure, err)
+	}
 
-	if len(cfg.VariablesFile) > 0 {
-		templateVariables, err = templateVariablesFromFile(cfg.VariablesFile)
-		if err != nil {
-			return nil, Errorf(ExitInvocationFailure, "load template variables: %s", err)
-		}
+	return PrepareResources(ctx, cfg, files)
+}
+
+// PrepareResources builds a deployment request from resource files that have already been read.
+func PrepareResources(ctx context.Context, cfg *Config, files []ResourceFile) (*pb.DeploymentRequest, error) {
+	resolved, err := ResolveTemplateVariables(cfg, os.Environ())
+	if err != nil {
+		return nil, Errorf(ExitInvocationFailure, "load template variables: %s", err)
 	}
+	templateVariables := resolved.Variables
 
-	if len(cfg.Variables) > 0 {
-		templateOverrides := templateVariablesFromSlice(cfg.Variables)
-		for key, val := range templateOverrides {
-			if oldval, ok := templateVariables[key]; ok {
-				log.Warnf("Overwriting template variable '%s'; previous value was '%v'", key, oldval)
+	engine, err := NewTemplateEngine(cfg.TemplateEngine)
+	if err != nil {
+		return nil, ErrorWrap(ExitInvocationFailure, err)
+	}
+
+	if cfg.StrictTemplates {
+		err = CheckTemplateVariables(engine, files, templateVariables, resolved.Files...)
+		strictErr := &StrictTemplateError{}
+		if errors.As(err, &strictErr) {
+			for _, problem := range strictErr.Problems {
+				problem.log()
 			}
-			log.Infof("Setting template variable '%s' to '%v'", key, val)
-			templateVariables[key] = val
+			return nil, Errorf(ExitTemplateError, "strict templating found %d problem(s)", len(strictErr.Problems))
+		} else if err != nil {
+			return nil, ErrorWrap(ExitTemplateError, err)
 		}
 	}
 
 	resources := make([]json.RawMessage, 0)
 
-	for i, path := range cfg.Resource {
-		parsed, err := MultiDocumentFileAsJSON(path, templateVariables)
+	// Auto-detection looks at the first document of each resource file.
+	firstDocuments := make(map[string]json.RawMessage)
+	detectionOrder := make([]string, 0, len(files))
+
+	problems := make([]TemplateProblem, 0)
+
+	for _, file := range files {
+		templated, parsed, err := renderDocuments(file, engine, templateVariables)
 		if err != nil {
-			if cfg.PrintPayload {
-				errStr := err.Error()[len(path)+2:]
-				line, er := detectErrorLine(errStr)
-				if er == nil {
-					ctx := errorContext(string(resources[i]), line)
-					for _, l := range ctx {
-						fmt.Println(l)
-					}
+			templateErr := &TemplateError{}
+			if cfg.PrintPayload && errors.As(err, &templateErr) && templateErr.Line > 0 {
+				ctx := errorContext(string(templateErr.Content), templateErr.Line)
+				for _, l := range ctx {
+					fmt.Println(RedactSecrets(l))
 				}
 			}
 			return nil, ErrorWrap(ExitTemplateError, err)
 		}
+		if cfg.ValidateResources {
+			fileProblems, err := ValidateResources(file.Path, templated, parsed)
+			if err != nil {
+				return nil, ErrorWrap(ExitInternalError, err)
+			}
+			problems = append(problems, fileProblems...)
+		}
+		if _, seen := firstDocuments[file.Path]; !seen && len(parsed) > 0 {
+			firstDocuments[file.Path] = parsed[0]
+			detectionOrder = append(detectionOrder, file.Path)
+		}
 		resources = append(resources, parsed...)
 	}
 
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			problem.log()
+		}
+		return nil, Errorf(ExitValidationError, "resource validation found %d problem(s)", len(problems))
+	}
+
 	if len(cfg.Team) == 0 {
 		log.Infof("Team not explicitly specified; attempting auto-detection...")
-		for i, path := range cfg.Resource {
-			team := detectTeam(resources[i])
+		for _, path := range detectionOrder {
+			team := detectTeam(firstDocuments[path])
 			if len(team) > 0 {
 				log.Infof("Detected team %q in %q", team, path)
 				cfg.Team = team
 				break
 			}
 
-			team = detectNamespace(resources[i])
+			team = detectNamespace(firstDocuments[path])
 			if len(team) > 0 {
 				log.Infof("Detected team %q from namespace in %q", team, path)
 				cfg.Team = team
@@ -112,8 +179,8 @@ func Prepare(ctx context.Context, cfg *Config) (*pb.DeploymentRequest, error) {
 		namespaces := make(map[string]interface{})
 		cfg.Environment = cfg.Cluster
 
-		for i := range cfg.Resource {
-			namespace := detectNamespace(resources[i])
+		for _, path := range detectionOrder {
+			namespace := detectNamespace(firstDocuments[path])
 			namespaces[namespace] = new(interface{})
 		}
 
@@ -154,19 +221,21 @@ func (d *Deployer) Deploy(ctx context.Context, cfg *Config, deployRequest *pb.De
 	var deployStatus *pb.DeploymentStatus
 	var err error
 
+	logger := d.logger()
+
 	// Root span for tracing.
 	// All sub-spans must be created from this context.
 	ctx, span := telemetry.Tracer().Start(ctx, "Send deploy request and wait for completion")
 	defer span.End()
 	deployRequest.TraceParent = telemetry.TraceParentHeader(ctx)
 
-	log.Infof("Sending deployment request to NAIS deploy at %s...", cfg.DeployServerURL)
+	logger.Infof("Sending deployment request to NAIS deploy at %s...", cfg.DeployServerURL)
 
 	sendDeploymentRequest := func() error {
 		requestContext, requestSpan := telemetry.Tracer().Start(ctx, "Waiting for deploy server")
 		defer requestSpan.End()
 
-		err = retryUnavailable(cfg.RetryInterval, cfg.Retry, func() error {
+		err = d.retryUnavailable(cfg.RetryInterval, cfg.Retry, func() error {
 			deployStatus, err = d.Client.Deploy(requestContext, deployRequest)
 			return err
 		})
@@ -180,16 +249,27 @@ func (d *Deployer) Deploy(ctx context.Context, cfg *Config, deployRequest *pb.De
 			}
 			if code == codes.Unauthenticated {
 				if !strings.HasSuffix(cfg.Environment, ":"+cfg.Team) {
-					log.Warnf("hint: team %q does not match namespace in %q", cfg.Team, cfg.Environment)
+					logger.Warnf("hint: team %q does not match namespace in %q", cfg.Team, cfg.Environment)
 				}
 			}
 			requestSpan.SetStatus(ocodes.Error, err.Error())
 			return ErrorWrap(ExitNoDeployment, err)
 		}
 
-		log.Infof("Deployment request accepted by NAIS deploy and dispatched to cluster '%s'.", deployStatus.GetRequest().GetCluster())
+		logger.Infof("Deployment request accepted by NAIS deploy and dispatched to cluster '%s'.", deployStatus.GetRequest().GetCluster())
 
 		deployRequest.ID = deployStatus.GetRequest().GetID()
+		traceID := telemetry.TraceID(ctx)
+		d.emit(Event{
+			Type:         EventAccepted,
+			DeploymentID: deployRequest.GetID(),
+			Cluster:      deployStatus.GetRequest().GetCluster(),
+			TraceID:      traceID,
+			TracingURL:   cfg.TracingDashboardURL + traceID,
+			LogsURL:      logsURL(cfg, deployRequest),
+			State:        deployStatus.GetState().String(),
+			Message:      deployStatus.GetMessage(),
+		})
 		telemetry.AddDeploymentRequestSpanAttributes(span, deployStatus.GetRequest())
 		telemetry.AddDeploymentRequestSpanAttributes(requestSpan, deployStatus.GetRequest())
 
@@ -203,28 +283,66 @@ func (d *Deployer) Deploy(ctx context.Context, cfg *Config, deployRequest *pb.De
 	if err != nil {
 		span.SetStatus(ocodes.Error, err.Error())
 		span.RecordError(err)
-		return err
+		return d.emitOutcome(deployRequest, err)
+	}
+
+	err = d.follow(ctx, cfg, deployRequest, deployStatus, sendDeploymentRequest)
+	return d.emitOutcome(deployRequest, err)
+}
+
+// Attach follows an existing deployment until it reaches a final state.
+func (d *Deployer) Attach(ctx context.Context, cfg *Config) error {
+	ctx, span := telemetry.Tracer().Start(ctx, "Attach to deployment and wait for completion")
+	defer span.End()
+
+	deployRequest := &pb.DeploymentRequest{
+		ID:          cfg.DeploymentID,
+		Cluster:     cfg.Cluster,
+		Team:        cfg.Team,
+		TraceParent: telemetry.TraceParentHeader(ctx),
 	}
 
+	log.Infof("Attaching to deployment %s at %s...", cfg.DeploymentID, cfg.DeployServerURL)
+
+	// Always wait for completion; there would be no point in attaching otherwise.
+	attachConfig := *cfg
+	attachConfig.Wait = true
+
+	err := d.follow(ctx, &attachConfig, deployRequest, nil, nil)
+	return d.emitOutcome(deployRequest, err)
+}
+
+// Stream status updates of a deployment until it reaches a final state or the context expires.
+// The initial status is nil when attaching to a deployment made elsewhere.
+// If resend is nil, a deployment that becomes inactive is treated as finished.
+func (d *Deployer) follow(ctx context.Context, cfg *Config, deployRequest *pb.DeploymentRequest, deployStatus *pb.DeploymentStatus, resend func() error) error {
+	var err error
+
+	logger := d.logger()
 	traceID := telemetry.TraceID(ctx)
 
 	// Print information to standard output
-	urlPrefix := "https://" + strings.Split(cfg.DeployServerURL, ":")[0]
-	log.Infof("Deployment information:")
-	log.Infof("---")
-	log.Infof("id...........: %s", deployRequest.GetID())
-	log.Infof("tracing......: %s", cfg.TracingDashboardURL+traceID)
-	log.Infof("debug logs...: %s", logproxy.MakeURL(urlPrefix, deployRequest.GetID(), deployRequest.GetTime().AsTime(), deployRequest.Cluster))
-	log.Infof("deadline.....: %s", deployRequest.GetDeadline().AsTime().Local())
-	log.Infof("---")
+	logger.Infof("Deployment information:")
+	logger.Infof("---")
+	logger.Infof("id...........: %s", deployRequest.GetID())
+	logger.Infof("tracing......: %s", cfg.TracingDashboardURL+traceID)
+	if deployRequest.GetTime() != nil {
+		logger.Infof("debug logs...: %s", logsURL(cfg, deployRequest))
+	}
+	if deployRequest.GetDeadline() != nil {
+		logger.Infof("deadline.....: %s", deployRequest.GetDeadline().AsTime().Local())
+	}
+	logger.Infof("---")
 
 	// If running in GitHub actions, print a markdown summary
+	// The summary is written in one go when finished, so that concurrent deployments to several clusters don't interleave.
 	summaryFile, err := os.OpenFile(os.Getenv("GITHUB_STEP_SUMMARY"), os.O_APPEND|os.O_WRONLY, 0644)
+	summaryBuffer := &bytes.Buffer{}
 	summary := func(format string, a ...any) {
 		if summaryFile == nil {
 			return
 		}
-		_, _ = fmt.Fprintf(summaryFile, format+"\n", a...)
+		_, _ = fmt.Fprintf(summaryBuffer, format+"\n", a...)
 	}
 	finalStatus := func(st *pb.DeploymentStatus) {
 		summary("* Finished at: %s", st.Timestamp().Truncate(time.Second))
@@ -232,40 +350,55 @@ func (d *Deployer) Deploy(ctx context.Context, cfg *Config, deployRequest *pb.De
 		summary("%c Final status: *%s* / %s", deployStatus.GetState().StatusEmoji(), deployStatus.GetState(), deployStatus.GetMessage())
 	}
 	if err == nil {
-		defer summaryFile.Close()
+		defer func() {
+			_, _ = summaryFile.Write(summaryBuffer.Bytes())
+			summaryFile.Close()
+		}()
 	}
 
 	summary("## 🚀 NAIS deploy")
 	summary("")
+	summary("* Cluster: %s", deployRequest.GetCluster())
 	summary("* Detailed trace: [%s](%s)", traceID, cfg.TracingDashboardURL+traceID)
 	summary("* Request ID: %s", deployRequest.GetID())
 	summary("* Started at: %s", time.Now().Local().Truncate(time.Second))
-	summary("* Deadline: %s", deployRequest.GetDeadline().AsTime().Local().Truncate(time.Second))
-
-	if deployStatus.GetState().Finished() {
-		finalStatus(deployStatus)
-		logDeployStatus(deployStatus)
-		return ErrorStatus(deployStatus)
+	if deployRequest.GetDeadline() != nil {
+		summary("* Deadline: %s", deployRequest.GetDeadline().AsTime().Local().Truncate(time.Second))
 	}
 
-	if !cfg.Wait {
-		finalStatus(deployStatus)
-		logDeployStatus(deployStatus)
-		return nil
+	if deployStatus != nil {
+		if deployStatus.GetState().Finished() {
+			finalStatus(deployStatus)
+			d.logDeployStatus(deployStatus)
+			return ErrorStatus(deployStatus)
+		}
+
+		// Validation reports from a server-side dry run are only available while streaming status.
+		if !cfg.Wait && !cfg.ServerDryRun() {
+			finalStatus(deployStatus)
+			d.logDeployStatus(deployStatus)
+			return nil
+		}
 	}
 
 	var stream pb.Deploy_StatusClient
 	var connectionLost bool
 
-	log.Infof("Waiting for deployment to complete...")
+	logger.Infof("Waiting for deployment to complete...")
 
 	for ctx.Err() == nil {
-		err = retryUnavailable(cfg.RetryInterval, cfg.Retry, func() error {
-			stream, err = d.Client.Status(ctx, deployRequest)
+		err = d.retryUnavailable(cfg.RetryInterval, cfg.Retry, func() error {
+			stream, err = d.Client.Status(auth_interceptor.WithTeam(ctx, deployRequest.GetTeam()), deployRequest)
 			if err != nil {
 				connectionLost = true
 			} else if connectionLost {
-				log.Infof("Connection to NAIS deploy re-established.")
+				logger.Infof("Connection to NAIS deploy re-established.")
+				d.emit(Event{
+					Type:         EventReconnect,
+					DeploymentID: deployRequest.GetID(),
+					Cluster:      deployRequest.GetCluster(),
+					Message:      "connection to NAIS deploy re-established",
+				})
 			}
 			return err
 		})
@@ -278,18 +411,33 @@ func (d *Deployer) Deploy(ctx context.Context, cfg *Config, deployRequest *pb.De
 			deployStatus, err = stream.Recv()
 			if err != nil {
 				connectionLost = true
-				if cfg.Retry && grpcErrorRetriable(err) {
-					log.Warnf(formatGrpcError(err))
+				if grpcErrorCode(err) == codes.NotFound {
+					summary("❌ deployment not found")
+					return Errorf(ExitNoDeployment, formatGrpcError(err))
+				} else if cfg.Retry && grpcErrorRetriable(err) {
+					logger.Warnf(formatGrpcError(err))
+					d.emit(Event{
+						Type:         EventRetry,
+						DeploymentID: deployRequest.GetID(),
+						Cluster:      deployRequest.GetCluster(),
+						Error:        formatGrpcError(err),
+					})
 					break
 				} else {
 					summary("❌ lost connection to NAIS deploy", deployStatus.GetState(), deployStatus.GetMessage())
 					return Errorf(ExitUnavailable, formatGrpcError(err))
 				}
 			}
-			logDeployStatus(deployStatus)
-			if deployStatus.GetState() == pb.DeploymentState_inactive {
-				log.Warnf("NAIS deploy has been restarted. Re-sending deployment request...")
-				err = sendDeploymentRequest()
+			d.logDeployStatus(deployStatus)
+			if deployStatus.GetState() == pb.DeploymentState_inactive && resend != nil {
+				logger.Warnf("NAIS deploy has been restarted. Re-sending deployment request...")
+				d.emit(Event{
+					Type:         EventReconnect,
+					DeploymentID: deployRequest.GetID(),
+					Cluster:      deployRequest.GetCluster(),
+					Message:      "NAIS deploy has been restarted; re-sending deployment request",
+				})
+				err = resend()
 				if err != nil {
 					summary("❌ lost connection to NAIS deploy", deployStatus.GetState(), deployStatus.GetMessage())
 					return err
@@ -305,6 +453,25 @@ func (d *Deployer) Deploy(ctx context.Context, cfg *Config, deployRequest *pb.De
 	return Errorf(ExitTimeout, "deployment timed out: %w", ctx.Err())
 }
 
+// logsURL returns a link to the debug logs of a deployment, or an empty string if the request time is unknown.
+func logsURL(cfg *Config, request *pb.DeploymentRequest) string {
+	if request.GetTime() == nil {
+		return ""
+	}
+	urlPrefix := "https://" + strings.Split(cfg.DeployServerURL, ":")[0]
+	return logproxy.MakeURL(urlPrefix, request.GetID(), request.GetTime().AsTime(), request.GetCluster())
+}
+
+// PrintPayload prints a deployment request to standard output, with the values of secret template variables redacted.
+// When events are written to standard output, the payload is printed to standard error instead.
+func (d *Deployer) PrintPayload(request *pb.DeploymentRequest) {
+	w := d.stdout()
+	if d.Events != nil {
+		w = os.Stderr
+	}
+	fmt.Fprintln(w, RedactSecrets(protojson.Format(request)))
+}
+
 func grpcErrorRetriable(err error) bool {
 	switch grpcErrorCode(err) {
 	case codes.Unavailable, codes.Internal:
@@ -314,11 +481,17 @@ func grpcErrorRetriable(err error) bool {
 	}
 }
 
-func retryUnavailable(interval time.Duration, retry bool, fn func() error) error {
+func (d *Deployer) retryUnavailable(interval time.Duration, retry bool, fn func() error) error {
+	logger := d.logger()
 	for {
 		err := fn()
 		if retry && grpcErrorRetriable(err) {
-			log.Warnf("%s (retrying in %s...)", formatGrpcError(err), interval)
+			logger.Warnf("%s (retrying in %s...)", formatGrpcError(err), interval)
+			d.emit(Event{
+				Type:    EventRetry,
+				Message: fmt.Sprintf("retrying in %s", interval),
+				Error:   formatGrpcError(err),
+			})
 			time.Sleep(interval)
 			continue
 		}
@@ -109,6 +109,47 @@ func TestDeployError(t *testing.T) {
 	assert.Equal(t, deployclient.ExitDeploymentError, deployclient.ErrorExitCode(err))
 }
 
+func TestServerDryRunWaitsForValidationReport(t *testing.T) {
+	cfg := validConfig()
+	cfg.DryRun = deployclient.DryRunServer
+	request := makeMockDeployRequest(*cfg)
+	request.ID = "1"
+	ctx := context.Background()
+	_, _ = telemetry.New(ctx, "test", "")
+
+	assert.True(t, request.GetDryRun())
+
+	client := &pb.MockDeployClient{}
+	client.On("Deploy", mock.Anything, request).Return(&pb.DeploymentStatus{
+		Request: request,
+		Time:    pb.TimeAsTimestamp(time.Now()),
+		State:   pb.DeploymentState_queued,
+	}, nil).Once()
+
+	statusClient := &pb.MockDeploy_StatusClient{}
+	statusClient.On("Recv").Return(&pb.DeploymentStatus{
+		Request: request,
+		Time:    pb.TimeAsTimestamp(time.Now()),
+		State:   pb.DeploymentState_in_progress,
+		Message: "Validation failed: Application/myapplication: admission webhook denied the request",
+	}, nil).Once()
+	statusClient.On("Recv").Return(&pb.DeploymentStatus{
+		Request: request,
+		Time:    pb.TimeAsTimestamp(time.Now()),
+		State:   pb.DeploymentState_failure,
+		Message: "dry run: 1 of 1 resources failed validation",
+	}, nil).Once()
+
+	client.On("Status", mock.Anything, request).Return(statusClient, nil).Once()
+
+	d := deployclient.Deployer{Client: client}
+	err := d.Deploy(ctx, cfg, request)
+
+	assert.Error(t, err)
+	assert.Equal(t, deployclient.ExitDeploymentFailure, deployclient.ErrorExitCode(err))
+	statusClient.AssertExpectations(t)
+}
+
 func TestDeployPolling(t *testing.T) {
 	cfg := validConfig()
 	cfg.Wait = true
@@ -299,6 +340,9 @@ func TestValidationFailures(t *testing.T) {
 		{deployclient.ErrAuthRequired.Error(), func(cfg deployclient.Config) deployclient.Config { cfg.APIKey = ""; return cfg }},
 		{deployclient.ErrResourceRequired.Error(), func(cfg deployclient.Config) deployclient.Config { cfg.Resource = nil; return cfg }},
 		{deployclient.ErrMalformedAPIKey.Error(), func(cfg deployclient.Config) deployclient.Config { cfg.APIKey = "malformed"; return cfg }},
+		{deployclient.ErrInvalidDryRun.Error(), func(cfg deployclient.Config) deployclient.Config { cfg.DryRun = "everywhere"; return cfg }},
+		{deployclient.ErrInvalidOutput.Error(), func(cfg deployclient.Config) deployclient.Config { cfg.Output = "xml"; return cfg }},
+		{deployclient.ErrInvalidCommand.Error(), func(cfg deployclient.Config) deployclient.Config { cfg.Command = "frobnicate"; return cfg }},
 	} {
 		cfg := testCase.transform(*valid)
 		err := cfg.Validate()
@@ -317,7 +361,7 @@ func TestTemplateVariableFromCommandLine(t *testing.T) {
 	cfg := validConfig()
 	cfg.Team = "foo"
 	cfg.Resource = []string{"testdata/templated.yaml"}
-	cfg.VariablesFile = "testdata/vars.yaml"
+	cfg.VariablesFiles = []string{"testdata/vars.yaml"}
 	cfg.Variables = []string{
 		"one=ONE",
 		"two=TWO",
@@ -339,6 +383,63 @@ func TestTemplateVariableFromCommandLine(t *testing.T) {
 	assert.Equal(t, "FOUR", vars.Four)
 }
 
+func TestAttach(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		state    pb.DeploymentState
+		err      error
+		exitCode deployclient.ExitCode
+	}{
+		{"success", pb.DeploymentState_success, nil, deployclient.ExitSuccess},
+		{"failure", pb.DeploymentState_failure, nil, deployclient.ExitDeploymentFailure},
+		{"error", pb.DeploymentState_error, nil, deployclient.ExitDeploymentError},
+		{"cancelled", pb.DeploymentState_cancelled, nil, deployclient.ExitDeploymentCancelled},
+		{"inactive", pb.DeploymentState_inactive, nil, deployclient.ExitDeploymentInactive},
+		{"not found", 0, status.Errorf(codes.NotFound, "deployment not found"), deployclient.ExitNoDeployment},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := teamConfig(deployclient.CommandStatus)
+			ctx := context.Background()
+			_, _ = telemetry.New(ctx, "test", "")
+
+			isStatusRequest := mock.MatchedBy(func(req *pb.DeploymentRequest) bool {
+				return req.GetID() == cfg.DeploymentID && req.GetTeam() == cfg.Team
+			})
+
+			statusClient := &pb.MockDeploy_StatusClient{}
+			if tt.err != nil {
+				statusClient.On("Recv").Return(nil, tt.err).Once()
+			} else {
+				statusClient.On("Recv").Return(&pb.DeploymentStatus{
+					Time:  pb.TimeAsTimestamp(time.Now()),
+					State: pb.DeploymentState_in_progress,
+				}, nil).Once()
+				statusClient.On("Recv").Return(&pb.DeploymentStatus{
+					Time:  pb.TimeAsTimestamp(time.Now()),
+					State: tt.state,
+				}, nil).Once()
+			}
+
+			client := &pb.MockDeployClient{}
+			client.On("Status", mock.Anything, isStatusRequest).Return(statusClient, nil).Once()
+
+			d := deployclient.Deployer{Client: client}
+			err := d.Attach(ctx, cfg)
+
+			assert.Equal(t, tt.exitCode, deployclient.ErrorExitCode(err))
+			client.AssertExpectations(t)
+			statusClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAttachValidation(t *testing.T) {
+	testTeamValidation(t, deployclient.CommandStatus, []teamValidationCase{
+		{"valid without cluster", func(cfg deployclient.Config) deployclient.Config { cfg.Cluster = ""; return cfg }, nil},
+		{"deployment ID required", func(cfg deployclient.Config) deployclient.Config { cfg.DeploymentID = ""; return cfg }, deployclient.ErrDeploymentIDRequired},
+	})
+}
+
 func TestExitCodeZero(t *testing.T) {
 	assert.Equal(t, deployclient.ExitCode(0), deployclient.ExitSuccess)
 }
@@ -351,3 +452,37 @@ func validConfig() *deployclient.Config {
 	cfg.APIKey = "1234567812345678"
 	return cfg
 }
+
+const testDeploymentID = "a8a4b9e6-62a1-4f7c-9d2b-7a8a0b1b3a6e"
+
+// teamConfig returns a valid configuration for commands that work on existing deployments of a team instead of resources.
+func teamConfig(command string) *deployclient.Config {
+	cfg := validConfig()
+	cfg.Command = command
+	cfg.Resource = nil
+	cfg.Owner = "navikt"
+	cfg.Team = "aura"
+	cfg.DeploymentID = testDeploymentID
+	return cfg
+}
+
+type teamValidationCase struct {
+	name      string
+	transform func(cfg deployclient.Config) deployclient.Config
+	err       error
+}
+
+// testTeamValidation validates transformed copies of the team configuration for a command.
+func testTeamValidation(t *testing.T, command string, cases []teamValidationCase) {
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			cfg := testCase.transform(*teamConfig(command))
+			err := cfg.Validate()
+			if testCase.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, testCase.err)
+			}
+		})
+	}
+}
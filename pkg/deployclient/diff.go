@@ -0,0 +1,148 @@
+package deployclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/nais/deploy/pkg/telemetry"
+	log "github.com/sirupsen/logrus"
+	ocodes "go.opentelemetry.io/otel/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	colorReset = "\033[0m"
+	colorBold  = "\033[1m"
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorCyan  = "\033[36m"
+)
+
+// Diff asks NAIS deploy to compare the resources in the request with the live state of the cluster,
+// and prints the result to standard output.
+func (d *Deployer) Diff(ctx context.Context, cfg *Config, diffRequest *pb.DeploymentRequest) error {
+	var result *pb.DiffResult
+	var err error
+
+	ctx, span := telemetry.Tracer().Start(ctx, "Send diff request and wait for result")
+	defer span.End()
+	diffRequest.TraceParent = telemetry.TraceParentHeader(ctx)
+
+	log.Infof("Sending diff request to NAIS deploy at %s...", cfg.DeployServerURL)
+
+	err = d.retryUnavailable(cfg.RetryInterval, cfg.Retry, func() error {
+		result, err = d.Client.Diff(ctx, diffRequest)
+		return err
+	})
+	if err != nil {
+		span.SetStatus(ocodes.Error, err.Error())
+		if ctx.Err() != nil {
+			return Errorf(ExitTimeout, "diff timed out: %s", ctx.Err())
+		}
+		return Errorf(ExitNoDeployment, formatGrpcError(err))
+	}
+
+	if len(result.GetError()) > 0 {
+		span.SetStatus(ocodes.Error, result.GetError())
+		return Errorf(ExitDeploymentError, "diff failed: %s", result.GetError())
+	}
+
+	switch cfg.Output {
+	case OutputJSON:
+		err = printDiffJSON(d.stdout(), result)
+	default:
+		err = printDiffText(d.stdout(), result, cfg.Color)
+	}
+	if err != nil {
+		return Errorf(ExitInternalError, "print diff: %s", err)
+	}
+
+	var changed, failed int
+	for _, resource := range result.GetResources() {
+		switch {
+		case len(resource.GetError()) > 0:
+			failed++
+		case resource.GetChange() != pb.ResourceChange_unchanged:
+			changed++
+		}
+	}
+
+	if failed > 0 {
+		return Errorf(ExitDeploymentFailure, "%d of %d resources could not be compared with the cluster", failed, len(result.GetResources()))
+	}
+
+	if changed > 0 && cfg.DiffExitCode {
+		return Errorf(ExitDiffChanges, "%d of %d resources would be changed", changed, len(result.GetResources()))
+	}
+
+	return nil
+}
+
+func printDiffJSON(w io.Writer, result *pb.DiffResult) error {
+	data, err := protojson.Marshal(result)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func printDiffText(w io.Writer, result *pb.DiffResult, color bool) error {
+	colorize := func(code, s string) string {
+		if !color {
+			return s
+		}
+		return code + s + colorReset
+	}
+
+	var created, updated, unchanged, failed int
+
+	for _, resource := range result.GetResources() {
+		name := resourceDiffName(resource)
+
+		switch {
+		case len(resource.GetError()) > 0:
+			failed++
+			fmt.Fprintln(w, colorize(colorRed, fmt.Sprintf("! %s: %s", name, resource.GetError())))
+			continue
+		case resource.GetChange() == pb.ResourceChange_unchanged:
+			unchanged++
+			fmt.Fprintf(w, "= %s (unchanged)\n", name)
+			continue
+		case resource.GetChange() == pb.ResourceChange_created:
+			created++
+			fmt.Fprintln(w, colorize(colorBold, fmt.Sprintf("+ %s (created)", name)))
+		default:
+			updated++
+			fmt.Fprintln(w, colorize(colorBold, fmt.Sprintf("~ %s (updated)", name)))
+		}
+
+		for _, line := range strings.Split(strings.TrimSuffix(resource.GetDiff(), "\n"), "\n") {
+			switch {
+			case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+				line = colorize(colorBold, line)
+			case strings.HasPrefix(line, "@@"):
+				line = colorize(colorCyan, line)
+			case strings.HasPrefix(line, "+"):
+				line = colorize(colorGreen, line)
+			case strings.HasPrefix(line, "-"):
+				line = colorize(colorRed, line)
+			}
+			fmt.Fprintln(w, line)
+		}
+		fmt.Fprintln(w)
+	}
+
+	_, err := fmt.Fprintf(w, "%d to create, %d to update, %d unchanged, %d failed.\n", created, updated, unchanged, failed)
+	return err
+}
+
+func resourceDiffName(resource *pb.ResourceDiff) string {
+	if len(resource.GetNamespace()) > 0 {
+		return fmt.Sprintf("%s/%s/%s", resource.GetKind(), resource.GetNamespace(), resource.GetName())
+	}
+	return fmt.Sprintf("%s/%s", resource.GetKind(), resource.GetName())
+}
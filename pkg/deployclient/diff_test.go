@@ -0,0 +1,125 @@
+package deployclient_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/nais/deploy/pkg/deployclient"
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/nais/deploy/pkg/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const configMapDiff = `--- live/ConfigMap/aura/foo
++++ desired/ConfigMap/aura/foo
+@@ -1,2 +1,2 @@
+ data:
+-  key: old
++  key: new
+`
+
+func diffResult(request *pb.DeploymentRequest) *pb.DiffResult {
+	return &pb.DiffResult{
+		Request: request,
+		Resources: []*pb.ResourceDiff{
+			{
+				ApiVersion: "v1",
+				Kind:       "ConfigMap",
+				Namespace:  "aura",
+				Name:       "foo",
+				Change:     pb.ResourceChange_updated,
+				Diff:       configMapDiff,
+			},
+			{
+				ApiVersion: "nais.io/v1alpha1",
+				Kind:       "Application",
+				Namespace:  "aura",
+				Name:       "myapplication",
+				Change:     pb.ResourceChange_unchanged,
+			},
+		},
+	}
+}
+
+func TestDiffText(t *testing.T) {
+	cfg := validConfig()
+	cfg.Command = deployclient.CommandDiff
+	request := makeMockDeployRequest(*cfg)
+	ctx := context.Background()
+	_, _ = telemetry.New(ctx, "test", "")
+
+	client := &pb.MockDeployClient{}
+	client.On("Diff", mock.Anything, request).Return(diffResult(request), nil).Once()
+
+	stdout := &bytes.Buffer{}
+	d := deployclient.Deployer{Client: client, Stdout: stdout}
+	err := d.Diff(ctx, cfg, request)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "~ ConfigMap/aura/foo (updated)\n"+configMapDiff+"\n= Application/aura/myapplication (unchanged)\n0 to create, 1 to update, 1 unchanged, 0 failed.\n", stdout.String())
+}
+
+func TestDiffJSON(t *testing.T) {
+	cfg := validConfig()
+	cfg.Command = deployclient.CommandDiff
+	cfg.Output = deployclient.OutputJSON
+	request := makeMockDeployRequest(*cfg)
+	ctx := context.Background()
+	_, _ = telemetry.New(ctx, "test", "")
+
+	client := &pb.MockDeployClient{}
+	client.On("Diff", mock.Anything, request).Return(diffResult(request), nil).Once()
+
+	stdout := &bytes.Buffer{}
+	d := deployclient.Deployer{Client: client, Stdout: stdout}
+	err := d.Diff(ctx, cfg, request)
+	assert.NoError(t, err)
+
+	decoded := make(map[string]any)
+	err = json.Unmarshal(stdout.Bytes(), &decoded)
+	assert.NoError(t, err)
+	assert.Len(t, decoded["resources"], 2)
+}
+
+func TestDiffExitCode(t *testing.T) {
+	cfg := validConfig()
+	cfg.Command = deployclient.CommandDiff
+	cfg.DiffExitCode = true
+	request := makeMockDeployRequest(*cfg)
+	ctx := context.Background()
+	_, _ = telemetry.New(ctx, "test", "")
+
+	client := &pb.MockDeployClient{}
+	client.On("Diff", mock.Anything, request).Return(diffResult(request), nil).Once()
+
+	d := deployclient.Deployer{Client: client, Stdout: &bytes.Buffer{}}
+	err := d.Diff(ctx, cfg, request)
+
+	assert.Error(t, err)
+	assert.Equal(t, deployclient.ExitDiffChanges, deployclient.ErrorExitCode(err))
+}
+
+func TestDiffResourceError(t *testing.T) {
+	cfg := validConfig()
+	cfg.Command = deployclient.CommandDiff
+	request := makeMockDeployRequest(*cfg)
+	ctx := context.Background()
+	_, _ = telemetry.New(ctx, "test", "")
+
+	result := diffResult(request)
+	result.Resources[1].Error = "admission webhook denied the request"
+
+	client := &pb.MockDeployClient{}
+	client.On("Diff", mock.Anything, request).Return(result, nil).Once()
+
+	stdout := &bytes.Buffer{}
+	d := deployclient.Deployer{Client: client, Stdout: stdout}
+	err := d.Diff(ctx, cfg, request)
+
+	assert.Error(t, err)
+	assert.Equal(t, deployclient.ExitDeploymentFailure, deployclient.ErrorExitCode(err))
+	assert.Contains(t, stdout.String(), "! Application/aura/myapplication: admission webhook denied the request")
+}
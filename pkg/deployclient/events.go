@@ -0,0 +1,102 @@
+package deployclient
+
+import (
+	"encoding/json"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/nais/deploy/pkg/pb"
+)
+
+// EventType identifies what happened in a machine-readable deployment event.
+type EventType string
+
+const (
+	EventAccepted  EventType = "accepted"
+	EventStatus    EventType = "status"
+	EventRetry     EventType = "retry"
+	EventReconnect EventType = "reconnect"
+	EventOutcome   EventType = "outcome"
+)
+
+// Event is written as a single line of JSON to standard output when using `--output=json`.
+// Fields that don't apply to the event type are omitted.
+type Event struct {
+	Type         EventType       `json:"type"`
+	Time         time.Time       `json:"time"`
+	DeploymentID string          `json:"deploymentID,omitempty"`
+	Cluster      string          `json:"cluster,omitempty"`
+	TraceID      string          `json:"traceID,omitempty"`
+	TracingURL   string          `json:"tracingURL,omitempty"`
+	LogsURL      string          `json:"logsURL,omitempty"`
+	State        string          `json:"state,omitempty"`
+	Message      string          `json:"message,omitempty"`
+	Status       json.RawMessage `json:"status,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	ExitCode     *ExitCode       `json:"exitCode,omitempty"`
+}
+
+// emit writes an event to the event output, if any.
+// Errors are logged rather than returned; event output must never cause a deployment to fail.
+func (d *Deployer) emit(event Event) {
+	if d.Events == nil {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		d.logger().Errorf("encode event: %s", err)
+		return
+	}
+
+	// Write the event and its newline at once, so that events from concurrent deployments don't interleave.
+	_, err = d.Events.Write([]byte(RedactSecrets(string(data)) + "\n"))
+	if err != nil {
+		d.logger().Errorf("write event: %s", err)
+	}
+}
+
+func (d *Deployer) emitStatus(status *pb.DeploymentStatus) {
+	if d.Events == nil {
+		return
+	}
+
+	data, err := protojson.Marshal(status)
+	if err != nil {
+		d.logger().Errorf("encode deployment status: %s", err)
+	}
+
+	event := Event{
+		Type:         EventStatus,
+		DeploymentID: status.GetRequest().GetID(),
+		Cluster:      status.GetRequest().GetCluster(),
+		State:        status.GetState().String(),
+		Message:      status.GetMessage(),
+		Status:       data,
+	}
+	if status.GetTime() != nil {
+		event.Time = status.Timestamp()
+	}
+	d.emit(event)
+}
+
+// emitOutcome emits the final outcome of a deployment, and returns the error unchanged.
+func (d *Deployer) emitOutcome(request *pb.DeploymentRequest, err error) error {
+	code := ErrorExitCode(err)
+	event := Event{
+		Type:         EventOutcome,
+		DeploymentID: request.GetID(),
+		Cluster:      request.GetCluster(),
+		ExitCode:     &code,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	d.emit(event)
+	return err
+}
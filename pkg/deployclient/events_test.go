@@ -0,0 +1,111 @@
+package deployclient_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nais/deploy/pkg/deployclient"
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/nais/deploy/pkg/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func readEvents(t *testing.T, buf *bytes.Buffer) []deployclient.Event {
+	events := make([]deployclient.Event, 0)
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		event := deployclient.Event{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &event), scanner.Text())
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestDeployEvents(t *testing.T) {
+	cfg := validConfig()
+	cfg.Retry = true
+	cfg.Wait = true
+	cfg.RetryInterval = time.Millisecond
+	request := makeMockDeployRequest(*cfg)
+	request.ID = "1"
+	ctx := context.Background()
+	_, _ = telemetry.New(ctx, "test", "")
+
+	client := &pb.MockDeployClient{}
+	client.On("Deploy", mock.Anything, request).Return(nil, status.Errorf(codes.Unavailable, "down")).Once()
+	client.On("Deploy", mock.Anything, request).Return(&pb.DeploymentStatus{
+		Request: request,
+		Time:    pb.TimeAsTimestamp(time.Now()),
+		State:   pb.DeploymentState_queued,
+		Message: "queued",
+	}, nil).Once()
+
+	statusClient := &pb.MockDeploy_StatusClient{}
+	client.On("Status", mock.Anything, request).Return(nil, status.Errorf(codes.Unavailable, "down")).Once()
+	client.On("Status", mock.Anything, request).Return(statusClient, nil).Once()
+	statusClient.On("Recv").Return(&pb.DeploymentStatus{
+		Request: request,
+		Time:    pb.TimeAsTimestamp(time.Now()),
+		State:   pb.DeploymentState_failure,
+		Message: "crashloop",
+	}, nil).Once()
+
+	events := &bytes.Buffer{}
+	d := deployclient.Deployer{Client: client, Events: events}
+	err := d.Deploy(ctx, cfg, request)
+	assert.Equal(t, deployclient.ExitDeploymentFailure, deployclient.ErrorExitCode(err))
+
+	output := readEvents(t, events)
+	types := make([]deployclient.EventType, len(output))
+	for i := range output {
+		types[i] = output[i].Type
+	}
+	assert.Equal(t, []deployclient.EventType{
+		deployclient.EventRetry,
+		deployclient.EventAccepted,
+		deployclient.EventRetry,
+		deployclient.EventReconnect,
+		deployclient.EventStatus,
+		deployclient.EventOutcome,
+	}, types)
+
+	accepted := output[1]
+	assert.Equal(t, "1", accepted.DeploymentID)
+	assert.Equal(t, "dev-fss", accepted.Cluster)
+	assert.NotEmpty(t, accepted.TraceID)
+	assert.Contains(t, accepted.LogsURL, "/logs?")
+	assert.Equal(t, "queued", accepted.State)
+
+	assert.Equal(t, "failure", output[4].State)
+	assert.Equal(t, "crashloop", output[4].Message)
+
+	outcome := output[5]
+	assert.Equal(t, "1", outcome.DeploymentID)
+	assert.Equal(t, deployclient.ExitDeploymentFailure, *outcome.ExitCode)
+	assert.Equal(t, "deployment failed", outcome.Error)
+}
+
+func TestDeployWithoutEvents(t *testing.T) {
+	cfg := validConfig()
+	request := makeMockDeployRequest(*cfg)
+	ctx := context.Background()
+	_, _ = telemetry.New(ctx, "test", "")
+
+	client := &pb.MockDeployClient{}
+	client.On("Deploy", mock.Anything, request).Return(&pb.DeploymentStatus{
+		Request: request,
+		State:   pb.DeploymentState_success,
+	}, nil).Once()
+
+	stdout := &bytes.Buffer{}
+	d := deployclient.Deployer{Client: client, Stdout: stdout}
+	assert.NoError(t, d.Deploy(ctx, cfg, request))
+	assert.Empty(t, stdout.String())
+}
@@ -22,6 +22,10 @@ func NewGrpcConnection(cfg Config) (*grpc.ClientConn, error) {
 	}
 
 	if cfg.GrpcAuthentication {
+		// The team may be detected from the resources after the connection is set up,
+		// so every request is authenticated on behalf of its own team.
+		dialOptions = append(dialOptions, grpc.WithChainUnaryInterceptor(auth_interceptor.TeamUnaryClientInterceptor))
+
 		var interceptor auth_interceptor.ClientInterceptor
 		if cfg.GithubToken != "" {
 			interceptor = &auth_interceptor.JWTInterceptor{
@@ -34,11 +38,17 @@ func NewGrpcConnection(cfg Config) (*grpc.ClientConn, error) {
 			if err != nil {
 				return nil, Errorf(ExitInvocationFailure, "%s: %s", ErrMalformedAPIKey, err)
 			}
-			interceptor = &auth_interceptor.APIKeyInterceptor{
+			apiKeyInterceptor := &auth_interceptor.APIKeyInterceptor{
 				APIKey:     decoded,
 				RequireTLS: cfg.GrpcUseTLS,
 				Team:       cfg.Team,
 			}
+			// Requests are signed with both signature versions, so that older servers keep working.
+			dialOptions = append(dialOptions,
+				grpc.WithChainUnaryInterceptor(apiKeyInterceptor.UnaryClientInterceptor),
+				grpc.WithChainStreamInterceptor(apiKeyInterceptor.StreamClientInterceptor),
+			)
+			interceptor = apiKeyInterceptor
 		}
 		dialOptions = append(dialOptions, grpc.WithPerRPCCredentials(interceptor))
 	}
@@ -0,0 +1,154 @@
+package deployclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/nais/deploy/pkg/telemetry"
+	log "github.com/sirupsen/logrus"
+	ocodes "go.opentelemetry.io/otel/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// History lists recent deployments made by a team, and prints them to standard output.
+func (d *Deployer) History(ctx context.Context, cfg *Config) error {
+	var response *pb.ListDeploymentsResponse
+
+	ctx, span := telemetry.Tracer().Start(ctx, "List deployments")
+	defer span.End()
+
+	request, err := MakeListDeploymentsRequest(*cfg, time.Now())
+	if err != nil {
+		return Errorf(ExitInvocationFailure, "%s", err)
+	}
+
+	log.Infof("Listing deployments for team %s...", cfg.Team)
+
+	err = d.retryUnavailable(cfg.RetryInterval, cfg.Retry, func() error {
+		response, err = d.Client.ListDeployments(ctx, request)
+		return err
+	})
+	if err != nil {
+		span.SetStatus(ocodes.Error, err.Error())
+		if ctx.Err() != nil {
+			return Errorf(ExitTimeout, "list deployments timed out: %s", ctx.Err())
+		}
+		return Errorf(ExitUnavailable, formatGrpcError(err))
+	}
+
+	switch cfg.Output {
+	case OutputJSON:
+		err = printHistoryJSON(d.stdout(), response)
+	default:
+		err = printHistoryText(d.stdout(), response)
+	}
+	if err != nil {
+		return Errorf(ExitInternalError, "print history: %s", err)
+	}
+
+	return nil
+}
+
+func MakeListDeploymentsRequest(cfg Config, now time.Time) (*pb.ListDeploymentsRequest, error) {
+	request := &pb.ListDeploymentsRequest{
+		Team:  cfg.Team,
+		Limit: int32(cfg.Limit),
+	}
+
+	if len(cfg.Cluster) > 0 {
+		request.Clusters = cfg.Clusters()
+	}
+
+	if len(cfg.Owner) > 0 && len(cfg.Repository) > 0 {
+		request.Repository = cfg.Owner + "/" + cfg.Repository
+	}
+
+	for _, state := range cfg.States {
+		value, ok := pb.DeploymentState_value[state]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidState, state)
+		}
+		request.States = append(request.States, pb.DeploymentState(value))
+	}
+
+	from, err := parseTimeFilter(cfg.Since, now)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTimeFilter, err)
+	}
+	if !from.IsZero() {
+		request.From = pb.TimeAsTimestamp(from)
+	}
+
+	to, err := parseTimeFilter(cfg.Until, now)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTimeFilter, err)
+	}
+	if !to.IsZero() {
+		request.To = pb.TimeAsTimestamp(to)
+	}
+
+	return request, nil
+}
+
+// parseTimeFilter accepts either an RFC 3339 timestamp, or a duration relative to now.
+// An empty string yields the zero time.
+func parseTimeFilter(value string, now time.Time) (time.Time, error) {
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err == nil {
+		return now.Add(-duration), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func printHistoryJSON(w io.Writer, response *pb.ListDeploymentsResponse) error {
+	data, err := protojson.Marshal(response)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func printHistoryText(w io.Writer, response *pb.ListDeploymentsResponse) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "ID\tCREATED\tCLUSTER\tREPOSITORY\tSTATE\tRESOURCES")
+	for _, deployment := range response.GetDeployments() {
+		request := deployment.GetRequest()
+
+		state := "unknown"
+		if len(deployment.GetStatuses()) > 0 {
+			state = deployment.GetStatuses()[0].GetState().String()
+		}
+
+		repository := request.GetRepository().FullNamePtr()
+		if repository == nil {
+			none := "-"
+			repository = &none
+		}
+
+		resources := make([]string, 0, len(deployment.GetResources()))
+		for _, resource := range deployment.GetResources() {
+			resources = append(resources, resource.GetKind()+"/"+resource.GetName())
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			request.GetID(),
+			request.GetTime().AsTime().Local().Format(time.DateTime),
+			request.GetCluster(),
+			*repository,
+			state,
+			strings.Join(resources, ", "),
+		)
+	}
+
+	return tw.Flush()
+}
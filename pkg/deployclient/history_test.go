@@ -0,0 +1,119 @@
+package deployclient_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nais/deploy/pkg/deployclient"
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/nais/deploy/pkg/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func historyResponse() *pb.ListDeploymentsResponse {
+	return &pb.ListDeploymentsResponse{
+		Deployments: []*pb.DeploymentRecord{
+			{
+				Request: &pb.DeploymentRequest{
+					ID:         testDeploymentID,
+					Time:       pb.TimeAsTimestamp(time.Now()),
+					Cluster:    "dev-fss",
+					Team:       "aura",
+					Repository: &pb.GithubRepository{Owner: "navikt", Name: "myrepo"},
+				},
+				Statuses: []*pb.DeploymentStatus{
+					{State: pb.DeploymentState_success},
+					{State: pb.DeploymentState_in_progress},
+				},
+				Resources: []*pb.DeploymentResource{
+					{Kind: "Application", Name: "myapplication", Namespace: "aura"},
+				},
+			},
+		},
+	}
+}
+
+func TestMakeListDeploymentsRequest(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	cfg := teamConfig(deployclient.CommandHistory)
+	cfg.Cluster = "dev-fss,prod-fss"
+	cfg.Owner = "navikt"
+	cfg.Repository = "myrepo"
+	cfg.States = []string{"success", "failure"}
+	cfg.Since = "24h"
+	cfg.Until = "2024-05-01T11:00:00Z"
+
+	request, err := deployclient.MakeListDeploymentsRequest(*cfg, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "aura", request.GetTeam())
+	assert.Equal(t, []string{"dev-fss", "prod-fss"}, request.GetClusters())
+	assert.Equal(t, "navikt/myrepo", request.GetRepository())
+	assert.Equal(t, []pb.DeploymentState{pb.DeploymentState_success, pb.DeploymentState_failure}, request.GetStates())
+	assert.Equal(t, now.Add(-24*time.Hour), request.GetFrom().AsTime())
+	assert.Equal(t, now.Add(-time.Hour), request.GetTo().AsTime())
+	assert.Equal(t, int32(deployclient.DefaultHistoryLimit), request.GetLimit())
+}
+
+func TestHistoryValidation(t *testing.T) {
+	testTeamValidation(t, deployclient.CommandHistory, []teamValidationCase{
+		{"valid", func(cfg deployclient.Config) deployclient.Config { return cfg }, nil},
+		{"valid without cluster", func(cfg deployclient.Config) deployclient.Config { cfg.Cluster = ""; return cfg }, nil},
+		{"invalid state", func(cfg deployclient.Config) deployclient.Config { cfg.States = []string{"exploded"}; return cfg }, deployclient.ErrInvalidState},
+		{"invalid time filter", func(cfg deployclient.Config) deployclient.Config { cfg.Since = "yesterday"; return cfg }, deployclient.ErrInvalidTimeFilter},
+		{"team required", func(cfg deployclient.Config) deployclient.Config { cfg.Team = ""; return cfg }, deployclient.ErrTeamRequired},
+	})
+}
+
+func TestHistory(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		output string
+		assert func(t *testing.T, stdout []byte)
+	}{
+		{
+			name:   "text",
+			output: deployclient.OutputText,
+			assert: func(t *testing.T, stdout []byte) {
+				assert.Contains(t, string(stdout), "ID")
+				assert.Contains(t, string(stdout), testDeploymentID)
+				assert.Contains(t, string(stdout), "navikt/myrepo")
+				assert.Contains(t, string(stdout), "success")
+				assert.Contains(t, string(stdout), "Application/myapplication")
+			},
+		},
+		{
+			name:   "json",
+			output: deployclient.OutputJSON,
+			assert: func(t *testing.T, stdout []byte) {
+				decoded := make(map[string]any)
+				assert.NoError(t, json.Unmarshal(stdout, &decoded))
+				assert.Len(t, decoded["deployments"], 1)
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := teamConfig(deployclient.CommandHistory)
+			cfg.Output = test.output
+			ctx := context.Background()
+			_, _ = telemetry.New(ctx, "test", "")
+
+			client := &pb.MockDeployClient{}
+			client.On("ListDeployments", mock.Anything, mock.MatchedBy(func(req *pb.ListDeploymentsRequest) bool {
+				return req.GetTeam() == cfg.Team
+			})).Return(historyResponse(), nil).Once()
+
+			stdout := &bytes.Buffer{}
+			d := deployclient.Deployer{Client: client, Stdout: stdout}
+			err := d.History(ctx, cfg)
+
+			assert.NoError(t, err)
+			test.assert(t, stdout.Bytes())
+			client.AssertExpectations(t)
+		})
+	}
+}
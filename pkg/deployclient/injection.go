@@ -42,7 +42,8 @@ func InjectAnnotations(resource json.RawMessage, annotations map[string]string)
 	return json.Marshal(decoded)
 }
 
-// https://docs.github.com/en/actions/reference/environment-variables#default-environment-variables
+// BuildEnvironmentAnnotations describes the CI pipeline run that made the deployment.
+// The provider specific GitHub and Jenkins annotations are kept for backwards compatibility.
 func BuildEnvironmentAnnotations() map[string]string {
 	a := make(map[string]string)
 
@@ -70,56 +71,24 @@ func BuildEnvironmentAnnotations() map[string]string {
 	)
 
 	a[DeployClientVersion] = version.Version()
-	runurl := githubWorkflowRunURL()
+	runurl := githubWorkflowRunURL(os.LookupEnv)
 	if len(runurl) > 0 {
 		a[GithubWorkflowRunURL] = runurl
 	}
 
-	cause := changeCause(a)
-	if len(cause) > 0 {
-		a["kubernetes.io/change-cause"] = cause
-	}
-
-	return a
-}
-
-func changeCause(annotations map[string]string) string {
-	var commit, url string
-	var ok bool
-
-	for _, key := range []string{"deploy.nais.io/github-sha", "deploy.nais.io/git-commit"} {
-		commit, ok = annotations[key]
-		if ok {
-			break
-		}
+	ci := DetectCI(os.LookupEnv)
+	if ci == nil {
+		return a
 	}
 
-	for _, key := range []string{GithubWorkflowRunURL, "deploy.nais.io/build-url"} {
-		url, ok = annotations[key]
-		if ok {
-			break
-		}
+	for k, v := range ci.Annotations() {
+		a[k] = v
 	}
 
-	if len(commit) == 0 || len(url) == 0 {
-		return ""
+	cause := ci.ChangeCause()
+	if len(cause) > 0 {
+		a["kubernetes.io/change-cause"] = cause
 	}
 
-	return fmt.Sprintf("nais deploy: commit %s: %s", commit, url)
-}
-
-func githubWorkflowRunURL() string {
-	server, ok := os.LookupEnv("GITHUB_SERVER_URL")
-	if !ok {
-		return ""
-	}
-	repo, ok := os.LookupEnv("GITHUB_REPOSITORY")
-	if !ok {
-		return ""
-	}
-	runid, ok := os.LookupEnv("GITHUB_RUN_ID")
-	if !ok {
-		return ""
-	}
-	return fmt.Sprintf("%s/%s/actions/runs/%s", server, repo, runid)
+	return a
 }
@@ -11,7 +11,7 @@ import (
 )
 
 func TestInjectAnnotations(t *testing.T) {
-	docs, err := deployclient.MultiDocumentFileAsJSON("testdata/nais.yaml", nil)
+	docs, err := deployclient.MultiDocumentFileAsJSON("testdata/nais.yaml", templateEngine(t, deployclient.TemplateEngineHandlebars), nil)
 	assert.NoError(t, err)
 	assert.Len(t, docs, 1)
 
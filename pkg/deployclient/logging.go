@@ -2,6 +2,7 @@ package deployclient
 
 import (
 	"bytes"
+	"fmt"
 	"os"
 	"time"
 
@@ -9,10 +10,18 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// Log fields with the location of an error in a resource file.
+// When present, GitHub Actions annotates the error in the file.
+const (
+	LogFieldFile = "file"
+	LogFieldLine = "line"
+)
+
 type ActionsFormatter struct{}
 
 func SetupLogging(cfg Config) {
 	log.SetOutput(os.Stderr)
+	log.AddHook(secrets)
 
 	if cfg.Actions {
 		log.SetFormatter(&ActionsFormatter{})
@@ -33,9 +42,13 @@ func (a *ActionsFormatter) Format(e *log.Entry) ([]byte, error) {
 	buf := &bytes.Buffer{}
 	switch e.Level {
 	case log.ErrorLevel:
-		buf.WriteString("::error::")
+		buf.WriteString("::error")
+		writeActionsLocation(buf, e.Data)
+		buf.WriteString("::")
 	case log.WarnLevel:
-		buf.WriteString("::warn::")
+		buf.WriteString("::warn")
+		writeActionsLocation(buf, e.Data)
+		buf.WriteString("::")
 	default:
 		buf.WriteString("[")
 		buf.WriteString(e.Time.Format(time.RFC3339Nano))
@@ -46,11 +59,29 @@ func (a *ActionsFormatter) Format(e *log.Entry) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func logDeployStatus(status *pb.DeploymentStatus) {
-	fn := log.Infof
+// writeActionsLocation writes the file and line parameters of a workflow command, if the entry has them.
+func writeActionsLocation(buf *bytes.Buffer, fields log.Fields) {
+	file, ok := fields[LogFieldFile]
+	if !ok {
+		return
+	}
+	fmt.Fprintf(buf, " file=%v", file)
+	if line, ok := fields[LogFieldLine]; ok {
+		fmt.Fprintf(buf, ",line=%v", line)
+	}
+}
+
+// logDeployStatus logs a status update, and emits it as an event.
+func (d *Deployer) logDeployStatus(status *pb.DeploymentStatus) {
+	d.emitStatus(status)
+
+	logger := d.logger()
+	fn := logger.Infof
 	switch status.GetState() {
 	case pb.DeploymentState_failure, pb.DeploymentState_error:
-		fn = log.Errorf
+		fn = logger.Errorf
+	case pb.DeploymentState_cancelled:
+		fn = logger.Warnf
 	}
 	fn("Status: %s: %s", status.GetState(), status.GetMessage())
 }
@@ -0,0 +1,171 @@
+package deployclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/nais/deploy/pkg/pb"
+)
+
+// ClusterResult is the outcome of deploying to a single cluster as part of a multi-cluster deployment.
+type ClusterResult struct {
+	Cluster string
+	Err     error
+	Skipped bool
+}
+
+// DeployClusters deploys the same resources to every cluster in the configuration.
+//
+// Resources are templated for every cluster before any request is sent, so that a templating error never
+// results in a partial deployment. Requests are sent concurrently, unless sequential deployment is requested,
+// in which case the first failed cluster stops the remaining ones.
+//
+// The exit code is that of the first failed cluster, in the order the clusters were given.
+func (d *Deployer) DeployClusters(ctx context.Context, cfg *Config) error {
+	files, err := ReadResourceFiles(cfg.Resource, os.Stdin)
+	if err != nil {
+		return ErrorWrap(ExitInvocationFailure, err)
+	}
+
+	clusters := cfg.Clusters()
+	configs := make([]*Config, len(clusters))
+	requests := make([]*pb.DeploymentRequest, len(clusters))
+
+	for i, cluster := range clusters {
+		clusterConfig := *cfg
+		clusterConfig.Cluster = cluster
+
+		request, err := PrepareResources(ctx, &clusterConfig, files)
+		if err != nil {
+			return ErrorWrap(ErrorExitCode(err), fmt.Errorf("cluster '%s': %w", cluster, err))
+		}
+
+		if cfg.PrintPayload {
+			d.PrintPayload(request)
+		}
+
+		configs[i] = &clusterConfig
+		requests[i] = request
+	}
+
+	if cfg.ClientDryRun() {
+		return nil
+	}
+
+	// Every cluster gets its own logger, but they all share the same output.
+	output := &lockedWriter{w: log.StandardLogger().Out}
+	var events io.Writer
+	if d.Events != nil {
+		events = &lockedWriter{w: d.Events}
+	}
+
+	results := make([]ClusterResult, len(clusters))
+	deploy := func(i int) {
+		deployer := &Deployer{
+			Client: d.Client,
+			Stdout: d.Stdout,
+			Log:    prefixLogger(log.StandardLogger(), output, fmt.Sprintf("[%s] ", clusters[i])),
+			Events: events,
+		}
+		results[i] = ClusterResult{
+			Cluster: clusters[i],
+			Err:     deployer.Deploy(ctx, configs[i], requests[i]),
+		}
+	}
+
+	if cfg.Sequential {
+		for i := range clusters {
+			deploy(i)
+			if results[i].Err != nil {
+				for j := i + 1; j < len(clusters); j++ {
+					results[j] = ClusterResult{Cluster: clusters[j], Skipped: true}
+				}
+				break
+			}
+		}
+	} else {
+		wg := &sync.WaitGroup{}
+		for i := range clusters {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				deploy(i)
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	return d.clusterResults(results)
+}
+
+// clusterResults logs the outcome of each cluster, and returns an error if any of them failed.
+func (d *Deployer) clusterResults(results []ClusterResult) error {
+	logger := d.logger()
+
+	var firstErr error
+	failed := 0
+
+	logger.Infof("Deployment results:")
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			logger.Warnf("%s: skipped", result.Cluster)
+		case result.Err != nil:
+			logger.Errorf("%s: %s", result.Cluster, result.Err)
+			failed++
+			if firstErr == nil {
+				firstErr = result.Err
+			}
+		default:
+			logger.Infof("%s: ok", result.Cluster)
+		}
+	}
+
+	if firstErr == nil {
+		return nil
+	}
+
+	return Errorf(ErrorExitCode(firstErr), "deployment failed in %d of %d clusters", failed, len(results))
+}
+
+// prefixFormatter prepends a prefix to every log message.
+type prefixFormatter struct {
+	prefix    string
+	formatter log.Formatter
+}
+
+func (f *prefixFormatter) Format(e *log.Entry) ([]byte, error) {
+	entry := *e
+	entry.Message = f.prefix + e.Message
+	return f.formatter.Format(&entry)
+}
+
+// prefixLogger returns a logger with the same format and level as base, where every message is prefixed.
+func prefixLogger(base *log.Logger, out io.Writer, prefix string) *log.Logger {
+	logger := log.New()
+	logger.SetOutput(out)
+	logger.SetLevel(base.GetLevel())
+	logger.ReplaceHooks(base.Hooks)
+	logger.SetFormatter(&prefixFormatter{
+		prefix:    prefix,
+		formatter: base.Formatter,
+	})
+	return logger
+}
+
+// lockedWriter serializes writes from several loggers to the same output.
+type lockedWriter struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.w.Write(p)
+}
@@ -0,0 +1,164 @@
+package deployclient_test
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nais/deploy/pkg/deployclient"
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/nais/deploy/pkg/telemetry"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+)
+
+func clusterRequest(cluster string) interface{} {
+	return mock.MatchedBy(func(req *pb.DeploymentRequest) bool {
+		return req.GetCluster() == cluster
+	})
+}
+
+func clusterStatus(state pb.DeploymentState) func(context.Context, *pb.DeploymentRequest, ...grpc.CallOption) *pb.DeploymentStatus {
+	return func(_ context.Context, req *pb.DeploymentRequest, _ ...grpc.CallOption) *pb.DeploymentStatus {
+		return &pb.DeploymentStatus{
+			Request: req,
+			Time:    pb.TimeAsTimestamp(time.Now()),
+			State:   state,
+		}
+	}
+}
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	out := log.StandardLogger().Out
+	log.SetOutput(buf)
+	t.Cleanup(func() {
+		log.SetOutput(out)
+	})
+	return buf
+}
+
+func TestDeployClusters(t *testing.T) {
+	cfg := validConfig()
+	cfg.Cluster = "dev-fss,dev-gcp, prod-gcp"
+	logs := captureLogs(t)
+
+	client := &pb.MockDeployClient{}
+	client.On("Deploy", mock.Anything, clusterRequest("dev-fss")).Return(clusterStatus(pb.DeploymentState_success), nil).Once()
+	client.On("Deploy", mock.Anything, clusterRequest("dev-gcp")).Return(clusterStatus(pb.DeploymentState_failure), nil).Once()
+	client.On("Deploy", mock.Anything, clusterRequest("prod-gcp")).Return(clusterStatus(pb.DeploymentState_error), nil).Once()
+
+	ctx := context.Background()
+	_, _ = telemetry.New(ctx, "test", "")
+
+	d := deployclient.Deployer{Client: client}
+	err := d.DeployClusters(ctx, cfg)
+
+	assert.Equal(t, deployclient.ExitDeploymentFailure, deployclient.ErrorExitCode(err))
+	assert.ErrorContains(t, err, "deployment failed in 2 of 3 clusters")
+	client.AssertExpectations(t)
+
+	assert.Contains(t, logs.String(), "[dev-fss] Deployment request accepted by NAIS deploy and dispatched to cluster 'dev-fss'.")
+	assert.Contains(t, logs.String(), "[dev-gcp] Status: failure")
+	assert.Contains(t, logs.String(), "[prod-gcp] Status: error")
+}
+
+func TestDeployClustersSequential(t *testing.T) {
+	cfg := validConfig()
+	cfg.Cluster = "dev-gcp,dev-fss,prod-gcp"
+	cfg.Sequential = true
+
+	client := &pb.MockDeployClient{}
+	client.On("Deploy", mock.Anything, clusterRequest("dev-gcp")).Return(clusterStatus(pb.DeploymentState_success), nil).Once()
+	client.On("Deploy", mock.Anything, clusterRequest("dev-fss")).Return(clusterStatus(pb.DeploymentState_error), nil).Once()
+
+	ctx := context.Background()
+	_, _ = telemetry.New(ctx, "test", "")
+
+	d := deployclient.Deployer{Client: client}
+	err := d.DeployClusters(ctx, cfg)
+
+	assert.Equal(t, deployclient.ExitDeploymentError, deployclient.ErrorExitCode(err))
+	client.AssertExpectations(t)
+	client.AssertNotCalled(t, "Deploy", mock.Anything, clusterRequest("prod-gcp"))
+}
+
+func TestDeployClustersSuccess(t *testing.T) {
+	cfg := validConfig()
+	cfg.Cluster = "dev-fss,dev-gcp"
+
+	client := &pb.MockDeployClient{}
+	client.On("Deploy", mock.Anything, mock.Anything).Return(clusterStatus(pb.DeploymentState_success), nil).Twice()
+
+	ctx := context.Background()
+	_, _ = telemetry.New(ctx, "test", "")
+
+	d := deployclient.Deployer{Client: client}
+	err := d.DeployClusters(ctx, cfg)
+
+	assert.NoError(t, err)
+	client.AssertExpectations(t)
+}
+
+func TestDeployClustersDryRun(t *testing.T) {
+	cfg := validConfig()
+	cfg.Cluster = "dev-fss,dev-gcp"
+	cfg.Resource = []string{writeTemplate(t, "resource.yaml", "metadata:\n  namespace: aura\nhost: {{host}}\n")}
+	cfg.VariablesFiles = []string{writeTemplate(t, "vars.yaml", "host: default.nav.no\n")}
+	cfg.ClusterVariablesFiles = map[string]string{
+		"dev-gcp": writeTemplate(t, "dev-gcp.yaml", "host: dev-gcp.nav.no\n"),
+	}
+	cfg.DryRun = deployclient.DryRunClient
+	cfg.PrintPayload = true
+
+	stdout := &bytes.Buffer{}
+	client := &pb.MockDeployClient{}
+	d := deployclient.Deployer{Client: client, Stdout: stdout}
+	err := d.DeployClusters(context.Background(), cfg)
+
+	assert.NoError(t, err)
+	client.AssertNotCalled(t, "Deploy", mock.Anything, mock.Anything)
+
+	// protojson randomizes whitespace, so match the cluster field with a pattern.
+	payloads := stdout.String()
+	assert.Len(t, regexp.MustCompile(`"cluster":\s+"dev-fss"`).FindAllString(payloads, -1), 1)
+	assert.Len(t, regexp.MustCompile(`"cluster":\s+"dev-gcp"`).FindAllString(payloads, -1), 1)
+	assert.Equal(t, 1, strings.Count(payloads, "default.nav.no"))
+	assert.Equal(t, 1, strings.Count(payloads, "dev-gcp.nav.no"))
+}
+
+func TestDeployClustersTemplateError(t *testing.T) {
+	cfg := validConfig()
+	cfg.Cluster = "dev-fss,dev-gcp"
+	cfg.Resource = []string{writeTemplate(t, "resource.yaml", "foo: {{#if bar}}")}
+	cfg.Variables = []string{"bar=baz"}
+
+	client := &pb.MockDeployClient{}
+	ctx := context.Background()
+	_, _ = telemetry.New(ctx, "test", "")
+
+	d := deployclient.Deployer{Client: client}
+	err := d.DeployClusters(ctx, cfg)
+
+	assert.Equal(t, deployclient.ExitTemplateError, deployclient.ErrorExitCode(err))
+	assert.ErrorContains(t, err, "cluster 'dev-fss'")
+	client.AssertNotCalled(t, "Deploy", mock.Anything, mock.Anything)
+}
+
+func TestSingleClusterCommands(t *testing.T) {
+	for _, command := range []string{deployclient.CommandCancel, deployclient.CommandDiff, deployclient.CommandRollback, deployclient.CommandStatus} {
+		cfg := validConfig()
+		cfg.Command = command
+		cfg.Cluster = "dev-fss,dev-gcp"
+		assert.ErrorIs(t, cfg.Validate(), deployclient.ErrSingleClusterRequired, command)
+	}
+
+	cfg := validConfig()
+	cfg.Cluster = "dev-fss,dev-gcp"
+	assert.NoError(t, cfg.Validate())
+}
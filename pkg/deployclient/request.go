@@ -10,6 +10,7 @@ func MakeDeploymentRequest(cfg Config, deadline time.Time, kubernetes *pb.Kubern
 	return &pb.DeploymentRequest{
 		Cluster:           cfg.Cluster,
 		Deadline:          pb.TimeAsTimestamp(deadline),
+		DryRun:            cfg.ServerDryRun(),
 		GitRefSha:         cfg.Ref,
 		GithubEnvironment: cfg.Environment,
 		Kubernetes:        kubernetes,
@@ -0,0 +1,141 @@
+package deployclient
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	// StdinResource is the resource path that reads resources from standard input.
+	StdinResource = "-"
+	stdinName     = "(stdin)"
+)
+
+// ResourceFile is the contents of a resource file, before templating.
+type ResourceFile struct {
+	Path string
+	Data []byte
+}
+
+// ReadResourceFiles reads all resource files given on the command line.
+//
+// Directories are searched recursively for YAML and JSON files, in lexical order.
+// A directory containing a kustomization file is built with kustomize instead,
+// and the build output is returned as a single multi-document file.
+// The path "-" reads resources from stdin.
+func ReadResourceFiles(paths []string, stdin io.Reader) ([]ResourceFile, error) {
+	files := make([]ResourceFile, 0, len(paths))
+	stdinRead := false
+
+	for _, path := range paths {
+		if path == StdinResource {
+			if stdinRead {
+				return nil, fmt.Errorf("standard input can only be used as a resource once")
+			}
+			stdinRead = true
+			data, err := io.ReadAll(stdin)
+			if err != nil {
+				return nil, fmt.Errorf("%s: read: %s", stdinName, err)
+			}
+			files = append(files, ResourceFile{Path: stdinName, Data: data})
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: open file: %s", path, err)
+		}
+
+		if !info.IsDir() {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("%s: open file: %s", path, err)
+			}
+			files = append(files, ResourceFile{Path: path, Data: data})
+			continue
+		}
+
+		dirFiles, err := readResourceDirectory(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(dirFiles) == 0 {
+			return nil, fmt.Errorf("%s: no resource files found in directory", path)
+		}
+		files = append(files, dirFiles...)
+	}
+
+	return files, nil
+}
+
+func readResourceDirectory(root string) ([]ResourceFile, error) {
+	files := make([]ResourceFile, 0)
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if !hasKustomization(path) {
+				return nil
+			}
+			data, err := kustomizeBuild(path)
+			if err != nil {
+				return err
+			}
+			files = append(files, ResourceFile{Path: path, Data: data})
+			return filepath.SkipDir
+		}
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: open file: %s", path, err)
+		}
+		files = append(files, ResourceFile{Path: path, Data: data})
+
+		return nil
+	})
+
+	return files, err
+}
+
+func hasKustomization(dir string) bool {
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err == nil && !info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// kustomizeBuild renders a kustomization, equivalent to running `kustomize build`.
+func kustomizeBuild(dir string) ([]byte, error) {
+	kustomizer := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	resources, err := kustomizer.Run(filesys.MakeFsOnDisk(), dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: kustomize build: %s", dir, err)
+	}
+
+	data, err := resources.AsYaml()
+	if err != nil {
+		return nil, fmt.Errorf("%s: kustomize build: %s", dir, err)
+	}
+
+	return data, nil
+}
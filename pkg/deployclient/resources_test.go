@@ -0,0 +1,87 @@
+package deployclient_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nais/deploy/pkg/deployclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func resourceFilePaths(files []deployclient.ResourceFile) []string {
+	paths := make([]string, len(files))
+	for i := range files {
+		paths[i] = files[i].Path
+	}
+	return paths
+}
+
+func TestReadResourceFilesDirectory(t *testing.T) {
+	files, err := deployclient.ReadResourceFiles([]string{"testdata/directory", "testdata/nais.yaml"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"testdata/directory/application.yaml",
+		"testdata/directory/nested/alert.json",
+		"testdata/directory/nested/topic.yml",
+		"testdata/nais.yaml",
+	}, resourceFilePaths(files))
+}
+
+func TestReadResourceFilesKustomize(t *testing.T) {
+	engine := templateEngine(t, deployclient.TemplateEngineHandlebars)
+	vars := deployclient.TemplateVariables{"image": "ghcr.io/navikt/myapp:1"}
+
+	// Nested kustomizations are built as a whole, and not searched for other resource files.
+	for _, path := range []string{"testdata/kustomize/overlays/prod", "testdata/kustomize/overlays"} {
+		t.Run(path, func(t *testing.T) {
+			files, err := deployclient.ReadResourceFiles([]string{path}, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"testdata/kustomize/overlays/prod"}, resourceFilePaths(files))
+
+			docs, err := deployclient.MultiDocumentAsJSON(files[0], engine, vars)
+			assert.NoError(t, err)
+			assert.Len(t, docs, 1)
+			assert.JSONEq(t, `{
+				"apiVersion": "nais.io/v1alpha1",
+				"kind": "Application",
+				"metadata": {"name": "myapp", "namespace": "aura"},
+				"spec": {"image": "ghcr.io/navikt/myapp:1", "replicas": {"min": 4}}
+			}`, string(docs[0]))
+		})
+	}
+}
+
+func TestReadResourceFilesStdin(t *testing.T) {
+	stdin := strings.NewReader("kind: Application\n")
+
+	files, err := deployclient.ReadResourceFiles([]string{"testdata/nais.yaml", deployclient.StdinResource}, stdin)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	assert.Equal(t, "(stdin)", files[1].Path)
+	assert.Equal(t, "kind: Application\n", string(files[1].Data))
+
+	_, err = deployclient.ReadResourceFiles([]string{deployclient.StdinResource, deployclient.StdinResource}, stdin)
+	assert.Error(t, err)
+}
+
+func TestReadResourceFilesErrors(t *testing.T) {
+	_, err := deployclient.ReadResourceFiles([]string{"testdata/nonexistent.yaml"}, nil)
+	assert.ErrorContains(t, err, "testdata/nonexistent.yaml")
+
+	_, err = deployclient.ReadResourceFiles([]string{t.TempDir()}, nil)
+	assert.ErrorContains(t, err, "no resource files found")
+}
+
+func TestPrepareDirectory(t *testing.T) {
+	cfg := validConfig()
+	cfg.Resource = []string{"testdata/directory"}
+	cfg.Variables = []string{"image=ghcr.io/navikt/myapp:1"}
+
+	request, err := deployclient.Prepare(context.Background(), cfg)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "aura", request.Team, "auto-detection of team works")
+	assert.Equal(t, "dev-fss:aura", request.GithubEnvironment, "auto-detection of environment works")
+	assert.Len(t, request.GetKubernetes().GetResources(), 3)
+}
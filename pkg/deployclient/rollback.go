@@ -0,0 +1,80 @@
+package deployclient
+
+import (
+	"context"
+
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/nais/deploy/pkg/telemetry"
+	log "github.com/sirupsen/logrus"
+	ocodes "go.opentelemetry.io/otel/codes"
+)
+
+// Rollback asks NAIS deploy to dispatch the resources of a previous deployment as a new deployment.
+// Unless a specific deployment is given, the last successful deployment of the repository is used.
+func (d *Deployer) Rollback(ctx context.Context, cfg *Config) error {
+	var deployStatus *pb.DeploymentStatus
+	var err error
+
+	ctx, span := telemetry.Tracer().Start(ctx, "Send rollback request")
+	defer span.End()
+
+	request := MakeRollbackRequest(*cfg)
+	deadline, ok := ctx.Deadline()
+	if ok {
+		request.Deadline = pb.TimeAsTimestamp(deadline)
+	}
+	request.TraceParent = telemetry.TraceParentHeader(ctx)
+
+	if len(cfg.RollbackTo) > 0 {
+		log.Infof("Requesting rollback to deployment %s...", cfg.RollbackTo)
+	} else {
+		log.Infof("Requesting rollback to the last successful deployment of %s...", request.GetRepository().FullName())
+	}
+
+	err = d.retryUnavailable(cfg.RetryInterval, cfg.Retry, func() error {
+		deployStatus, err = d.Client.Rollback(ctx, request)
+		return err
+	})
+	if err != nil {
+		span.SetStatus(ocodes.Error, err.Error())
+		if ctx.Err() != nil {
+			err = Errorf(ExitTimeout, "rollback timed out: %s", ctx.Err())
+		} else {
+			err = Errorf(ExitNoDeployment, formatGrpcError(err))
+		}
+		return d.emitOutcome(&pb.DeploymentRequest{Cluster: cfg.Cluster}, err)
+	}
+
+	log.Infof("Rolling back to deployment %s", deployStatus.GetRequest().GetRollbackOf())
+
+	traceID := telemetry.TraceID(ctx)
+	d.emit(Event{
+		Type:         EventAccepted,
+		DeploymentID: deployStatus.GetRequest().GetID(),
+		Cluster:      deployStatus.GetRequest().GetCluster(),
+		TraceID:      traceID,
+		TracingURL:   cfg.TracingDashboardURL + traceID,
+		LogsURL:      logsURL(cfg, deployStatus.GetRequest()),
+		State:        deployStatus.GetState().String(),
+		Message:      deployStatus.GetMessage(),
+	})
+
+	// Sending the rollback request again would create yet another deployment, so don't.
+	err = d.follow(ctx, cfg, deployStatus.GetRequest(), deployStatus, nil)
+	return d.emitOutcome(deployStatus.GetRequest(), err)
+}
+
+func MakeRollbackRequest(cfg Config) *pb.RollbackRequest {
+	request := &pb.RollbackRequest{
+		Team:         cfg.Team,
+		Cluster:      cfg.Cluster,
+		DeploymentID: cfg.RollbackTo,
+	}
+	if len(cfg.Owner) > 0 && len(cfg.Repository) > 0 {
+		request.Repository = &pb.GithubRepository{
+			Owner: cfg.Owner,
+			Name:  cfg.Repository,
+		}
+	}
+	return request
+}
@@ -0,0 +1,92 @@
+package deployclient_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nais/deploy/pkg/deployclient"
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/nais/deploy/pkg/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRollback(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		rollbackTo string
+		err        error
+		exitCode   deployclient.ExitCode
+	}{
+		{"latest deployment of repository", "", nil, deployclient.ExitSuccess},
+		{"given deployment", "old", nil, deployclient.ExitSuccess},
+		{"rollback not possible", "old", status.Errorf(codes.FailedPrecondition, "deployment 'old' has no stored payload"), deployclient.ExitNoDeployment},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := teamConfig(deployclient.CommandRollback)
+			cfg.RollbackTo = test.rollbackTo
+			cfg.Wait = true
+			ctx := context.Background()
+			_, _ = telemetry.New(ctx, "test", "")
+
+			newRequest := &pb.DeploymentRequest{
+				ID:         "new",
+				Team:       cfg.Team,
+				Cluster:    cfg.Cluster,
+				RollbackOf: "old",
+			}
+
+			client := &pb.MockDeployClient{}
+			rollback := client.On("Rollback", mock.Anything, mock.MatchedBy(func(req *pb.RollbackRequest) bool {
+				return req.GetTeam() == cfg.Team &&
+					req.GetCluster() == cfg.Cluster &&
+					req.GetRepository().FullName() == "navikt/myrepo" &&
+					req.GetDeploymentID() == test.rollbackTo
+			})).Once()
+
+			if test.err != nil {
+				rollback.Return(nil, test.err)
+			} else {
+				rollback.Return(&pb.DeploymentStatus{
+					Request: newRequest,
+					Time:    pb.TimeAsTimestamp(time.Now()),
+					State:   pb.DeploymentState_queued,
+				}, nil)
+
+				statusClient := &pb.MockDeploy_StatusClient{}
+				statusClient.On("Recv").Return(&pb.DeploymentStatus{
+					Request: newRequest,
+					Time:    pb.TimeAsTimestamp(time.Now()),
+					State:   pb.DeploymentState_success,
+				}, nil).Once()
+				client.On("Status", mock.Anything, newRequest).Return(statusClient, nil).Once()
+			}
+
+			d := deployclient.Deployer{Client: client}
+			err := d.Rollback(ctx, cfg)
+
+			assert.Equal(t, test.exitCode, deployclient.ErrorExitCode(err))
+			client.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRollbackValidation(t *testing.T) {
+	testTeamValidation(t, deployclient.CommandRollback, []teamValidationCase{
+		{"valid", func(cfg deployclient.Config) deployclient.Config { return cfg }, nil},
+		{"rollback target required", func(cfg deployclient.Config) deployclient.Config { cfg.Repository = ""; return cfg }, deployclient.ErrRollbackTargetRequired},
+		{"valid without repository", func(cfg deployclient.Config) deployclient.Config {
+			cfg.Repository = ""
+			cfg.RollbackTo = "old"
+			return cfg
+		}, nil},
+		{"cluster required", func(cfg deployclient.Config) deployclient.Config {
+			cfg.RollbackTo = "old"
+			cfg.Cluster = ""
+			return cfg
+		}, deployclient.ErrClusterRequired},
+	})
+}
@@ -0,0 +1,172 @@
+package deployclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// SecretVariablePrefix marks a template variable given with `--var` as sensitive, e.g. `--var secret:password=hunter2`.
+	SecretVariablePrefix = "secret:"
+
+	// SecretVariablesSection is a section of the template variables file with sensitive variables.
+	// Its variables are available to templates as e.g. `{{secrets.password}}`.
+	SecretVariablesSection = "secrets"
+
+	redactedValue = "***"
+)
+
+// secretMask redacts the values of sensitive template variables from output.
+type secretMask struct {
+	lock     sync.RWMutex
+	values   map[string]struct{}
+	replacer *strings.Replacer
+}
+
+// Secret template variables are collected from several clusters and files, so they are kept in one place.
+var secrets = &secretMask{}
+
+// add registers a secret value, and returns true if it was not already known.
+func (m *secretMask) add(value string) bool {
+	if len(value) == 0 {
+		return false
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.values == nil {
+		m.values = make(map[string]struct{})
+	}
+	if _, ok := m.values[value]; ok {
+		return false
+	}
+	m.values[value] = struct{}{}
+
+	// Payloads and events are printed as JSON, where quotes, backslashes and line breaks in a secret are escaped.
+	for _, escaped := range jsonEscaped(value) {
+		m.values[escaped] = struct{}{}
+	}
+
+	// Replace longer values first, in case one secret contains another.
+	values := make([]string, 0, len(m.values))
+	for v := range m.values {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+	pairs := make([]string, 0, len(values)*2)
+	for _, v := range values {
+		pairs = append(pairs, v, redactedValue)
+	}
+	m.replacer = strings.NewReplacer(pairs...)
+
+	return true
+}
+
+// jsonEscaped returns a value the way it is written inside a JSON string, both with and without HTML characters escaped.
+func jsonEscaped(value string) []string {
+	escaped := make([]string, 0, 2)
+	for _, escapeHTML := range []bool{true, false} {
+		buf := &bytes.Buffer{}
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(escapeHTML)
+		if enc.Encode(value) != nil {
+			continue
+		}
+		quoted := strings.TrimSuffix(buf.String(), "\n")
+		escaped = append(escaped, quoted[1:len(quoted)-1])
+	}
+	return escaped
+}
+
+// Redact replaces every known secret value in text.
+func (m *secretMask) Redact(text string) string {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if m.replacer == nil {
+		return text
+	}
+	return m.replacer.Replace(text)
+}
+
+func (m *secretMask) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire redacts secrets from log messages before they are formatted.
+func (m *secretMask) Fire(e *log.Entry) error {
+	e.Message = m.Redact(e.Message)
+	for key, value := range e.Data {
+		if s, ok := value.(string); ok {
+			e.Data[key] = m.Redact(s)
+		}
+	}
+	return nil
+}
+
+// RedactSecrets replaces the values of sensitive template variables in text.
+func RedactSecrets(text string) string {
+	return secrets.Redact(text)
+}
+
+// registerSecret makes sure a value is never printed.
+// When running in GitHub Actions, the runner is asked to mask the value as well.
+func registerSecret(cfg *Config, value interface{}) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case float64, int, int64:
+		s = fmt.Sprint(v)
+	default:
+		return
+	}
+
+	if !secrets.add(s) || !cfg.Actions {
+		return
+	}
+
+	writeActionsMask(log.StandardLogger().Out, s)
+}
+
+// writeActionsMask registers a value with the GitHub Actions runner, which masks it in the job log.
+// Workflow commands are single lines, so each line of a multi-line value is masked separately.
+func writeActionsMask(w io.Writer, value string) {
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if len(line) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "::add-mask::%s\n", strings.ReplaceAll(line, "%", "%25"))
+	}
+}
+
+// registerSecretSection registers every value in the secrets section of a template variables file.
+func registerSecretSection(cfg *Config, vars TemplateVariables) {
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		default:
+			registerSecret(cfg, v)
+		}
+	}
+	walk(vars[SecretVariablesSection])
+}
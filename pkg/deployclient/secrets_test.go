@@ -0,0 +1,104 @@
+package deployclient_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/nais/deploy/pkg/deployclient"
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/nais/deploy/pkg/telemetry"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPrepareSecretVariables(t *testing.T) {
+	cfg := validConfig()
+	cfg.Resource = []string{writeTemplate(t, "resource.yaml", "metadata:\n  namespace: aura\npassword: {{password}}\ntoken: {{token}}\ndatabase: {{secrets.database}}\nplain: {{plain}}\n")}
+	cfg.VariablesFiles = []string{writeTemplate(t, "vars.yaml", "secrets:\n  database: correct-horse-battery-staple\n")}
+	cfg.Variables = []string{"secret:token=s3cr3t-t0k3n", "plain=visible"}
+	cfg.SecretVariables = []string{"password=hunter2"}
+
+	deployclient.SetupLogging(*cfg)
+	logs := captureLogs(t)
+
+	request, err := deployclient.Prepare(context.Background(), cfg)
+	assert.NoError(t, err)
+
+	// Secrets are still sent to NAIS deploy...
+	data, err := request.GetKubernetes().JSONResources()
+	assert.NoError(t, err)
+	assert.Len(t, data, 1)
+	resources := string(data[0])
+	assert.Contains(t, resources, "hunter2")
+	assert.Contains(t, resources, "s3cr3t-t0k3n")
+	assert.Contains(t, resources, "correct-horse-battery-staple")
+
+	// ...but never printed.
+	log.Infof("The password is %s", "hunter2")
+	stdout := &bytes.Buffer{}
+	d := deployclient.Deployer{Stdout: stdout}
+	d.PrintPayload(request)
+
+	for _, output := range []string{logs.String(), stdout.String()} {
+		assert.NotContains(t, output, "hunter2")
+		assert.NotContains(t, output, "s3cr3t-t0k3n")
+		assert.NotContains(t, output, "correct-horse-battery-staple")
+		assert.Contains(t, output, "visible")
+	}
+	assert.Contains(t, logs.String(), "Setting secret template variable 'token'")
+	assert.Contains(t, logs.String(), "The password is ***")
+	assert.Contains(t, stdout.String(), "***")
+}
+
+func TestSecretVariablesActionsMask(t *testing.T) {
+	cfg := validConfig()
+	cfg.Actions = true
+	cfg.Resource = []string{writeTemplate(t, "resource.yaml", "metadata:\n  namespace: aura\n{{#if key}}key: true{{/if}}\n")}
+	cfg.SecretVariables = []string{"key=first line\nsecond 100%"}
+
+	logs := captureLogs(t)
+
+	_, err := deployclient.Prepare(context.Background(), cfg)
+	assert.NoError(t, err)
+
+	assert.Contains(t, logs.String(), "::add-mask::first line\n")
+	assert.Contains(t, logs.String(), "::add-mask::second 100%25\n")
+}
+
+func TestSecretVariablesRedactedFromJSON(t *testing.T) {
+	const secret = "first \"line\"\nsecond \\ <line>"
+
+	ctx := context.Background()
+	_, _ = telemetry.New(ctx, "test", "")
+
+	cfg := validConfig()
+	cfg.TemplateEngine = deployclient.TemplateEngineGo
+	cfg.Resource = []string{writeTemplate(t, "resource.yaml", "metadata:\n  namespace: aura\npassword: {{ .password | quote }}\n")}
+	cfg.SecretVariables = []string{"password=" + secret}
+
+	request, err := deployclient.Prepare(ctx, cfg)
+	assert.NoError(t, err)
+
+	data, err := request.GetKubernetes().JSONResources()
+	assert.NoError(t, err)
+	assert.Contains(t, string(data[0]), `"first \"line\"\nsecond \\ <line>"`)
+
+	client := &pb.MockDeployClient{}
+	client.On("Deploy", mock.Anything, mock.Anything).Return(nil, status.Errorf(codes.InvalidArgument, "invalid password %s", secret)).Once()
+
+	stdout := &bytes.Buffer{}
+	events := &bytes.Buffer{}
+	d := deployclient.Deployer{Client: client, Stdout: stdout, Events: events}
+	d.PrintPayload(request)
+	_ = d.Deploy(ctx, cfg, request)
+
+	for _, output := range []string{stdout.String(), events.String()} {
+		assert.NotContains(t, output, "first")
+		assert.NotContains(t, output, "second")
+	}
+	assert.Contains(t, events.String(), "***")
+}
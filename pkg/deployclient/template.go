@@ -6,23 +6,64 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 
-	"github.com/aymerick/raymond"
 	"github.com/ghodss/yaml"
 	yamlv2 "gopkg.in/yaml.v2"
 )
 
-func MultiDocumentFileAsJSON(path string, ctx TemplateVariables) ([]json.RawMessage, error) {
+// TemplateError is returned when a resource file cannot be templated or parsed.
+// Line is zero if the location of the error is unknown.
+type TemplateError struct {
+	Path    string
+	Line    int
+	Content []byte
+	Err     error
+}
+
+func (e *TemplateError) Error() string {
+	errMsg := strings.ReplaceAll(e.Err.Error(), "\n", ": ")
+	return fmt.Sprintf("%s: %s", e.Path, errMsg)
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+func newTemplateError(path string, content []byte, err error) *TemplateError {
+	line, _ := detectErrorLine(err.Error())
+	return &TemplateError{
+		Path:    path,
+		Line:    line,
+		Content: content,
+		Err:     err,
+	}
+}
+
+func MultiDocumentFileAsJSON(path string, engine TemplateEngine, ctx TemplateVariables) ([]json.RawMessage, error) {
 	fileContents, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("%s: open file: %s", path, err)
 	}
 
-	templated, err := templatedFile(fileContents, ctx)
+	return MultiDocumentAsJSON(ResourceFile{Path: path, Data: fileContents}, engine, ctx)
+}
+
+// MultiDocumentAsJSON templates a resource file, and returns each of its YAML or JSON documents as JSON.
+func MultiDocumentAsJSON(file ResourceFile, engine TemplateEngine, ctx TemplateVariables) ([]json.RawMessage, error) {
+	_, messages, err := renderDocuments(file, engine, ctx)
+	return messages, err
+}
+
+// renderDocuments is like MultiDocumentAsJSON, but also returns the templated file contents.
+func renderDocuments(file ResourceFile, engine TemplateEngine, ctx TemplateVariables) ([]byte, []json.RawMessage, error) {
+	path, fileContents := file.Path, file.Data
+
+	templated, err := engine.Render(path, fileContents, ctx)
 	if err != nil {
-		errMsg := strings.ReplaceAll(err.Error(), "\n", ": ")
-		return nil, fmt.Errorf("%s: %s", path, errMsg)
+		return nil, nil, newTemplateError(path, fileContents, err)
 	}
 
 	var content interface{}
@@ -35,24 +76,23 @@ func MultiDocumentFileAsJSON(path string, ctx TemplateVariables) ([]json.RawMess
 			err = nil
 			break
 		} else if err != nil {
-			return nil, err
+			return nil, nil, newTemplateError(path, templated, err)
 		}
 
 		rawdocument, err := yamlv2.Marshal(content)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		data, err := yaml.YAMLToJSON(rawdocument)
 		if err != nil {
-			errMsg := strings.ReplaceAll(err.Error(), "\n", ": ")
-			return nil, fmt.Errorf("%s: %s", path, errMsg)
+			return nil, nil, newTemplateError(path, rawdocument, err)
 		}
 
 		messages = append(messages, data)
 	}
 
-	return messages, err
+	return templated, messages, err
 }
 
 func detectTeam(resource json.RawMessage) string {
@@ -92,23 +132,6 @@ func wrapResources(resources []json.RawMessage) (json.RawMessage, error) {
 	return json.Marshal(resources)
 }
 
-func templatedFile(data []byte, ctx TemplateVariables) ([]byte, error) {
-	if len(ctx) == 0 {
-		return data, nil
-	}
-	template, err := raymond.Parse(string(data))
-	if err != nil {
-		return nil, fmt.Errorf("parse template file: %s", err)
-	}
-
-	output, err := template.Exec(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("execute template: %s", err)
-	}
-
-	return []byte(output), nil
-}
-
 func templateVariablesFromFile(path string) (TemplateVariables, error) {
 	file, err := os.ReadFile(path)
 	if err != nil {
@@ -143,10 +166,23 @@ func templateVariablesFromSlice(vars []string) TemplateVariables {
 	return tv
 }
 
+// Error messages from the YAML parser and each template engine, capturing the line number.
+var errorLinePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`yaml: line (\d+):`),
+	regexp.MustCompile(`Parse error on line (\d+):`),
+	regexp.MustCompile(`template: [^:]+:(\d+):`),
+	regexp.MustCompile(`(?:^|\s)[^\s:]+:(\d+):\d+`),
+}
+
 func detectErrorLine(e string) (int, error) {
-	var line int
-	_, err := fmt.Sscanf(e, "yaml: line %d:", &line)
-	return line, err
+	for _, pattern := range errorLinePatterns {
+		match := pattern.FindStringSubmatch(e)
+		if match == nil {
+			continue
+		}
+		return strconv.Atoi(match[1])
+	}
+	return 0, fmt.Errorf("no line number in error message")
 }
 
 func errorContext(content string, line int) []string {
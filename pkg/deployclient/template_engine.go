@@ -0,0 +1,507 @@
+package deployclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/aymerick/raymond"
+	"github.com/aymerick/raymond/ast"
+	"github.com/aymerick/raymond/parser"
+	"github.com/ghodss/yaml"
+	"github.com/google/go-jsonnet"
+)
+
+const (
+	TemplateEngineHandlebars = "handlebars"
+	TemplateEngineGo         = "go"
+	TemplateEngineEnvsubst   = "envsubst"
+	TemplateEngineJsonnet    = "jsonnet"
+)
+
+// TemplateEngine renders a resource file using template variables.
+// The name is used in error messages, and to resolve relative imports where supported.
+type TemplateEngine interface {
+	Render(name string, data []byte, vars TemplateVariables) ([]byte, error)
+}
+
+var templateEngines = map[string]TemplateEngine{
+	TemplateEngineHandlebars: &handlebarsEngine{},
+	TemplateEngineGo:         &goTemplateEngine{},
+	TemplateEngineEnvsubst:   &envsubstEngine{},
+	TemplateEngineJsonnet:    &jsonnetEngine{},
+}
+
+func NewTemplateEngine(name string) (TemplateEngine, error) {
+	engine, ok := templateEngines[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTemplateEngine, name)
+	}
+	return engine, nil
+}
+
+// Handlebars templates, rendered with raymond.
+// Files are passed through untouched if there are no template variables.
+type handlebarsEngine struct{}
+
+func (e *handlebarsEngine) Render(name string, data []byte, vars TemplateVariables) ([]byte, error) {
+	if len(vars) == 0 {
+		return data, nil
+	}
+	template, err := raymond.Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("parse template file: %s", err)
+	}
+
+	output, err := template.Exec(vars)
+	if err != nil {
+		return nil, fmt.Errorf("execute template: %s", err)
+	}
+
+	return []byte(output), nil
+}
+
+// Helpers built into raymond. An expression without parameters is a variable reference, unless it names one of these.
+var handlebarsHelpers = map[string]bool{
+	"each":   true,
+	"equal":  true,
+	"if":     true,
+	"log":    true,
+	"lookup": true,
+	"unless": true,
+	"with":   true,
+}
+
+func (e *handlebarsEngine) References(data []byte) ([]TemplateReference, error) {
+	program, err := parser.Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("parse template file: %s", err)
+	}
+
+	walker := &handlebarsWalker{}
+	walker.program(program)
+
+	return walker.references, nil
+}
+
+// handlebarsWalker collects variable references from a Handlebars AST.
+// Raymond resolves unknown names inside blocks such as {{#each}} against parent contexts,
+// so references inside those blocks are ambiguous unless they explicitly point to the root context.
+type handlebarsWalker struct {
+	depth       int
+	blockParams []string
+	references  []TemplateReference
+}
+
+func (w *handlebarsWalker) program(program *ast.Program) {
+	if program == nil {
+		return
+	}
+	for _, node := range program.Body {
+		w.statement(node)
+	}
+}
+
+func (w *handlebarsWalker) statement(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.MustacheStatement:
+		w.expression(n.Expression)
+	case *ast.BlockStatement:
+		w.expression(n.Expression)
+
+		// Conditionals keep the current context, while all other blocks evaluate their body in a new one.
+		helper := n.Expression.HelperName()
+		nested := helper != "if" && helper != "unless"
+		blockParams := w.blockParams
+		if n.Program != nil {
+			w.blockParams = append(w.blockParams, n.Program.BlockParams...)
+		}
+		if nested {
+			w.depth++
+		}
+		w.program(n.Program)
+		if nested {
+			w.depth--
+		}
+		w.blockParams = blockParams
+
+		w.program(n.Inverse)
+	case *ast.PartialStatement:
+		for _, param := range n.Params {
+			w.param(param)
+		}
+		w.hash(n.Hash)
+	}
+}
+
+func (w *handlebarsWalker) expression(expr *ast.Expression) {
+	if len(expr.Params) == 0 && expr.Hash == nil {
+		path, ok := expr.Path.(*ast.PathExpression)
+		if ok && !handlebarsHelpers[expr.HelperName()] {
+			w.path(path)
+		}
+	}
+	for _, param := range expr.Params {
+		w.param(param)
+	}
+	w.hash(expr.Hash)
+}
+
+func (w *handlebarsWalker) hash(hash *ast.Hash) {
+	if hash == nil {
+		return
+	}
+	for _, pair := range hash.Pairs {
+		w.param(pair.Val)
+	}
+}
+
+func (w *handlebarsWalker) param(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.PathExpression:
+		w.path(n)
+	case *ast.SubExpression:
+		w.expression(n.Expression)
+	case *ast.Expression:
+		w.expression(n)
+	}
+}
+
+func (w *handlebarsWalker) path(path *ast.PathExpression) {
+	line := path.Location().Line
+
+	if path.Data {
+		// Private data such as @index, except for explicit references to the root context.
+		if path.IsDataRoot() && len(path.Parts) > 1 {
+			w.add(path.Parts[1], line, false)
+		}
+		return
+	}
+
+	// References to the current context, e.g. {{this}}.
+	if len(path.Parts) == 0 {
+		return
+	}
+
+	for _, param := range w.blockParams {
+		if param == path.Parts[0] {
+			return
+		}
+	}
+
+	w.add(path.Parts[0], line, path.Depth < w.depth)
+}
+
+func (w *handlebarsWalker) add(name string, line int, ambiguous bool) {
+	w.references = append(w.references, TemplateReference{
+		Name:      name,
+		Line:      line,
+		Ambiguous: ambiguous,
+	})
+}
+
+// Go text/template, with a small set of Sprig-style helper functions.
+// Variables are available on the root object, e.g. {{ .image }}.
+// Referring to a variable that is not set is an error; optional variables are looked up with index,
+// e.g. {{ index . "replicas" | default 2 }}.
+type goTemplateEngine struct{}
+
+func (e *goTemplateEngine) Render(name string, data []byte, vars TemplateVariables) ([]byte, error) {
+	tpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("parse template file: %s", err)
+	}
+
+	buf := &bytes.Buffer{}
+	err = tpl.Execute(buf, map[string]interface{}(vars))
+	if err != nil {
+		return nil, fmt.Errorf("execute template: %s", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (e *goTemplateEngine) References(data []byte) ([]TemplateReference, error) {
+	tpl, err := template.New("").Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("parse template file: %s", err)
+	}
+
+	walker := &goTemplateWalker{data: data}
+	for _, t := range tpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		// Named templates may be invoked with any value as dot, so only references through $ are known to be variables.
+		if t.Name() != tpl.Name() {
+			walker.depth = 1
+		} else {
+			walker.depth = 0
+		}
+		walker.node(t.Tree.Root)
+	}
+
+	return walker.references, nil
+}
+
+// goTemplateWalker collects variable references from a Go template parse tree.
+// Inside {{range}} and {{with}}, dot refers to something other than the template variables,
+// so only references through $ are collected there.
+type goTemplateWalker struct {
+	data       []byte
+	depth      int
+	references []TemplateReference
+}
+
+func (w *goTemplateWalker) node(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			w.node(child)
+		}
+	case *parse.ActionNode:
+		w.pipe(n.Pipe)
+	case *parse.TemplateNode:
+		w.pipe(n.Pipe)
+	case *parse.IfNode:
+		w.branch(&n.BranchNode, false)
+	case *parse.RangeNode:
+		w.branch(&n.BranchNode, true)
+	case *parse.WithNode:
+		w.branch(&n.BranchNode, true)
+	}
+}
+
+func (w *goTemplateWalker) branch(n *parse.BranchNode, nested bool) {
+	w.pipe(n.Pipe)
+	if nested {
+		w.depth++
+	}
+	w.node(n.List)
+	if nested {
+		w.depth--
+	}
+	w.node(n.ElseList)
+}
+
+func (w *goTemplateWalker) pipe(pipe *parse.PipeNode) {
+	if pipe == nil {
+		return
+	}
+	for _, cmd := range pipe.Cmds {
+		for _, arg := range cmd.Args {
+			w.arg(arg)
+		}
+	}
+}
+
+func (w *goTemplateWalker) arg(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		if w.depth == 0 {
+			w.add(n.Ident[0], n.Position())
+		}
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			w.add(n.Ident[1], n.Position())
+		}
+	case *parse.ChainNode:
+		w.arg(n.Node)
+	case *parse.PipeNode:
+		w.pipe(n)
+	}
+}
+
+func (w *goTemplateWalker) add(name string, pos parse.Pos) {
+	w.references = append(w.references, TemplateReference{
+		Name: name,
+		Line: lineAt(w.data, int(pos)),
+	})
+}
+
+var templateFuncs = template.FuncMap{
+	"b64dec": func(s string) (string, error) {
+		decoded, err := base64.StdEncoding.DecodeString(s)
+		return string(decoded), err
+	},
+	"b64enc": func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	},
+	"contains": func(substr, s string) bool {
+		return strings.Contains(s, substr)
+	},
+	"default": func(def interface{}, value ...interface{}) interface{} {
+		if len(value) == 0 || isEmpty(value[0]) {
+			return def
+		}
+		return value[0]
+	},
+	"empty": isEmpty,
+	"hasPrefix": func(prefix, s string) bool {
+		return strings.HasPrefix(s, prefix)
+	},
+	"hasSuffix": func(suffix, s string) bool {
+		return strings.HasSuffix(s, suffix)
+	},
+	"indent": indent,
+	"join": func(sep string, list []interface{}) string {
+		items := make([]string, len(list))
+		for i := range list {
+			items[i] = fmt.Sprint(list[i])
+		}
+		return strings.Join(items, sep)
+	},
+	"lower": strings.ToLower,
+	"nindent": func(spaces int, s string) string {
+		return "\n" + indent(spaces, s)
+	},
+	"quote": func(value interface{}) string {
+		return fmt.Sprintf("%q", fmt.Sprint(value))
+	},
+	"replace": func(old, new, s string) string {
+		return strings.ReplaceAll(s, old, new)
+	},
+	"required": func(message string, value interface{}) (interface{}, error) {
+		if isEmpty(value) {
+			return nil, fmt.Errorf("%s", message)
+		}
+		return value, nil
+	},
+	"split": func(sep, s string) []string {
+		return strings.Split(s, sep)
+	},
+	"squote": func(value interface{}) string {
+		return "'" + fmt.Sprint(value) + "'"
+	},
+	"toJson": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+	"toYaml": func(value interface{}) (string, error) {
+		data, err := yaml.Marshal(value)
+		return strings.TrimSuffix(string(data), "\n"), err
+	},
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"upper":      strings.ToUpper,
+}
+
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return len(v) == 0
+	case bool:
+		return !v
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+// Shell-style ${VAR} and $VAR substitution.
+// Unknown variables are left untouched, and $$ is an escaped dollar sign.
+type envsubstEngine struct{}
+
+var envsubstPattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+func (e *envsubstEngine) Render(name string, data []byte, vars TemplateVariables) ([]byte, error) {
+	return envsubstPattern.ReplaceAllFunc(data, func(match []byte) []byte {
+		if string(match) == "$$" {
+			return []byte("$")
+		}
+		key := strings.Trim(string(match), "${}")
+		value, ok := vars[key]
+		if !ok {
+			return match
+		}
+		return []byte(fmt.Sprint(value))
+	}), nil
+}
+
+func (e *envsubstEngine) References(data []byte) ([]TemplateReference, error) {
+	references := make([]TemplateReference, 0)
+	for _, match := range envsubstPattern.FindAllSubmatchIndex(data, -1) {
+		if string(data[match[0]:match[1]]) == "$$" {
+			continue
+		}
+		key := strings.Trim(string(data[match[0]:match[1]]), "${}")
+		references = append(references, TemplateReference{
+			Name: key,
+			Line: lineAt(data, match[0]),
+		})
+	}
+	return references, nil
+}
+
+// Jsonnet programs, where variables are available as external variables, e.g. std.extVar('image').
+// A program evaluating to an array yields one resource per element.
+type jsonnetEngine struct{}
+
+func (e *jsonnetEngine) Render(name string, data []byte, vars TemplateVariables) ([]byte, error) {
+	vm := jsonnet.MakeVM()
+	for key, value := range vars {
+		code, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("encode template variable %q: %s", key, err)
+		}
+		vm.ExtCode(key, string(code))
+	}
+
+	output, err := vm.EvaluateAnonymousSnippet(name, string(data))
+	if err != nil {
+		return nil, fmt.Errorf("evaluate jsonnet: %s", err)
+	}
+
+	var documents []json.RawMessage
+	if json.Unmarshal([]byte(output), &documents) != nil {
+		return []byte(output), nil
+	}
+
+	// Emit a multi-document stream, so that each element becomes a separate resource.
+	buf := &bytes.Buffer{}
+	for _, document := range documents {
+		buf.WriteString("---\n")
+		buf.Write(document)
+		buf.WriteString("\n")
+	}
+
+	return buf.Bytes(), nil
+}
+
+var jsonnetExtVarPattern = regexp.MustCompile(`std\.extVar\(\s*(?:'([^']*)'|"([^"]*)")\s*\)`)
+
+// Jsonnet references are found by looking for calls to std.extVar with a literal name.
+func (e *jsonnetEngine) References(data []byte) ([]TemplateReference, error) {
+	references := make([]TemplateReference, 0)
+	for _, match := range jsonnetExtVarPattern.FindAllSubmatchIndex(data, -1) {
+		var key string
+		if match[2] >= 0 {
+			key = string(data[match[2]:match[3]])
+		} else {
+			key = string(data[match[4]:match[5]])
+		}
+		references = append(references, TemplateReference{
+			Name: key,
+			Line: lineAt(data, match[0]),
+		})
+	}
+	return references, nil
+}
@@ -0,0 +1,113 @@
+package deployclient_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nais/deploy/pkg/deployclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTemplate(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0o644)
+	assert.NoError(t, err)
+	return path
+}
+
+func TestTemplateEngines(t *testing.T) {
+	vars := deployclient.TemplateVariables{
+		"image": "ghcr.io/navikt/myapp:1",
+		"ingresses": []interface{}{
+			"https://foo",
+			"https://bar",
+		},
+		"replicas": 2,
+	}
+
+	for _, tt := range []struct {
+		engine   string
+		template string
+		expected []string
+	}{
+		{
+			engine:   deployclient.TemplateEngineHandlebars,
+			template: "image: {{image}}\ningresses:\n{{#each ingresses}}  - {{this}}\n{{/each}}",
+			expected: []string{`{"image":"ghcr.io/navikt/myapp:1","ingresses":["https://foo","https://bar"]}`},
+		},
+		{
+			engine:   deployclient.TemplateEngineGo,
+			template: "image: {{ .image | quote }}\nname: {{ index . \"name\" | default \"myapp\" | upper }}\ningresses:\n{{- range .ingresses }}\n  - {{ . }}\n{{- end }}\nreplicas: {{ .replicas }}",
+			expected: []string{`{"image":"ghcr.io/navikt/myapp:1","ingresses":["https://foo","https://bar"],"name":"MYAPP","replicas":2}`},
+		},
+		{
+			engine:   deployclient.TemplateEngineGo,
+			template: "spec:\n  ingresses: {{ .ingresses | toYaml | nindent 4 }}",
+			expected: []string{`{"spec":{"ingresses":["https://foo","https://bar"]}}`},
+		},
+		{
+			engine:   deployclient.TemplateEngineEnvsubst,
+			template: "image: ${image}\nreplicas: $replicas\nunknown: ${UNKNOWN}\nprice: $$5",
+			expected: []string{`{"image":"ghcr.io/navikt/myapp:1","price":"$5","replicas":2,"unknown":"${UNKNOWN}"}`},
+		},
+		{
+			engine:   deployclient.TemplateEngineJsonnet,
+			template: "{ image: std.extVar('image'), replicas: std.extVar('replicas') + 1 }",
+			expected: []string{`{"image":"ghcr.io/navikt/myapp:1","replicas":3}`},
+		},
+		{
+			engine:   deployclient.TemplateEngineJsonnet,
+			template: "[{ ingress: url } for url in std.extVar('ingresses')]",
+			expected: []string{`{"ingress":"https://foo"}`, `{"ingress":"https://bar"}`},
+		},
+	} {
+		t.Run(tt.engine, func(t *testing.T) {
+			path := writeTemplate(t, "resource", tt.template)
+			docs, err := deployclient.MultiDocumentFileAsJSON(path, templateEngine(t, tt.engine), vars)
+			assert.NoError(t, err)
+			assert.Len(t, docs, len(tt.expected))
+			for i := range docs {
+				assert.JSONEq(t, tt.expected[i], string(docs[i]))
+			}
+		})
+	}
+}
+
+func TestTemplateEngineErrorLine(t *testing.T) {
+	vars := deployclient.TemplateVariables{"foo": "bar"}
+
+	for _, tt := range []struct {
+		engine   string
+		template string
+		line     int
+	}{
+		{deployclient.TemplateEngineHandlebars, "foo: bar\nbar: {{#if foo}}", 2},
+		{deployclient.TemplateEngineGo, "foo: bar\nbar: baz\nbaz: {{ .foo | nonexistent }}\n", 3},
+		{deployclient.TemplateEngineGo, "foo: bar\nbar: {{ required \"bar is required\" (index . \"bar\") }}\n", 2},
+		{deployclient.TemplateEngineGo, "foo: bar\nbar: {{ .bar }}\n", 2},
+		{deployclient.TemplateEngineJsonnet, "{\n  foo: 'bar',\n  bar: baz,\n}\n", 3},
+		{deployclient.TemplateEngineEnvsubst, "foo: bar\nbar: baz: ${foo}\n", 2},
+	} {
+		t.Run(tt.engine, func(t *testing.T) {
+			path := writeTemplate(t, "resource", tt.template)
+			_, err := deployclient.MultiDocumentFileAsJSON(path, templateEngine(t, tt.engine), vars)
+			assert.Error(t, err)
+
+			templateErr := &deployclient.TemplateError{}
+			assert.True(t, errors.As(err, &templateErr))
+			assert.Equal(t, tt.line, templateErr.Line, err.Error())
+			assert.Contains(t, err.Error(), path)
+		})
+	}
+}
+
+func TestInvalidTemplateEngine(t *testing.T) {
+	_, err := deployclient.NewTemplateEngine("mustache")
+	assert.ErrorIs(t, err, deployclient.ErrInvalidTemplateEngine)
+
+	cfg := validConfig()
+	cfg.TemplateEngine = "mustache"
+	assert.ErrorIs(t, cfg.Validate(), deployclient.ErrInvalidTemplateEngine)
+}
@@ -0,0 +1,177 @@
+package deployclient
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TemplateReference is a template variable referenced from a resource file.
+type TemplateReference struct {
+	Name string
+	Line int
+	// Ambiguous references might resolve against something other than the template variables,
+	// such as the current item in a loop, and are not reported if the variable is missing.
+	Ambiguous bool
+}
+
+// TemplateAnalyzer is implemented by template engines that support strict templating.
+type TemplateAnalyzer interface {
+	References(data []byte) ([]TemplateReference, error)
+}
+
+// TemplateProblem is a single violation found by strict templating or resource validation.
+// Path and Line are empty if the location is unknown.
+type TemplateProblem struct {
+	Path    string
+	Line    int
+	Message string
+}
+
+func (p TemplateProblem) String() string {
+	switch {
+	case len(p.Path) == 0:
+		return p.Message
+	case p.Line == 0:
+		return fmt.Sprintf("%s: %s", p.Path, p.Message)
+	default:
+		return fmt.Sprintf("%s:%d: %s", p.Path, p.Line, p.Message)
+	}
+}
+
+// log the problem as an error, with its location as fields so that it can be annotated in GitHub Actions.
+func (p TemplateProblem) log() {
+	fields := log.Fields{}
+	if len(p.Path) > 0 {
+		fields[LogFieldFile] = p.Path
+	}
+	if p.Line > 0 {
+		fields[LogFieldLine] = p.Line
+	}
+	log.WithFields(fields).Error(p.String())
+}
+
+// StrictTemplateError is returned when resource templates reference undefined variables,
+// or when template variables are defined but never used.
+type StrictTemplateError struct {
+	Problems []TemplateProblem
+}
+
+func (e *StrictTemplateError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for i := range e.Problems {
+		problems[i] = e.Problems[i].String()
+	}
+	return fmt.Sprintf("strict templating: %s", strings.Join(problems, "; "))
+}
+
+// CheckTemplateVariables reports every variable referenced from the resource files that is missing from vars,
+// and every variable in vars that is never referenced. Unused variables are located in varsFiles, if given.
+func CheckTemplateVariables(engine TemplateEngine, files []ResourceFile, vars TemplateVariables, varsFiles ...string) error {
+	analyzer, ok := engine.(TemplateAnalyzer)
+	if !ok {
+		return fmt.Errorf("template engine does not support strict templating")
+	}
+
+	problems := make([]TemplateProblem, 0)
+	used := make(map[string]bool)
+
+	for _, file := range files {
+		path, fileContents := file.Path, file.Data
+
+		references, err := analyzer.References(fileContents)
+		if err != nil {
+			return newTemplateError(path, fileContents, err)
+		}
+
+		for _, ref := range references {
+			if _, defined := vars[ref.Name]; defined {
+				used[ref.Name] = true
+				continue
+			}
+			if ref.Ambiguous {
+				continue
+			}
+			problems = append(problems, TemplateProblem{
+				Path:    path,
+				Line:    ref.Line,
+				Message: fmt.Sprintf("template variable '%s' is not defined", ref.Name),
+			})
+		}
+	}
+
+	// Variables in later files override earlier ones, so the last definition is the one reported.
+	locations := make(map[string]TemplateProblem)
+	for _, varsFile := range varsFiles {
+		lines, err := variableLines(varsFile)
+		if err != nil {
+			return err
+		}
+		for key, line := range lines {
+			locations[key] = TemplateProblem{Path: varsFile, Line: line}
+		}
+	}
+
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if used[key] {
+			continue
+		}
+		problem := locations[key]
+		problem.Message = fmt.Sprintf("template variable '%s' is defined but never used", key)
+		problems = append(problems, problem)
+	}
+
+	if len(problems) > 0 {
+		return &StrictTemplateError{Problems: problems}
+	}
+
+	return nil
+}
+
+// Top-level keys in a YAML document, optionally quoted.
+var topLevelKeyPattern = regexp.MustCompile(`^(?:"([^"]+)"|'([^']+)'|([^\s#"'-][^:]*?))\s*:(?:\s|$)`)
+
+// variableLines returns the line number of each top-level key in a template variables file.
+func variableLines(path string) (map[string]int, error) {
+	lines := make(map[string]int)
+	if len(path) == 0 {
+		return lines, nil
+	}
+
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: open file: %s", path, err)
+	}
+
+	for i, line := range strings.Split(string(file), "\n") {
+		match := topLevelKeyPattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		key := match[1] + match[2] + match[3]
+		if _, exists := lines[key]; !exists {
+			lines[key] = i + 1
+		}
+	}
+
+	return lines, nil
+}
+
+// lineAt returns the line number of a byte offset in data.
+func lineAt(data []byte, offset int) int {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
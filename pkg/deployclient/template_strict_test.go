@@ -0,0 +1,139 @@
+package deployclient_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nais/deploy/pkg/deployclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTemplateVariables(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		engine   string
+		template string
+		vars     deployclient.TemplateVariables
+		problems []string
+	}{
+		{
+			name:     "handlebars all variables used",
+			engine:   deployclient.TemplateEngineHandlebars,
+			template: "image: {{image}}\n{{#if debug}}debug: true{{/if}}\n",
+			vars:     deployclient.TemplateVariables{"image": "foo", "debug": true},
+		},
+		{
+			name:     "handlebars missing variable",
+			engine:   deployclient.TemplateEngineHandlebars,
+			template: "image: {{image}}\nhost: {{ingress.host}}\n",
+			vars:     deployclient.TemplateVariables{"image": "foo"},
+			problems: []string{"RESOURCE:2: template variable 'ingress' is not defined"},
+		},
+		{
+			name:     "handlebars unused variable",
+			engine:   deployclient.TemplateEngineHandlebars,
+			template: "image: {{image}}\n",
+			vars:     deployclient.TemplateVariables{"image": "foo", "imagee": "bar"},
+			problems: []string{"template variable 'imagee' is defined but never used"},
+		},
+		{
+			name:     "handlebars loops resolve against the current item",
+			engine:   deployclient.TemplateEngineHandlebars,
+			template: "ingresses:\n{{#each ingresses as |ingress|}}\n  - {{ingress}}.{{domain}}/{{path}}{{@index}}\n{{/each}}\nfoo: {{../missing}}{{@root.alsomissing}}\n",
+			vars:     deployclient.TemplateVariables{"ingresses": []interface{}{"foo"}, "domain": "nav.no"},
+			problems: []string{
+				"RESOURCE:5: template variable 'missing' is not defined",
+				"RESOURCE:5: template variable 'alsomissing' is not defined",
+			},
+		},
+		{
+			name:     "handlebars helper parameters",
+			engine:   deployclient.TemplateEngineHandlebars,
+			template: "foo: {{lookup map key}}\n",
+			vars:     deployclient.TemplateVariables{"map": map[string]interface{}{}},
+			problems: []string{"RESOURCE:1: template variable 'key' is not defined"},
+		},
+		{
+			name:     "go templates",
+			engine:   deployclient.TemplateEngineGo,
+			template: "image: {{ .image | quote }}\n{{ range .ingresses }}\n- {{ .host }}{{ $.path }}\n{{ end }}\n",
+			vars:     deployclient.TemplateVariables{"image": "foo", "ingresses": []interface{}{}, "unused": true},
+			problems: []string{
+				"RESOURCE:3: template variable 'path' is not defined",
+				"template variable 'unused' is defined but never used",
+			},
+		},
+		{
+			name:     "envsubst",
+			engine:   deployclient.TemplateEngineEnvsubst,
+			template: "image: ${image}\nprice: $$5\nhost: $HOST\n",
+			vars:     deployclient.TemplateVariables{"image": "foo"},
+			problems: []string{"RESOURCE:3: template variable 'HOST' is not defined"},
+		},
+		{
+			name:     "jsonnet",
+			engine:   deployclient.TemplateEngineJsonnet,
+			template: "{\n  image: std.extVar('image'),\n  host: std.extVar(\"host\"),\n}\n",
+			vars:     deployclient.TemplateVariables{"image": "foo"},
+			problems: []string{"RESOURCE:3: template variable 'host' is not defined"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "resource.yaml"
+			files := []deployclient.ResourceFile{{Path: path, Data: []byte(tt.template)}}
+			err := deployclient.CheckTemplateVariables(templateEngine(t, tt.engine), files, tt.vars, "")
+
+			if len(tt.problems) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			strictErr := &deployclient.StrictTemplateError{}
+			assert.True(t, errors.As(err, &strictErr))
+
+			problems := make([]string, len(strictErr.Problems))
+			for i, problem := range strictErr.Problems {
+				problems[i] = problem.String()
+				if problem.Path == path {
+					problems[i] = "RESOURCE" + problems[i][len(path):]
+				}
+			}
+			assert.Equal(t, tt.problems, problems)
+		})
+	}
+}
+
+func TestCheckTemplateVariablesFromFile(t *testing.T) {
+	files := []deployclient.ResourceFile{{Path: "resource.yaml", Data: []byte("image: {{image}}\n")}}
+	varsFile := writeTemplate(t, "vars.yaml", "# template variables\nimage: foo\n\"imagee\": bar\n")
+
+	vars := deployclient.TemplateVariables{
+		"image":  "foo",
+		"imagee": "bar",
+		"flag":   "baz",
+	}
+
+	err := deployclient.CheckTemplateVariables(templateEngine(t, deployclient.TemplateEngineHandlebars), files, vars, varsFile)
+
+	strictErr := &deployclient.StrictTemplateError{}
+	assert.True(t, errors.As(err, &strictErr))
+	assert.Equal(t, []deployclient.TemplateProblem{
+		{Message: "template variable 'flag' is defined but never used"},
+		{Path: varsFile, Line: 3, Message: "template variable 'imagee' is defined but never used"},
+	}, strictErr.Problems)
+}
+
+func TestPrepareStrictTemplates(t *testing.T) {
+	cfg := validConfig()
+	cfg.Resource = []string{writeTemplate(t, "resource.yaml", "metadata:\n  namespace: {{namespace}}\n")}
+	cfg.StrictTemplates = true
+
+	_, err := deployclient.Prepare(context.Background(), cfg)
+	assert.Equal(t, deployclient.ExitTemplateError, deployclient.ErrorExitCode(err))
+
+	cfg.Variables = []string{"namespace=aura"}
+	request, err := deployclient.Prepare(context.Background(), cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "aura", request.GetTeam())
+}
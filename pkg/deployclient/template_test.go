@@ -7,8 +7,14 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func templateEngine(t *testing.T, name string) deployclient.TemplateEngine {
+	engine, err := deployclient.NewTemplateEngine(name)
+	assert.NoError(t, err)
+	return engine
+}
+
 func TestMultiDocumentParsing(t *testing.T) {
-	docs, err := deployclient.MultiDocumentFileAsJSON("testdata/multi_document.yaml", deployclient.TemplateVariables{})
+	docs, err := deployclient.MultiDocumentFileAsJSON("testdata/multi_document.yaml", templateEngine(t, deployclient.TemplateEngineHandlebars), deployclient.TemplateVariables{})
 	assert.Len(t, docs, 2)
 	assert.NoError(t, err)
 	assert.Equal(t, `{"document":1}`, string(docs[0]))
@@ -22,7 +28,7 @@ func TestMultiDocumentTemplating(t *testing.T) {
 			"https://bar",
 		},
 	}
-	docs, err := deployclient.MultiDocumentFileAsJSON("testdata/templating.yaml", ctx)
+	docs, err := deployclient.MultiDocumentFileAsJSON("testdata/templating.yaml", templateEngine(t, deployclient.TemplateEngineHandlebars), ctx)
 	assert.Len(t, docs, 2)
 	assert.NoError(t, err)
 	assert.Equal(t, `{"ingresses":["https://foo","https://bar"]}`, string(docs[0]))
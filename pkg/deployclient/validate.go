@@ -0,0 +1,43 @@
+package deployclient
+
+import (
+	"encoding/json"
+
+	"github.com/nais/deploy/pkg/deployclient/validation"
+)
+
+// ValidateResources validates the documents of a templated resource file against the schemas of NAIS resources.
+// Problems are located in the templated file contents, which may differ from the source file if
+// template expressions span several lines.
+func ValidateResources(path string, templated []byte, documents []json.RawMessage) ([]TemplateProblem, error) {
+	validator, err := validation.Default()
+	if err != nil {
+		return nil, err
+	}
+
+	// Locations are best effort; if the file cannot be parsed with line numbers, problems are reported without them.
+	nodes, err := validation.Documents(templated)
+	if err != nil || len(nodes) != len(documents) {
+		nodes = nil
+	}
+
+	problems := make([]TemplateProblem, 0)
+	for i, document := range documents {
+		fieldErrors, err := validator.Validate(document)
+		if err != nil {
+			return nil, err
+		}
+		for _, fieldError := range fieldErrors {
+			problem := TemplateProblem{
+				Path:    path,
+				Message: fieldError.String(),
+			}
+			if nodes != nil {
+				problem.Line = validation.Locate(nodes[i], fieldError.Field)
+			}
+			problems = append(problems, problem)
+		}
+	}
+
+	return problems, nil
+}
@@ -0,0 +1,73 @@
+package deployclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/nais/deploy/pkg/deployclient"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateResources(t *testing.T) {
+	templated := []byte("apiVersion: v1\nkind: ConfigMap\n---\napiVersion: nais.io/v1alpha1\nkind: Application\nmetadata:\n  name: myapp\nspec:\n  image: foo\n  replicass: 2\n")
+	documents := []json.RawMessage{
+		json.RawMessage(`{"apiVersion":"v1","kind":"ConfigMap"}`),
+		json.RawMessage(`{"apiVersion":"nais.io/v1alpha1","kind":"Application","metadata":{"name":"myapp"},"spec":{"image":"foo","replicass":2}}`),
+	}
+
+	problems, err := deployclient.ValidateResources("nais.yaml", templated, documents)
+	assert.NoError(t, err)
+	assert.Equal(t, []deployclient.TemplateProblem{
+		{Path: "nais.yaml", Line: 10, Message: "spec.replicass: unknown field"},
+	}, problems)
+}
+
+func TestPrepareValidation(t *testing.T) {
+	cfg := validConfig()
+	cfg.Resource = []string{writeTemplate(t, "nais.yaml", "apiVersion: nais.io/v1alpha1\nkind: Application\nmetadata:\n  name: myapp\n  namespace: aura\nspec:\n  image: {{image}}\n  port: {{port}}\n")}
+	cfg.Variables = []string{"image=foo", "port=http"}
+	cfg.ValidateResources = true
+
+	_, err := deployclient.Prepare(context.Background(), cfg)
+	assert.Equal(t, deployclient.ExitValidationError, deployclient.ErrorExitCode(err))
+
+	cfg.ValidateResources = false
+	_, err = deployclient.Prepare(context.Background(), cfg)
+	assert.NoError(t, err)
+
+	cfg.ValidateResources = true
+	cfg.Variables = []string{"image=foo", "port=8080"}
+	_, err = deployclient.Prepare(context.Background(), cfg)
+	assert.NoError(t, err)
+}
+
+func TestActionsFormatter(t *testing.T) {
+	formatter := &deployclient.ActionsFormatter{}
+	logger := log.New()
+
+	for _, tt := range []struct {
+		entry    *log.Entry
+		expected string
+	}{
+		{
+			entry:    logger.WithFields(log.Fields{}),
+			expected: "::error::problem\n",
+		},
+		{
+			entry:    logger.WithField(deployclient.LogFieldFile, "nais.yaml"),
+			expected: "::error file=nais.yaml::problem\n",
+		},
+		{
+			entry:    logger.WithFields(log.Fields{deployclient.LogFieldFile: "nais.yaml", deployclient.LogFieldLine: 12}),
+			expected: "::error file=nais.yaml,line=12::problem\n",
+		},
+	} {
+		tt.entry.Level = log.ErrorLevel
+		tt.entry.Message = "problem"
+		output, err := formatter.Format(tt.entry)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, string(output))
+	}
+}
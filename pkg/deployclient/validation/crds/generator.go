@@ -0,0 +1,48 @@
+// CRD schema generator, see README.md in this directory for instructions.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nais/liberator/pkg/crd"
+	log "github.com/sirupsen/logrus"
+)
+
+//go:generate go run generator.go
+
+func main() {
+	dir := crd.YamlDirectory()
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, file := range files {
+		fn := file.Name()
+		if file.IsDir() || filepath.Ext(fn) != ".yaml" {
+			log.Infof("skip %s", fn)
+			continue
+		}
+
+		// Only resources owned by NAIS; Config Connector resources are validated by the cluster.
+		if !strings.Contains(fn, "nais.io_") {
+			log.Infof("skip %s", fn)
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, fn))
+		if err != nil {
+			log.Fatalf("%s: %s", fn, err)
+		}
+
+		err = os.WriteFile(fn, data, 0o644)
+		if err != nil {
+			log.Fatalf("%s: %s", fn, err)
+		}
+
+		log.Infof("copy %s", fn)
+	}
+}
@@ -0,0 +1,83 @@
+package validation
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Documents parses every document in a YAML stream, keeping track of source locations.
+func Documents(data []byte) ([]*yaml.Node, error) {
+	documents := make([]*yaml.Node, 0)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		document := &yaml.Node{}
+		err := decoder.Decode(document)
+		if errors.Is(err, io.EOF) {
+			return documents, nil
+		} else if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+}
+
+// Locate returns the line number of a field in a parsed YAML document.
+// If the field does not exist, the line of the closest existing parent is returned instead.
+func Locate(document *yaml.Node, field string) int {
+	node := resolve(document)
+	if node == nil {
+		return 0
+	}
+	line := node.Line
+
+	for _, part := range parseFieldPath(field) {
+		node = resolve(node)
+		switch node.Kind {
+		case yaml.MappingNode:
+			var value *yaml.Node
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == part {
+					line = node.Content[i].Line
+					value = node.Content[i+1]
+					break
+				}
+			}
+			if value == nil {
+				return line
+			}
+			node = value
+		case yaml.SequenceNode:
+			index, ok := isIndex(part)
+			if !ok || index >= len(node.Content) {
+				return line
+			}
+			node = node.Content[index]
+			line = node.Line
+		default:
+			return line
+		}
+	}
+
+	return line
+}
+
+// resolve follows document roots and aliases to the node holding the actual content.
+func resolve(node *yaml.Node) *yaml.Node {
+	for node != nil {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			if len(node.Content) == 0 {
+				return nil
+			}
+			node = node.Content[0]
+		case yaml.AliasNode:
+			node = node.Alias
+		default:
+			return node
+		}
+	}
+	return nil
+}
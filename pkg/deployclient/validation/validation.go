@@ -0,0 +1,211 @@
+// Package validation validates Kubernetes resources against the OpenAPI schemas of NAIS custom resources,
+// so that errors can be reported before a deployment request is sent.
+package validation
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema/pruning"
+	apiservervalidation "k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/json"
+)
+
+//go:embed crds/*.yaml
+var crds embed.FS
+
+// FieldError is a validation error for a single field.
+// The field is a path such as `spec.env[0].name`.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) String() string {
+	if len(e.Field) == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+type crdSchema struct {
+	validator  apiservervalidation.SchemaValidator
+	structural *structuralschema.Structural
+}
+
+// Validator validates resources of known kinds. Resources of other kinds are always valid.
+type Validator struct {
+	schemas map[schema.GroupVersionKind]*crdSchema
+}
+
+var (
+	defaultValidator    *Validator
+	defaultValidatorErr error
+	defaultValidatorMu  sync.Once
+)
+
+// Default returns a validator for all CRDs embedded in this package.
+// The schemas are parsed once, on first use.
+func Default() (*Validator, error) {
+	defaultValidatorMu.Do(func() {
+		defaultValidator, defaultValidatorErr = New()
+	})
+	return defaultValidator, defaultValidatorErr
+}
+
+// New parses the CRDs embedded in this package.
+func New() (*Validator, error) {
+	v := &Validator{
+		schemas: make(map[schema.GroupVersionKind]*crdSchema),
+	}
+
+	files, err := crds.ReadDir("crds")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		data, err := crds.ReadFile(path.Join("crds", file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		err = v.AddCRD(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name(), err)
+		}
+	}
+
+	return v, nil
+}
+
+// AddCRD adds the schemas of every version of a CustomResourceDefinition in YAML or JSON format.
+func (v *Validator) AddCRD(data []byte) error {
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	err := yaml.Unmarshal(data, crd)
+	if err != nil {
+		return fmt.Errorf("decode CRD: %w", err)
+	}
+
+	for _, version := range crd.Spec.Versions {
+		if version.Schema == nil || version.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+
+		internal := &apiextensions.JSONSchemaProps{}
+		err = apiextensionsv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(version.Schema.OpenAPIV3Schema, internal, nil)
+		if err != nil {
+			return fmt.Errorf("convert schema for version %s: %w", version.Name, err)
+		}
+
+		validator, _, err := apiservervalidation.NewSchemaValidator(internal)
+		if err != nil {
+			return fmt.Errorf("schema validator for version %s: %w", version.Name, err)
+		}
+
+		structural, err := structuralschema.NewStructural(internal)
+		if err != nil {
+			return fmt.Errorf("structural schema for version %s: %w", version.Name, err)
+		}
+
+		gvk := schema.GroupVersionKind{
+			Group:   crd.Spec.Group,
+			Version: version.Name,
+			Kind:    crd.Spec.Names.Kind,
+		}
+		v.schemas[gvk] = &crdSchema{
+			validator:  validator,
+			structural: structural,
+		}
+	}
+
+	return nil
+}
+
+// Validate a single resource in JSON format.
+// Unknown fields and schema violations are returned as field errors, sorted by field path.
+// Documents that are not JSON objects are left for the Kubernetes API server to reject.
+func (v *Validator) Validate(resource []byte) ([]FieldError, error) {
+	obj := make(map[string]interface{})
+	err := json.Unmarshal(resource, &obj)
+	if err != nil {
+		return nil, nil
+	}
+
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, nil
+	}
+
+	crd, ok := v.schemas[gv.WithKind(kind)]
+	if !ok {
+		return nil, nil
+	}
+
+	errs := make([]FieldError, 0)
+
+	opts := structuralschema.UnknownFieldPathOptions{TrackUnknownFieldPaths: true}
+	// Pruning removes unknown fields in place, so prune a copy.
+	pruned := runtime.DeepCopyJSON(obj)
+	for _, field := range pruning.PruneWithOptions(pruned, crd.structural, true, opts) {
+		errs = append(errs, FieldError{
+			Field:   field,
+			Message: "unknown field",
+		})
+	}
+
+	for _, fieldErr := range apiservervalidation.ValidateCustomResource(nil, obj, crd.validator) {
+		errs = append(errs, FieldError{
+			Field:   fieldErr.Field,
+			Message: fieldErr.ErrorBody(),
+		})
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Field < errs[j].Field
+	})
+
+	return errs, nil
+}
+
+// parseFieldPath splits a field path such as `spec.env[0].name` into its parts.
+func parseFieldPath(field string) []string {
+	parts := make([]string, 0)
+	for _, part := range strings.Split(field, ".") {
+		for len(part) > 0 {
+			open := strings.IndexByte(part, '[')
+			if open < 0 {
+				parts = append(parts, part)
+				break
+			}
+			if open > 0 {
+				parts = append(parts, part[:open])
+			}
+			end := strings.IndexByte(part[open:], ']')
+			if end < 0 {
+				parts = append(parts, part[open:])
+				break
+			}
+			parts = append(parts, part[open+1:open+end])
+			part = part[open+end+1:]
+		}
+	}
+	return parts
+}
+
+func isIndex(part string) (int, bool) {
+	i, err := strconv.Atoi(part)
+	return i, err == nil && i >= 0
+}
@@ -0,0 +1,94 @@
+package validation_test
+
+import (
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/nais/deploy/pkg/deployclient/validation"
+	"github.com/stretchr/testify/assert"
+)
+
+const application = `apiVersion: nais.io/v1alpha1
+kind: Application
+metadata:
+  name: myapp
+  namespace: aura
+spec:
+  image: ghcr.io/navikt/myapp:1
+  port: 8080
+  env:
+    - name: FOO
+      value: bar
+    - name: BAR
+      valeu: baz
+  replicas:
+    min: two
+`
+
+func TestValidate(t *testing.T) {
+	validator, err := validation.Default()
+	assert.NoError(t, err)
+
+	for _, tt := range []struct {
+		name     string
+		resource string
+		errors   []string
+	}{
+		{
+			name:     "valid application",
+			resource: "apiVersion: nais.io/v1alpha1\nkind: Application\nmetadata:\n  name: myapp\nspec:\n  image: foo\n  port: 8080\n",
+		},
+		{
+			name:     "unknown field and wrong type",
+			resource: application,
+			errors: []string{
+				"spec.env[1].valeu: unknown field",
+				`spec.replicas.min: Invalid value: "string": spec.replicas.min in body must be of type integer: "string"`,
+			},
+		},
+		{
+			name:     "unknown kinds are not validated",
+			resource: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\nspec:\n  whatever: true\n",
+		},
+		{
+			name:     "unknown versions are not validated",
+			resource: "apiVersion: nais.io/v2\nkind: Application\nspec:\n  whatever: true\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resource, err := yaml.YAMLToJSON([]byte(tt.resource))
+			assert.NoError(t, err)
+
+			fieldErrors, err := validator.Validate(resource)
+			assert.NoError(t, err)
+
+			errors := make([]string, len(fieldErrors))
+			for i := range fieldErrors {
+				errors[i] = fieldErrors[i].String()
+			}
+			assert.ElementsMatch(t, tt.errors, errors)
+		})
+	}
+}
+
+func TestLocate(t *testing.T) {
+	documents, err := validation.Documents([]byte("kind: ConfigMap\n---\n" + application))
+	assert.NoError(t, err)
+	assert.Len(t, documents, 2)
+
+	for field, line := range map[string]int{
+		"":                    3,
+		"kind":                4,
+		"spec.port":           10,
+		"spec.env[1].valeu":   15,
+		"spec.env.1.valeu":    15,
+		"spec.env[0]":         12,
+		"spec.replicas.min":   17,
+		"spec.replicas.max":   16,
+		"spec.env[5].name":    11,
+		"metadata.labels.app": 5,
+	} {
+		// Lines are counted from the start of the stream, not the document.
+		assert.Equal(t, line, validation.Locate(documents[1], field), field)
+	}
+}
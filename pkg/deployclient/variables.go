@@ -0,0 +1,259 @@
+package deployclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ClusterPlaceholder is replaced with the cluster name in the path given with `--cluster-vars-path`.
+const ClusterPlaceholder = "{cluster}"
+
+// Sources of template variables that are not files.
+const (
+	VariableSourceEnvironment = "environment"
+	VariableSourceFlag        = "--var"
+	VariableSourceSecretFlag  = "--secret-var"
+)
+
+// ResolvedVariables are template variables merged from every source, in order of precedence:
+//
+//  1. variables files given with `--vars`, in order;
+//  2. the cluster variables file, found with `--cluster-vars-path` or given with `--cluster-vars`;
+//  3. environment variables with the prefix given with `--vars-env-prefix`;
+//  4. variables given with `--var` and `--secret-var`.
+//
+// Maps are merged deeply, so that a later source can override a single nested value.
+type ResolvedVariables struct {
+	Variables TemplateVariables
+	// Sources maps the path of every value, such as `ingress.host`, to where it was set.
+	Sources map[string]string
+	// Files are the variables files that were read, in order.
+	Files []string
+}
+
+// ResolveTemplateVariables loads the template variables for the configured cluster.
+// Environment variables are taken from environ, in the form of os.Environ().
+func ResolveTemplateVariables(cfg *Config, environ []string) (*ResolvedVariables, error) {
+	resolved := &ResolvedVariables{
+		Variables: make(TemplateVariables),
+		Sources:   make(map[string]string),
+		Files:     make([]string, 0),
+	}
+
+	mergeFile := func(path string) error {
+		vars, err := templateVariablesFromFile(path)
+		if err != nil {
+			return err
+		}
+		registerSecretSection(cfg, vars)
+		resolved.merge(vars, path)
+		resolved.Files = append(resolved.Files, path)
+		return nil
+	}
+
+	for _, path := range cfg.VariablesFiles {
+		err := mergeFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(cfg.ClusterVariablesPath) > 0 && len(cfg.Cluster) > 0 {
+		path := strings.ReplaceAll(cfg.ClusterVariablesPath, ClusterPlaceholder, cfg.Cluster)
+		_, err := os.Stat(path)
+		switch {
+		case err == nil:
+			log.Debugf("Using template variables file '%s' for cluster '%s'", path, cfg.Cluster)
+			err = mergeFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("cluster '%s': %w", cfg.Cluster, err)
+			}
+		case errors.Is(err, os.ErrNotExist):
+			log.Debugf("No template variables file for cluster '%s' at '%s'", cfg.Cluster, path)
+		default:
+			return nil, err
+		}
+	}
+
+	if path, ok := cfg.ClusterVariablesFiles[cfg.Cluster]; ok {
+		err := mergeFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cluster '%s': %w", cfg.Cluster, err)
+		}
+	}
+
+	if len(cfg.VariablesEnvPrefix) > 0 {
+		resolved.merge(templateVariablesFromEnviron(environ, cfg.VariablesEnvPrefix), VariableSourceEnvironment)
+	}
+
+	templateOverrides := templateVariablesFromSlice(cfg.Variables)
+	secretOverrides := templateVariablesFromSlice(cfg.SecretVariables)
+	for key, val := range templateOverrides {
+		if name, ok := strings.CutPrefix(key, SecretVariablePrefix); ok {
+			secretOverrides[name] = val
+			delete(templateOverrides, key)
+		}
+	}
+	for key, val := range secretOverrides {
+		registerSecret(cfg, val)
+		delete(templateOverrides, key)
+	}
+
+	for key, val := range templateOverrides {
+		if oldval, ok := resolved.Variables[key]; ok {
+			log.Warnf("Overwriting template variable '%s'; previous value was '%v'", key, oldval)
+		}
+		log.Infof("Setting template variable '%s' to '%v'", key, val)
+	}
+	resolved.merge(templateOverrides, VariableSourceFlag)
+
+	for key := range secretOverrides {
+		if _, ok := resolved.Variables[key]; ok {
+			log.Warnf("Overwriting template variable '%s'", key)
+		}
+		log.Infof("Setting secret template variable '%s'", key)
+	}
+	resolved.merge(secretOverrides, VariableSourceSecretFlag)
+
+	return resolved, nil
+}
+
+// merge vars into the resolved variables.
+// Values that are maps in both are merged recursively; any other value replaces the existing one.
+func (r *ResolvedVariables) merge(vars TemplateVariables, source string) {
+	mergeVariables(r.Variables, vars, "", source, r.Sources)
+}
+
+func mergeVariables(dst, src map[string]interface{}, prefix, source string, sources map[string]string) {
+	for key, value := range src {
+		path := prefix + key
+
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeVariables(dstMap, srcMap, path+".", source, sources)
+			continue
+		}
+
+		for existing := range sources {
+			if existing == path || strings.HasPrefix(existing, path+".") {
+				delete(sources, existing)
+			}
+		}
+		if srcIsMap {
+			// Copy, so that later merges don't modify the source.
+			value = copyVariables(srcMap)
+		}
+		dst[key] = value
+		recordSources(value, path, source, sources)
+	}
+}
+
+func copyVariables(vars map[string]interface{}) map[string]interface{} {
+	cp := make(map[string]interface{}, len(vars))
+	for key, value := range vars {
+		if m, ok := value.(map[string]interface{}); ok {
+			value = copyVariables(m)
+		}
+		cp[key] = value
+	}
+	return cp
+}
+
+func recordSources(value interface{}, path, source string, sources map[string]string) {
+	m, ok := value.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		sources[path] = source
+		return
+	}
+	for key, child := range m {
+		recordSources(child, path+"."+key, source, sources)
+	}
+}
+
+// templateVariablesFromEnviron returns every environment variable with the given prefix, with the prefix removed.
+func templateVariablesFromEnviron(environ []string, prefix string) TemplateVariables {
+	tv := TemplateVariables{}
+	for _, keyval := range environ {
+		key, val, ok := strings.Cut(keyval, "=")
+		if !ok {
+			continue
+		}
+		name, ok := strings.CutPrefix(key, prefix)
+		if !ok || len(name) == 0 {
+			continue
+		}
+		tv[name] = val
+	}
+	return tv
+}
+
+// lookup returns the value at a path such as `ingress.host`.
+func (r *ResolvedVariables) lookup(path string) interface{} {
+	var value interface{} = map[string]interface{}(r.Variables)
+	for _, part := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[part]
+	}
+	return value
+}
+
+// ExplainVariables prints every template variable for each configured cluster, along with where its value came from.
+func (d *Deployer) ExplainVariables(cfg *Config) error {
+	clusters := cfg.Clusters()
+	if len(clusters) == 0 {
+		clusters = []string{""}
+	}
+
+	w := d.stdout()
+	for i, cluster := range clusters {
+		clusterConfig := *cfg
+		clusterConfig.Cluster = cluster
+
+		resolved, err := ResolveTemplateVariables(&clusterConfig, os.Environ())
+		if err != nil {
+			return Errorf(ExitInvocationFailure, "load template variables: %s", err)
+		}
+
+		if len(clusters) > 1 {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "# %s\n", cluster)
+		}
+
+		err = printVariableSources(w, resolved)
+		if err != nil {
+			return Errorf(ExitInternalError, "print template variables: %s", err)
+		}
+	}
+
+	return nil
+}
+
+func printVariableSources(w io.Writer, resolved *ResolvedVariables) error {
+	paths := make([]string, 0, len(resolved.Sources))
+	for path := range resolved.Sources {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VARIABLE\tVALUE\tSOURCE")
+	for _, path := range paths {
+		value := RedactSecrets(fmt.Sprintf("%v", resolved.lookup(path)))
+		value = strings.ReplaceAll(value, "\n", `\n`)
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", path, value, resolved.Sources[path])
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,86 @@
+package deployclient_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/nais/deploy/pkg/deployclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveTemplateVariables(t *testing.T) {
+	common := writeTemplate(t, "common.yaml", "image: foo\ningress:\n  host: nav.no\n  path: /\nreplicas: 2\n")
+	overrides := writeTemplate(t, "overrides.yaml", "ingress:\n  path: /api\n")
+	cluster := writeTemplate(t, "dev-gcp.yaml", "ingress:\n  host: dev.nav.no\nreplicas:\n  min: 1\n")
+
+	cfg := validConfig()
+	cfg.Cluster = "dev-gcp"
+	cfg.VariablesFiles = []string{common, overrides}
+	cfg.ClusterVariablesPath = filepath.Join(filepath.Dir(cluster), deployclient.ClusterPlaceholder+".yaml")
+	cfg.VariablesEnvPrefix = "DEPLOY_VAR_"
+	cfg.Variables = []string{"image=bar"}
+
+	environ := []string{"DEPLOY_VAR_tag=1.2.3", "DEPLOY_VAR_=empty", "HOME=/root"}
+
+	resolved, err := deployclient.ResolveTemplateVariables(cfg, environ)
+	assert.NoError(t, err)
+
+	assert.Equal(t, deployclient.TemplateVariables{
+		"image": "bar",
+		"ingress": map[string]interface{}{
+			"host": "dev.nav.no",
+			"path": "/api",
+		},
+		"replicas": map[string]interface{}{
+			"min": float64(1),
+		},
+		"tag": "1.2.3",
+	}, resolved.Variables)
+
+	assert.Equal(t, map[string]string{
+		"image":        deployclient.VariableSourceFlag,
+		"ingress.host": cluster,
+		"ingress.path": overrides,
+		"replicas.min": cluster,
+		"tag":          deployclient.VariableSourceEnvironment,
+	}, resolved.Sources)
+
+	assert.Equal(t, []string{common, overrides, cluster}, resolved.Files)
+
+	// Missing cluster files are ignored.
+	cfg.Cluster = "prod-gcp"
+	resolved, err = deployclient.ResolveTemplateVariables(cfg, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "nav.no", resolved.Variables["ingress"].(map[string]interface{})["host"])
+	assert.Equal(t, []string{common, overrides}, resolved.Files)
+}
+
+func TestExplainVariables(t *testing.T) {
+	vars := writeTemplate(t, "vars.yaml", "image: foo\nsecrets:\n  password: explain-me-not\n")
+
+	cfg := validConfig()
+	cfg.Cluster = "dev-fss,dev-gcp"
+	cfg.VariablesFiles = []string{vars}
+	cfg.ClusterVariablesFiles = map[string]string{
+		"dev-gcp": writeTemplate(t, "dev-gcp.yaml", "image: bar\n"),
+	}
+	cfg.Variables = []string{"tag=1"}
+
+	stdout := &bytes.Buffer{}
+	d := deployclient.Deployer{Stdout: stdout}
+	assert.NoError(t, d.ExplainVariables(cfg))
+
+	expected := "# dev-fss\n" +
+		"VARIABLE          VALUE  SOURCE\n" +
+		"image             foo    " + vars + "\n" +
+		"secrets.password  ***    " + vars + "\n" +
+		"tag               1      --var\n" +
+		"\n" +
+		"# dev-gcp\n" +
+		"VARIABLE          VALUE  SOURCE\n" +
+		"image             bar    " + cfg.ClusterVariablesFiles["dev-gcp"] + "\n" +
+		"secrets.password  ***    " + vars + "\n" +
+		"tag               1      --var\n"
+	assert.Equal(t, expected, stdout.String())
+}
@@ -34,6 +34,10 @@ func Run(op *operation.Operation, client kubeclient.Interface) {
 
 	failure := func(err error) {
 		op.Cancel()
+		if op.CancelledByUser() {
+			op.StatusChan <- pb.NewCancelledStatus(op.Request)
+			return
+		}
 		op.StatusChan <- pb.NewFailureStatus(op.Request, err)
 	}
 
@@ -53,6 +57,11 @@ func Run(op *operation.Operation, client kubeclient.Interface) {
 		return
 	}
 
+	if op.Request.GetDryRun() {
+		dryRun(op, client, resources)
+		return
+	}
+
 	wait := sync.WaitGroup{}
 	errors := make(chan error, len(resources))
 
@@ -145,7 +154,10 @@ func Run(op *operation.Operation, client kubeclient.Interface) {
 		op.Cancel()
 
 		errCount := len(errors)
-		if errCount > 0 {
+		if op.CancelledByUser() {
+			op.StatusChan <- pb.NewCancelledStatus(op.Request)
+			op.Trace.SetStatus(codes.Error, "Deployment cancelled")
+		} else if errCount > 0 {
 			err := <-errors
 			close(errors)
 			aggregateError := fmt.Errorf("%s (total of %d errors)", err, errCount)
@@ -40,6 +40,7 @@ type testSpec struct {
 	endStatus         *pb.DeploymentStatus // which end state we expect
 	deployedResources []client.Object      // list of Kubernetes resources expected to be applied to the cluster - only checks name and namespace
 	processing        processCallback      // processing that happens in a coroutine together with deployd.Run(). Requires all resources in `deployedResources` to exist.
+	dryRun            bool                 // submit the deployment request as a server-side dry run
 }
 
 var tests = []testSpec{
@@ -173,6 +174,30 @@ var tests = []testSpec{
 		},
 		deployedResources: nil,
 	},
+
+	// Dry run of a valid resource succeeds
+	{
+		fixture: "testdata/configmap.json",
+		timeout: 2 * time.Second,
+		dryRun:  true,
+		endStatus: &pb.DeploymentStatus{
+			State:   pb.DeploymentState_success,
+			Message: "Dry run completed successfully; all 1 resources passed validation.",
+		},
+		deployedResources: nil,
+	},
+
+	// Dry run reports strict decoding errors without creating anything
+	{
+		fixture: "testdata/application-unknownfields-create.json",
+		timeout: 2 * time.Second,
+		dryRun:  true,
+		endStatus: &pb.DeploymentStatus{
+			State:   pb.DeploymentState_failure,
+			Message: "dry run: 1 of 1 resources failed validation",
+		},
+		deployedResources: nil,
+	},
 }
 
 type testRig struct {
@@ -446,6 +471,7 @@ func subTest(t *testing.T, rig *testRig, test testSpec, team string) {
 			ID:         test.fixture,
 			Team:       team,
 			Kubernetes: kubes,
+			DryRun:     test.dryRun,
 		},
 		Trace:      span,
 		StatusChan: rig.statusChan,
@@ -0,0 +1,179 @@
+package deployd
+
+import (
+	"fmt"
+
+	"github.com/ghodss/yaml"
+	"github.com/nais/deploy/pkg/deployd/kubeclient"
+	"github.com/nais/deploy/pkg/deployd/operation"
+	"github.com/nais/deploy/pkg/deployd/strategy"
+	"github.com/nais/deploy/pkg/k8sutils"
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/nais/deploy/pkg/telemetry"
+	nais_io_v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"github.com/pmezard/go-difflib/difflib"
+	"go.opentelemetry.io/otel/codes"
+	otrace "go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Fields populated by the API server that would otherwise show up as changes in every diff.
+var serverManagedFields = [][]string{
+	{"metadata", "creationTimestamp"},
+	{"metadata", "generation"},
+	{"metadata", "managedFields"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "selfLink"},
+	{"metadata", "uid"},
+	{"status"},
+}
+
+// Diff compares each resource in the request with its live counterpart in the cluster.
+// The desired state is computed with a server-side dry run, so that defaulting and
+// mutating admission webhooks are taken into account. Nothing is persisted.
+func Diff(op *operation.Operation, client kubeclient.Interface) *pb.DiffResult {
+	defer op.Trace.End()
+	defer op.Cancel()
+
+	op.Logger.Infof("Starting diff")
+
+	result := &pb.DiffResult{
+		Request: op.Request,
+	}
+
+	err := op.Context.Err()
+	if err != nil {
+		result.Error = err.Error()
+		op.Trace.SetStatus(codes.Error, err.Error())
+		return result
+	}
+
+	resources, err := op.ExtractResources()
+	if err != nil {
+		result.Error = err.Error()
+		op.Trace.SetStatus(codes.Error, err.Error())
+		return result
+	}
+
+	for _, resource := range resources {
+		identifier := k8sutils.ResourceIdentifier(resource)
+
+		spanName := fmt.Sprintf("%s/%s (diff)", identifier.Kind, identifier.Name)
+		_, span := telemetry.Tracer().Start(op.Context, spanName, otrace.WithSpanKind(otrace.SpanKindClient))
+		telemetry.AddDeploymentRequestSpanAttributes(span, op.Request)
+
+		resourceDiff, err := diffResource(op, client, resource, span)
+		if err != nil {
+			err = fmt.Errorf("%s: %s", identifier.String(), err)
+			span.SetStatus(codes.Error, err.Error())
+			op.Logger.Error(err)
+			resourceDiff.Error = err.Error()
+		} else {
+			span.SetStatus(codes.Ok, resourceDiff.GetChange().String())
+		}
+		span.End()
+
+		result.Resources = append(result.Resources, resourceDiff)
+	}
+
+	op.Trace.SetStatus(codes.Ok, "Diff completed")
+
+	return result
+}
+
+func diffResource(op *operation.Operation, client kubeclient.Interface, resource unstructured.Unstructured, span otrace.Span) (*pb.ResourceDiff, error) {
+	resourceDiff := &pb.ResourceDiff{
+		ApiVersion: resource.GetAPIVersion(),
+		Kind:       resource.GetKind(),
+		Namespace:  resource.GetNamespace(),
+		Name:       resource.GetName(),
+	}
+
+	resourceInterface, err := client.ResourceInterface(&resource)
+	if err != nil {
+		return resourceDiff, err
+	}
+
+	live, err := resourceInterface.Get(op.Context, resource.GetName(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		live = nil
+	} else if err != nil {
+		return resourceDiff, fmt.Errorf("get existing resource: %s", err)
+	}
+
+	desired, err := strategy.NewDryRunDeployStrategy(resourceInterface).Deploy(op.Context, resource, span)
+	if err != nil {
+		return resourceDiff, err
+	}
+
+	liveYAML, err := diffableYAML(live)
+	if err != nil {
+		return resourceDiff, fmt.Errorf("encode live resource: %s", err)
+	}
+
+	desiredYAML, err := diffableYAML(desired)
+	if err != nil {
+		return resourceDiff, fmt.Errorf("encode desired resource: %s", err)
+	}
+
+	switch {
+	case live == nil:
+		resourceDiff.Change = pb.ResourceChange_created
+	case liveYAML == desiredYAML:
+		resourceDiff.Change = pb.ResourceChange_unchanged
+		return resourceDiff, nil
+	default:
+		resourceDiff.Change = pb.ResourceChange_updated
+	}
+
+	path := resourcePath(resource)
+	resourceDiff.Diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(liveYAML),
+		B:        difflib.SplitLines(desiredYAML),
+		FromFile: "live/" + path,
+		ToFile:   "desired/" + path,
+		Context:  3,
+	})
+	if err != nil {
+		return resourceDiff, fmt.Errorf("generate diff: %s", err)
+	}
+
+	return resourceDiff, nil
+}
+
+// Strip server-managed fields from a resource and encode it as YAML.
+// A nil resource yields an empty document.
+func diffableYAML(resource *unstructured.Unstructured) (string, error) {
+	if resource == nil {
+		return "", nil
+	}
+
+	obj := resource.DeepCopy()
+	for _, field := range serverManagedFields {
+		unstructured.RemoveNestedField(obj.Object, field...)
+	}
+
+	annotations := obj.GetAnnotations()
+	delete(annotations, nais_io_v1.DeploymentCorrelationIDAnnotation)
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(obj.Object, "metadata", "annotations")
+	} else {
+		obj.SetAnnotations(annotations)
+	}
+
+	data, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func resourcePath(resource unstructured.Unstructured) string {
+	if len(resource.GetNamespace()) > 0 {
+		return fmt.Sprintf("%s/%s/%s", resource.GetKind(), resource.GetNamespace(), resource.GetName())
+	}
+	return fmt.Sprintf("%s/%s", resource.GetKind(), resource.GetName())
+}
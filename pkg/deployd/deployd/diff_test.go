@@ -0,0 +1,53 @@
+package deployd
+
+import (
+	"testing"
+
+	nais_io_v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDiffableYAML(t *testing.T) {
+	resource := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":              "foo",
+				"namespace":         "aura",
+				"uid":               "ae4b2a5e-1b84-4e8e-9b5c-2d2d8b5c1f19",
+				"resourceVersion":   "12345",
+				"generation":        int64(3),
+				"creationTimestamp": "2024-01-01T00:00:00Z",
+				"managedFields":     []interface{}{map[string]interface{}{"manager": "deployd"}},
+				"annotations": map[string]interface{}{
+					nais_io_v1.DeploymentCorrelationIDAnnotation: "correlation-id",
+				},
+			},
+			"data": map[string]interface{}{
+				"key": "value",
+			},
+			"status": map[string]interface{}{
+				"ready": true,
+			},
+		},
+	}
+
+	expected := `apiVersion: v1
+data:
+  key: value
+kind: ConfigMap
+metadata:
+  name: foo
+  namespace: aura
+`
+
+	output, err := diffableYAML(resource)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, output)
+
+	output, err = diffableYAML(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, output)
+}
@@ -0,0 +1,64 @@
+package deployd
+
+import (
+	"fmt"
+
+	"github.com/nais/deploy/pkg/deployd/kubeclient"
+	"github.com/nais/deploy/pkg/deployd/operation"
+	"github.com/nais/deploy/pkg/deployd/strategy"
+	"github.com/nais/deploy/pkg/k8sutils"
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/nais/deploy/pkg/telemetry"
+	"go.opentelemetry.io/otel/codes"
+	otrace "go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Submit all resources to Kubernetes using server-side dry run, and report
+// the validation result of each resource back as a separate status.
+// Unlike a real deployment, validation continues after the first error,
+// so that the complete report is available in a single run.
+func dryRun(op *operation.Operation, client kubeclient.Interface, resources []unstructured.Unstructured) {
+	defer op.Trace.End()
+	defer op.Cancel()
+
+	errors := make([]error, 0)
+
+	for _, resource := range resources {
+		addCorrelationID(&resource, op.Request.GetID())
+		identifier := k8sutils.ResourceIdentifier(resource)
+
+		spanName := fmt.Sprintf("%s/%s (dry run)", identifier.Kind, identifier.Name)
+		_, span := telemetry.Tracer().Start(op.Context, spanName, otrace.WithSpanKind(otrace.SpanKindClient))
+		telemetry.AddDeploymentRequestSpanAttributes(span, op.Request)
+
+		resourceInterface, err := client.ResourceInterface(&resource)
+		if err == nil {
+			_, err = strategy.NewDryRunDeployStrategy(resourceInterface).Deploy(op.Context, resource, span)
+		}
+
+		if err != nil {
+			err = fmt.Errorf("%s: %s", identifier.String(), err)
+			span.SetStatus(codes.Error, err.Error())
+			span.End()
+			op.Logger.Error(err)
+			op.StatusChan <- pb.NewInProgressStatus(op.Request, "Validation failed: %s", err)
+			errors = append(errors, err)
+			continue
+		}
+
+		span.SetStatus(codes.Ok, "Resource passed validation")
+		span.End()
+		op.StatusChan <- pb.NewInProgressStatus(op.Request, "Validated %s", identifier.String())
+	}
+
+	if len(errors) > 0 {
+		aggregateError := fmt.Errorf("dry run: %d of %d resources failed validation", len(errors), len(resources))
+		op.StatusChan <- pb.NewFailureStatus(op.Request, aggregateError)
+		op.Trace.SetStatus(codes.Error, aggregateError.Error())
+		return
+	}
+
+	op.StatusChan <- pb.NewDryRunSuccessStatus(op.Request, len(resources))
+	op.Trace.SetStatus(codes.Ok, "All resources passed validation")
+}
@@ -3,6 +3,8 @@ package operation
 import (
 	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 
 	"github.com/nais/deploy/pkg/k8sutils"
 	"github.com/nais/deploy/pkg/pb"
@@ -18,6 +20,8 @@ type Operation struct {
 	Request    *pb.DeploymentRequest
 	Trace      trace.Span
 	StatusChan chan<- *pb.DeploymentStatus
+
+	cancelledByUser atomic.Bool
 }
 
 func (op *Operation) ExtractResources() ([]unstructured.Unstructured, error) {
@@ -32,3 +36,67 @@ func (op *Operation) ExtractResources() ([]unstructured.Unstructured, error) {
 
 	return resources, nil
 }
+
+// CancelByUser aborts the operation, and marks it as cancelled so that it is reported as such.
+func (op *Operation) CancelByUser() {
+	op.cancelledByUser.Store(true)
+	op.Cancel()
+}
+
+// CancelledByUser returns true if the operation was aborted using CancelByUser.
+func (op *Operation) CancelledByUser() bool {
+	return op.cancelledByUser.Load()
+}
+
+// Registry keeps track of operations in progress, so that they can be cancelled by request ID.
+type Registry struct {
+	lock       sync.Mutex
+	operations map[string]*Operation
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		operations: make(map[string]*Operation),
+	}
+}
+
+// Add registers an operation, and removes it again once its context is done.
+// Returns false without registering it if an operation with the same request ID is already in progress,
+// e.g. because hookd sent the request again after a reconnect.
+func (r *Registry) Add(op *Operation) bool {
+	id := op.Request.GetID()
+
+	r.lock.Lock()
+	if _, ok := r.operations[id]; ok {
+		r.lock.Unlock()
+		return false
+	}
+	r.operations[id] = op
+	r.lock.Unlock()
+
+	go func() {
+		<-op.Context.Done()
+		r.lock.Lock()
+		if r.operations[id] == op {
+			delete(r.operations, id)
+		}
+		r.lock.Unlock()
+	}()
+
+	return true
+}
+
+// Cancel aborts the operation with the given request ID.
+// Returns false if no such operation is in progress.
+func (r *Registry) Cancel(id string) bool {
+	r.lock.Lock()
+	op, ok := r.operations[id]
+	r.lock.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	op.CancelByUser()
+	return true
+}
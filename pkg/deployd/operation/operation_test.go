@@ -0,0 +1,41 @@
+package operation_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nais/deploy/pkg/deployd/operation"
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+func newOperation(id string) *operation.Operation {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &operation.Operation{
+		Context: ctx,
+		Cancel:  cancel,
+		Request: &pb.DeploymentRequest{ID: id},
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := operation.NewRegistry()
+
+	first := newOperation("deployment")
+	assert.True(t, registry.Add(first))
+
+	// the same request received again while in progress
+	duplicate := newOperation("deployment")
+	assert.False(t, registry.Add(duplicate))
+
+	assert.True(t, registry.Cancel("deployment"))
+	assert.True(t, first.CancelledByUser())
+	assert.False(t, duplicate.CancelledByUser())
+	assert.False(t, registry.Cancel("unknown"))
+
+	// finished operations are removed, so the ID may be used again
+	assert.Eventually(t, func() bool {
+		return registry.Add(newOperation("deployment"))
+	}, time.Second, 10*time.Millisecond)
+}
@@ -16,18 +16,33 @@ func NewDeployStrategy(namespacedResource dynamic.ResourceInterface) DeployStrat
 	return createOrUpdateStrategy{client: namespacedResource}
 }
 
+// NewDryRunDeployStrategy returns a strategy that submits resources to the API server
+// with all admission, validation and authorization checks, but never persists them.
+func NewDryRunDeployStrategy(namespacedResource dynamic.ResourceInterface) DeployStrategy {
+	return createOrUpdateStrategy{client: namespacedResource, dryRun: true}
+}
+
 type DeployStrategy interface {
 	Deploy(ctx context.Context, resource unstructured.Unstructured, trace trace.Span) (*unstructured.Unstructured, error)
 }
 
 type createOrUpdateStrategy struct {
 	client dynamic.ResourceInterface
+	dryRun bool
+}
+
+func (c createOrUpdateStrategy) dryRunOptions() []string {
+	if c.dryRun {
+		return []string{metav1.DryRunAll}
+	}
+	return nil
 }
 
 func (c createOrUpdateStrategy) Deploy(ctx context.Context, resource unstructured.Unstructured, trace trace.Span) (*unstructured.Unstructured, error) {
 	existing, err := c.client.Get(ctx, resource.GetName(), metav1.GetOptions{})
 	if errors.IsNotFound(err) {
 		deployed, err := c.client.Create(ctx, &resource, metav1.CreateOptions{
+			DryRun:          c.dryRunOptions(),
 			FieldValidation: metav1.FieldValidationStrict,
 		})
 		if err != nil {
@@ -40,6 +55,7 @@ func (c createOrUpdateStrategy) Deploy(ctx context.Context, resource unstructure
 
 	resource.SetResourceVersion(existing.GetResourceVersion())
 	updated, err := c.client.Update(ctx, &resource, metav1.UpdateOptions{
+		DryRun:          c.dryRunOptions(),
 		FieldValidation: metav1.FieldValidationStrict,
 	})
 	if err != nil {
@@ -2,9 +2,12 @@ package deployserver
 
 import (
 	"context"
+	"errors"
+	"slices"
 
 	"github.com/google/uuid"
 	"github.com/nais/deploy/pkg/grpc/dispatchserver"
+	auth_interceptor "github.com/nais/deploy/pkg/grpc/interceptor/auth"
 	"github.com/nais/deploy/pkg/hookd/database"
 	database_mapper "github.com/nais/deploy/pkg/hookd/database/mapper"
 	"github.com/nais/deploy/pkg/k8sutils"
@@ -12,10 +15,18 @@ import (
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 var ErrDatabaseUnavailable = status.Errorf(codes.Unavailable, "database is unavailable; try again later")
 
+var ErrClusterRequired = status.Errorf(codes.InvalidArgument, "cluster is required")
+
+const (
+	DefaultListLimit = 30
+	MaxListLimit     = 500
+)
+
 type deployServer struct {
 	pb.UnimplementedDeployServer
 	dispatchServer  dispatchserver.DispatchServer
@@ -59,6 +70,10 @@ func (ds *deployServer) addToDatabase(ctx context.Context, request *pb.Deploymen
 		Created:          pb.TimestampAsTime(request.GetTime()),
 		GitHubRepository: request.GetRepository().FullNamePtr(),
 	}
+	if len(request.GetRollbackOf()) > 0 {
+		rollbackOf := request.GetRollbackOf()
+		deployment.RollbackOf = &rollbackOf
+	}
 
 	// Write deployment request to database
 	err = ds.deploymentStore.WriteDeployment(ctx, deployment)
@@ -87,6 +102,17 @@ func (ds *deployServer) addToDatabase(ctx context.Context, request *pb.Deploymen
 				return ErrDatabaseUnavailable
 			}
 		}
+
+		// Keep the payload around so that this deployment can be rolled back to later on.
+		payload, err := proto.MarshalOptions{Deterministic: true}.Marshal(request.GetKubernetes())
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "encode Kubernetes resources: %s", err)
+		}
+		err = ds.deploymentStore.WriteDeploymentPayload(ctx, deployment.ID, payload)
+		if err != nil {
+			logger.Error(err)
+			return ErrDatabaseUnavailable
+		}
 	} else {
 		logger.Error(err)
 		return ErrDatabaseUnavailable
@@ -96,6 +122,11 @@ func (ds *deployServer) addToDatabase(ctx context.Context, request *pb.Deploymen
 }
 
 func (ds *deployServer) Deploy(ctx context.Context, request *pb.DeploymentRequest) (*pb.DeploymentStatus, error) {
+	// The interceptor only authorizes the cluster of requests that name one.
+	if len(request.GetCluster()) == 0 {
+		return nil, ErrClusterRequired
+	}
+
 	uuidstr, err := ds.uuidgen()
 	if err != nil {
 		return nil, err
@@ -103,17 +134,33 @@ func (ds *deployServer) Deploy(ctx context.Context, request *pb.DeploymentReques
 	request.ID = uuidstr
 
 	logger := log.WithFields(request.LogFields())
-	logger.Infof("Received deployment request")
 
-	logger.Debugf("Writing deployment to database")
-	err = ds.addToDatabase(ctx, request)
-	if err != nil {
-		logger.Errorf("Write deployment to database: %s", err)
-		return nil, err
+	if request.GetDryRun() {
+		// Dry runs are validated by deployd, but never persisted as real deployments.
+		logger.Infof("Received dry run deployment request")
+		_, err = k8sutils.ResourcesFromDeploymentRequest(request)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid Kubernetes resources in request: %s", err)
+		}
+	} else {
+		logger.Infof("Received deployment request")
+
+		logger.Debugf("Writing deployment to database")
+		err = ds.addToDatabase(ctx, request)
+		if err != nil {
+			logger.Errorf("Write deployment to database: %s", err)
+			return nil, err
+		}
+		logger.Debugf("Deployment committed to database")
 	}
-	logger.Debugf("Deployment committed to database")
 
-	err = ds.dispatchServer.SendDeploymentRequest(ctx, request)
+	return ds.dispatch(ctx, request)
+}
+
+func (ds *deployServer) dispatch(ctx context.Context, request *pb.DeploymentRequest) (*pb.DeploymentStatus, error) {
+	logger := log.WithFields(request.LogFields())
+
+	err := ds.dispatchServer.SendDeploymentRequest(ctx, request)
 	if err != nil {
 		logger.Errorf("Dispatch deployment: %s", err)
 		return nil, err
@@ -128,17 +175,123 @@ func (ds *deployServer) Deploy(ctx context.Context, request *pb.DeploymentReques
 	return st, nil
 }
 
+func (ds *deployServer) Diff(ctx context.Context, request *pb.DeploymentRequest) (*pb.DiffResult, error) {
+	// The interceptor only authorizes the cluster of requests that name one.
+	if len(request.GetCluster()) == 0 {
+		return nil, ErrClusterRequired
+	}
+
+	uuidstr, err := ds.uuidgen()
+	if err != nil {
+		return nil, err
+	}
+	request.ID = uuidstr
+	request.DryRun = false
+
+	logger := log.WithFields(request.LogFields())
+	logger.Infof("Received diff request")
+
+	_, err = k8sutils.ResourcesFromDeploymentRequest(request)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid Kubernetes resources in request: %s", err)
+	}
+
+	result, err := ds.dispatchServer.SendDiffRequest(ctx, request)
+	if err != nil {
+		logger.Errorf("Diff deployment: %s", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (ds *deployServer) Cancel(ctx context.Context, request *pb.DeploymentRequest) (*pb.DeploymentStatus, error) {
+	logger := log.WithFields(request.LogFields())
+
+	deployment, err := ds.deploymentStore.Deployment(ctx, request.GetID())
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "deployment '%s' not found", request.GetID())
+	} else if err != nil {
+		logger.Errorf("Get deployment from database: %s", err)
+		return nil, ErrDatabaseUnavailable
+	}
+
+	if deployment.Team != request.GetTeam() {
+		return nil, status.Errorf(codes.PermissionDenied, "deployment '%s' does not belong to team '%s'", request.GetID(), request.GetTeam())
+	}
+
+	dbStatus, err := ds.deploymentStore.DeploymentStatus(ctx, request.GetID())
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		logger.Errorf("Get deployment status from database: %s", err)
+		return nil, ErrDatabaseUnavailable
+	}
+	if len(dbStatus) > 0 {
+		current := database_mapper.PbStatus(dbStatus[0])
+		if current.GetState().Finished() {
+			return nil, status.Errorf(codes.FailedPrecondition, "deployment has already finished with state '%s'", current.GetState())
+		}
+	}
+
+	cancelRequest := database_mapper.PbRequest(*deployment)
+	logger = log.WithFields(cancelRequest.LogFields())
+
+	err = auth_interceptor.AuthorizeCluster(ctx, cancelRequest.GetCluster())
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("Received cancel request")
+
+	err = ds.dispatchServer.SendCancelRequest(ctx, cancelRequest)
+	if err != nil {
+		logger.Errorf("Dispatch cancel request: %s", err)
+		return nil, err
+	}
+
+	return pb.NewInProgressStatus(cancelRequest, "Cancellation has been requested; waiting for deployment to stop."), nil
+}
+
 func (ds *deployServer) Status(request *pb.DeploymentRequest, server pb.Deploy_StatusServer) error {
 	logger := log.WithFields(request.LogFields())
 	logger.Debugf("Status stream opened")
 	defer logger.Debugf("Status stream closed")
 
-	dbStatus, err := ds.deploymentStore.DeploymentStatus(server.Context(), request.GetID())
-	if err == nil && len(dbStatus) > 0 {
-		err = server.Send(database_mapper.PbStatus(dbStatus[0]))
-	}
-	if err != nil {
-		return err
+	if request.GetDryRun() {
+		// Dry runs are not persisted, so replay any statuses received before the stream was opened.
+		team, statuses := ds.dispatchServer.DryRunStatuses(request.GetID())
+		if len(statuses) > 0 && team != request.GetTeam() {
+			return status.Errorf(codes.PermissionDenied, "deployment '%s' does not belong to team '%s'", request.GetID(), request.GetTeam())
+		}
+		for _, st := range statuses {
+			err := server.Send(st)
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		deployment, err := ds.deploymentStore.Deployment(server.Context(), request.GetID())
+		if errors.Is(err, database.ErrNotFound) {
+			return status.Errorf(codes.NotFound, "deployment '%s' not found", request.GetID())
+		} else if err != nil {
+			logger.Errorf("Get deployment from database: %s", err)
+			return ErrDatabaseUnavailable
+		}
+
+		if deployment.Team != request.GetTeam() {
+			return status.Errorf(codes.PermissionDenied, "deployment '%s' does not belong to team '%s'", request.GetID(), request.GetTeam())
+		}
+
+		err = auth_interceptor.AuthorizeCluster(server.Context(), database_mapper.PbRequest(*deployment).GetCluster())
+		if err != nil {
+			return err
+		}
+
+		dbStatus, err := ds.deploymentStore.DeploymentStatus(server.Context(), request.GetID())
+		if err == nil && len(dbStatus) > 0 {
+			err = server.Send(database_mapper.PbStatus(dbStatus[0]))
+		}
+		if err != nil {
+			return err
+		}
 	}
 
 	ch := make(chan *pb.DeploymentStatus, 16)
@@ -147,7 +300,8 @@ func (ds *deployServer) Status(request *pb.DeploymentRequest, server pb.Deploy_S
 	go ds.dispatchServer.StreamStatus(server.Context(), ch)
 
 	for st := range ch {
-		if st.GetRequest().GetID() != request.GetID() {
+		// Statuses of other teams' deployments are never sent, even if the deployment ID is known.
+		if st.GetRequest().GetID() != request.GetID() || st.GetRequest().GetTeam() != request.GetTeam() {
 			continue
 		}
 		err := server.Send(st)
@@ -159,3 +313,81 @@ func (ds *deployServer) Status(request *pb.DeploymentRequest, server pb.Deploy_S
 	}
 	return nil
 }
+
+func (ds *deployServer) ListDeployments(ctx context.Context, request *pb.ListDeploymentsRequest) (*pb.ListDeploymentsResponse, error) {
+	if len(request.GetTeam()) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "team is required")
+	}
+
+	filter := database.DeploymentFilter{
+		Team:       request.GetTeam(),
+		Clusters:   request.GetClusters(),
+		Repository: request.GetRepository(),
+		Limit:      int(request.GetLimit()),
+	}
+	for _, state := range request.GetStates() {
+		filter.States = append(filter.States, state.String())
+	}
+	if request.GetFrom() != nil {
+		filter.From = pb.TimestampAsTime(request.GetFrom())
+	}
+	if request.GetTo() != nil {
+		filter.To = pb.TimestampAsTime(request.GetTo())
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultListLimit
+	} else if filter.Limit > MaxListLimit {
+		filter.Limit = MaxListLimit
+	}
+
+	logger := log.WithField(pb.LogFieldTeam, filter.Team)
+
+	deployments, err := ds.deploymentStore.FilteredDeployments(ctx, filter)
+	if err != nil {
+		logger.Errorf("List deployments from database: %s", err)
+		return nil, ErrDatabaseUnavailable
+	}
+
+	// API keys limited to some clusters don't see deployments to other clusters.
+	// Requests naming clusters outside the key are rejected by the interceptor; this covers listing every cluster.
+	deployments = slices.DeleteFunc(deployments, func(deployment *database.Deployment) bool {
+		return !auth_interceptor.APIKeyAllowsCluster(ctx, database_mapper.PbRequest(*deployment).GetCluster())
+	})
+
+	ids := make([]string, len(deployments))
+	for i, deployment := range deployments {
+		ids[i] = deployment.ID
+	}
+
+	// Statuses and resources of all listed deployments are fetched at once, instead of one query per deployment.
+	statuses, err := ds.deploymentStore.DeploymentStatusByIDs(ctx, ids)
+	if err != nil {
+		logger.Errorf("Get deployment statuses from database: %s", err)
+		return nil, ErrDatabaseUnavailable
+	}
+
+	resources, err := ds.deploymentStore.DeploymentResourcesByIDs(ctx, ids)
+	if err != nil {
+		logger.Errorf("Get deployment resources from database: %s", err)
+		return nil, ErrDatabaseUnavailable
+	}
+
+	response := &pb.ListDeploymentsResponse{
+		Deployments: make([]*pb.DeploymentRecord, 0, len(deployments)),
+	}
+
+	for _, deployment := range deployments {
+		record := &pb.DeploymentRecord{
+			Request: database_mapper.PbRequest(*deployment),
+		}
+		for _, st := range statuses[deployment.ID] {
+			record.Statuses = append(record.Statuses, database_mapper.PbStatus(st))
+		}
+		for _, resource := range resources[deployment.ID] {
+			record.Resources = append(record.Resources, database_mapper.PbResource(resource))
+		}
+		response.Deployments = append(response.Deployments, record)
+	}
+
+	return response, nil
+}
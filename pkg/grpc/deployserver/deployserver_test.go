@@ -0,0 +1,223 @@
+package deployserver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nais/deploy/pkg/grpc/deployserver"
+	"github.com/nais/deploy/pkg/grpc/dispatchserver"
+	"github.com/nais/deploy/pkg/hookd/database"
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCancel(t *testing.T) {
+	ctx := context.Background()
+	cluster := "dev"
+	deployment := &database.Deployment{
+		ID:      "deployment-1",
+		Team:    "aura",
+		Cluster: &cluster,
+		Created: time.Now(),
+	}
+
+	statusWithState := func(state pb.DeploymentState) []database.DeploymentStatus {
+		return []database.DeploymentStatus{{DeploymentID: deployment.ID, Status: state.String(), Created: time.Now()}}
+	}
+
+	t.Run("deployment in progress is cancelled on its cluster", func(t *testing.T) {
+		store := database.NewMockDeploymentStore(t)
+		store.On("Deployment", mock.Anything, deployment.ID).Return(deployment, nil).Once()
+		store.On("DeploymentStatus", mock.Anything, deployment.ID).Return(statusWithState(pb.DeploymentState_in_progress), nil).Once()
+
+		dispatcher := dispatchserver.NewMockDispatchServer(t)
+		dispatcher.On("SendCancelRequest", mock.Anything, mock.MatchedBy(func(req *pb.DeploymentRequest) bool {
+			return req.GetID() == deployment.ID && req.GetCluster() == cluster
+		})).Return(nil).Once()
+
+		server := deployserver.New(dispatcher, store)
+		st, err := server.Cancel(ctx, &pb.DeploymentRequest{ID: deployment.ID, Team: "aura"})
+		assert.NoError(t, err)
+		assert.Equal(t, deployment.ID, st.GetRequest().GetID())
+	})
+
+	t.Run("deployment belonging to another team is not cancelled", func(t *testing.T) {
+		store := database.NewMockDeploymentStore(t)
+		store.On("Deployment", mock.Anything, deployment.ID).Return(deployment, nil).Once()
+
+		server := deployserver.New(dispatchserver.NewMockDispatchServer(t), store)
+		_, err := server.Cancel(ctx, &pb.DeploymentRequest{ID: deployment.ID, Team: "other"})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("finished deployment is not cancelled", func(t *testing.T) {
+		store := database.NewMockDeploymentStore(t)
+		store.On("Deployment", mock.Anything, deployment.ID).Return(deployment, nil).Once()
+		store.On("DeploymentStatus", mock.Anything, deployment.ID).Return(statusWithState(pb.DeploymentState_success), nil).Once()
+
+		server := deployserver.New(dispatchserver.NewMockDispatchServer(t), store)
+		_, err := server.Cancel(ctx, &pb.DeploymentRequest{ID: deployment.ID, Team: "aura"})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("unknown deployment", func(t *testing.T) {
+		store := database.NewMockDeploymentStore(t)
+		store.On("Deployment", mock.Anything, "unknown").Return(nil, database.ErrNotFound).Once()
+
+		server := deployserver.New(dispatchserver.NewMockDispatchServer(t), store)
+		_, err := server.Cancel(ctx, &pb.DeploymentRequest{ID: "unknown", Team: "aura"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+func TestClusterRequired(t *testing.T) {
+	ctx := context.Background()
+	server := deployserver.New(dispatchserver.NewMockDispatchServer(t), database.NewMockDeploymentStore(t))
+
+	_, err := server.Deploy(ctx, &pb.DeploymentRequest{Team: "aura"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = server.Diff(ctx, &pb.DeploymentRequest{Team: "aura"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestStatusOwnership(t *testing.T) {
+	cluster := "dev"
+	deployment := &database.Deployment{
+		ID:      "deployment-1",
+		Team:    "aura",
+		Cluster: &cluster,
+		Created: time.Now(),
+	}
+
+	t.Run("deployment belonging to another team is not streamed", func(t *testing.T) {
+		store := database.NewMockDeploymentStore(t)
+		store.On("Deployment", mock.Anything, deployment.ID).Return(deployment, nil).Once()
+
+		stream := pb.NewMockDeploy_StatusServer(t)
+		stream.On("Context").Return(context.Background())
+
+		server := deployserver.New(dispatchserver.NewMockDispatchServer(t), store)
+		err := server.Status(&pb.DeploymentRequest{ID: deployment.ID, Team: "other"}, stream)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("dry run belonging to another team is not streamed", func(t *testing.T) {
+		request := &pb.DeploymentRequest{ID: "dry-run-1", Team: "aura", DryRun: true}
+
+		dispatcher := dispatchserver.NewMockDispatchServer(t)
+		dispatcher.On("DryRunStatuses", request.ID).Return("aura", []*pb.DeploymentStatus{pb.NewInProgressStatus(request, "validating")}).Once()
+
+		stream := pb.NewMockDeploy_StatusServer(t)
+
+		server := deployserver.New(dispatcher, database.NewMockDeploymentStore(t))
+		err := server.Status(&pb.DeploymentRequest{ID: request.ID, Team: "other", DryRun: true}, stream)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("live statuses of other teams with the same deployment ID are not streamed", func(t *testing.T) {
+		store := database.NewMockDeploymentStore(t)
+		store.On("Deployment", mock.Anything, deployment.ID).Return(deployment, nil).Once()
+		store.On("DeploymentStatus", mock.Anything, deployment.ID).Return(nil, nil).Once()
+
+		dispatcher := dispatchserver.NewMockDispatchServer(t)
+		dispatcher.On("StreamStatus", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			ch := args.Get(1).(chan<- *pb.DeploymentStatus)
+			ch <- pb.NewSuccessStatus(&pb.DeploymentRequest{ID: deployment.ID, Team: "other"})
+			ch <- pb.NewSuccessStatus(&pb.DeploymentRequest{ID: deployment.ID, Team: "aura"})
+			close(ch)
+		}).Return().Once()
+
+		stream := pb.NewMockDeploy_StatusServer(t)
+		stream.On("Context").Return(context.Background())
+		stream.On("Send", mock.MatchedBy(func(st *pb.DeploymentStatus) bool {
+			return st.GetRequest().GetTeam() == "aura"
+		})).Return(nil).Once()
+
+		server := deployserver.New(dispatcher, store)
+		err := server.Status(&pb.DeploymentRequest{ID: deployment.ID, Team: "aura"}, stream)
+		assert.NoError(t, err)
+	})
+
+	t.Run("unknown deployment", func(t *testing.T) {
+		store := database.NewMockDeploymentStore(t)
+		store.On("Deployment", mock.Anything, "unknown").Return(nil, database.ErrNotFound).Once()
+
+		stream := pb.NewMockDeploy_StatusServer(t)
+		stream.On("Context").Return(context.Background())
+
+		server := deployserver.New(dispatchserver.NewMockDispatchServer(t), store)
+		err := server.Status(&pb.DeploymentRequest{ID: "unknown", Team: "aura"}, stream)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+func TestListDeployments(t *testing.T) {
+	ctx := context.Background()
+	cluster := "dev"
+	repository := "navikt/myrepo"
+	deployment := &database.Deployment{
+		ID:               "deployment-1",
+		Team:             "aura",
+		Cluster:          &cluster,
+		GitHubRepository: &repository,
+		Created:          time.Now(),
+	}
+	pending := &database.Deployment{
+		ID:      "deployment-2",
+		Team:    "aura",
+		Cluster: &cluster,
+		Created: time.Now(),
+	}
+
+	t.Run("deployments are listed with statuses and resources", func(t *testing.T) {
+		from := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+		store := database.NewMockDeploymentStore(t)
+		store.On("FilteredDeployments", mock.Anything, mock.MatchedBy(func(filter database.DeploymentFilter) bool {
+			return filter.Team == "aura" &&
+				filter.Repository == repository &&
+				assert.ObjectsAreEqual([]string{"success"}, filter.States) &&
+				filter.From.Equal(from) &&
+				filter.To.IsZero() &&
+				filter.Limit == deployserver.DefaultListLimit
+		})).Return([]*database.Deployment{deployment, pending}, nil).Once()
+		store.On("DeploymentStatusByIDs", mock.Anything, []string{deployment.ID, pending.ID}).Return(map[string][]database.DeploymentStatus{
+			deployment.ID: {{DeploymentID: deployment.ID, Status: "success", Created: time.Now()}},
+		}, nil).Once()
+		store.On("DeploymentResourcesByIDs", mock.Anything, []string{deployment.ID, pending.ID}).Return(map[string][]database.DeploymentResource{
+			deployment.ID: {{DeploymentID: deployment.ID, Kind: "Application", Name: "myapplication"}},
+		}, nil).Once()
+
+		server := deployserver.New(dispatchserver.NewMockDispatchServer(t), store)
+		response, err := server.ListDeployments(ctx, &pb.ListDeploymentsRequest{
+			Team:       "aura",
+			Repository: repository,
+			States:     []pb.DeploymentState{pb.DeploymentState_success},
+			From:       pb.TimeAsTimestamp(from),
+		})
+		assert.NoError(t, err)
+		assert.Len(t, response.GetDeployments(), 2)
+
+		record := response.GetDeployments()[0]
+		assert.Equal(t, deployment.ID, record.GetRequest().GetID())
+		assert.Equal(t, repository, record.GetRequest().GetRepository().FullName())
+		assert.Equal(t, pb.DeploymentState_success, record.GetStatuses()[0].GetState())
+		assert.Equal(t, "Application", record.GetResources()[0].GetKind())
+
+		record = response.GetDeployments()[1]
+		assert.Equal(t, pending.ID, record.GetRequest().GetID())
+		assert.Empty(t, record.GetStatuses())
+		assert.Empty(t, record.GetResources())
+	})
+
+	t.Run("team is required", func(t *testing.T) {
+		server := deployserver.New(dispatchserver.NewMockDispatchServer(t), database.NewMockDeploymentStore(t))
+		_, err := server.ListDeployments(ctx, &pb.ListDeploymentsRequest{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
@@ -0,0 +1,154 @@
+package deployserver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	auth_interceptor "github.com/nais/deploy/pkg/grpc/interceptor/auth"
+	"github.com/nais/deploy/pkg/hookd/database"
+	database_mapper "github.com/nais/deploy/pkg/hookd/database/mapper"
+	"github.com/nais/deploy/pkg/pb"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// Number of successful deployments to look through when searching for a stored payload to roll back to.
+	rollbackCandidates = 10
+
+	// Used when the client does not specify a deadline for the rollback.
+	defaultRollbackDeadline = 10 * time.Minute
+)
+
+// Rollback dispatches the payload of a previous deployment as a new deployment.
+// If no deployment ID is given, the last successful deployment made before the most recent one
+// for the same team, cluster and repository is used.
+func (ds *deployServer) Rollback(ctx context.Context, request *pb.RollbackRequest) (*pb.DeploymentStatus, error) {
+	if len(request.GetTeam()) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "team is required")
+	}
+
+	target, payload, err := ds.rollbackTarget(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	kubernetes := &pb.Kubernetes{}
+	err = proto.Unmarshal(payload, kubernetes)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "decode stored payload of deployment '%s': %s", target.ID, err)
+	}
+
+	uuidstr, err := ds.uuidgen()
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := request.GetDeadline()
+	if deadline == nil {
+		deadline = pb.TimeAsTimestamp(time.Now().Add(defaultRollbackDeadline))
+	}
+
+	deployRequest := database_mapper.PbRequest(*target)
+	deployRequest.ID = uuidstr
+	deployRequest.Time = pb.TimeAsTimestamp(time.Now())
+	deployRequest.Deadline = deadline
+	deployRequest.Kubernetes = kubernetes
+	deployRequest.TraceParent = request.GetTraceParent()
+	deployRequest.RollbackOf = target.ID
+
+	// The request may only name the deployment, so the policies are checked against the cluster it was made to.
+	err = auth_interceptor.AuthorizeCluster(ctx, deployRequest.GetCluster())
+	if err != nil {
+		return nil, err
+	}
+
+	logger := log.WithFields(deployRequest.LogFields())
+	logger.Infof("Received rollback request to deployment %s", target.ID)
+
+	err = ds.addToDatabase(ctx, deployRequest)
+	if err != nil {
+		logger.Errorf("Write deployment to database: %s", err)
+		return nil, err
+	}
+
+	return ds.dispatch(ctx, deployRequest)
+}
+
+// Find the deployment to roll back to, along with its stored payload.
+func (ds *deployServer) rollbackTarget(ctx context.Context, request *pb.RollbackRequest) (*database.Deployment, []byte, error) {
+	if len(request.GetDeploymentID()) > 0 {
+		target, err := ds.deploymentStore.Deployment(ctx, request.GetDeploymentID())
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, nil, status.Errorf(codes.NotFound, "deployment '%s' not found", request.GetDeploymentID())
+		} else if err != nil {
+			log.Errorf("Get deployment from database: %s", err)
+			return nil, nil, ErrDatabaseUnavailable
+		}
+
+		if target.Team != request.GetTeam() {
+			return nil, nil, status.Errorf(codes.PermissionDenied, "deployment '%s' does not belong to team '%s'", target.ID, request.GetTeam())
+		}
+
+		if len(request.GetCluster()) > 0 && (target.Cluster == nil || *target.Cluster != request.GetCluster()) {
+			return nil, nil, status.Errorf(codes.InvalidArgument, "deployment '%s' was not made to cluster '%s'", target.ID, request.GetCluster())
+		}
+
+		payload, err := ds.deploymentStore.DeploymentPayload(ctx, target.ID)
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, nil, status.Errorf(codes.FailedPrecondition, "deployment '%s' has no stored payload and cannot be rolled back to", target.ID)
+		} else if err != nil {
+			log.Errorf("Get deployment payload from database: %s", err)
+			return nil, nil, ErrDatabaseUnavailable
+		}
+
+		return target, payload, nil
+	}
+
+	if len(request.GetCluster()) == 0 || !request.GetRepository().Valid() {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "cluster and repository are required when no deployment ID is given")
+	}
+
+	filter := database.DeploymentFilter{
+		Team:       request.GetTeam(),
+		Clusters:   []string{request.GetCluster()},
+		Repository: request.GetRepository().FullName(),
+		Limit:      1,
+	}
+
+	latest, err := ds.deploymentStore.FilteredDeployments(ctx, filter)
+	if err != nil {
+		log.Errorf("List deployments from database: %s", err)
+		return nil, nil, ErrDatabaseUnavailable
+	}
+	if len(latest) == 0 {
+		return nil, nil, status.Errorf(codes.NotFound, "no deployments of '%s' to cluster '%s' found", filter.Repository, request.GetCluster())
+	}
+
+	filter.States = []string{pb.DeploymentState_success.String()}
+	filter.To = latest[0].Created
+	filter.Limit = rollbackCandidates
+
+	candidates, err := ds.deploymentStore.FilteredDeployments(ctx, filter)
+	if err != nil {
+		log.Errorf("List deployments from database: %s", err)
+		return nil, nil, ErrDatabaseUnavailable
+	}
+
+	// Deployments made before payloads were stored cannot be rolled back to.
+	for _, candidate := range candidates {
+		payload, err := ds.deploymentStore.DeploymentPayload(ctx, candidate.ID)
+		if errors.Is(err, database.ErrNotFound) {
+			continue
+		} else if err != nil {
+			log.Errorf("Get deployment payload from database: %s", err)
+			return nil, nil, ErrDatabaseUnavailable
+		}
+		return candidate, payload, nil
+	}
+
+	return nil, nil, status.Errorf(codes.NotFound, "no previous successful deployment of '%s' to cluster '%s' can be rolled back to", filter.Repository, request.GetCluster())
+}
@@ -0,0 +1,139 @@
+package deployserver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nais/deploy/pkg/grpc/deployserver"
+	"github.com/nais/deploy/pkg/grpc/dispatchserver"
+	"github.com/nais/deploy/pkg/hookd/database"
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestRollback(t *testing.T) {
+	ctx := context.Background()
+	cluster := "dev"
+	repository := "navikt/myrepo"
+
+	resource, err := structpb.NewStruct(map[string]any{
+		"apiVersion": "nais.io/v1alpha1",
+		"kind":       "Application",
+		"metadata": map[string]any{
+			"name":      "myapplication",
+			"namespace": "aura",
+		},
+	})
+	assert.NoError(t, err)
+	payload, err := proto.MarshalOptions{Deterministic: true}.Marshal(&pb.Kubernetes{Resources: []*structpb.Struct{resource}})
+	assert.NoError(t, err)
+
+	deployment := func(id string, created time.Time) *database.Deployment {
+		return &database.Deployment{
+			ID:               id,
+			Team:             "aura",
+			Cluster:          &cluster,
+			GitHubRepository: &repository,
+			Created:          created,
+		}
+	}
+
+	latest := deployment("latest", time.Now())
+	previous := deployment("previous", time.Now().Add(-time.Hour))
+	ancient := deployment("ancient", time.Now().Add(-2*time.Hour))
+
+	isRollbackOf := func(id string) any {
+		return mock.MatchedBy(func(req *pb.DeploymentRequest) bool {
+			return req.GetRollbackOf() == id &&
+				req.GetTeam() == "aura" &&
+				req.GetCluster() == cluster &&
+				req.GetRepository().FullName() == repository &&
+				len(req.GetKubernetes().GetResources()) == 1
+		})
+	}
+
+	expectNewDeployment := func(store *database.MockDeploymentStore, dispatcher *dispatchserver.MockDispatchServer, rollbackOf string) {
+		store.On("WriteDeployment", mock.Anything, mock.MatchedBy(func(d database.Deployment) bool {
+			return d.RollbackOf != nil && *d.RollbackOf == rollbackOf
+		})).Return(nil).Once()
+		store.On("WriteDeploymentResource", mock.Anything, mock.Anything).Return(nil).Once()
+		store.On("WriteDeploymentPayload", mock.Anything, mock.Anything, payload).Return(nil).Once()
+		dispatcher.On("SendDeploymentRequest", mock.Anything, isRollbackOf(rollbackOf)).Return(nil).Once()
+		dispatcher.On("HandleDeploymentStatus", mock.Anything, mock.Anything).Return(nil).Once()
+	}
+
+	t.Run("last successful deployment before the latest one is dispatched again", func(t *testing.T) {
+		store := database.NewMockDeploymentStore(t)
+		dispatcher := dispatchserver.NewMockDispatchServer(t)
+
+		store.On("FilteredDeployments", mock.Anything, mock.MatchedBy(func(filter database.DeploymentFilter) bool {
+			return filter.Limit == 1 && len(filter.States) == 0
+		})).Return([]*database.Deployment{latest}, nil).Once()
+		store.On("FilteredDeployments", mock.Anything, mock.MatchedBy(func(filter database.DeploymentFilter) bool {
+			return filter.To.Equal(latest.Created) &&
+				assert.ObjectsAreEqual([]string{"success"}, filter.States) &&
+				filter.Repository == repository
+		})).Return([]*database.Deployment{previous, ancient}, nil).Once()
+
+		// payloads were not stored for the previous deployment
+		store.On("DeploymentPayload", mock.Anything, previous.ID).Return(nil, database.ErrNotFound).Once()
+		store.On("DeploymentPayload", mock.Anything, ancient.ID).Return(payload, nil).Once()
+		expectNewDeployment(store, dispatcher, ancient.ID)
+
+		server := deployserver.New(dispatcher, store)
+		st, err := server.Rollback(ctx, &pb.RollbackRequest{
+			Team:       "aura",
+			Cluster:    cluster,
+			Repository: pb.GithubRepositoryFromFullName(repository),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, pb.DeploymentState_queued, st.GetState())
+		assert.Equal(t, ancient.ID, st.GetRequest().GetRollbackOf())
+		assert.NotEqual(t, ancient.ID, st.GetRequest().GetID())
+	})
+
+	t.Run("specific deployment is dispatched again", func(t *testing.T) {
+		store := database.NewMockDeploymentStore(t)
+		dispatcher := dispatchserver.NewMockDispatchServer(t)
+
+		store.On("Deployment", mock.Anything, previous.ID).Return(previous, nil).Once()
+		store.On("DeploymentPayload", mock.Anything, previous.ID).Return(payload, nil).Once()
+		expectNewDeployment(store, dispatcher, previous.ID)
+
+		server := deployserver.New(dispatcher, store)
+		st, err := server.Rollback(ctx, &pb.RollbackRequest{Team: "aura", DeploymentID: previous.ID})
+		assert.NoError(t, err)
+		assert.Equal(t, previous.ID, st.GetRequest().GetRollbackOf())
+	})
+
+	t.Run("deployment belonging to another team", func(t *testing.T) {
+		store := database.NewMockDeploymentStore(t)
+		store.On("Deployment", mock.Anything, previous.ID).Return(previous, nil).Once()
+
+		server := deployserver.New(dispatchserver.NewMockDispatchServer(t), store)
+		_, err := server.Rollback(ctx, &pb.RollbackRequest{Team: "other", DeploymentID: previous.ID})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("deployment without stored payload", func(t *testing.T) {
+		store := database.NewMockDeploymentStore(t)
+		store.On("Deployment", mock.Anything, previous.ID).Return(previous, nil).Once()
+		store.On("DeploymentPayload", mock.Anything, previous.ID).Return(nil, database.ErrNotFound).Once()
+
+		server := deployserver.New(dispatchserver.NewMockDispatchServer(t), store)
+		_, err := server.Rollback(ctx, &pb.RollbackRequest{Team: "aura", DeploymentID: previous.ID})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("repository is required without deployment ID", func(t *testing.T) {
+		server := deployserver.New(dispatchserver.NewMockDispatchServer(t), database.NewMockDeploymentStore(t))
+		_, err := server.Rollback(ctx, &pb.RollbackRequest{Team: "aura", Cluster: cluster})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
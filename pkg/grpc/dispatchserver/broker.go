@@ -4,7 +4,9 @@ package dispatchserver
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/nais/deploy/pkg/hookd/database"
 	database_mapper "github.com/nais/deploy/pkg/hookd/database/mapper"
@@ -17,11 +19,89 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-func (s *dispatchServer) SendDeploymentRequest(ctx context.Context, request *pb.DeploymentRequest) error {
+// Returns true if the cluster is connected to this or another hookd replica.
+func (s *dispatchServer) clusterOnline(cluster string) bool {
+	s.onlineClustersLock.RLock()
+	local := len(s.onlineClustersMap[cluster]) > 0
+	s.onlineClustersLock.RUnlock()
+	if local {
+		return true
+	}
+	_, ok := s.remoteReplica(cluster)
+	return ok
+}
+
+// Send a request to one of the deployd connections of its cluster.
+// Connections held by other replicas are reached through the pub/sub.
+func (s *dispatchServer) send(ctx context.Context, request *pb.DeploymentRequest) error {
+	c, err := s.localConnection(request)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		replica, ok := s.remoteReplica(request.GetCluster())
+		if !ok {
+			return status.Errorf(codes.Unavailable, "cluster '%s' is offline", request.GetCluster())
+		}
+		return s.publishRequest(ctx, replica, request)
+	}
+
+	return c.send(request)
+}
+
+// Send a request to every deployd connection of its cluster, on this and other replicas.
+func (s *dispatchServer) broadcast(ctx context.Context, request *pb.DeploymentRequest) error {
+	_, remote := s.remoteReplica(request.GetCluster())
+	if remote {
+		err := s.publishRequest(ctx, "", request)
+		if err != nil {
+			return err
+		}
+	}
+
+	sent, err := s.sendAll(request)
+	if err != nil {
+		return err
+	}
+	if sent == 0 && !remote {
+		return status.Errorf(codes.Unavailable, "cluster '%s' is offline", request.GetCluster())
+	}
+
+	return nil
+}
+
+// Send a request to every deployd connection of its cluster on this replica that supports it,
+// and return the number of connections it was sent to.
+func (s *dispatchServer) sendAll(request *pb.DeploymentRequest) (int, error) {
 	s.onlineClustersLock.RLock()
-	c, online := s.onlineClustersMap[request.Cluster]
+	connections := make([]*clusterConnection, 0, len(s.onlineClustersMap[request.GetCluster()]))
+	for _, c := range s.onlineClustersMap[request.GetCluster()] {
+		if c.supports(request) {
+			connections = append(connections, c)
+		}
+	}
 	s.onlineClustersLock.RUnlock()
-	if !online {
+
+	sent := 0
+	var errs []error
+	for _, c := range connections {
+		err := c.send(request)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sent++
+	}
+
+	return sent, errors.Join(errs...)
+}
+
+// SendDeploymentRequest hands a request to deployd. Deployments are put on the dispatch queue of their cluster,
+// and delivered in order by the cluster's connection, or when it comes online. Other requests are sent directly.
+func (s *dispatchServer) SendDeploymentRequest(ctx context.Context, request *pb.DeploymentRequest) error {
+	queueable := s.queueable(request)
+
+	if !queueable && !s.clusterOnline(request.Cluster) {
 		return status.Errorf(codes.Unavailable, "cluster '%s' is offline", request.Cluster)
 	}
 
@@ -32,13 +112,17 @@ func (s *dispatchServer) SendDeploymentRequest(ctx context.Context, request *pb.
 	request.TraceParent = telemetry.TraceParentHeader(ctx)
 	s.traceSpansLock.Unlock()
 
-	wait := make(chan error, 1)
-	c <- &requestWithWait{request: request, wait: wait}
-	if err := <-wait; err != nil {
-		span.End()
-		s.traceSpansLock.Lock()
-		delete(s.traceSpans, request.ID)
-		s.traceSpansLock.Unlock()
+	if queueable {
+		err := s.enqueue(ctx, request)
+		if err != nil {
+			s.endTraceSpan(request.ID)
+			return err
+		}
+		return nil
+	}
+
+	if err := s.send(ctx, request); err != nil {
+		s.endTraceSpan(request.ID)
 		return fmt.Errorf("send deployment request: %w", err)
 	}
 
@@ -47,15 +131,52 @@ func (s *dispatchServer) SendDeploymentRequest(ctx context.Context, request *pb.
 	return nil
 }
 
+// SendCancelRequest asks deployd to abort a deployment in progress.
+// The request is sent to every deployd instance of the cluster, and only the instance running the deployment
+// reports the outcome as a regular deployment status. Deployments that are still waiting in the dispatch queue
+// are removed from it, and reported as cancelled right away.
+func (s *dispatchServer) SendCancelRequest(ctx context.Context, request *pb.DeploymentRequest) error {
+	request.Action = pb.DeploymentAction_cancel
+
+	if s.queue != nil {
+		_, err := s.queue.WithdrawDeploymentRequest(ctx, request.GetID())
+		if err == nil {
+			log.WithFields(request.LogFields()).Infof("Deployment cancelled before it was sent to deployd")
+			return s.HandleDeploymentStatus(ctx, pb.NewCancelledStatus(request))
+		}
+		if !errors.Is(err, database.ErrNotFound) {
+			return status.Errorf(codes.Unavailable, "withdraw deployment from dispatch queue: %s", err)
+		}
+	}
+
+	if err := s.broadcast(ctx, request); err != nil {
+		return fmt.Errorf("send cancel request: %w", err)
+	}
+
+	log.WithFields(request.LogFields()).Debugf("Cancel request sent to deployd")
+
+	return nil
+}
+
+// How long statuses from dry runs are kept in memory after the dry run has finished.
+const dryRunStatusRetention = 5 * time.Minute
+
 func (s *dispatchServer) HandleDeploymentStatus(ctx context.Context, st *pb.DeploymentStatus) error {
-	s.statusStreamsLock.RLock()
-	for _, ch := range s.statusStreams {
-		ch <- st
+	s.streamDeploymentStatus(st)
+
+	// Status streams may be opened on other replicas.
+	err := s.publishProto(ctx, channelStatus, st.GetRequest().GetCluster(), st)
+	if err != nil {
+		log.WithFields(st.LogFields()).Errorf("Publish deployment status to hookd replicas: %s", err)
+	}
+
+	if st.GetRequest().GetDryRun() {
+		s.recordDryRunStatus(st)
+		return nil
 	}
-	s.statusStreamsLock.RUnlock()
 
 	dbStatus := database_mapper.DeploymentStatus(st)
-	err := s.db.WriteDeploymentStatus(ctx, dbStatus)
+	err = s.db.WriteDeploymentStatus(ctx, dbStatus)
 	if err != nil {
 		if database.IsErrForeignKeyViolation(err) {
 			return status.Errorf(codes.FailedPrecondition, err.Error())
@@ -65,6 +186,11 @@ func (s *dispatchServer) HandleDeploymentStatus(ctx context.Context, st *pb.Depl
 
 	metrics.UpdateQueue(st)
 
+	err = s.dequeue(ctx, st)
+	if err != nil {
+		return err
+	}
+
 	logger := log.WithFields(st.LogFields())
 	logger.Debugf("Saved deployment status in database")
 
@@ -81,3 +207,101 @@ func (s *dispatchServer) HandleDeploymentStatus(ctx context.Context, st *pb.Depl
 
 	return nil
 }
+
+func (s *dispatchServer) streamDeploymentStatus(st *pb.DeploymentStatus) {
+	s.statusStreamsLock.RLock()
+	for _, ch := range s.statusStreams {
+		ch <- st
+	}
+	s.statusStreamsLock.RUnlock()
+}
+
+// receiveDeploymentStatus handles a status reported to another hookd replica, which has already saved it.
+func (s *dispatchServer) receiveDeploymentStatus(st *pb.DeploymentStatus) {
+	s.streamDeploymentStatus(st)
+
+	if st.GetRequest().GetDryRun() {
+		s.recordDryRunStatus(st)
+		return
+	}
+
+	if st.GetState().Finished() {
+		s.endTraceSpan(st.GetRequest().GetID())
+	}
+}
+
+// Dry runs are never written to the database, so their statuses are kept in memory
+// for a while, making them available for status streams opened after the fact.
+// Statuses are removed shortly after the dry run finishes, or after its deadline if deployd never reports it finished.
+func (s *dispatchServer) recordDryRunStatus(st *pb.DeploymentStatus) {
+	deployID := st.GetRequest().GetID()
+
+	s.dryRunStatusesLock.Lock()
+	dryRun, seen := s.dryRunStatuses[deployID]
+	if !seen {
+		dryRun = &dryRunStatuses{team: st.GetRequest().GetTeam()}
+		s.dryRunStatuses[deployID] = dryRun
+	}
+	dryRun.statuses = append(dryRun.statuses, st)
+	s.dryRunStatusesLock.Unlock()
+
+	if !seen {
+		time.AfterFunc(dryRunExpiry(st.GetRequest(), time.Now()), func() {
+			s.forgetDryRunStatuses(deployID)
+		})
+	}
+
+	logger := log.WithFields(st.LogFields())
+	logger.Debugf("Dry run status received")
+
+	if !st.GetState().Finished() {
+		return
+	}
+
+	s.traceSpansLock.Lock()
+	if span, ok := s.traceSpans[deployID]; ok {
+		span.End()
+		delete(s.traceSpans, deployID)
+	}
+	s.traceSpansLock.Unlock()
+	logger.Infof("Dry run finished")
+
+	time.AfterFunc(dryRunStatusRetention, func() {
+		s.forgetDryRunStatuses(deployID)
+	})
+}
+
+func (s *dispatchServer) forgetDryRunStatuses(deployID string) {
+	s.dryRunStatusesLock.Lock()
+	delete(s.dryRunStatuses, deployID)
+	s.dryRunStatusesLock.Unlock()
+}
+
+// dryRunExpiry returns how long statuses of an unfinished dry run are kept, which is until its deadline has passed
+// and the retention period after it. Requests without a deadline are only kept for the retention period.
+func dryRunExpiry(request *pb.DeploymentRequest, now time.Time) time.Duration {
+	if request.GetDeadline() == nil {
+		return dryRunStatusRetention
+	}
+	untilDeadline := pb.TimestampAsTime(request.GetDeadline()).Sub(now)
+	if untilDeadline < 0 {
+		untilDeadline = 0
+	}
+	return untilDeadline + dryRunStatusRetention
+}
+
+// DryRunStatuses returns the team a dry run belongs to, and the statuses received so far.
+// The team is empty if no statuses have been received.
+func (s *dispatchServer) DryRunStatuses(deploymentID string) (string, []*pb.DeploymentStatus) {
+	s.dryRunStatusesLock.RLock()
+	defer s.dryRunStatusesLock.RUnlock()
+
+	dryRun, ok := s.dryRunStatuses[deploymentID]
+	if !ok {
+		return "", nil
+	}
+
+	statuses := make([]*pb.DeploymentStatus, len(dryRun.statuses))
+	copy(statuses, dryRun.statuses)
+	return dryRun.team, statuses
+}
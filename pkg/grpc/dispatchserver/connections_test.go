@@ -0,0 +1,253 @@
+package dispatchserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/nais/deploy/pkg/telemetry"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDeploydInstances(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, _ = telemetry.New(ctx, "test", "")
+
+	deadline := pb.TimeAsTimestamp(time.Now().Add(time.Minute))
+
+	t.Run("each request is sent to exactly one instance", func(t *testing.T) {
+		queue := &memoryQueue{}
+		ds, client := startReplica(ctx, t, nil, queue)
+
+		first := connectInstance(ctx, t, client, "ha", "deployd-1")
+		second := connectInstance(ctx, t, client, "ha", "deployd-2")
+		defer first.cancel()
+		defer second.cancel()
+		assert.Eventually(t, func() bool { return ds.connectionCount("ha") == 2 }, time.Second, 10*time.Millisecond)
+
+		ids := []string{"ha-1", "ha-2", "ha-3", "ha-4"}
+		for _, id := range ids {
+			err := ds.SendDeploymentRequest(ctx, &pb.DeploymentRequest{ID: id, Cluster: "ha", Team: "test", Deadline: deadline})
+			assert.NoError(t, err)
+		}
+
+		received := make(map[string]int)
+		for range ids {
+			select {
+			case req := <-first.requests:
+				received[req.GetID()]++
+			case req := <-second.requests:
+				received[req.GetID()]++
+			case <-time.After(time.Second):
+				t.Fatal("not all requests were received")
+			}
+		}
+
+		// give duplicates a chance to arrive
+		time.Sleep(100 * time.Millisecond)
+		assert.Len(t, first.requests, 0)
+		assert.Len(t, second.requests, 0)
+		for _, id := range ids {
+			assert.Equal(t, 1, received[id], id)
+		}
+	})
+
+	t.Run("unfinished requests are reassigned when an instance disappears", func(t *testing.T) {
+		queue := &memoryQueue{}
+		ds, client := startReplica(ctx, t, nil, queue)
+		ds.leaseTTL = 300 * time.Millisecond
+
+		first := connectInstance(ctx, t, client, "ha", "deployd-1")
+		assert.Eventually(t, func() bool { return ds.connectionCount("ha") == 1 }, time.Second, 10*time.Millisecond)
+
+		err := ds.SendDeploymentRequest(ctx, &pb.DeploymentRequest{ID: "orphan", Cluster: "ha", Team: "test", Deadline: deadline})
+		assert.NoError(t, err)
+		assert.Equal(t, "orphan", first.receive(t).GetID())
+
+		second := connectInstance(ctx, t, client, "ha", "deployd-2")
+		defer second.cancel()
+		assert.Eventually(t, func() bool { return ds.connectionCount("ha") == 2 }, time.Second, 10*time.Millisecond)
+		assert.Len(t, second.requests, 0)
+
+		first.cancel()
+		req := second.receive(t)
+		assert.Equal(t, "orphan", req.GetID())
+
+		_, err = client.ReportStatus(ctx, pb.NewSuccessStatus(req))
+		assert.NoError(t, err)
+		assert.Equal(t, 0, queue.len())
+	})
+
+	t.Run("instances keep their requests when they reconnect", func(t *testing.T) {
+		queue := &memoryQueue{}
+		ds, client := startReplica(ctx, t, nil, queue)
+		ds.leaseTTL = 300 * time.Millisecond
+
+		first := connectInstance(ctx, t, client, "ha", "deployd-1")
+		assert.Eventually(t, func() bool { return ds.connectionCount("ha") == 1 }, time.Second, 10*time.Millisecond)
+
+		err := ds.SendDeploymentRequest(ctx, &pb.DeploymentRequest{ID: "running", Cluster: "ha", Team: "test", Deadline: deadline})
+		assert.NoError(t, err)
+		assert.Equal(t, "running", first.receive(t).GetID())
+
+		second := connectInstance(ctx, t, client, "ha", "deployd-2")
+		defer second.cancel()
+		assert.Eventually(t, func() bool { return ds.connectionCount("ha") == 2 }, time.Second, 10*time.Millisecond)
+
+		first.cancel()
+		reconnected := connectInstance(ctx, t, client, "ha", "deployd-1")
+		defer reconnected.cancel()
+
+		// well past the lease TTL
+		time.Sleep(3 * ds.leaseTTL)
+		assert.Len(t, second.requests, 0)
+		assert.Len(t, reconnected.requests, 0)
+		assert.Equal(t, 1, queue.len())
+	})
+
+	t.Run("idle connections time out while their leases are renewed", func(t *testing.T) {
+		queue := &memoryQueue{}
+		ds, client := startReplica(ctx, t, nil, queue)
+		ds.leaseTTL = 30 * time.Millisecond
+		ds.idleTimeout = 300 * time.Millisecond
+
+		idle := connectInstance(ctx, t, client, "ha", "deployd-1")
+		defer idle.cancel()
+		assert.Eventually(t, func() bool { return ds.connectionCount("ha") == 1 }, time.Second, 10*time.Millisecond)
+
+		err := ds.SendDeploymentRequest(ctx, &pb.DeploymentRequest{ID: "running", Cluster: "ha", Team: "test", Deadline: deadline})
+		assert.NoError(t, err)
+		assert.Equal(t, "running", idle.receive(t).GetID())
+
+		select {
+		case err := <-idle.closed:
+			assert.Contains(t, err.Error(), "timeout")
+		case <-time.After(10 * ds.idleTimeout):
+			t.Fatal("idle connection was not closed")
+		}
+		assert.Equal(t, 0, ds.connectionCount("ha"))
+	})
+
+	t.Run("stale connections are replaced by new connections from the same instance", func(t *testing.T) {
+		queue := &memoryQueue{}
+		ds, client := startReplica(ctx, t, nil, queue)
+
+		stale := connectInstance(ctx, t, client, "ha", "deployd-1")
+		defer stale.cancel()
+		assert.Eventually(t, func() bool { return ds.connectionCount("ha") == 1 }, time.Second, 10*time.Millisecond)
+
+		fresh := connectInstance(ctx, t, client, "ha", "deployd-1")
+		defer fresh.cancel()
+
+		select {
+		case err := <-stale.closed:
+			assert.Equal(t, codes.Aborted, status.Code(err))
+		case <-time.After(time.Second):
+			t.Fatal("stale connection was not closed")
+		}
+		assert.Equal(t, 1, ds.connectionCount("ha"))
+
+		err := ds.SendDeploymentRequest(ctx, &pb.DeploymentRequest{ID: "fresh", Cluster: "ha", Team: "test", Deadline: deadline})
+		assert.NoError(t, err)
+		assert.Equal(t, "fresh", fresh.receive(t).GetID())
+	})
+
+	t.Run("cancel requests are sent to every instance", func(t *testing.T) {
+		queue := &memoryQueue{}
+		ds, client := startReplica(ctx, t, nil, queue)
+
+		first := connectInstance(ctx, t, client, "ha", "deployd-1")
+		defer first.cancel()
+		assert.Eventually(t, func() bool { return ds.connectionCount("ha") == 1 }, time.Second, 10*time.Millisecond)
+
+		err := ds.SendDeploymentRequest(ctx, &pb.DeploymentRequest{ID: "running", Cluster: "ha", Team: "test", Deadline: deadline})
+		assert.NoError(t, err)
+		req := first.receive(t)
+
+		second := connectInstance(ctx, t, client, "ha", "deployd-2")
+		defer second.cancel()
+		assert.Eventually(t, func() bool { return ds.connectionCount("ha") == 2 }, time.Second, 10*time.Millisecond)
+
+		err = ds.SendCancelRequest(ctx, req)
+		assert.NoError(t, err)
+		for _, instance := range []*deploydInstance{first, second} {
+			cancelRequest := instance.receive(t)
+			assert.Equal(t, "running", cancelRequest.GetID())
+			assert.Equal(t, pb.DeploymentAction_cancel, cancelRequest.GetAction())
+		}
+
+		// the deployment stays queued until the instance running it reports that it has finished
+		assert.Equal(t, 1, queue.len())
+	})
+}
+
+func TestDeploydCapabilities(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, _ = telemetry.New(ctx, "test", "")
+
+	deadline := pb.TimeAsTimestamp(time.Now().Add(time.Minute))
+
+	ds, client := startReplica(ctx, t, nil, nil)
+
+	// deployd versions that don't advertise their capabilities would carry out any request as a deployment
+	legacyStream, err := client.Deployments(ctx, &pb.GetDeploymentOpts{Cluster: "legacy"})
+	assert.NoError(t, err)
+	legacy := make(chan *pb.DeploymentRequest, 10)
+	go func() {
+		for {
+			req, err := legacyStream.Recv()
+			if err != nil {
+				return
+			}
+			legacy <- req
+		}
+	}()
+	assert.Eventually(t, func() bool { return ds.connectionCount("legacy") == 1 }, time.Second, 10*time.Millisecond)
+
+	for _, test := range []struct {
+		name    string
+		request *pb.DeploymentRequest
+		code    codes.Code
+	}{
+		{name: "deploy", request: &pb.DeploymentRequest{ID: "deploy", Action: pb.DeploymentAction_deploy}, code: codes.OK},
+		{name: "dry run", request: &pb.DeploymentRequest{ID: "dry-run", DryRun: true}, code: codes.FailedPrecondition},
+		{name: "diff", request: &pb.DeploymentRequest{ID: "diff", Action: pb.DeploymentAction_diff}, code: codes.FailedPrecondition},
+		{name: "cancel", request: &pb.DeploymentRequest{ID: "cancel", Action: pb.DeploymentAction_cancel}, code: codes.FailedPrecondition},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.request.Cluster = "legacy"
+			test.request.Team = "test"
+			test.request.Deadline = deadline
+
+			err := ds.SendDeploymentRequest(ctx, test.request)
+			assert.Equal(t, test.code, status.Code(err))
+			if test.code == codes.OK {
+				assert.Equal(t, test.request.GetID(), (<-legacy).GetID())
+			}
+		})
+	}
+
+	t.Run("requests are sent to instances that support them", func(t *testing.T) {
+		current := connectInstance(ctx, t, client, "legacy", "deployd-2")
+		defer current.cancel()
+		assert.Eventually(t, func() bool { return ds.connectionCount("legacy") == 2 }, time.Second, 10*time.Millisecond)
+
+		for i := 0; i < 4; i++ {
+			err := ds.SendDeploymentRequest(ctx, &pb.DeploymentRequest{ID: "dry-run", Cluster: "legacy", Team: "test", DryRun: true, Deadline: deadline})
+			assert.NoError(t, err)
+			assert.Equal(t, "dry-run", current.receive(t).GetID())
+		}
+		assert.Len(t, legacy, 0)
+	})
+}
+
+func (s *dispatchServer) connectionCount(cluster string) int {
+	s.onlineClustersLock.RLock()
+	defer s.onlineClustersLock.RUnlock()
+	return len(s.onlineClustersMap[cluster])
+}
@@ -0,0 +1,99 @@
+package dispatchserver
+
+import (
+	"context"
+
+	"github.com/nais/deploy/pkg/pb"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SendDiffRequest dispatches a diff request to deployd, and blocks until the result is reported back.
+func (s *dispatchServer) SendDiffRequest(ctx context.Context, request *pb.DeploymentRequest) (*pb.DiffResult, error) {
+	request.Action = pb.DeploymentAction_diff
+
+	results := make(chan *pb.DiffResult, 1)
+	s.diffWaitersLock.Lock()
+	s.diffWaiters[request.GetID()] = results
+	s.diffWaitersLock.Unlock()
+
+	defer func() {
+		s.diffWaitersLock.Lock()
+		delete(s.diffWaiters, request.GetID())
+		s.diffWaitersLock.Unlock()
+	}()
+
+	err := s.SendDeploymentRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case result := <-results:
+		return result, nil
+	case <-ctx.Done():
+		s.endTraceSpan(request.GetID())
+		return nil, status.Errorf(codes.DeadlineExceeded, "waiting for diff result from cluster '%s': %s", request.GetCluster(), ctx.Err())
+	}
+}
+
+func (s *dispatchServer) ReportDiff(ctx context.Context, result *pb.DiffResult) (*pb.ReportStatusOpts, error) {
+	deployID := result.GetRequest().GetID()
+	logger := log.WithFields(result.GetRequest().LogFields())
+
+	s.endTraceSpan(deployID)
+
+	waiting, err := s.deliverDiff(result)
+	if err != nil {
+		return nil, err
+	}
+
+	if !waiting {
+		if s.bus == nil {
+			logger.Warnf("Discarding diff result; nobody is waiting for it")
+			return nil, status.Errorf(codes.FailedPrecondition, "no pending diff request with ID '%s'", deployID)
+		}
+
+		// The diff may have been requested through another replica.
+		err = s.publishProto(ctx, channelDiff, result.GetRequest().GetCluster(), result)
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "forward diff result to hookd replicas: %s", err)
+		}
+	}
+
+	logger.Debugf("Diff result received from deployd")
+
+	return &pb.ReportStatusOpts{}, nil
+}
+
+// deliverDiff hands a diff result to the request waiting for it on this replica.
+// Returns false if nobody is waiting for it here.
+func (s *dispatchServer) deliverDiff(result *pb.DiffResult) (bool, error) {
+	deployID := result.GetRequest().GetID()
+
+	s.diffWaitersLock.Lock()
+	results, ok := s.diffWaiters[deployID]
+	s.diffWaitersLock.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+
+	select {
+	case results <- result:
+	default:
+		return true, status.Errorf(codes.AlreadyExists, "diff result for '%s' already reported", deployID)
+	}
+
+	return true, nil
+}
+
+func (s *dispatchServer) endTraceSpan(deployID string) {
+	s.traceSpansLock.Lock()
+	defer s.traceSpansLock.Unlock()
+	if span, ok := s.traceSpans[deployID]; ok {
+		span.End()
+		delete(s.traceSpans, deployID)
+	}
+}
@@ -0,0 +1,89 @@
+package dispatchserver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nais/deploy/pkg/hookd/database"
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/nais/deploy/pkg/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestDiffRoundTrip(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, _ = telemetry.New(ctx, "test", "")
+
+	deploymentStore := database.MockDeploymentStore{}
+	deploymentStore.On("HistoricDeployments", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+
+	ds := New(&deploymentStore, nil, nil)
+
+	b := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	pb.RegisterDispatchServer(srv, ds)
+	go func() {
+		err := srv.Serve(b)
+		if err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			t.Error(err)
+		}
+	}()
+	defer srv.Stop()
+
+	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithContextDialer(bufDialer(b)), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+
+	client := pb.NewDispatchClient(conn)
+
+	t.Run("diff result without pending request is rejected", func(t *testing.T) {
+		_, err := client.ReportDiff(ctx, &pb.DiffResult{Request: &pb.DeploymentRequest{ID: "unknown"}})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("diff request is dispatched and result returned to caller", func(t *testing.T) {
+		deploymentsClient, err := client.Deployments(ctx, deploydOpts("diff", ""))
+		assert.NoError(t, err)
+
+		// deployd
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			req, err := deploymentsClient.Recv()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			assert.Equal(t, pb.DeploymentAction_diff, req.GetAction())
+			_, err = client.ReportDiff(ctx, &pb.DiffResult{
+				Request: req,
+				Resources: []*pb.ResourceDiff{
+					{Kind: "ConfigMap", Name: "foo", Change: pb.ResourceChange_created},
+				},
+			})
+			assert.NoError(t, err)
+		}()
+
+		// wait for cluster to come online
+		time.Sleep(500 * time.Millisecond)
+
+		result, err := ds.SendDiffRequest(ctx, &pb.DeploymentRequest{
+			ID:      "diff-1",
+			Cluster: "diff",
+			Team:    "test",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "diff-1", result.GetRequest().GetID())
+		assert.Len(t, result.GetResources(), 1)
+		assert.Equal(t, pb.ResourceChange_created, result.GetResources()[0].GetChange())
+		<-done
+	})
+}
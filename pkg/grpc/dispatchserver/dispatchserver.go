@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc/codes"
@@ -15,6 +17,7 @@ import (
 	"github.com/nais/deploy/pkg/hookd/database"
 	database_mapper "github.com/nais/deploy/pkg/hookd/database/mapper"
 	"github.com/nais/deploy/pkg/hookd/metrics"
+	"github.com/nais/deploy/pkg/hookd/pubsub"
 
 	"github.com/nais/deploy/pkg/pb"
 )
@@ -24,32 +27,156 @@ type DispatchServer interface {
 	SendDeploymentRequest(ctx context.Context, deployment *pb.DeploymentRequest) error
 	HandleDeploymentStatus(ctx context.Context, status *pb.DeploymentStatus) error
 	StreamStatus(context.Context, chan<- *pb.DeploymentStatus)
+	DryRunStatuses(deploymentID string) (string, []*pb.DeploymentStatus)
+	SendDiffRequest(ctx context.Context, request *pb.DeploymentRequest) (*pb.DiffResult, error)
+	SendCancelRequest(ctx context.Context, request *pb.DeploymentRequest) error
+	ExpireQueuedRequests(ctx context.Context) error
+	Run(ctx context.Context) error
 }
 
 type dispatchServer struct {
 	pb.UnimplementedDispatchServer
 	onlineClustersLock sync.RWMutex
-	onlineClustersMap  map[string]chan<- *requestWithWait
+	onlineClustersMap  map[string][]*clusterConnection
+	nextConnection     atomic.Uint64
 	statusStreamsLock  sync.RWMutex
 	statusStreams      map[context.Context]chan<- *pb.DeploymentStatus
 	traceSpans         map[string]trace.Span
 	traceSpansLock     sync.RWMutex
+	dryRunStatuses     map[string]*dryRunStatuses
+	dryRunStatusesLock sync.RWMutex
+	diffWaiters        map[string]chan<- *pb.DiffResult
+	diffWaitersLock    sync.Mutex
+	ackWaiters         map[string]chan<- replicaMessage
+	ackWaitersLock     sync.Mutex
 	db                 database.DeploymentStore
+	queue              database.DispatchQueueStore
+	bus                pubsub.PubSub
+	replica            string
+	remoteReplicas     map[string]remoteReplica
+	remoteReplicasLock sync.RWMutex
+	leaseTTL           time.Duration
+	idleTimeout        time.Duration
 }
 
 var _ DispatchServer = &dispatchServer{}
 
+// dryRunStatuses are the statuses of a dry run, and the team it belongs to.
+type dryRunStatuses struct {
+	team     string
+	statuses []*pb.DeploymentStatus
+}
+
 type requestWithWait struct {
 	request *pb.DeploymentRequest
 	wait    chan error
 }
 
-func New(db database.DeploymentStore) DispatchServer {
+// clusterConnection is a connection from one of the deployd instances of an online cluster.
+// Requests are sent directly through the requests channel, while the queued channel
+// signals that requests have been added to the dispatch queue. The replaced channel is closed
+// when the same deployd instance opens a new connection, which means this one is stale,
+// and the closed channel when the connection is gone.
+type clusterConnection struct {
+	id           string
+	cluster      string
+	instance     string
+	lease        string
+	actions      []pb.DeploymentAction
+	dryRun       bool
+	requests     chan *requestWithWait
+	queued       chan struct{}
+	replaced     chan struct{}
+	replacedOnce sync.Once
+	closed       chan struct{}
+}
+
+// supports returns true if deployd has advertised support for the request's action, and for dry runs if requested.
+// Older versions of deployd advertise nothing, and would carry out any request as a deployment.
+func (c *clusterConnection) supports(request *pb.DeploymentRequest) bool {
+	if request.GetDryRun() && !c.dryRun {
+		return false
+	}
+	if request.GetAction() == pb.DeploymentAction_deploy {
+		return true
+	}
+	for _, action := range c.actions {
+		if action == request.GetAction() {
+			return true
+		}
+	}
+	return false
+}
+
+// send a request to deployd through this connection, and wait until it has been sent.
+func (c *clusterConnection) send(request *pb.DeploymentRequest) error {
+	if !c.supports(request) {
+		return status.Errorf(codes.FailedPrecondition, "deployd instance '%s' in cluster '%s' does not support %s requests; upgrade deployd", c.instance, c.cluster, requestKind(request))
+	}
+
+	wait := make(chan error, 1)
+	select {
+	case c.requests <- &requestWithWait{request: request, wait: wait}:
+		return <-wait
+	case <-c.closed:
+		return status.Errorf(codes.Unavailable, "connection to deployd instance '%s' in cluster '%s' is closed", c.instance, c.cluster)
+	}
+}
+
+// Describes the kind of request, for errors about unsupported requests.
+func requestKind(request *pb.DeploymentRequest) string {
+	if request.GetDryRun() {
+		return "dry run"
+	}
+	return request.GetAction().String()
+}
+
+func (c *clusterConnection) replace() {
+	c.replacedOnce.Do(func() {
+		close(c.replaced)
+	})
+}
+
+// Queued requests are leased to a single deployd instance, and the lease is renewed as long as the instance is connected.
+// If the instance disappears, its requests are leased to another instance when the lease expires.
+const defaultLeaseTTL = time.Minute
+
+// Connections that haven't been sent any requests for this long are closed, and deployd reconnects.
+const defaultIdleTimeout = 30 * time.Minute
+
+// Connection IDs are prefixed with the replica holding the connection.
+func (s *dispatchServer) newConnectionID() string {
+	return s.replica + "/" + uuid.NewString()[:8]
+}
+
+// leaseHolder identifies a running deployd process, so that it keeps its leases when it reconnects,
+// while a restarted deployd, which has lost track of its deployments, does not.
+// Older versions of deployd don't identify themselves, and lose their leases when the connection is lost.
+func leaseHolder(opts *pb.GetDeploymentOpts, connection string) string {
+	if opts.GetInstance() == "" {
+		return connection
+	}
+	return fmt.Sprintf("%s/%s@%s", opts.GetCluster(), opts.GetInstance(), opts.GetStartupTime().AsTime().UTC().Format(time.RFC3339Nano))
+}
+
+// New returns a dispatch server. If a queue store is given, deployment requests to offline clusters
+// are kept in the dispatch queue until deployd connects, instead of being rejected.
+// If a pub/sub is given, requests and statuses are routed between hookd replicas; see Run.
+func New(db database.DeploymentStore, queue database.DispatchQueueStore, bus pubsub.PubSub) DispatchServer {
 	server := &dispatchServer{
-		onlineClustersMap: make(map[string]chan<- *requestWithWait),
+		onlineClustersMap: make(map[string][]*clusterConnection),
 		statusStreams:     make(map[context.Context]chan<- *pb.DeploymentStatus),
 		traceSpans:        make(map[string]trace.Span),
+		dryRunStatuses:    make(map[string]*dryRunStatuses),
+		diffWaiters:       make(map[string]chan<- *pb.DiffResult),
+		ackWaiters:        make(map[string]chan<- replicaMessage),
 		db:                db,
+		queue:             queue,
+		bus:               bus,
+		replica:           replicaID(),
+		remoteReplicas:    make(map[string]remoteReplica),
+		leaseTTL:          defaultLeaseTTL,
+		idleTimeout:       defaultIdleTimeout,
 	}
 
 	return server
@@ -66,6 +193,101 @@ func (s *dispatchServer) onlineClusters() []string {
 	return clusters
 }
 
+// localConnection returns one of the connections to this replica that supports the request, or nil if the cluster
+// isn't connected to this replica. Requests are spread evenly between the connections.
+func (s *dispatchServer) localConnection(request *pb.DeploymentRequest) (*clusterConnection, error) {
+	s.onlineClustersLock.RLock()
+	defer s.onlineClustersLock.RUnlock()
+
+	connections := s.onlineClustersMap[request.GetCluster()]
+	if len(connections) == 0 {
+		return nil, nil
+	}
+
+	supported := make([]*clusterConnection, 0, len(connections))
+	for _, c := range connections {
+		if c.supports(request) {
+			supported = append(supported, c)
+		}
+	}
+	if len(supported) == 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "deployd in cluster '%s' does not support %s requests; upgrade deployd", request.GetCluster(), requestKind(request))
+	}
+
+	next := s.nextConnection.Add(1)
+	return supported[next%uint64(len(supported))], nil
+}
+
+// addConnection registers a new connection, replacing older connections from the same deployd instance.
+func (s *dispatchServer) addConnection(c *clusterConnection) {
+	s.replaceConnections(c.cluster, c.instance, c.id)
+
+	s.onlineClustersLock.Lock()
+	s.onlineClustersMap[c.cluster] = append(s.onlineClustersMap[c.cluster], c)
+	s.onlineClustersLock.Unlock()
+}
+
+func (s *dispatchServer) removeConnection(c *clusterConnection) {
+	s.onlineClustersLock.Lock()
+	defer s.onlineClustersLock.Unlock()
+
+	connections := make([]*clusterConnection, 0, len(s.onlineClustersMap[c.cluster]))
+	for _, existing := range s.onlineClustersMap[c.cluster] {
+		if existing != c {
+			connections = append(connections, existing)
+		}
+	}
+	if len(connections) == 0 {
+		delete(s.onlineClustersMap, c.cluster)
+	} else {
+		s.onlineClustersMap[c.cluster] = connections
+	}
+}
+
+// replaceConnections closes the connections of a deployd instance, except the one with the given ID.
+// A deployd instance only opens a new connection when it has lost the old one, even if hookd hasn't noticed yet.
+func (s *dispatchServer) replaceConnections(cluster, instance, keep string) {
+	if instance == "" {
+		return
+	}
+
+	s.onlineClustersLock.Lock()
+	stale := make([]*clusterConnection, 0)
+	connections := make([]*clusterConnection, 0, len(s.onlineClustersMap[cluster]))
+	for _, c := range s.onlineClustersMap[cluster] {
+		if c.instance == instance && c.id != keep {
+			stale = append(stale, c)
+		} else {
+			connections = append(connections, c)
+		}
+	}
+	if len(connections) == 0 {
+		delete(s.onlineClustersMap, cluster)
+	} else if len(stale) > 0 {
+		s.onlineClustersMap[cluster] = connections
+	}
+	s.onlineClustersLock.Unlock()
+
+	for _, c := range stale {
+		log.Warnf("Replacing stale connection from deployd instance '%s' in cluster '%s'", instance, cluster)
+		c.replace()
+	}
+}
+
+// notifyQueued wakes up the connections of a cluster connected to this replica, so that they lease and deliver queued requests.
+func (s *dispatchServer) notifyQueued(cluster string) {
+	s.onlineClustersLock.RLock()
+	defer s.onlineClustersLock.RUnlock()
+
+	for _, c := range s.onlineClustersMap[cluster] {
+		select {
+		case c.queued <- struct{}{}:
+		default:
+			// the connection is already about to deliver queued requests
+		}
+	}
+}
+
 func (s *dispatchServer) reportOnlineClusters() {
 	clusters := s.onlineClusters()
 	metrics.SetConnectedClusters(clusters)
@@ -89,50 +311,111 @@ func (s *dispatchServer) invalidateHistoric(ctx context.Context, cluster string,
 	return nil
 }
 
+// Deployments streams requests to a deployd instance. A cluster may have several deployd instances,
+// and each request is sent to only one of them.
 func (s *dispatchServer) Deployments(opts *pb.GetDeploymentOpts, stream pb.Dispatch_DeploymentsServer) error {
-	c := make(chan *requestWithWait)
-	s.onlineClustersLock.RLock()
-	_, clusterAlreadyConnected := s.onlineClustersMap[opts.Cluster]
-	s.onlineClustersLock.RUnlock()
-	if clusterAlreadyConnected {
-		log.Warnf("Rejected connection from cluster '%s': already connected", opts.Cluster)
-		return fmt.Errorf("cluster already connected: %s", opts.Cluster)
+	id := s.newConnectionID()
+	c := &clusterConnection{
+		id:       id,
+		cluster:  opts.GetCluster(),
+		instance: opts.GetInstance(),
+		lease:    leaseHolder(opts, id),
+		actions:  opts.GetActions(),
+		dryRun:   opts.GetDryRun(),
+		requests: make(chan *requestWithWait),
+		queued:   make(chan struct{}, 1),
+		replaced: make(chan struct{}),
+		closed:   make(chan struct{}),
 	}
 
-	s.onlineClustersLock.Lock()
-	s.onlineClustersMap[opts.Cluster] = c
-	log.Infof("Connection opened from cluster '%s'", opts.Cluster)
-	s.onlineClustersLock.Unlock()
+	// Deployments in progress on other deployd instances are leased from the dispatch queue, and are never invalidated.
+	// Without the queue, they can't be told apart from deployments lost by a restarted deployd.
+	invalidate := s.queue != nil || !s.clusterOnline(opts.GetCluster())
+
+	s.addConnection(c)
+	log.Infof("Connection opened from cluster '%s' (instance '%s')", opts.Cluster, opts.Instance)
 	s.reportOnlineClusters()
+	s.announce(stream.Context(), false)
+
+	// Stale connections from the same deployd instance may be held by other replicas.
+	if c.instance != "" {
+		err := s.publish(stream.Context(), channelConnection, replicaMessage{Cluster: c.cluster, Instance: c.instance, Connection: c.id})
+		if err != nil {
+			log.Errorf("Notify replicas of connection from cluster '%s': %s", opts.Cluster, err)
+		}
+	}
 
 	defer func() {
-		s.onlineClustersLock.Lock()
-		delete(s.onlineClustersMap, opts.Cluster)
-		s.onlineClustersLock.Unlock()
+		close(c.closed)
+		s.removeConnection(c)
 		s.reportOnlineClusters()
+		s.announce(context.Background(), false)
 	}()
 
 	// invalidate older deployments
-	err := s.invalidateHistoric(stream.Context(), opts.GetCluster(), opts.GetStartupTime().AsTime())
+	if invalidate {
+		err := s.invalidateHistoric(stream.Context(), opts.GetCluster(), opts.GetStartupTime().AsTime())
+		if err != nil {
+			return status.Errorf(codes.Unavailable, err.Error())
+		}
+	}
+
+	// keep the leases of deployments still running on a reconnecting deployd,
+	// and deliver requests queued while the cluster was offline
+	err := s.renewLeases(stream, c)
 	if err != nil {
-		return status.Errorf(codes.Unavailable, err.Error())
+		return status.Errorf(codes.Unavailable, "deliver queued deployment requests: %s", err)
 	}
 
+	renew := time.NewTicker(s.leaseTTL / 3)
+	defer renew.Stop()
+
+	// Renewing leases is not activity; the idle timer is only reset when requests are sent.
+	idle := time.NewTimer(s.idleTimeout)
+	defer idle.Stop()
+
 	for {
 		select {
 		case <-stream.Context().Done():
-			log.Warnf("Connection from cluster '%s' closed", opts.Cluster)
+			log.Warnf("Connection from cluster '%s' (instance '%s') closed", opts.Cluster, opts.Instance)
 			return nil
-		case req := <-c:
+		case <-c.replaced:
+			return status.Errorf(codes.Aborted, "replaced by a newer connection from deployd instance '%s'", opts.Instance)
+		case req := <-c.requests:
 			err := stream.Send(req.request)
 			req.wait <- err
-		case <-time.After(30 * time.Minute):
+			resetTimer(idle, s.idleTimeout)
+		case <-c.queued:
+			err := s.deliverQueued(stream, c)
+			if err != nil {
+				log.Errorf("Deliver queued deployment requests to cluster '%s': %s", opts.Cluster, err)
+				return status.Errorf(codes.Unavailable, "deliver queued deployment requests: %s", err)
+			}
+			resetTimer(idle, s.idleTimeout)
+		case <-renew.C:
+			err := s.renewLeases(stream, c)
+			if err != nil {
+				log.Errorf("Renew deployment leases of cluster '%s': %s", opts.Cluster, err)
+				return status.Errorf(codes.Unavailable, "renew deployment leases: %s", err)
+			}
+		case <-idle.C:
 			log.Warnf("Connection from cluster '%s' timed out", opts.Cluster)
 			return fmt.Errorf("timeout")
 		}
 	}
 }
 
+// resetTimer restarts a timer that may or may not have fired.
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+}
+
 func (s *dispatchServer) ReportStatus(ctx context.Context, status *pb.DeploymentStatus) (*pb.ReportStatusOpts, error) {
 	return &pb.ReportStatusOpts{}, s.HandleDeploymentStatus(ctx, status)
 }
@@ -11,12 +11,14 @@ import (
 	"github.com/nais/deploy/pkg/hookd/database"
 	"github.com/nais/deploy/pkg/pb"
 	"github.com/nais/deploy/pkg/telemetry"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func bufDialer(b *bufconn.Listener) func(context.Context, string) (net.Conn, error) {
@@ -25,6 +27,56 @@ func bufDialer(b *bufconn.Listener) func(context.Context, string) (net.Conn, err
 	}
 }
 
+// deploydOpts returns the options sent by a deployd instance supporting every kind of request.
+func deploydOpts(cluster, instance string) *pb.GetDeploymentOpts {
+	return &pb.GetDeploymentOpts{
+		Cluster:  cluster,
+		Instance: instance,
+		Actions:  []pb.DeploymentAction{pb.DeploymentAction_deploy, pb.DeploymentAction_diff, pb.DeploymentAction_cancel},
+		DryRun:   true,
+	}
+}
+
+// deploydInstance is a deployment stream opened by a deployd instance, with the requests it has received.
+type deploydInstance struct {
+	requests chan *pb.DeploymentRequest
+	closed   chan error
+	cancel   context.CancelFunc
+}
+
+func connectInstance(ctx context.Context, t *testing.T, client pb.DispatchClient, cluster, instance string) *deploydInstance {
+	streamCtx, cancel := context.WithCancel(ctx)
+	stream, err := client.Deployments(streamCtx, deploydOpts(cluster, instance))
+	assert.NoError(t, err)
+
+	d := &deploydInstance{
+		requests: make(chan *pb.DeploymentRequest, 10),
+		closed:   make(chan error, 1),
+		cancel:   cancel,
+	}
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				d.closed <- err
+				return
+			}
+			d.requests <- req
+		}
+	}()
+	return d
+}
+
+func (d *deploydInstance) receive(t *testing.T) *pb.DeploymentRequest {
+	select {
+	case req := <-d.requests:
+		return req
+	case <-time.After(time.Second):
+		t.Fatal("no request received")
+		return nil
+	}
+}
+
 const (
 	CorrectPassword = "correct"
 	WrongPassword   = "wrong"
@@ -43,7 +95,7 @@ func TestInterceptors(t *testing.T) {
 	deploymentStore.On("WriteDeploymentStatus", mock.Anything, mock.Anything).Return(nil)
 	deploymentStore.On("Deployment", mock.Anything, mock.Anything).Return(mockDeployment, nil)
 
-	ds := New(&deploymentStore)
+	ds := New(&deploymentStore, nil, nil)
 
 	presharedkeyInterceptor := &presharedkey_interceptor.ServerInterceptor{
 		Keys: []string{CorrectPassword},
@@ -144,3 +196,22 @@ func TestInterceptors(t *testing.T) {
 		}
 	})
 }
+
+func TestDryRunExpiry(t *testing.T) {
+	now := time.Now()
+
+	for _, test := range []struct {
+		name     string
+		deadline *timestamppb.Timestamp
+		expiry   time.Duration
+	}{
+		{name: "no deadline", deadline: nil, expiry: dryRunStatusRetention},
+		{name: "deadline passed", deadline: pb.TimeAsTimestamp(now.Add(-time.Minute)), expiry: dryRunStatusRetention},
+		{name: "deadline ahead", deadline: pb.TimeAsTimestamp(now.Add(time.Minute)), expiry: time.Minute + dryRunStatusRetention},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			expiry := dryRunExpiry(&pb.DeploymentRequest{DryRun: true, Deadline: test.deadline}, now)
+			assert.Equal(t, test.expiry, expiry)
+		})
+	}
+}
@@ -29,6 +29,46 @@ func (_m *MockDispatchServer) Deployments(_a0 *pb.GetDeploymentOpts, _a1 pb.Disp
 	return r0
 }
 
+// DryRunStatuses provides a mock function with given fields: deploymentID
+func (_m *MockDispatchServer) DryRunStatuses(deploymentID string) (string, []*pb.DeploymentStatus) {
+	ret := _m.Called(deploymentID)
+
+	var r0 string
+	var r1 []*pb.DeploymentStatus
+	if rf, ok := ret.Get(0).(func(string) (string, []*pb.DeploymentStatus)); ok {
+		return rf(deploymentID)
+	}
+	if rf, ok := ret.Get(0).(func(string) string); ok {
+		r0 = rf(deploymentID)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(string) []*pb.DeploymentStatus); ok {
+		r1 = rf(deploymentID)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]*pb.DeploymentStatus)
+		}
+	}
+
+	return r0, r1
+}
+
+// ExpireQueuedRequests provides a mock function with given fields: ctx
+func (_m *MockDispatchServer) ExpireQueuedRequests(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // HandleDeploymentStatus provides a mock function with given fields: ctx, status
 func (_m *MockDispatchServer) HandleDeploymentStatus(ctx context.Context, status *pb.DeploymentStatus) error {
 	ret := _m.Called(ctx, status)
@@ -43,6 +83,32 @@ func (_m *MockDispatchServer) HandleDeploymentStatus(ctx context.Context, status
 	return r0
 }
 
+// ReportDiff provides a mock function with given fields: _a0, _a1
+func (_m *MockDispatchServer) ReportDiff(_a0 context.Context, _a1 *pb.DiffResult) (*pb.ReportStatusOpts, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *pb.ReportStatusOpts
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *pb.DiffResult) (*pb.ReportStatusOpts, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *pb.DiffResult) *pb.ReportStatusOpts); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*pb.ReportStatusOpts)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *pb.DiffResult) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ReportStatus provides a mock function with given fields: _a0, _a1
 func (_m *MockDispatchServer) ReportStatus(_a0 context.Context, _a1 *pb.DeploymentStatus) (*pb.ReportStatusOpts, error) {
 	ret := _m.Called(_a0, _a1)
@@ -69,6 +135,34 @@ func (_m *MockDispatchServer) ReportStatus(_a0 context.Context, _a1 *pb.Deployme
 	return r0, r1
 }
 
+// Run provides a mock function with given fields: ctx
+func (_m *MockDispatchServer) Run(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SendCancelRequest provides a mock function with given fields: ctx, request
+func (_m *MockDispatchServer) SendCancelRequest(ctx context.Context, request *pb.DeploymentRequest) error {
+	ret := _m.Called(ctx, request)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *pb.DeploymentRequest) error); ok {
+		r0 = rf(ctx, request)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SendDeploymentRequest provides a mock function with given fields: ctx, deployment
 func (_m *MockDispatchServer) SendDeploymentRequest(ctx context.Context, deployment *pb.DeploymentRequest) error {
 	ret := _m.Called(ctx, deployment)
@@ -83,6 +177,32 @@ func (_m *MockDispatchServer) SendDeploymentRequest(ctx context.Context, deploym
 	return r0
 }
 
+// SendDiffRequest provides a mock function with given fields: ctx, request
+func (_m *MockDispatchServer) SendDiffRequest(ctx context.Context, request *pb.DeploymentRequest) (*pb.DiffResult, error) {
+	ret := _m.Called(ctx, request)
+
+	var r0 *pb.DiffResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *pb.DeploymentRequest) (*pb.DiffResult, error)); ok {
+		return rf(ctx, request)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *pb.DeploymentRequest) *pb.DiffResult); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*pb.DiffResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *pb.DeploymentRequest) error); ok {
+		r1 = rf(ctx, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // StreamStatus provides a mock function with given fields: _a0, _a1
 func (_m *MockDispatchServer) StreamStatus(_a0 context.Context, _a1 chan<- *pb.DeploymentStatus) {
 	_m.Called(_a0, _a1)
@@ -0,0 +1,169 @@
+package dispatchserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nais/deploy/pkg/hookd/database"
+	"github.com/nais/deploy/pkg/pb"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Deployments can wait in the dispatch queue while their cluster is offline.
+// Diffs, dry runs and cancellations are answered while the caller is waiting, and are never queued.
+func (s *dispatchServer) queueable(request *pb.DeploymentRequest) bool {
+	return s.queue != nil && request.GetAction() == pb.DeploymentAction_deploy && !request.GetDryRun()
+}
+
+// Persist a deployment request in the dispatch queue, and wake up the cluster's connection if it is online.
+// Requests are delivered by the replica the cluster is connected to.
+func (s *dispatchServer) enqueue(ctx context.Context, request *pb.DeploymentRequest) error {
+	deadline := pb.TimestampAsTime(request.GetDeadline())
+	if !time.Now().Before(deadline) {
+		return status.Errorf(codes.DeadlineExceeded, "deployment request deadline has passed")
+	}
+
+	payload, err := proto.Marshal(request)
+	if err != nil {
+		return status.Errorf(codes.Internal, "encode deployment request: %s", err)
+	}
+
+	err = s.queue.EnqueueDeploymentRequest(ctx, database.QueuedDeploymentRequest{
+		DeploymentID: request.GetID(),
+		Cluster:      request.GetCluster(),
+		Request:      payload,
+		Created:      time.Now(),
+		Deadline:     deadline,
+	})
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "queue deployment request: %s", err)
+	}
+
+	logger := log.WithFields(request.LogFields())
+
+	// The cluster may be connected to this or another replica.
+	s.notifyQueued(request.GetCluster())
+	err = s.publish(ctx, channelQueue, replicaMessage{Cluster: request.GetCluster()})
+	if err != nil {
+		// The request is delivered when the cluster reconnects, or with the next request to it.
+		logger.Errorf("Notify replicas of queued deployment request: %s", err)
+	}
+
+	if !s.clusterOnline(request.GetCluster()) {
+		logger.Infof("Cluster '%s' is offline; deployment request queued until deployd connects", request.GetCluster())
+		return nil
+	}
+
+	logger.Debugf("Deployment request queued for delivery to deployd")
+
+	return nil
+}
+
+// Lease queued requests of a cluster to a deployd instance and send them to it, in the order they were queued.
+// Requests stay in the queue until they have finished. If the instance disappears, they are leased to another one.
+func (s *dispatchServer) deliverQueued(stream pb.Dispatch_DeploymentsServer, c *clusterConnection) error {
+	if s.queue == nil {
+		return nil
+	}
+
+	ctx := stream.Context()
+
+	err := s.ExpireQueuedRequests(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		q, err := s.queue.LeaseDeploymentRequest(ctx, c.cluster, c.lease, s.leaseTTL)
+		if errors.Is(err, database.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("lease request from dispatch queue: %w", err)
+		}
+
+		request := &pb.DeploymentRequest{}
+		err = proto.Unmarshal(q.Request, request)
+		if err != nil {
+			// This request can never be delivered, so don't let it block the queue.
+			log.Errorf("Discarding queued deployment request %s: %s", q.DeploymentID, err)
+			err = s.queue.DequeueDeploymentRequest(ctx, q.DeploymentID)
+			if err != nil {
+				return fmt.Errorf("remove request from dispatch queue: %w", err)
+			}
+			continue
+		}
+
+		err = stream.Send(request)
+		if err != nil {
+			return fmt.Errorf("send deployment request: %w", err)
+		}
+
+		log.WithFields(request.LogFields()).Debugf("Queued deployment request leased to deployd %s", c.lease)
+	}
+}
+
+// Renew the leases of a deployd instance, and pick up requests whose lease has expired,
+// e.g. because the deployd instance running them disappeared.
+func (s *dispatchServer) renewLeases(stream pb.Dispatch_DeploymentsServer, c *clusterConnection) error {
+	if s.queue == nil {
+		return nil
+	}
+
+	err := s.queue.RenewDeploymentLeases(stream.Context(), c.lease, s.leaseTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.deliverQueued(stream, c)
+}
+
+// Remove a deployment from the dispatch queue once it has finished.
+func (s *dispatchServer) dequeue(ctx context.Context, st *pb.DeploymentStatus) error {
+	if s.queue == nil || st.GetRequest().GetDryRun() || !st.GetState().Finished() {
+		return nil
+	}
+
+	err := s.queue.DequeueDeploymentRequest(ctx, st.GetRequest().GetID())
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "remove finished deployment from dispatch queue: %s", err)
+	}
+
+	return nil
+}
+
+// ExpireQueuedRequests removes requests from the dispatch queue whose deadline passed before they were leased to deployd,
+// and reports them as failed, so that nobody is left waiting for a cluster that didn't come online in time.
+func (s *dispatchServer) ExpireQueuedRequests(ctx context.Context) error {
+	if s.queue == nil {
+		return nil
+	}
+
+	expired, err := s.queue.ExpireDeploymentRequests(ctx, time.Now())
+	if err != nil {
+		return fmt.Errorf("expire queued deployment requests: %w", err)
+	}
+
+	for _, q := range expired {
+		request := &pb.DeploymentRequest{}
+		err = proto.Unmarshal(q.Request, request)
+		if err != nil {
+			log.Errorf("Discarding expired deployment request %s: %s", q.DeploymentID, err)
+			continue
+		}
+
+		log.WithFields(request.LogFields()).Warnf("Deployment request expired in the dispatch queue")
+
+		err = s.HandleDeploymentStatus(ctx, pb.NewErrorStatus(request, fmt.Errorf("deadline exceeded before the deployment could be delivered to cluster '%s'", q.Cluster)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,278 @@
+package dispatchserver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nais/deploy/pkg/hookd/database"
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/nais/deploy/pkg/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// memoryQueue is a dispatch queue store that keeps requests in memory, in the order they were queued.
+type memoryQueue struct {
+	lock     sync.Mutex
+	requests []*memoryQueuedRequest
+}
+
+type memoryQueuedRequest struct {
+	database.QueuedDeploymentRequest
+	leasedTo     string
+	leaseExpires time.Time
+}
+
+func (r *memoryQueuedRequest) leased(now time.Time) bool {
+	return r.leasedTo != "" && now.Before(r.leaseExpires)
+}
+
+var _ database.DispatchQueueStore = &memoryQueue{}
+
+func (q *memoryQueue) EnqueueDeploymentRequest(_ context.Context, request database.QueuedDeploymentRequest) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.requests = append(q.requests, &memoryQueuedRequest{QueuedDeploymentRequest: request})
+	return nil
+}
+
+func (q *memoryQueue) LeaseDeploymentRequest(_ context.Context, cluster, holder string, ttl time.Duration) (*database.QueuedDeploymentRequest, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	now := time.Now()
+	for _, request := range q.requests {
+		if request.Cluster == cluster && now.Before(request.Deadline) && !request.leased(now) {
+			request.leasedTo = holder
+			request.leaseExpires = now.Add(ttl)
+			queued := request.QueuedDeploymentRequest
+			return &queued, nil
+		}
+	}
+	return nil, database.ErrNotFound
+}
+
+func (q *memoryQueue) RenewDeploymentLeases(_ context.Context, holder string, ttl time.Duration) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for _, request := range q.requests {
+		if request.leasedTo == holder {
+			request.leaseExpires = time.Now().Add(ttl)
+		}
+	}
+	return nil
+}
+
+func (q *memoryQueue) WithdrawDeploymentRequest(_ context.Context, deploymentID string) (*database.QueuedDeploymentRequest, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for i, request := range q.requests {
+		if request.DeploymentID == deploymentID && !request.leased(time.Now()) {
+			q.requests = append(q.requests[:i], q.requests[i+1:]...)
+			queued := request.QueuedDeploymentRequest
+			return &queued, nil
+		}
+	}
+	return nil, database.ErrNotFound
+}
+
+func (q *memoryQueue) DequeueDeploymentRequest(_ context.Context, deploymentID string) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for i, request := range q.requests {
+		if request.DeploymentID == deploymentID {
+			q.requests = append(q.requests[:i], q.requests[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (q *memoryQueue) ExpireDeploymentRequests(_ context.Context, now time.Time) ([]*database.QueuedDeploymentRequest, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	expired := make([]*database.QueuedDeploymentRequest, 0)
+	remaining := make([]*memoryQueuedRequest, 0)
+	for _, request := range q.requests {
+		if request.Deadline.Before(now) && !request.leased(now) {
+			queued := request.QueuedDeploymentRequest
+			expired = append(expired, &queued)
+		} else {
+			remaining = append(remaining, request)
+		}
+	}
+	q.requests = remaining
+	return expired, nil
+}
+
+func (q *memoryQueue) QueuedDeploymentIDs(_ context.Context) ([]string, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	ids := make([]string, 0, len(q.requests))
+	for _, request := range q.requests {
+		ids = append(ids, request.DeploymentID)
+	}
+	return ids, nil
+}
+
+func (q *memoryQueue) len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.requests)
+}
+
+func TestDispatchQueue(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, _ = telemetry.New(ctx, "test", "")
+
+	deadline := pb.TimeAsTimestamp(time.Now().Add(time.Minute))
+
+	deploymentStore := database.MockDeploymentStore{}
+	deploymentStore.On("HistoricDeployments", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+	deploymentStore.On("WriteDeploymentStatus", mock.Anything, mock.Anything).Return(nil)
+
+	queue := &memoryQueue{}
+	ds := New(&deploymentStore, queue, nil)
+
+	b := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	pb.RegisterDispatchServer(srv, ds)
+	go func() {
+		err := srv.Serve(b)
+		if err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			t.Error(err)
+		}
+	}()
+	defer srv.Stop()
+
+	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithContextDialer(bufDialer(b)), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+
+	client := pb.NewDispatchClient(conn)
+
+	t.Run("requests to offline cluster are delivered in order when it connects, and stay queued until finished", func(t *testing.T) {
+		for _, id := range []string{"first", "second", "third"} {
+			err := ds.SendDeploymentRequest(ctx, &pb.DeploymentRequest{
+				ID:       id,
+				Cluster:  "offline",
+				Team:     "test",
+				Deadline: deadline,
+			})
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 3, queue.len())
+
+		deploymentsClient, err := client.Deployments(ctx, &pb.GetDeploymentOpts{Cluster: "offline"})
+		assert.NoError(t, err)
+
+		received := make([]*pb.DeploymentRequest, 0)
+		for _, id := range []string{"first", "second", "third"} {
+			req, err := deploymentsClient.Recv()
+			assert.NoError(t, err)
+			assert.Equal(t, id, req.GetID())
+			received = append(received, req)
+		}
+		assert.Equal(t, 3, queue.len())
+
+		for _, req := range received {
+			_, err := client.ReportStatus(ctx, pb.NewSuccessStatus(req))
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 0, queue.len())
+	})
+
+	t.Run("requests to online cluster are delivered through the queue", func(t *testing.T) {
+		deploymentsClient, err := client.Deployments(ctx, &pb.GetDeploymentOpts{Cluster: "online"})
+		assert.NoError(t, err)
+
+		// wait for cluster to come online
+		time.Sleep(500 * time.Millisecond)
+
+		err = ds.SendDeploymentRequest(ctx, &pb.DeploymentRequest{
+			ID:       "online-1",
+			Cluster:  "online",
+			Team:     "test",
+			Deadline: deadline,
+		})
+		assert.NoError(t, err)
+
+		req, err := deploymentsClient.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, "online-1", req.GetID())
+
+		_, err = client.ReportStatus(ctx, pb.NewSuccessStatus(req))
+		assert.NoError(t, err)
+		assert.Equal(t, 0, queue.len())
+	})
+
+	t.Run("requests past their deadline are rejected", func(t *testing.T) {
+		err := ds.SendDeploymentRequest(ctx, &pb.DeploymentRequest{
+			ID:       "too-late",
+			Cluster:  "offline-2",
+			Team:     "test",
+			Deadline: pb.TimeAsTimestamp(time.Now().Add(-time.Second)),
+		})
+		assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+		assert.Equal(t, 0, queue.len())
+	})
+
+	t.Run("expired requests are reported as errors and never delivered", func(t *testing.T) {
+		err := ds.SendDeploymentRequest(ctx, &pb.DeploymentRequest{
+			ID:       "expiring",
+			Cluster:  "offline-3",
+			Team:     "test",
+			Deadline: pb.TimeAsTimestamp(time.Now().Add(100 * time.Millisecond)),
+		})
+		assert.NoError(t, err)
+
+		time.Sleep(200 * time.Millisecond)
+
+		err = ds.ExpireQueuedRequests(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, queue.len())
+
+		deploymentStore.AssertCalled(t, "WriteDeploymentStatus", mock.Anything, mock.MatchedBy(func(st database.DeploymentStatus) bool {
+			return st.DeploymentID == "expiring" && st.Status == pb.DeploymentState_error.String()
+		}))
+	})
+
+	t.Run("queued deployments are cancelled without involving deployd", func(t *testing.T) {
+		request := &pb.DeploymentRequest{
+			ID:       "cancel-queued",
+			Cluster:  "offline-5",
+			Team:     "test",
+			Deadline: deadline,
+		}
+		err := ds.SendDeploymentRequest(ctx, request)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, queue.len())
+
+		err = ds.SendCancelRequest(ctx, request)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, queue.len())
+
+		deploymentStore.AssertCalled(t, "WriteDeploymentStatus", mock.Anything, mock.MatchedBy(func(st database.DeploymentStatus) bool {
+			return st.DeploymentID == "cancel-queued" && st.Status == pb.DeploymentState_cancelled.String()
+		}))
+	})
+
+	t.Run("diffs to offline clusters are not queued", func(t *testing.T) {
+		err := ds.SendDeploymentRequest(ctx, &pb.DeploymentRequest{
+			ID:       "diff",
+			Cluster:  "offline-4",
+			Team:     "test",
+			Action:   pb.DeploymentAction_diff,
+			Deadline: deadline,
+		})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, 0, queue.len())
+	})
+}
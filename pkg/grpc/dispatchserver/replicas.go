@@ -0,0 +1,378 @@
+package dispatchserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/nais/deploy/pkg/hookd/pubsub"
+	"github.com/nais/deploy/pkg/pb"
+)
+
+// Channels used to communicate with other hookd replicas.
+const (
+	// Lists of clusters connected to each replica.
+	channelPresence = "hookd_presence"
+	// Requests to be sent directly to deployd by a replica the cluster is connected to.
+	channelRequest = "hookd_request"
+	// Acknowledgements of requests sent to deployd on behalf of another replica.
+	channelAck = "hookd_ack"
+	// Clusters with new requests in the dispatch queue.
+	channelQueue = "hookd_queue"
+	// Deployment statuses, for status streams on every replica.
+	channelStatus = "hookd_status"
+	// Diff results, for the replica waiting for them.
+	channelDiff = "hookd_diff"
+	// New deployd connections, so that stale connections from the same deployd instance are closed.
+	channelConnection = "hookd_connection"
+)
+
+// Replicas announce their connected clusters this often, and forget other replicas that
+// haven't announced themselves for presenceTTL.
+const (
+	presenceInterval   = 30 * time.Second
+	presenceTTL        = 3 * presenceInterval
+	resubscribeBackoff = 3 * time.Second
+)
+
+// How long to wait for another replica to acknowledge that it has sent a request to deployd.
+const requestAckTimeout = 10 * time.Second
+
+// replicaMessage is published between hookd replicas. Requests, statuses and diff results are protobuf encoded.
+type replicaMessage struct {
+	Replica  string   `json:"replica"`
+	Cluster  string   `json:"cluster,omitempty"`
+	Clusters []string `json:"clusters,omitempty"`
+	// Target is the replica that should send a request to deployd. Requests without a target,
+	// such as cancellations, are sent by every replica to all of the cluster's connections.
+	Target     string `json:"target,omitempty"`
+	Connection string `json:"connection,omitempty"`
+	Instance   string `json:"instance,omitempty"`
+	// Sync asks other replicas to announce their clusters, e.g. when a replica starts.
+	Sync bool `json:"sync,omitempty"`
+	// Ack identifies a request that the target replica must acknowledge, and the acknowledgement itself.
+	// Acknowledgements of requests that could not be sent carry the error code and message.
+	Ack     string     `json:"ack,omitempty"`
+	Code    codes.Code `json:"code,omitempty"`
+	Error   string     `json:"error,omitempty"`
+	Payload []byte     `json:"payload,omitempty"`
+}
+
+// replicaID identifies this replica in messages to other replicas, and is unique even for replicas in the same process.
+func replicaID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "hookd"
+	}
+	return hostname + "-" + uuid.NewString()[:8]
+}
+
+type remoteReplica struct {
+	clusters []string
+	expires  time.Time
+}
+
+// Run routes requests and statuses between this and other hookd replicas, until the context is cancelled.
+// Does nothing if the dispatch server has no pub/sub.
+func (s *dispatchServer) Run(ctx context.Context) error {
+	if s.bus == nil {
+		return nil
+	}
+
+	channels := []string{channelPresence, channelRequest, channelAck, channelQueue, channelStatus, channelDiff, channelConnection}
+
+	for {
+		messages, err := s.bus.Subscribe(ctx, channels...)
+		if err != nil {
+			log.Errorf("Subscribe to hookd replica messages: %s", err)
+		} else {
+			log.Infof("Routing requests and statuses between hookd replicas as %s", s.replica)
+			s.announce(ctx, true)
+			s.receive(ctx, messages)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(resubscribeBackoff):
+		}
+	}
+}
+
+// receive handles messages until the subscription is lost.
+func (s *dispatchServer) receive(ctx context.Context, messages <-chan pubsub.Message) {
+	ticker := time.NewTicker(presenceInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			s.handleReplicaMessage(ctx, msg)
+		case <-ticker.C:
+			s.announce(ctx, false)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *dispatchServer) handleReplicaMessage(ctx context.Context, msg pubsub.Message) {
+	var message replicaMessage
+	err := json.Unmarshal(msg.Payload, &message)
+	if err != nil {
+		log.Errorf("Invalid message on %s: %s", msg.Channel, err)
+		return
+	}
+	if message.Replica == s.replica {
+		return
+	}
+
+	switch msg.Channel {
+	case channelPresence:
+		s.remoteReplicasLock.Lock()
+		s.remoteReplicas[message.Replica] = remoteReplica{
+			clusters: message.Clusters,
+			expires:  time.Now().Add(presenceTTL),
+		}
+		s.remoteReplicasLock.Unlock()
+		if message.Sync {
+			s.announce(ctx, false)
+		}
+
+	case channelRequest:
+		if message.Target != "" && message.Target != s.replica {
+			return
+		}
+		request := &pb.DeploymentRequest{}
+		err = proto.Unmarshal(message.Payload, request)
+		if err != nil {
+			log.Errorf("Invalid request from replica %s: %s", message.Replica, err)
+			return
+		}
+		logger := log.WithFields(request.LogFields())
+
+		// Don't hold up other messages while deployd is busy.
+		if message.Target == "" {
+			go func() {
+				if _, err := s.sendAll(request); err != nil {
+					logger.Errorf("Send request from replica %s: %s", message.Replica, err)
+				}
+			}()
+			return
+		}
+
+		c, err := s.localConnection(request)
+		if err != nil {
+			logger.Errorf("Discarding request from replica %s: %s", message.Replica, err)
+			s.acknowledge(ctx, message, err)
+			return
+		}
+		if c == nil {
+			logger.Warnf("Discarding request from replica %s; cluster '%s' is not connected", message.Replica, message.Cluster)
+			s.acknowledge(ctx, message, status.Errorf(codes.Unavailable, "cluster '%s' is offline", message.Cluster))
+			return
+		}
+		go func() {
+			err := c.send(request)
+			if err != nil {
+				logger.Errorf("Send request from replica %s: %s", message.Replica, err)
+			}
+			s.acknowledge(ctx, message, err)
+		}()
+
+	case channelAck:
+		if message.Target != s.replica {
+			return
+		}
+		s.ackWaitersLock.Lock()
+		waiter, ok := s.ackWaiters[message.Ack]
+		delete(s.ackWaiters, message.Ack)
+		s.ackWaitersLock.Unlock()
+		if ok {
+			waiter <- message
+		}
+
+	case channelQueue:
+		s.notifyQueued(message.Cluster)
+
+	case channelStatus:
+		st := &pb.DeploymentStatus{}
+		err = proto.Unmarshal(message.Payload, st)
+		if err != nil {
+			log.Errorf("Invalid status from replica %s: %s", message.Replica, err)
+			return
+		}
+		s.receiveDeploymentStatus(st)
+
+	case channelDiff:
+		result := &pb.DiffResult{}
+		err = proto.Unmarshal(message.Payload, result)
+		if err != nil {
+			log.Errorf("Invalid diff result from replica %s: %s", message.Replica, err)
+			return
+		}
+		s.endTraceSpan(result.GetRequest().GetID())
+		_, _ = s.deliverDiff(result)
+
+	case channelConnection:
+		s.replaceConnections(message.Cluster, message.Instance, message.Connection)
+	}
+}
+
+// publish a message to other replicas.
+func (s *dispatchServer) publish(ctx context.Context, channel string, message replicaMessage) error {
+	if s.bus == nil {
+		return nil
+	}
+
+	message.Replica = s.replica
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("encode message to %s: %w", channel, err)
+	}
+
+	err = s.bus.Publish(ctx, channel, payload)
+	if err != nil {
+		return fmt.Errorf("publish message to %s: %w", channel, err)
+	}
+
+	return nil
+}
+
+// publishProto publishes a protobuf message, such as a request, status or diff result.
+func (s *dispatchServer) publishProto(ctx context.Context, channel, cluster string, m proto.Message) error {
+	if s.bus == nil {
+		return nil
+	}
+
+	payload, err := proto.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("encode message to %s: %w", channel, err)
+	}
+
+	return s.publish(ctx, channel, replicaMessage{Cluster: cluster, Payload: payload})
+}
+
+// publishRequest asks another replica to send a request to deployd, or every replica if none is given.
+// A request for a single replica is only sent by that replica, so it waits until the replica acknowledges
+// that deployd has received the request. Requests for every replica are not acknowledged.
+func (s *dispatchServer) publishRequest(ctx context.Context, replica string, request *pb.DeploymentRequest) error {
+	payload, err := proto.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("encode message to %s: %w", channelRequest, err)
+	}
+
+	message := replicaMessage{
+		Cluster: request.GetCluster(),
+		Target:  replica,
+		Payload: payload,
+	}
+	if replica == "" {
+		return s.publish(ctx, channelRequest, message)
+	}
+
+	message.Ack = uuid.NewString()
+	acks := make(chan replicaMessage, 1)
+	s.ackWaitersLock.Lock()
+	s.ackWaiters[message.Ack] = acks
+	s.ackWaitersLock.Unlock()
+	defer func() {
+		s.ackWaitersLock.Lock()
+		delete(s.ackWaiters, message.Ack)
+		s.ackWaitersLock.Unlock()
+	}()
+
+	err = s.publish(ctx, channelRequest, message)
+	if err != nil {
+		return err
+	}
+
+	timeout := time.NewTimer(requestAckTimeout)
+	defer timeout.Stop()
+
+	select {
+	case ack := <-acks:
+		if len(ack.Error) > 0 {
+			return status.Errorf(ack.Code, "send request through replica %s: %s", replica, ack.Error)
+		}
+		return nil
+	case <-timeout.C:
+		return status.Errorf(codes.Unavailable, "replica %s did not acknowledge the request; try again later", replica)
+	case <-ctx.Done():
+		return status.Errorf(codes.DeadlineExceeded, "waiting for replica %s to acknowledge the request: %s", replica, ctx.Err())
+	}
+}
+
+// acknowledge tells the replica that published a request whether it was sent to deployd.
+func (s *dispatchServer) acknowledge(ctx context.Context, request replicaMessage, sendErr error) {
+	if len(request.Ack) == 0 {
+		return
+	}
+
+	ack := replicaMessage{
+		Target: request.Replica,
+		Ack:    request.Ack,
+	}
+	if sendErr != nil {
+		st := status.Convert(sendErr)
+		ack.Code = st.Code()
+		ack.Error = st.Message()
+	}
+
+	err := s.publish(ctx, channelAck, ack)
+	if err != nil {
+		log.Errorf("Acknowledge request from replica %s: %s", request.Replica, err)
+	}
+}
+
+// announce the clusters connected to this replica, optionally asking other replicas to do the same.
+// Failures are only logged, as clusters are announced again periodically.
+func (s *dispatchServer) announce(ctx context.Context, sync bool) {
+	err := s.publish(ctx, channelPresence, replicaMessage{
+		Clusters: s.onlineClusters(),
+		Sync:     sync,
+	})
+	if err != nil {
+		log.Errorf("Announce online clusters: %s", err)
+	}
+}
+
+// remoteReplica returns the ID of another replica that the cluster is connected to.
+// If the cluster is connected to several replicas, requests are spread evenly between them.
+func (s *dispatchServer) remoteReplica(cluster string) (string, bool) {
+	s.remoteReplicasLock.RLock()
+	defer s.remoteReplicasLock.RUnlock()
+
+	now := time.Now()
+	ids := make([]string, 0, len(s.remoteReplicas))
+	for id, replica := range s.remoteReplicas {
+		if now.After(replica.expires) {
+			continue
+		}
+		for _, c := range replica.clusters {
+			if c == cluster {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return "", false
+	}
+	sort.Strings(ids)
+
+	next := s.nextConnection.Add(1)
+	return ids[next%uint64(len(ids))], true
+}
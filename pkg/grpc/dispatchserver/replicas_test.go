@@ -0,0 +1,245 @@
+package dispatchserver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nais/deploy/pkg/hookd/database"
+	"github.com/nais/deploy/pkg/hookd/pubsub"
+	"github.com/nais/deploy/pkg/pb"
+	"github.com/nais/deploy/pkg/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startReplica runs a dispatch server with its own gRPC server, sharing the pub/sub and queue with other replicas.
+func startReplica(ctx context.Context, t *testing.T, bus pubsub.PubSub, queue database.DispatchQueueStore) (*dispatchServer, pb.DispatchClient) {
+	deploymentStore := database.MockDeploymentStore{}
+	deploymentStore.On("HistoricDeployments", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+	deploymentStore.On("WriteDeploymentStatus", mock.Anything, mock.Anything).Return(nil)
+
+	ds := New(&deploymentStore, queue, bus).(*dispatchServer)
+	go ds.Run(ctx)
+
+	b := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	pb.RegisterDispatchServer(srv, ds)
+	go func() {
+		err := srv.Serve(b)
+		if err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			t.Error(err)
+		}
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithContextDialer(bufDialer(b)), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+
+	return ds, pb.NewDispatchClient(conn)
+}
+
+func TestReplicas(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, _ = telemetry.New(ctx, "test", "")
+
+	bus := pubsub.NewMemory()
+	queue := &memoryQueue{}
+	deadline := pb.TimeAsTimestamp(time.Now().Add(time.Minute))
+
+	// deploy clients talk to replica A, while deployd is connected to replica B
+	a, _ := startReplica(ctx, t, bus, queue)
+	b, clientB := startReplica(ctx, t, bus, queue)
+
+	deploymentsClient, err := clientB.Deployments(ctx, deploydOpts("remote", ""))
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		replica, ok := a.remoteReplica("remote")
+		return ok && replica == b.replica
+	}, time.Second, 10*time.Millisecond, "replica A never learned that the cluster is connected to replica B")
+
+	t.Run("deployments are delivered by the replica the cluster is connected to", func(t *testing.T) {
+		err := a.SendDeploymentRequest(ctx, &pb.DeploymentRequest{
+			ID:       "deploy-1",
+			Cluster:  "remote",
+			Team:     "test",
+			Deadline: deadline,
+		})
+		assert.NoError(t, err)
+
+		req, err := deploymentsClient.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, "deploy-1", req.GetID())
+	})
+
+	t.Run("dry runs are routed to the replica the cluster is connected to", func(t *testing.T) {
+		err := a.SendDeploymentRequest(ctx, &pb.DeploymentRequest{
+			ID:       "dry-run-1",
+			Cluster:  "remote",
+			Team:     "test",
+			DryRun:   true,
+			Deadline: deadline,
+		})
+		assert.NoError(t, err)
+
+		req, err := deploymentsClient.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, "dry-run-1", req.GetID())
+	})
+
+	t.Run("statuses reported to one replica reach status streams on another", func(t *testing.T) {
+		streamCtx, streamCancel := context.WithCancel(ctx)
+		defer streamCancel()
+
+		statuses := make(chan *pb.DeploymentStatus, 10)
+		go a.StreamStatus(streamCtx, statuses)
+
+		// wait for status stream to be registered
+		time.Sleep(100 * time.Millisecond)
+
+		request := &pb.DeploymentRequest{ID: "dry-run-2", Cluster: "remote", Team: "test", DryRun: true}
+		_, err := clientB.ReportStatus(ctx, pb.NewDryRunSuccessStatus(request, 1))
+		assert.NoError(t, err)
+
+		select {
+		case st := <-statuses:
+			assert.Equal(t, "dry-run-2", st.GetRequest().GetID())
+		case <-ctx.Done():
+			t.Fatal("status never reached replica A")
+		}
+
+		team, recorded := a.DryRunStatuses("dry-run-2")
+		assert.Len(t, recorded, 1)
+		assert.Equal(t, "test", team)
+	})
+
+	t.Run("diff results are returned to the replica that requested them", func(t *testing.T) {
+		// deployd
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			req, err := deploymentsClient.Recv()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_, err = clientB.ReportDiff(ctx, &pb.DiffResult{Request: req})
+			assert.NoError(t, err)
+		}()
+
+		result, err := a.SendDiffRequest(ctx, &pb.DeploymentRequest{
+			ID:      "diff-1",
+			Cluster: "remote",
+			Team:    "test",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "diff-1", result.GetRequest().GetID())
+		<-done
+	})
+
+	t.Run("requests are sent by only one of the replicas a cluster is connected to", func(t *testing.T) {
+		c, _ := startReplica(ctx, t, bus, queue)
+		_, clientA := startReplica(ctx, t, bus, queue)
+
+		onA := connectInstance(ctx, t, clientA, "shared", "deployd-1")
+		onB := connectInstance(ctx, t, clientB, "shared", "deployd-2")
+		defer onA.cancel()
+		defer onB.cancel()
+
+		assert.Eventually(t, func() bool {
+			c.remoteReplicasLock.RLock()
+			defer c.remoteReplicasLock.RUnlock()
+			connected := 0
+			for _, replica := range c.remoteReplicas {
+				for _, cluster := range replica.clusters {
+					if cluster == "shared" {
+						connected++
+					}
+				}
+			}
+			return connected == 2
+		}, time.Second, 10*time.Millisecond)
+
+		ids := []string{"shared-1", "shared-2", "shared-3", "shared-4"}
+		for _, id := range ids {
+			err := c.SendDeploymentRequest(ctx, &pb.DeploymentRequest{ID: id, Cluster: "shared", Team: "test", DryRun: true, Deadline: deadline})
+			assert.NoError(t, err)
+		}
+
+		received := make(map[string]int)
+		for range ids {
+			select {
+			case req := <-onA.requests:
+				received[req.GetID()]++
+			case req := <-onB.requests:
+				received[req.GetID()]++
+			case <-time.After(time.Second):
+				t.Fatal("not all requests were received")
+			}
+		}
+
+		// give duplicates a chance to arrive
+		time.Sleep(100 * time.Millisecond)
+		assert.Len(t, onA.requests, 0)
+		assert.Len(t, onB.requests, 0)
+		for _, id := range ids {
+			assert.Equal(t, 1, received[id], id)
+		}
+
+		// cancellations are sent to every instance, as any of them may be running the deployment
+		err := c.SendCancelRequest(ctx, &pb.DeploymentRequest{ID: "shared-deploy", Cluster: "shared", Team: "test"})
+		assert.NoError(t, err)
+		for _, instance := range []*deploydInstance{onA, onB} {
+			assert.Equal(t, pb.DeploymentAction_cancel, instance.receive(t).GetAction())
+		}
+	})
+
+	t.Run("requests that are not acknowledged by the owning replica fail", func(t *testing.T) {
+		// replica B claims a cluster that it is not connected to, and an unknown replica a cluster that it never answers for
+		a.remoteReplicasLock.Lock()
+		a.remoteReplicas[b.replica] = remoteReplica{clusters: []string{"remote", "moved"}, expires: time.Now().Add(time.Minute)}
+		a.remoteReplicas["gone"] = remoteReplica{clusters: []string{"ghost"}, expires: time.Now().Add(time.Minute)}
+		a.remoteReplicasLock.Unlock()
+
+		for _, test := range []struct {
+			cluster string
+			code    codes.Code
+		}{
+			{cluster: "moved", code: codes.Unavailable},
+			{cluster: "ghost", code: codes.DeadlineExceeded},
+		} {
+			requestCtx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+			err := a.SendDeploymentRequest(requestCtx, &pb.DeploymentRequest{
+				ID:       "dry-run-" + test.cluster,
+				Cluster:  test.cluster,
+				Team:     "test",
+				DryRun:   true,
+				Deadline: deadline,
+			})
+			cancel()
+			assert.Equal(t, test.code, status.Code(errors.Unwrap(err)), test.cluster)
+		}
+	})
+
+	t.Run("replicas forget clusters that disconnect", func(t *testing.T) {
+		leaving := connectInstance(ctx, t, clientB, "leaving", "")
+		assert.Eventually(t, func() bool {
+			_, ok := a.remoteReplica("leaving")
+			return ok
+		}, time.Second, 10*time.Millisecond)
+
+		leaving.cancel()
+		assert.Eventually(t, func() bool {
+			_, ok := a.remoteReplica("leaving")
+			return !ok
+		}, time.Second, 10*time.Millisecond)
+	})
+}
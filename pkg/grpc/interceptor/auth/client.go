@@ -5,7 +5,10 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"sync/atomic"
 	"time"
+
+	"google.golang.org/grpc"
 )
 
 type ClientInterceptor interface {
@@ -13,21 +16,56 @@ type ClientInterceptor interface {
 	RequireTransportSecurity() bool
 }
 
+type teamContextKey struct{}
+
+// WithTeam makes calls using the context authenticate on behalf of a team other than the configured one,
+// e.g. one that was detected from the deployed resources after the connection was set up.
+func WithTeam(ctx context.Context, team string) context.Context {
+	if team == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, teamContextKey{}, team)
+}
+
+// contextTeam returns the team set with WithTeam, or the configured team.
+func contextTeam(ctx context.Context, configured string) string {
+	if team, ok := ctx.Value(teamContextKey{}).(string); ok {
+		return team
+	}
+	return configured
+}
+
+// TeamUnaryClientInterceptor authenticates every request that belongs to a team on behalf of that team.
+// Streams are opened before any request is sent, so their team must be set with WithTeam.
+func TeamUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if r, ok := req.(teamRequest); ok {
+		ctx = WithTeam(ctx, r.GetTeam())
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
 var _ ClientInterceptor = &APIKeyInterceptor{}
 
 type APIKeyInterceptor struct {
 	APIKey     []byte
 	RequireTLS bool
 	Team       string
+	// Set when the server has rejected a version 2 signature, so that version 1 signatures are used instead.
+	legacy atomic.Bool
 }
 
+// GetRequestMetadata adds the team to every request. Requests are signed by the client interceptors;
+// a version 1 signature is only added here when falling back to it.
 func (c *APIKeyInterceptor) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
-	timestamp := time.Now().Format(time.RFC3339Nano)
-	return map[string]string{
-		"authorization": sign([]byte(timestamp), c.APIKey),
-		"timestamp":     timestamp,
-		"team":          c.Team,
-	}, nil
+	md := map[string]string{
+		"team": contextTeam(ctx, c.Team),
+	}
+	if ctx.Value(legacySignatureKey{}) != nil {
+		timestamp := time.Now().Format(time.RFC3339Nano)
+		md["authorization"] = sign([]byte(timestamp), c.APIKey)
+		md["timestamp"] = timestamp
+	}
+	return md, nil
 }
 
 func (t *APIKeyInterceptor) RequireTransportSecurity() bool {
@@ -45,7 +83,7 @@ type JWTInterceptor struct {
 func (c *JWTInterceptor) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
 	return map[string]string{
 		"jwt":  c.JWT,
-		"team": c.Team,
+		"team": contextTeam(ctx, c.Team),
 	}, nil
 }
 
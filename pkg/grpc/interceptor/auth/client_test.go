@@ -0,0 +1,86 @@
+package auth_interceptor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nais/deploy/pkg/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestClientInterceptorTeam(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		interceptor ClientInterceptor
+	}{
+		{name: "api key", interceptor: &APIKeyInterceptor{APIKey: []byte("apikey")}},
+		{name: "jwt", interceptor: &JWTInterceptor{JWT: "token"}},
+	} {
+		t.Run(test.name+": team is taken from the request", func(t *testing.T) {
+			var team string
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				md, err := test.interceptor.GetRequestMetadata(ctx)
+				team = md["team"]
+				return err
+			}
+
+			req := &pb.DeploymentRequest{Team: "detected"}
+			err := TeamUnaryClientInterceptor(context.Background(), deployMethod, req, nil, nil, invoker)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if team != "detected" {
+				t.Fatalf("got team %q, want %q", team, "detected")
+			}
+		})
+
+		t.Run(test.name+": team is taken from the context", func(t *testing.T) {
+			md, err := test.interceptor.GetRequestMetadata(WithTeam(context.Background(), "detected"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if md["team"] != "detected" {
+				t.Fatalf("got team %q, want %q", md["team"], "detected")
+			}
+		})
+	}
+}
+
+func TestClientInterceptorSignatureFallback(t *testing.T) {
+	client := &APIKeyInterceptor{APIKey: []byte("apikey"), Team: "team"}
+
+	// signatures sent with each call, as received by a server that only supports version 1 signatures
+	var signatures []string
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		credentials, err := client.GetRequestMetadata(ctx)
+		if err != nil {
+			return err
+		}
+		switch {
+		case len(credentials["authorization"]) > 0:
+			signatures = append(signatures, "v1")
+			return nil
+		case len(md.Get(metadataSignature)) > 0:
+			signatures = append(signatures, "v2")
+		}
+		return status.Errorf(codes.Unauthenticated, "request is not signed with API key")
+	}
+
+	req := &pb.DeploymentRequest{Team: "team"}
+	for i := 0; i < 2; i++ {
+		err := client.UnaryClientInterceptor(context.Background(), deployMethod, req, nil, nil, invoker)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"v2", "v1", "v1"}
+	if strings.Join(signatures, ",") != strings.Join(want, ",") {
+		t.Fatalf("got signatures %v, want %v", signatures, want)
+	}
+}
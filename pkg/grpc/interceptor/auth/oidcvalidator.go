@@ -0,0 +1,200 @@
+package auth_interceptor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// RepositoryClaim is the JWT claim holding the repository that a deployment is made from.
+// Tokens from issuers that use another claim have it copied into this one, so that the
+// rest of hookd only has to look at a single claim.
+const RepositoryClaim = "repository"
+
+// TrustedIssuer is an OIDC provider whose tokens are accepted for deployments, such as a CI system.
+type TrustedIssuer struct {
+	// Issuer must match the `iss` claim of the token exactly.
+	Issuer string `json:"issuer"`
+	// JWKSURL is where the issuer publishes its signing keys. Mutually exclusive with JWKSFile.
+	JWKSURL string `json:"jwks-url"`
+	// JWKSFile is a local file with the signing keys, for issuers that don't publish them.
+	JWKSFile string `json:"jwks-file"`
+	// Audience must be one of the audiences in the `aud` claim of the token.
+	Audience string `json:"audience"`
+	// RepositoryClaim names the claim holding the repository, e.g. `project_path` for GitLab.
+	// Defaults to `repository`.
+	RepositoryClaim string `json:"repository-claim"`
+	// RepositoryOwners are the owners, i.e. the first path segment, of the repositories the issuer may assert.
+	// Repositories from every issuer are authorized against teams by name, so an owner listed here
+	// can only be asserted by this issuer. Required for every issuer except GitHub Actions,
+	// which may assert any owner that is not listed by another issuer.
+	RepositoryOwners []string `json:"repository-owners"`
+}
+
+// OIDCValidator validates tokens from any of its trusted issuers.
+type OIDCValidator struct {
+	issuers map[string]*issuerKeys
+	// Maps repository owners, in lower case, to the only issuer that may assert them.
+	owners map[string]string
+}
+
+var _ TokenValidator = &OIDCValidator{}
+
+type issuerKeys struct {
+	TrustedIssuer
+	cache *jwk.Cache
+	// Keys read from a file; nil if keys are fetched from an URL.
+	keys jwk.Set
+}
+
+// NewOIDCValidator fetches the signing keys of every issuer, and returns an error if any of them are unavailable.
+// Keys published at an URL are refreshed in the background until the context is cancelled.
+func NewOIDCValidator(ctx context.Context, issuers []TrustedIssuer) (*OIDCValidator, error) {
+	if len(issuers) == 0 {
+		return nil, fmt.Errorf("no trusted token issuers configured")
+	}
+
+	v := &OIDCValidator{
+		issuers: make(map[string]*issuerKeys),
+		owners:  make(map[string]string),
+	}
+	var cache *jwk.Cache
+
+	for _, issuer := range issuers {
+		if len(issuer.Issuer) == 0 {
+			return nil, fmt.Errorf("trusted issuer must have an issuer URL")
+		}
+		if _, ok := v.issuers[issuer.Issuer]; ok {
+			return nil, fmt.Errorf("issuer %s: configured more than once", issuer.Issuer)
+		}
+		if len(issuer.Audience) == 0 {
+			return nil, fmt.Errorf("issuer %s: audience must be set", issuer.Issuer)
+		}
+		if len(issuer.RepositoryClaim) == 0 {
+			issuer.RepositoryClaim = RepositoryClaim
+		}
+		if issuer.Issuer != Issuer && len(issuer.RepositoryOwners) == 0 {
+			return nil, fmt.Errorf("issuer %s: repository owners must be set", issuer.Issuer)
+		}
+		for _, owner := range issuer.RepositoryOwners {
+			owner = strings.ToLower(owner)
+			if other, ok := v.owners[owner]; ok {
+				return nil, fmt.Errorf("issuer %s: repository owner %q is already trusted for issuer %s", issuer.Issuer, owner, other)
+			}
+			v.owners[owner] = issuer.Issuer
+		}
+
+		ik := &issuerKeys{TrustedIssuer: issuer}
+
+		switch {
+		case len(issuer.JWKSURL) > 0 && len(issuer.JWKSFile) > 0:
+			return nil, fmt.Errorf("issuer %s: only one of JWKS URL and JWKS file can be set", issuer.Issuer)
+
+		case len(issuer.JWKSURL) > 0:
+			if cache == nil {
+				cache = jwk.NewCache(ctx)
+			}
+			err := cache.Register(issuer.JWKSURL, jwk.WithRefreshInterval(time.Hour))
+			if err != nil {
+				return nil, fmt.Errorf("issuer %s: jwks caching: %w", issuer.Issuer, err)
+			}
+			// force initial refresh
+			_, err = cache.Refresh(ctx, issuer.JWKSURL)
+			if err != nil {
+				return nil, fmt.Errorf("issuer %s: jwks caching: %w", issuer.Issuer, err)
+			}
+			ik.cache = cache
+
+		case len(issuer.JWKSFile) > 0:
+			keys, err := jwk.ReadFile(issuer.JWKSFile)
+			if err != nil {
+				return nil, fmt.Errorf("issuer %s: read jwks file: %w", issuer.Issuer, err)
+			}
+			ik.keys = keys
+
+		default:
+			return nil, fmt.Errorf("issuer %s: either JWKS URL or JWKS file must be set", issuer.Issuer)
+		}
+
+		v.issuers[issuer.Issuer] = ik
+	}
+
+	return v, nil
+}
+
+func (v *OIDCValidator) Validate(ctx context.Context, token string) (jwt.Token, error) {
+	// The issuer is only used to select signing keys; the token is verified against them below.
+	unverified, err := jwt.ParseInsecure([]byte(token))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT token: %w", err)
+	}
+
+	issuer, ok := v.issuers[unverified.Issuer()]
+	if !ok {
+		return nil, fmt.Errorf("invalid JWT token: issuer %q is not trusted", unverified.Issuer())
+	}
+
+	pubKeys, err := issuer.keySet(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get signing keys of issuer %s: %w", issuer.Issuer, err)
+	}
+
+	t, err := jwt.Parse([]byte(token),
+		jwt.WithKeySet(pubKeys, jws.WithInferAlgorithmFromKey(true)),
+		jwt.WithValidate(true),
+		jwt.WithAcceptableSkew(5*time.Second),
+		jwt.WithIssuer(issuer.Issuer),
+		jwt.WithAudience(issuer.Audience),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT token: %w", err)
+	}
+
+	if issuer.RepositoryClaim != RepositoryClaim {
+		repo, ok := t.Get(issuer.RepositoryClaim)
+		if !ok {
+			return nil, fmt.Errorf("invalid JWT token: missing %q claim", issuer.RepositoryClaim)
+		}
+		err = t.Set(RepositoryClaim, repo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT token: %w", err)
+		}
+	}
+
+	// The repository is checked by the caller, but must be a string if present.
+	if repo, ok := t.Get(RepositoryClaim); ok {
+		name, isString := repo.(string)
+		if !isString {
+			return nil, fmt.Errorf("invalid JWT token: %q claim is not a string", issuer.RepositoryClaim)
+		}
+		err = v.authorizeOwner(issuer, name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT token: %w", err)
+		}
+	}
+
+	return t, nil
+}
+
+// authorizeOwner makes sure that an issuer only asserts repositories of owners it is trusted for,
+// so that a repository from one issuer cannot pass as a repository with the same name from another.
+func (v *OIDCValidator) authorizeOwner(issuer *issuerKeys, repo string) error {
+	owner, _, _ := strings.Cut(repo, "/")
+	trusted, listed := v.owners[strings.ToLower(owner)]
+	if (listed || len(issuer.RepositoryOwners) > 0) && trusted != issuer.Issuer {
+		return fmt.Errorf("issuer %s is not trusted for repositories owned by %q", issuer.Issuer, owner)
+	}
+	return nil
+}
+
+func (ik *issuerKeys) keySet(ctx context.Context) (jwk.Set, error) {
+	if ik.keys != nil {
+		return ik.keys, nil
+	}
+	return ik.cache.Get(ctx, ik.JWKSURL)
+}
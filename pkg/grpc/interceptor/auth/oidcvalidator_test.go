@@ -0,0 +1,252 @@
+package auth_interceptor
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+type testIssuer struct {
+	issuer string
+	key    jwk.Key
+	jwks   []byte
+}
+
+func newTestIssuer(t *testing.T, issuer string) *testIssuer {
+	raw, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.FromRaw(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.Set(jwk.KeyIDKey, issuer)
+	key.Set(jwk.AlgorithmKey, jwa.RS256)
+
+	set := jwk.NewSet()
+	set.AddKey(key)
+	public, err := jwk.PublicSetOf(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := json.Marshal(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testIssuer{issuer: issuer, key: key, jwks: jwks}
+}
+
+func (i *testIssuer) token(t *testing.T, audience string, claims map[string]interface{}) string {
+	builder := jwt.NewBuilder().
+		Issuer(i.issuer).
+		Audience([]string{audience}).
+		IssuedAt(time.Now()).
+		Expiration(time.Now().Add(time.Minute))
+	for claim, value := range claims {
+		builder = builder.Claim(claim, value)
+	}
+	token, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.RS256, i.key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(signed)
+}
+
+func TestOIDCValidator(t *testing.T) {
+	github := newTestIssuer(t, Issuer)
+	gitlab := newTestIssuer(t, "https://gitlab.example.com")
+	untrusted := newTestIssuer(t, "https://untrusted.example.com")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(github.jwks)
+	}))
+	defer server.Close()
+
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	err := os.WriteFile(jwksFile, gitlab.jwks, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	v, err := NewOIDCValidator(ctx, []TrustedIssuer{
+		{
+			Issuer:   github.issuer,
+			JWKSURL:  server.URL,
+			Audience: "hookd",
+		},
+		{
+			Issuer:           gitlab.issuer,
+			JWKSFile:         jwksFile,
+			Audience:         "https://hookd.example.com",
+			RepositoryClaim:  "project_path",
+			RepositoryOwners: []string{"Platform"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("token from issuer with jwks url", func(t *testing.T) {
+		token, err := v.Validate(ctx, github.token(t, "hookd", map[string]interface{}{"repository": "nais/deploy"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo, _ := token.Get(RepositoryClaim)
+		if repo != "nais/deploy" {
+			t.Fatalf("got repository %v, want nais/deploy", repo)
+		}
+	})
+
+	t.Run("token from issuer with jwks file and repository claim mapping", func(t *testing.T) {
+		token, err := v.Validate(ctx, gitlab.token(t, "https://hookd.example.com", map[string]interface{}{"project_path": "platform/apps/deploy"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo, _ := token.Get(RepositoryClaim)
+		if repo != "platform/apps/deploy" {
+			t.Fatalf("got repository %v, want platform/apps/deploy", repo)
+		}
+	})
+
+	for _, test := range []struct {
+		name  string
+		token string
+		err   string
+	}{
+		{
+			name:  "wrong audience",
+			token: github.token(t, "https://hookd.example.com", nil),
+			err:   `"aud" not satisfied`,
+		},
+		{
+			name:  "untrusted issuer",
+			token: untrusted.token(t, "hookd", nil),
+			err:   `issuer "https://untrusted.example.com" is not trusted`,
+		},
+		{
+			name:  "missing mapped repository claim",
+			token: gitlab.token(t, "https://hookd.example.com", nil),
+			err:   `missing "project_path" claim`,
+		},
+		{
+			name:  "repository of owner not trusted for issuer",
+			token: gitlab.token(t, "https://hookd.example.com", map[string]interface{}{"project_path": "nais/deploy"}),
+			err:   `issuer https://gitlab.example.com is not trusted for repositories owned by "nais"`,
+		},
+		{
+			name:  "repository of owner trusted for another issuer",
+			token: github.token(t, "hookd", map[string]interface{}{"repository": "platform/deploy"}),
+			err:   `issuer https://token.actions.githubusercontent.com is not trusted for repositories owned by "platform"`,
+		},
+		{
+			name:  "not a token",
+			token: "invalid",
+			err:   "invalid JWT token",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := v.Validate(ctx, test.token)
+			if err == nil {
+				t.Fatal("got nil, want error")
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("got '%s', want '%s'", err.Error(), test.err)
+			}
+		})
+	}
+
+	t.Run("token signed by another key", func(t *testing.T) {
+		forged := &testIssuer{issuer: github.issuer, key: untrusted.key}
+		_, err := v.Validate(ctx, forged.token(t, "hookd", map[string]interface{}{"repository": "nais/deploy"}))
+		if err == nil {
+			t.Fatal("got nil, want error")
+		}
+	})
+}
+
+func TestNewOIDCValidatorErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	owners := []string{"nais"}
+
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	err := os.WriteFile(jwksFile, newTestIssuer(t, "https://issuer").jwks, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name    string
+		issuers []TrustedIssuer
+		err     string
+	}{
+		{
+			name:    "jwks unavailable",
+			issuers: []TrustedIssuer{{Issuer: "https://issuer", JWKSURL: server.URL, Audience: "hookd", RepositoryOwners: owners}},
+			err:     "jwks caching",
+		},
+		{
+			name:    "jwks file missing",
+			issuers: []TrustedIssuer{{Issuer: "https://issuer", JWKSFile: filepath.Join(t.TempDir(), "missing.json"), Audience: "hookd", RepositoryOwners: owners}},
+			err:     "read jwks file",
+		},
+		{
+			name:    "no keys",
+			issuers: []TrustedIssuer{{Issuer: "https://issuer", Audience: "hookd", RepositoryOwners: owners}},
+			err:     "either JWKS URL or JWKS file must be set",
+		},
+		{
+			name:    "no audience",
+			issuers: []TrustedIssuer{{Issuer: "https://issuer", JWKSURL: server.URL, RepositoryOwners: owners}},
+			err:     "audience must be set",
+		},
+		{
+			name:    "no repository owners",
+			issuers: []TrustedIssuer{{Issuer: "https://issuer", JWKSURL: server.URL, Audience: "hookd"}},
+			err:     "repository owners must be set",
+		},
+		{
+			name: "repository owner trusted for several issuers",
+			issuers: []TrustedIssuer{
+				{Issuer: "https://issuer", JWKSFile: jwksFile, Audience: "hookd", RepositoryOwners: owners},
+				{Issuer: "https://other-issuer", JWKSFile: jwksFile, Audience: "hookd", RepositoryOwners: []string{"NAIS"}},
+			},
+			err: `repository owner "nais" is already trusted for issuer https://issuer`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewOIDCValidator(context.Background(), test.issuers)
+			if err == nil {
+				t.Fatal("got nil, want error")
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("got '%s', want '%s'", err.Error(), test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package auth_interceptor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DeployPolicy restricts which workflows may deploy to a set of clusters, based on the claims of their JWT.
+// The example below only allows deployments to production clusters from the main branch,
+// through the GitHub environment `production`:
+//
+//	deploy-policies:
+//	  - name: production
+//	    clusters: ["prod-*"]
+//	    claims:
+//	      ref: ["refs/heads/main"]
+//	      environment: ["production"]
+//
+// Clusters, teams and claim values are patterns where `*` matches any sequence of characters, including `/`.
+type DeployPolicy struct {
+	// Name identifies the policy in error messages.
+	Name string `json:"name"`
+	// Clusters the policy applies to. The policy applies to all clusters if empty.
+	Clusters []string `json:"clusters"`
+	// Teams the policy applies to. The policy applies to all teams if empty.
+	Teams []string `json:"teams"`
+	// Claims maps a JWT claim, such as `ref`, `environment`, `workflow_ref`, `event_name` or `actor`,
+	// to its allowed values. Every claim must match one of its values.
+	Claims map[string][]string `json:"claims"`
+}
+
+// DeployPolicies are evaluated together; a request must satisfy every policy that applies to it.
+// Requests that no policy applies to are allowed.
+type DeployPolicies []DeployPolicy
+
+// CompiledDeployPolicies are deploy policies with their patterns compiled, so that they can be evaluated for every request.
+type CompiledDeployPolicies []compiledDeployPolicy
+
+type compiledDeployPolicy struct {
+	DeployPolicy
+	clusters []*regexp.Regexp
+	teams    []*regexp.Regexp
+	// Claims are checked in a stable order, so that the error message is the same for every attempt.
+	claimNames []string
+	claims     map[string][]*regexp.Regexp
+}
+
+// ClaimLookup returns the value of a JWT claim, and whether it is present.
+type ClaimLookup func(claim string) (interface{}, bool)
+
+// Compile compiles the patterns of every policy. It is called once, when the policies are loaded.
+func (policies DeployPolicies) Compile() (CompiledDeployPolicies, error) {
+	compiled := make(CompiledDeployPolicies, 0, len(policies))
+	for _, policy := range policies {
+		c, err := policy.compile()
+		if err != nil {
+			return nil, fmt.Errorf("deploy policy %q: %w", policy.Name, err)
+		}
+		compiled = append(compiled, *c)
+	}
+	return compiled, nil
+}
+
+func (policy DeployPolicy) compile() (*compiledDeployPolicy, error) {
+	var err error
+	compiled := &compiledDeployPolicy{
+		DeployPolicy: policy,
+		claimNames:   make([]string, 0, len(policy.Claims)),
+		claims:       make(map[string][]*regexp.Regexp, len(policy.Claims)),
+	}
+
+	compiled.clusters, err = compilePatterns(policy.Clusters)
+	if err != nil {
+		return nil, fmt.Errorf("clusters: %w", err)
+	}
+
+	compiled.teams, err = compilePatterns(policy.Teams)
+	if err != nil {
+		return nil, fmt.Errorf("teams: %w", err)
+	}
+
+	for name, allowed := range policy.Claims {
+		compiled.claimNames = append(compiled.claimNames, name)
+		compiled.claims[name], err = compilePatterns(allowed)
+		if err != nil {
+			return nil, fmt.Errorf("claim %q: %w", name, err)
+		}
+	}
+	sort.Strings(compiled.claimNames)
+
+	return compiled, nil
+}
+
+// Evaluate returns an error describing the first violated policy, or nil if the request is allowed.
+func (policies CompiledDeployPolicies) Evaluate(cluster, team string, claims ClaimLookup) error {
+	for _, policy := range policies {
+		if !policy.appliesTo(cluster, team) {
+			continue
+		}
+		err := policy.evaluate(claims)
+		if err != nil {
+			return fmt.Errorf("deploy policy %q does not allow team %q to deploy to cluster %q: %w", policy.Name, team, cluster, err)
+		}
+	}
+	return nil
+}
+
+func (policy compiledDeployPolicy) appliesTo(cluster, team string) bool {
+	return (len(policy.clusters) == 0 || matchAny(policy.clusters, cluster)) &&
+		(len(policy.teams) == 0 || matchAny(policy.teams, team))
+}
+
+func (policy compiledDeployPolicy) evaluate(claims ClaimLookup) error {
+	for _, name := range policy.claimNames {
+		allowed := policy.Claims[name]
+		raw, ok := claims(name)
+		if !ok {
+			return fmt.Errorf("token has no %q claim; must be one of %s", name, quoteAll(allowed))
+		}
+		value, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("claim %q is not a string", name)
+		}
+		if !matchAny(policy.claims[name], value) {
+			return fmt.Errorf("claim %q is %q; must be one of %s", name, value, quoteAll(allowed))
+		}
+	}
+	return nil
+}
+
+func matchAny(patterns []*regexp.Regexp, value string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		var err error
+		compiled[i], err = compilePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return compiled, nil
+}
+
+// compilePattern compiles a pattern where `*` matches any sequence of characters.
+// Unlike path.Match, `*` also matches `/`, so that `refs/heads/release/*` matches nested branch names.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	parts := strings.Split(pattern, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	re, err := regexp.Compile("^" + strings.Join(parts, ".*") + "$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = fmt.Sprintf("%q", value)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,150 @@
+package auth_interceptor
+
+import (
+	"strings"
+	"testing"
+)
+
+func claimLookup(claims map[string]interface{}) ClaimLookup {
+	return func(claim string) (interface{}, bool) {
+		value, ok := claims[claim]
+		return value, ok
+	}
+}
+
+func TestDeployPoliciesEvaluate(t *testing.T) {
+	policies := DeployPolicies{
+		{
+			Name:     "production",
+			Clusters: []string{"prod-*"},
+			Claims: map[string][]string{
+				"ref":         {"refs/heads/main", "refs/heads/release/*"},
+				"environment": {"production"},
+			},
+		},
+		{
+			Name:  "aura",
+			Teams: []string{"aura"},
+			Claims: map[string][]string{
+				"event_name": {"push", "workflow_dispatch"},
+			},
+		},
+	}
+
+	compiled, err := policies.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	production := map[string]interface{}{
+		"ref":         "refs/heads/main",
+		"environment": "production",
+		"event_name":  "pull_request",
+	}
+
+	for _, test := range []struct {
+		name    string
+		cluster string
+		team    string
+		claims  map[string]interface{}
+		err     string
+	}{
+		{
+			name:    "no policy applies",
+			cluster: "dev-gcp",
+			team:    "team",
+			claims:  map[string]interface{}{},
+		},
+		{
+			name:    "all claims match",
+			cluster: "prod-gcp",
+			team:    "team",
+			claims:  production,
+		},
+		{
+			name:    "wildcard matches nested branches",
+			cluster: "prod-gcp",
+			team:    "team",
+			claims: map[string]interface{}{
+				"ref":         "refs/heads/release/2024/1",
+				"environment": "production",
+			},
+		},
+		{
+			name:    "claim does not match",
+			cluster: "prod-gcp",
+			team:    "team",
+			claims: map[string]interface{}{
+				"ref":         "refs/heads/main",
+				"environment": "dev",
+			},
+			err: `deploy policy "production" does not allow team "team" to deploy to cluster "prod-gcp": claim "environment" is "dev"; must be one of "production"`,
+		},
+		{
+			name:    "claim is missing",
+			cluster: "prod-gcp",
+			team:    "team",
+			claims: map[string]interface{}{
+				"environment": "production",
+			},
+			err: `token has no "ref" claim; must be one of "refs/heads/main", "refs/heads/release/*"`,
+		},
+		{
+			name:    "claim is not a string",
+			cluster: "prod-gcp",
+			team:    "team",
+			claims: map[string]interface{}{
+				"ref":         "refs/heads/main",
+				"environment": []string{"production"},
+			},
+			err: `claim "environment" is not a string`,
+		},
+		{
+			name:    "every applicable policy must be satisfied",
+			cluster: "prod-gcp",
+			team:    "aura",
+			claims:  production,
+			err:     `deploy policy "aura" does not allow team "aura" to deploy to cluster "prod-gcp": claim "event_name" is "pull_request"; must be one of "push", "workflow_dispatch"`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := compiled.Evaluate(test.cluster, test.team, claimLookup(test.claims))
+			if len(test.err) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("got nil, want error")
+			}
+			if !strings.HasSuffix(err.Error(), test.err) {
+				t.Fatalf("got '%s', want suffix '%s'", err.Error(), test.err)
+			}
+		})
+	}
+}
+
+func TestCompilePattern(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		value   string
+		match   bool
+	}{
+		{"prod-*", "prod-gcp", true},
+		{"prod-*", "dev-gcp", false},
+		{"*", "", true},
+		{"refs/heads/main", "refs/heads/main", true},
+		{"refs/heads/main", "refs/heads/main2", false},
+		{"nais/*/.github/workflows/*.yaml@refs/heads/main", "nais/deploy/.github/workflows/deploy.yaml@refs/heads/main", true},
+		{"v1.*", "v1x2", false},
+	} {
+		re, err := compilePattern(test.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := re.MatchString(test.value); got != test.match {
+			t.Errorf("compilePattern(%q).MatchString(%q) = %v, want %v", test.pattern, test.value, got, test.match)
+		}
+	}
+}
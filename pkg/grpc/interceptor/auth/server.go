@@ -18,7 +18,6 @@ import (
 	api_v1 "github.com/nais/deploy/pkg/hookd/api/v1"
 	"github.com/nais/deploy/pkg/hookd/database"
 	"github.com/nais/deploy/pkg/hookd/metrics"
-	"github.com/nais/deploy/pkg/pb"
 )
 
 const (
@@ -30,6 +29,12 @@ type ServerInterceptor struct {
 	APIKeyStore    database.ApiKeyStore
 	TokenValidator TokenValidator
 	TeamsClient    TeamsClient
+	// Nonces of version 2 signatures; replayed requests are rejected.
+	Nonces NonceCache
+	// RejectLegacySignatures requires API key requests to be signed with version 2 signatures.
+	RejectLegacySignatures bool
+	// Policies restrict deployments made with JWT tokens, based on the token claims.
+	Policies CompiledDeployPolicies
 }
 
 type TokenValidator interface {
@@ -40,10 +45,49 @@ type TeamsClient interface {
 	IsAuthorized(ctx context.Context, repo, team string) (bool, error)
 }
 
+// Requests are scoped to the team they are made on behalf of.
+type teamRequest interface {
+	GetTeam() string
+}
+
+// clusterAuthorizer checks whether the authenticated caller may act on a cluster.
+type clusterAuthorizer func(cluster string) error
+
+type clusterAuthorizerKey struct{}
+
+type apiKeyKey struct{}
+
+// Requests that list deployments in several clusters.
+type clustersRequest interface {
+	GetClusters() []string
+}
+
+// AuthorizeCluster checks the cluster that a request resolves to against the deploy policies
+// and the clusters of the API key the request was signed with.
+// Handlers must call it when the cluster is looked up from stored deployments rather than taken
+// from the request, such as when cancelling, following or rolling back a deployment by its ID.
+func AuthorizeCluster(ctx context.Context, cluster string) error {
+	authorize, ok := ctx.Value(clusterAuthorizerKey{}).(clusterAuthorizer)
+	if !ok {
+		return nil
+	}
+	return authorize(cluster)
+}
+
+// APIKeyAllowsCluster returns false if the request was signed with an API key that may not be used for the cluster.
+// Handlers listing deployments use it to leave out deployments outside the clusters of the key.
+func APIKeyAllowsCluster(ctx context.Context, cluster string) bool {
+	apiKey, ok := ctx.Value(apiKeyKey{}).(*database.ApiKey)
+	return !ok || apiKey == nil || apiKey.AllowsCluster(cluster)
+}
+
 type authData struct {
 	hmac      []byte
 	timestamp string
 	team      string
+	// Version 2 signatures only
+	version int
+	nonce   string
 }
 
 func NewServerInterceptor(apiKeyStore database.ApiKeyStore, tokenValidator TokenValidator, teamsClient TeamsClient) *ServerInterceptor {
@@ -51,13 +95,14 @@ func NewServerInterceptor(apiKeyStore database.ApiKeyStore, tokenValidator Token
 		APIKeyStore:    apiKeyStore,
 		TokenValidator: tokenValidator,
 		TeamsClient:    teamsClient,
+		Nonces:         NewMemoryNonceCache(NonceTTL),
 	}
 }
 
 func (s *ServerInterceptor) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-	_, ok := req.(*pb.DeploymentRequest)
+	_, ok := req.(teamRequest)
 	if !ok {
-		return nil, status.Errorf(codes.InvalidArgument, "requests to this endpoint must be DeploymentRequest")
+		return nil, status.Errorf(codes.InvalidArgument, "requests to this endpoint must be scoped to a team")
 	}
 
 	md, ok := metadata.FromIncomingContext(ctx)
@@ -70,8 +115,13 @@ func (s *ServerInterceptor) UnaryServerInterceptor(ctx context.Context, req inte
 	}
 
 	jwtToken := get("jwt", md)
+	requestType := requestTypeApiKey
+	var team string
+	var authorize clusterAuthorizer
+	var apiKey *database.ApiKey
 
 	if jwtToken != "" {
+		requestType = requestTypeJWT
 		t, err := s.TokenValidator.Validate(ctx, jwtToken)
 		if err != nil {
 			log.WithError(err).Infof("validating token")
@@ -90,7 +140,7 @@ func (s *ServerInterceptor) UnaryServerInterceptor(ctx context.Context, req inte
 		}
 		repo := r.(string)
 
-		team := get("team", md)
+		team = get("team", md)
 		if team == "" {
 			metrics.InterceptorRequest(requestTypeJWT, "no_team")
 			return nil, status.Errorf(codes.InvalidArgument, "missing team in metadata")
@@ -106,7 +156,15 @@ func (s *ServerInterceptor) UnaryServerInterceptor(ctx context.Context, req inte
 			return nil, status.Errorf(codes.PermissionDenied, fmt.Sprintf("repo %q not authorized by team %q", repo, team))
 		}
 
-		metrics.InterceptorRequest(requestTypeJWT, "")
+		authorize = func(cluster string) error {
+			err := s.Policies.Evaluate(cluster, team, t.Get)
+			if err != nil {
+				log.WithError(err).Infof("Deployment from repo %s denied by policy", repo)
+				metrics.InterceptorRequest(requestTypeJWT, "policy_denied")
+				return status.Errorf(codes.PermissionDenied, "%s", err)
+			}
+			return nil
+		}
 	} else {
 		auth, err := extractAuthFromContext(ctx)
 		if err != nil {
@@ -120,17 +178,105 @@ func (s *ServerInterceptor) UnaryServerInterceptor(ctx context.Context, req inte
 			return nil, status.Errorf(codes.DeadlineExceeded, "signature expired")
 		}
 
-		err = s.authenticate(ctx, *auth)
+		message, err := s.signedMessage(*auth, fullMethod(info), req)
 		if err != nil {
 			return nil, err
 		}
 
-		metrics.InterceptorRequest(requestTypeApiKey, "")
+		apiKey, err = s.authenticate(ctx, *auth, message)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.checkNonce(ctx, *auth)
+		if err != nil {
+			return nil, err
+		}
+
+		authorize = func(cluster string) error {
+			err := authorizeAPIKeyCluster(apiKey, cluster)
+			if err != nil {
+				metrics.InterceptorRequest(requestTypeApiKey, "cluster_not_allowed")
+			}
+			return err
+		}
+
+		team = auth.team
+	}
+
+	// Requests naming a deployment by its ID may leave out the cluster.
+	// Their handlers check the cluster of the stored deployment with AuthorizeCluster instead.
+	if r, ok := req.(clusterRequest); ok && len(r.GetCluster()) > 0 {
+		err = authorize(r.GetCluster())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Deploy policies restrict deployments, not listing them, so only the clusters of the API key are checked.
+	if r, ok := req.(clustersRequest); ok {
+		for _, cluster := range r.GetClusters() {
+			err = authorizeAPIKeyCluster(apiKey, cluster)
+			if err != nil {
+				metrics.InterceptorRequest(requestType, "cluster_not_allowed")
+				return nil, err
+			}
+		}
+	}
+
+	err = authorizeTeam(req, team)
+	if err != nil {
+		metrics.InterceptorRequest(requestType, "team_mismatch")
+		return nil, err
 	}
 
+	metrics.InterceptorRequest(requestType, "")
+
+	ctx = context.WithValue(ctx, clusterAuthorizerKey{}, authorize)
+	ctx = context.WithValue(ctx, apiKeyKey{}, apiKey)
+
 	return handler(ctx, req)
 }
 
+// Make sure that the request is made on behalf of the authenticated team.
+// Requests without a team are left to the handler.
+func authorizeTeam(req interface{}, team string) error {
+	request, ok := req.(teamRequest)
+	if !ok {
+		return status.Errorf(codes.InvalidArgument, "requests to this endpoint must be scoped to a team")
+	}
+	if len(request.GetTeam()) > 0 && request.GetTeam() != team {
+		return status.Errorf(codes.PermissionDenied, "request for team %q does not match authenticated team %q", request.GetTeam(), team)
+	}
+	return nil
+}
+
+// teamScopedStream authorizes every message received on a stream against the authenticated team,
+// and the clusters of the API key, if any.
+type teamScopedStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	team   string
+	apiKey *database.ApiKey
+}
+
+// Context carries the cluster authorizer of the API key, so that handlers can call AuthorizeCluster.
+func (ss *teamScopedStream) Context() context.Context {
+	return ss.ctx
+}
+
+func (ss *teamScopedStream) RecvMsg(m interface{}) error {
+	err := ss.ServerStream.RecvMsg(m)
+	if err != nil {
+		return err
+	}
+	err = authorizeTeam(m, ss.team)
+	if err != nil {
+		return err
+	}
+	return authorizeCluster(m, ss.apiKey)
+}
+
 func get(key string, md metadata.MD) string {
 	_, ok := md[key]
 	if ok && len(md[key]) == 1 {
@@ -139,26 +285,97 @@ func get(key string, md metadata.MD) string {
 	return ""
 }
 
-func (s *ServerInterceptor) authenticate(ctx context.Context, auth authData) error {
+// signedMessage returns the message that the request signature is computed over.
+func (s *ServerInterceptor) signedMessage(auth authData, method string, req interface{}) ([]byte, error) {
+	if auth.version < 2 {
+		if s.RejectLegacySignatures {
+			metrics.InterceptorRequest(requestTypeApiKey, "legacy_signature")
+			return nil, status.Errorf(codes.Unauthenticated, "request signature is no longer supported; please upgrade your deploy client")
+		}
+		return []byte(auth.timestamp), nil
+	}
+
+	digest, err := SignatureDigest(method, auth.team, req, auth.timestamp, auth.nonce)
+	if err != nil {
+		metrics.InterceptorRequest(requestTypeApiKey, "invalid_auth_metadata")
+		return nil, status.Errorf(codes.InvalidArgument, "unable to compute request signature: %s", err)
+	}
+	return digest, nil
+}
+
+// checkNonce rejects version 2 signatures that have already been used.
+// Only authenticated requests are checked, so that anonymous callers cannot fill up the cache.
+func (s *ServerInterceptor) checkNonce(ctx context.Context, auth authData) error {
+	if auth.version < 2 || s.Nonces == nil {
+		return nil
+	}
+	added, err := s.Nonces.Add(ctx, auth.nonce, time.Now())
+	if err != nil {
+		log.Errorf("Store signature nonce for team %s: %s", auth.team, err)
+		metrics.InterceptorRequest(requestTypeApiKey, "database_error")
+		return status.Errorf(codes.Unavailable, "something wrong happened when communicating with the database")
+	}
+	if !added {
+		log.Warnf("Replayed request signature for team %s", auth.team)
+		metrics.InterceptorRequest(requestTypeApiKey, "signature_replayed")
+		return status.Errorf(codes.PermissionDenied, "request signature has already been used")
+	}
+	return nil
+}
+
+// authenticate returns the API key that the request is signed with.
+func (s *ServerInterceptor) authenticate(ctx context.Context, auth authData, message []byte) (*database.ApiKey, error) {
 	apiKeys, err := s.APIKeyStore.ApiKeys(ctx, auth.team)
 	if err != nil {
 		log.Errorf("Fetch API keys for team %s: %s", auth.team, err)
 		if database.IsErrNotFound(err) {
 			metrics.InterceptorRequest(requestTypeApiKey, "team_not_found")
-			return status.Errorf(codes.Unauthenticated, "failed authentication")
+			return nil, status.Errorf(codes.Unauthenticated, "failed authentication")
 		}
 		metrics.InterceptorRequest(requestTypeApiKey, "database_error")
-		return status.Errorf(codes.Unavailable, "something wrong happened when communicating with api key service")
+		return nil, status.Errorf(codes.Unavailable, "something wrong happened when communicating with api key service")
 	}
 
-	err = api_v1.ValidateAnyMAC([]byte(auth.timestamp), auth.hmac, apiKeys.Valid().Keys())
-	if err != nil {
-		log.WithError(err).Infof("Validate HMAC signature of team %s", auth.team)
-		metrics.InterceptorRequest(requestTypeApiKey, "invalid_api_key")
-		return status.Errorf(codes.PermissionDenied, "failed authentication")
+	for _, apiKey := range apiKeys.Valid() {
+		if len(apiKey.Key) == 0 || !api_v1.ValidateMAC(message, auth.hmac, apiKey.Key) {
+			continue
+		}
+		if len(apiKey.ID) > 0 {
+			err = s.APIKeyStore.MarkApiKeyUsed(ctx, apiKey.ID)
+			if err != nil {
+				log.Warnf("Record use of API key %s for team %s: %s", apiKey.ID, auth.team, err)
+			}
+		}
+		return &apiKey, nil
 	}
 
-	return nil
+	log.Infof("Validate HMAC signature of team %s: no matching API key", auth.team)
+	metrics.InterceptorRequest(requestTypeApiKey, "invalid_api_key")
+	return nil, status.Errorf(codes.PermissionDenied, "failed authentication")
+}
+
+// authorizeCluster makes sure that an API key limited to some clusters is only used for those clusters.
+// Requests that don't target a cluster are allowed, and their handlers check the cluster with AuthorizeCluster.
+func authorizeCluster(req interface{}, apiKey *database.ApiKey) error {
+	request, ok := req.(clusterRequest)
+	if !ok || len(request.GetCluster()) == 0 {
+		return nil
+	}
+	return authorizeAPIKeyCluster(apiKey, request.GetCluster())
+}
+
+func authorizeAPIKeyCluster(apiKey *database.ApiKey, cluster string) error {
+	if apiKey == nil || apiKey.AllowsCluster(cluster) {
+		return nil
+	}
+	return status.Errorf(codes.PermissionDenied, "API key %q is not allowed to be used for cluster %q", apiKey.Name, cluster)
+}
+
+func fullMethod(info *grpc.UnaryServerInfo) string {
+	if info == nil {
+		return ""
+	}
+	return info.FullMethod
 }
 
 func withinTimeRange(t time.Time) bool {
@@ -180,6 +397,8 @@ func (s *ServerInterceptor) StreamServerInterceptor(srv interface{}, ss grpc.Ser
 	}
 
 	jwtToken := get("jwt", md)
+	var team string
+	var apiKey *database.ApiKey
 
 	if jwtToken != "" {
 		t, err := s.TokenValidator.Validate(ss.Context(), jwtToken)
@@ -196,7 +415,7 @@ func (s *ServerInterceptor) StreamServerInterceptor(srv interface{}, ss grpc.Ser
 		}
 		repo := r.(string)
 
-		team := get("team", md)
+		team = get("team", md)
 		if team == "" {
 			return status.Errorf(codes.InvalidArgument, "missing team in metadata")
 		}
@@ -219,13 +438,32 @@ func (s *ServerInterceptor) StreamServerInterceptor(srv interface{}, ss grpc.Ser
 			return status.Errorf(codes.DeadlineExceeded, "signature is too old")
 		}
 
-		err = s.authenticate(ss.Context(), *auth)
+		// Streams are signed before the first message is sent, so only the method is covered.
+		message, err := s.signedMessage(*auth, info.FullMethod, nil)
+		if err != nil {
+			return err
+		}
+
+		apiKey, err = s.authenticate(ss.Context(), *auth, message)
 		if err != nil {
 			return err
 		}
+
+		err = s.checkNonce(ss.Context(), *auth)
+		if err != nil {
+			return err
+		}
+
+		team = auth.team
 	}
 
-	return handler(srv, ss)
+	authorize := clusterAuthorizer(func(cluster string) error {
+		return authorizeAPIKeyCluster(apiKey, cluster)
+	})
+	ctx := context.WithValue(ss.Context(), clusterAuthorizerKey{}, authorize)
+	ctx = context.WithValue(ctx, apiKeyKey{}, apiKey)
+
+	return handler(srv, &teamScopedStream{ServerStream: ss, ctx: ctx, team: team, apiKey: apiKey})
 }
 
 func (s *ServerInterceptor) Stream() grpc.StreamServerInterceptor {
@@ -238,16 +476,31 @@ func extractAuthFromContext(ctx context.Context) (*authData, error) {
 		return nil, status.Errorf(codes.Unauthenticated, "request is not signed with API key; metadata is missing from request headers")
 	}
 
-	hmac := md["authorization"]
+	version := 2
+	hmac := md[metadataSignature]
+	timestamp := md[metadataSignatureTimestamp]
+	nonce := md[metadataSignatureNonce]
+
+	// Fall back to version 1 signatures, which are also sent by clients supporting version 2.
+	if len(hmac) == 0 {
+		version = 1
+		hmac = md["authorization"]
+		timestamp = md["timestamp"]
+		nonce = []string{""}
+	}
+
 	if len(hmac) == 0 {
 		return nil, status.Errorf(codes.Unauthenticated, "request is not signed with API key")
 	}
 
-	timestamp := md["timestamp"]
 	if len(timestamp) == 0 {
 		return nil, status.Errorf(codes.Unauthenticated, "API key signature timestamp is not provided")
 	}
 
+	if len(nonce) == 0 || (version == 2 && len(nonce[0]) == 0) {
+		return nil, status.Errorf(codes.Unauthenticated, "API key signature nonce is not provided")
+	}
+
 	team := md["team"]
 	if len(team) == 0 {
 		return nil, status.Errorf(codes.Unauthenticated, "team is not provided in API key signature metadata")
@@ -262,5 +515,7 @@ func extractAuthFromContext(ctx context.Context) (*authData, error) {
 		hmac:      mac,
 		timestamp: timestamp[0],
 		team:      team[0],
+		version:   version,
+		nonce:     nonce[0],
 	}, nil
 }
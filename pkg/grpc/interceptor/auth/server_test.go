@@ -11,7 +11,11 @@ import (
 	api_v1 "github.com/nais/deploy/pkg/hookd/api/v1"
 	"github.com/nais/deploy/pkg/hookd/database"
 	"github.com/nais/deploy/pkg/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestServerInterceptorApiKey(t *testing.T) {
@@ -81,6 +85,160 @@ func TestServerInterceptorApiKey(t *testing.T) {
 	})
 }
 
+func TestServerInterceptorApiKeyScope(t *testing.T) {
+	i := &ServerInterceptor{APIKeyStore: &mockAPIKeyStore{}}
+
+	signedContext := func(key string) context.Context {
+		timestamp := time.Now().Format(time.RFC3339Nano)
+		return metadata.NewIncomingContext(context.Background(), metadata.MD{
+			"authorization": []string{sign([]byte(timestamp), []byte(key))},
+			"timestamp":     []string{timestamp},
+			"team":          []string{"team"},
+		})
+	}
+
+	t.Run("scoped key in allowed cluster", func(t *testing.T) {
+		_, err := i.UnaryServerInterceptor(signedContext("scoped"), &pb.DeploymentRequest{Team: "team", Cluster: "dev-gcp"}, nil, handler)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("scoped key in other cluster", func(t *testing.T) {
+		_, err := i.UnaryServerInterceptor(signedContext("scoped"), &pb.DeploymentRequest{Team: "team", Cluster: "prod-gcp"}, nil, handler)
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("got %v, want PermissionDenied", err)
+		}
+
+		want := `API key "dev" is not allowed to be used for cluster "prod-gcp"`
+		if status.Convert(err).Message() != want {
+			t.Fatalf("got '%s', want '%s'", status.Convert(err).Message(), want)
+		}
+	})
+
+	t.Run("unscoped key in any cluster", func(t *testing.T) {
+		_, err := i.UnaryServerInterceptor(signedContext("apikey"), &pb.DeploymentRequest{Team: "team", Cluster: "prod-gcp"}, nil, handler)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("scoped key for deployment in other cluster", func(t *testing.T) {
+		resolve := func(ctx context.Context, req any) (any, error) {
+			return nil, AuthorizeCluster(ctx, "prod-gcp")
+		}
+		_, err := i.UnaryServerInterceptor(signedContext("scoped"), &pb.DeploymentRequest{Team: "team", ID: "deployment", Cluster: "dev-gcp"}, nil, resolve)
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("got %v, want PermissionDenied", err)
+		}
+	})
+
+	t.Run("revoked key", func(t *testing.T) {
+		_, err := i.UnaryServerInterceptor(signedContext("revoked"), &pb.DeploymentRequest{Team: "team", Cluster: "dev-gcp"}, nil, handler)
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("got %v, want PermissionDenied", err)
+		}
+	})
+
+	// Cancel and status requests may only name a deployment; the handler checks the cluster it was made to.
+	for _, test := range []struct {
+		name    string
+		cluster string
+		code    codes.Code
+	}{
+		{name: "scoped key without cluster for deployment in allowed cluster", cluster: "dev-gcp", code: codes.OK},
+		{name: "scoped key without cluster for deployment in other cluster", cluster: "prod-gcp", code: codes.PermissionDenied},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			resolve := func(ctx context.Context, req any) (any, error) {
+				return nil, AuthorizeCluster(ctx, test.cluster)
+			}
+			_, err := i.UnaryServerInterceptor(signedContext("scoped"), &pb.DeploymentRequest{Team: "team", ID: "deployment"}, nil, resolve)
+			if status.Code(err) != test.code {
+				t.Fatalf("got %v, want %s", err, test.code)
+			}
+		})
+	}
+
+	for _, test := range []struct {
+		name     string
+		clusters []string
+		code     codes.Code
+	}{
+		{name: "scoped key lists allowed clusters", clusters: []string{"dev-gcp", "dev-fss"}, code: codes.OK},
+		{name: "scoped key lists other clusters", clusters: []string{"dev-gcp", "prod-gcp"}, code: codes.PermissionDenied},
+		{name: "scoped key lists every cluster", code: codes.OK},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := i.UnaryServerInterceptor(signedContext("scoped"), &pb.ListDeploymentsRequest{Team: "team", Clusters: test.clusters}, nil, handler)
+			if status.Code(err) != test.code {
+				t.Fatalf("got %v, want %s", err, test.code)
+			}
+		})
+	}
+
+	t.Run("deployments listed with scoped key", func(t *testing.T) {
+		list := func(ctx context.Context, req any) (any, error) {
+			if !APIKeyAllowsCluster(ctx, "dev-gcp") {
+				t.Error("deployment in allowed cluster is left out")
+			}
+			if APIKeyAllowsCluster(ctx, "prod-gcp") {
+				t.Error("deployment in other cluster is listed")
+			}
+			return nil, nil
+		}
+		_, err := i.UnaryServerInterceptor(signedContext("scoped"), &pb.ListDeploymentsRequest{Team: "team"}, nil, list)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestStreamServerInterceptorApiKeyScope(t *testing.T) {
+	i := &ServerInterceptor{APIKeyStore: &mockAPIKeyStore{}}
+
+	for _, test := range []struct {
+		name     string
+		request  *pb.DeploymentRequest
+		resolved string
+		code     codes.Code
+	}{
+		{name: "scoped key in allowed cluster", request: &pb.DeploymentRequest{Team: "team", ID: "deployment", Cluster: "dev-gcp"}, code: codes.OK},
+		{name: "scoped key in other cluster", request: &pb.DeploymentRequest{Team: "team", ID: "deployment", Cluster: "prod-gcp"}, code: codes.PermissionDenied},
+		{name: "scoped key without cluster for deployment in allowed cluster", request: &pb.DeploymentRequest{Team: "team", ID: "deployment"}, resolved: "dev-gcp", code: codes.OK},
+		{name: "scoped key without cluster for deployment in other cluster", request: &pb.DeploymentRequest{Team: "team", ID: "deployment"}, resolved: "prod-gcp", code: codes.PermissionDenied},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			timestamp := time.Now().Format(time.RFC3339Nano)
+			ss := &mockServerStream{
+				ctx: metadata.NewIncomingContext(context.Background(), metadata.MD{
+					"authorization": []string{sign([]byte(timestamp), []byte("scoped"))},
+					"timestamp":     []string{timestamp},
+					"team":          []string{"team"},
+				}),
+				request: test.request,
+			}
+
+			follow := func(srv any, stream grpc.ServerStream) error {
+				request := &pb.DeploymentRequest{}
+				err := stream.RecvMsg(request)
+				if err != nil {
+					return err
+				}
+				if len(test.resolved) > 0 {
+					return AuthorizeCluster(stream.Context(), test.resolved)
+				}
+				return nil
+			}
+
+			err := i.StreamServerInterceptor(nil, ss, &grpc.StreamServerInfo{FullMethod: "/pb.Deploy/Status"}, follow)
+			if status.Code(err) != test.code {
+				t.Fatalf("got %v, want %s", err, test.code)
+			}
+		})
+	}
+}
+
 func TestServerInterceptorJWT(t *testing.T) {
 	i := &ServerInterceptor{
 		APIKeyStore: &mockAPIKeyStore{},
@@ -147,25 +305,182 @@ func TestServerInterceptorJWT(t *testing.T) {
 	})
 }
 
+func TestServerInterceptorDeployPolicies(t *testing.T) {
+	i := &ServerInterceptor{
+		TokenValidator: &mockTokenValidator{
+			repo:  "repo",
+			valid: "valid",
+			claims: map[string]interface{}{
+				"ref":         "refs/heads/feature",
+				"environment": "production",
+			},
+		},
+		TeamsClient: &mockTeamsClient{
+			authorized: map[string]string{"repo": "team"},
+		},
+	}
+
+	policies, err := DeployPolicies{
+		{
+			Name:     "production",
+			Clusters: []string{"prod-*"},
+			Claims: map[string][]string{
+				"ref":         {"refs/heads/main"},
+				"environment": {"production"},
+			},
+		},
+	}.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.Policies = policies
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.MD{
+		"jwt":  []string{"valid"},
+		"team": []string{"team"},
+	})
+
+	t.Run("deploy to cluster without policy", func(t *testing.T) {
+		_, err := i.UnaryServerInterceptor(ctx, &pb.DeploymentRequest{Cluster: "dev-gcp"}, nil, handler)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("deploy to cluster with policy", func(t *testing.T) {
+		_, err := i.UnaryServerInterceptor(ctx, &pb.DeploymentRequest{Cluster: "prod-gcp"}, nil, handler)
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("got %v, want PermissionDenied", err)
+		}
+
+		want := `deploy policy "production" does not allow team "team" to deploy to cluster "prod-gcp": claim "ref" is "refs/heads/feature"; must be one of "refs/heads/main"`
+		if status.Convert(err).Message() != want {
+			t.Fatalf("got '%s', want '%s'", status.Convert(err).Message(), want)
+		}
+	})
+
+	t.Run("rollback in cluster with policy", func(t *testing.T) {
+		_, err := i.UnaryServerInterceptor(ctx, &pb.RollbackRequest{Cluster: "prod-gcp"}, nil, handler)
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("got %v, want PermissionDenied", err)
+		}
+	})
+
+	t.Run("list deployments", func(t *testing.T) {
+		_, err := i.UnaryServerInterceptor(ctx, &pb.ListDeploymentsRequest{Clusters: []string{"prod-gcp"}}, nil, handler)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// Cancel and rollback requests may only name a deployment; the handler looks up its cluster.
+	for _, test := range []struct {
+		name    string
+		cluster string
+		code    codes.Code
+	}{
+		{name: "deployment resolved to cluster without policy", cluster: "dev-gcp", code: codes.OK},
+		{name: "deployment resolved to cluster with policy", cluster: "prod-gcp", code: codes.PermissionDenied},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			resolve := func(ctx context.Context, req any) (any, error) {
+				return nil, AuthorizeCluster(ctx, test.cluster)
+			}
+			_, err := i.UnaryServerInterceptor(ctx, &pb.RollbackRequest{DeploymentID: "deployment"}, nil, resolve)
+			if status.Code(err) != test.code {
+				t.Fatalf("got %v, want %s", err, test.code)
+			}
+		})
+	}
+}
+
+func TestServerInterceptorTeamScope(t *testing.T) {
+	i := &ServerInterceptor{APIKeyStore: &mockAPIKeyStore{}}
+
+	signedContext := func() context.Context {
+		timestamp := time.Now().Format(time.RFC3339Nano)
+		return metadata.NewIncomingContext(context.Background(), metadata.MD{
+			"authorization": []string{sign([]byte(timestamp), []byte("apikey"))},
+			"timestamp":     []string{timestamp},
+			"team":          []string{"team"},
+		})
+	}
+
+	t.Run("list deployments for authenticated team", func(t *testing.T) {
+		_, err := i.UnaryServerInterceptor(signedContext(), &pb.ListDeploymentsRequest{Team: "team"}, nil, handler)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("list deployments for another team", func(t *testing.T) {
+		_, err := i.UnaryServerInterceptor(signedContext(), &pb.ListDeploymentsRequest{Team: "other"}, nil, handler)
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("got %v, want PermissionDenied", err)
+		}
+	})
+
+	t.Run("deploy on behalf of another team", func(t *testing.T) {
+		_, err := i.UnaryServerInterceptor(signedContext(), &pb.DeploymentRequest{Team: "other"}, nil, handler)
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("got %v, want PermissionDenied", err)
+		}
+	})
+
+	t.Run("request without team scope", func(t *testing.T) {
+		_, err := i.UnaryServerInterceptor(signedContext(), &pb.ReportStatusOpts{}, nil, handler)
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("got %v, want InvalidArgument", err)
+		}
+	})
+}
+
 type mockAPIKeyStore struct{}
 
 func (m *mockAPIKeyStore) ApiKeys(ctx context.Context, id string) (database.ApiKeys, error) {
+	revoked := time.Now().Add(-time.Second)
 	return database.ApiKeys{
 		database.ApiKey{
 			Key:     api_v1.Key("apikey"),
 			Team:    "team",
 			Expires: time.Now().Add(time.Duration(30 * time.Second)),
 		},
+		database.ApiKey{
+			Key:      api_v1.Key("scoped"),
+			Team:     "team",
+			Name:     "dev",
+			Clusters: []string{"dev-*"},
+			Expires:  time.Now().Add(time.Duration(30 * time.Second)),
+		},
+		database.ApiKey{
+			Key:     api_v1.Key("revoked"),
+			Team:    "team",
+			Expires: time.Now().Add(time.Duration(30 * time.Second)),
+			Revoked: &revoked,
+		},
 	}, nil
 }
 
+func (m *mockAPIKeyStore) CreateApiKey(ctx context.Context, apiKey database.ApiKey) (*database.ApiKey, error) {
+	return &apiKey, nil
+}
+
+func (m *mockAPIKeyStore) RevokeApiKey(ctx context.Context, team, id string) error {
+	return nil
+}
+
+func (m *mockAPIKeyStore) MarkApiKeyUsed(ctx context.Context, id string) error {
+	return nil
+}
+
 func (m *mockAPIKeyStore) RotateApiKey(ctx context.Context, team string, key api_v1.Key) error {
 	return nil
 }
 
 type mockTokenValidator struct {
-	repo  string
-	valid string
+	repo   string
+	valid  string
+	claims map[string]interface{}
 }
 
 func (m *mockTokenValidator) Validate(ctx context.Context, token string) (jwt.Token, error) {
@@ -173,7 +488,11 @@ func (m *mockTokenValidator) Validate(ctx context.Context, token string) (jwt.To
 		return nil, fmt.Errorf("invalid token")
 	}
 
-	return jwt.NewBuilder().Claim("repository", m.repo).Build()
+	builder := jwt.NewBuilder().Claim("repository", m.repo)
+	for claim, value := range m.claims {
+		builder = builder.Claim(claim, value)
+	}
+	return builder.Build()
 }
 
 type mockTeamsClient struct {
@@ -184,6 +503,22 @@ func (m *mockTeamsClient) IsAuthorized(ctx context.Context, repo, team string) (
 	return m.authorized[repo] == team, nil
 }
 
+// mockServerStream receives a single request.
+type mockServerStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	request *pb.DeploymentRequest
+}
+
+func (m *mockServerStream) Context() context.Context {
+	return m.ctx
+}
+
+func (m *mockServerStream) RecvMsg(msg any) error {
+	proto.Merge(msg.(proto.Message), m.request)
+	return nil
+}
+
 func handler(ctx context.Context, req any) (any, error) {
 	return nil, nil
 }
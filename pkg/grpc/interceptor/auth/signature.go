@@ -0,0 +1,208 @@
+package auth_interceptor
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	api_v1 "github.com/nais/deploy/pkg/hookd/api/v1"
+	"github.com/nais/deploy/pkg/hookd/database"
+	"github.com/nais/deploy/pkg/pb"
+)
+
+// Metadata keys for version 2 API key signatures.
+//
+// Version 1 signatures only cover the timestamp, so a captured signature can be used for any request
+// on behalf of the same team until it expires. Version 2 signatures cover the gRPC method, team, cluster,
+// Kubernetes resources and a nonce, so that a signature is only valid for a single request.
+const (
+	metadataSignature          = "signature"
+	metadataSignatureTimestamp = "signature-timestamp"
+	metadataSignatureNonce     = "signature-nonce"
+
+	signatureV2Prefix = "nais-deploy-signature-v2"
+	nonceLength       = 16
+
+	// NonceTTL covers the lifetime of a signature, in both directions of the allowed clock skew.
+	NonceTTL = 2 * api_v1.MaxTimeSkew * time.Second
+)
+
+// Requests that target a cluster.
+type clusterRequest interface {
+	GetCluster() string
+}
+
+// Requests that carry Kubernetes resources.
+type resourceRequest interface {
+	GetKubernetes() *pb.Kubernetes
+}
+
+// SignatureDigest returns the canonical digest of a request, which is signed with the team's API key.
+// Streaming requests are signed before any message is sent, so req is nil for streams.
+func SignatureDigest(method, team string, req interface{}, timestamp, nonce string) ([]byte, error) {
+	var cluster, resources string
+
+	if r, ok := req.(clusterRequest); ok {
+		cluster = r.GetCluster()
+	}
+
+	if r, ok := req.(resourceRequest); ok && r.GetKubernetes() != nil {
+		data, err := proto.MarshalOptions{Deterministic: true}.Marshal(r.GetKubernetes())
+		if err != nil {
+			return nil, fmt.Errorf("encode resources: %w", err)
+		}
+		sum := sha256.Sum256(data)
+		resources = hex.EncodeToString(sum[:])
+	}
+
+	canonical := strings.Join([]string{
+		signatureV2Prefix,
+		method,
+		team,
+		cluster,
+		resources,
+		timestamp,
+		nonce,
+	}, "\n")
+
+	digest := sha256.Sum256([]byte(canonical))
+	return digest[:], nil
+}
+
+// signV2 adds a version 2 signature of the request to the outgoing metadata.
+func (c *APIKeyInterceptor) signV2(ctx context.Context, method string, req interface{}) (context.Context, error) {
+	nonce := make([]byte, nonceLength)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+
+	timestamp := time.Now().Format(time.RFC3339Nano)
+	encodedNonce := hex.EncodeToString(nonce)
+
+	digest, err := SignatureDigest(method, contextTeam(ctx, c.Team), req, timestamp, encodedNonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return metadata.AppendToOutgoingContext(ctx,
+		metadataSignature, sign(digest, c.APIKey),
+		metadataSignatureTimestamp, timestamp,
+		metadataSignatureNonce, encodedNonce,
+	), nil
+}
+
+// UnaryClientInterceptor signs every unary request with a version 2 signature.
+// Servers that only support version 1 signatures reject the request as unsigned; it is then sent again
+// with a version 1 signature, which is used for every following request as well.
+func (c *APIKeyInterceptor) UnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if !c.legacy.Load() {
+		signed, err := c.signV2(ctx, method, req)
+		if err != nil {
+			return err
+		}
+		err = invoker(signed, method, req, reply, cc, opts...)
+		if !requiresLegacySignature(err) {
+			return err
+		}
+		log.Warnf("NAIS deploy does not support version 2 request signatures; falling back to version 1")
+		c.legacy.Store(true)
+	}
+	return invoker(withLegacySignature(ctx), method, req, reply, cc, opts...)
+}
+
+// StreamClientInterceptor signs every stream with a version 2 signature.
+// Streams are rejected when the first message is received, so they can't fall back to version 1 signatures
+// by themselves. They do so if a unary request has already fallen back.
+func (c *APIKeyInterceptor) StreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if c.legacy.Load() {
+		return streamer(withLegacySignature(ctx), desc, cc, method, opts...)
+	}
+	ctx, err := c.signV2(ctx, method, nil)
+	if err != nil {
+		return nil, err
+	}
+	return streamer(ctx, desc, cc, method, opts...)
+}
+
+type legacySignatureKey struct{}
+
+// withLegacySignature makes GetRequestMetadata add a version 1 signature to calls using the context.
+func withLegacySignature(ctx context.Context) context.Context {
+	return context.WithValue(ctx, legacySignatureKey{}, true)
+}
+
+// requiresLegacySignature returns true if the server rejected a request because it has no version 1 signature.
+// Servers supporting version 2 signatures never reject requests with one for this reason.
+func requiresLegacySignature(err error) bool {
+	return status.Code(err) == codes.Unauthenticated && strings.HasPrefix(status.Convert(err).Message(), "request is not signed with API key")
+}
+
+// NonceCache remembers the nonces of version 2 signatures, so that a signed request cannot be replayed.
+type NonceCache interface {
+	// Add returns false if the nonce has already been seen.
+	Add(ctx context.Context, nonce string, now time.Time) (bool, error)
+}
+
+// DatabaseNonceCache keeps nonces in the database, so that a request cannot be replayed against another hookd replica.
+type DatabaseNonceCache struct {
+	Store database.NonceStore
+	TTL   time.Duration
+}
+
+var _ NonceCache = &DatabaseNonceCache{}
+
+func (c *DatabaseNonceCache) Add(ctx context.Context, nonce string, now time.Time) (bool, error) {
+	return c.Store.AddNonce(ctx, nonce, now.Add(c.TTL))
+}
+
+// MemoryNonceCache keeps nonces in memory until they expire.
+// Signatures expire as well, so the cache only needs to cover their lifetime.
+// Nonces are not shared between processes, so it only protects a single hookd replica.
+type MemoryNonceCache struct {
+	lock       sync.Mutex
+	ttl        time.Duration
+	nonces     map[string]time.Time
+	lastPurged time.Time
+}
+
+var _ NonceCache = &MemoryNonceCache{}
+
+func NewMemoryNonceCache(ttl time.Duration) *MemoryNonceCache {
+	return &MemoryNonceCache{
+		ttl:    ttl,
+		nonces: make(map[string]time.Time),
+	}
+}
+
+func (c *MemoryNonceCache) Add(_ context.Context, nonce string, now time.Time) (bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if now.Sub(c.lastPurged) > c.ttl {
+		for n, expires := range c.nonces {
+			if now.After(expires) {
+				delete(c.nonces, n)
+			}
+		}
+		c.lastPurged = now
+	}
+
+	if expires, ok := c.nonces[nonce]; ok && !now.After(expires) {
+		return false, nil
+	}
+	c.nonces[nonce] = now.Add(c.ttl)
+	return true, nil
+}
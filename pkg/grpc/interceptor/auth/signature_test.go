@@ -0,0 +1,182 @@
+package auth_interceptor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nais/deploy/pkg/hookd/database"
+	"github.com/nais/deploy/pkg/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const deployMethod = "/pb.Deploy/Deploy"
+
+// signedIncomingContext signs a request like the deploy client, and returns the metadata as received by the server.
+func signedIncomingContext(t *testing.T, key, method string, req interface{}) context.Context {
+	client := &APIKeyInterceptor{APIKey: []byte(key), Team: "team"}
+
+	var md metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+
+	err := client.UnaryClientInterceptor(context.Background(), method, req, nil, nil, invoker)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacy, _ := client.GetRequestMetadata(context.Background())
+	return metadata.NewIncomingContext(context.Background(), metadata.Join(md, metadata.New(legacy)))
+}
+
+func deploymentRequest(t *testing.T, cluster, image string) *pb.DeploymentRequest {
+	resource, err := structpb.NewStruct(map[string]interface{}{
+		"kind": "Application",
+		"spec": map[string]interface{}{"image": image},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &pb.DeploymentRequest{
+		Team:       "team",
+		Cluster:    cluster,
+		Kubernetes: &pb.Kubernetes{Resources: []*structpb.Struct{resource}},
+	}
+}
+
+func TestServerInterceptorSignatureV2(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: deployMethod}
+
+	newInterceptor := func() *ServerInterceptor {
+		return &ServerInterceptor{
+			APIKeyStore:            &mockAPIKeyStore{},
+			Nonces:                 NewMemoryNonceCache(time.Minute),
+			RejectLegacySignatures: true,
+		}
+	}
+
+	t.Run("happy path", func(t *testing.T) {
+		req := deploymentRequest(t, "dev-gcp", "myapp:1")
+		ctx := signedIncomingContext(t, "apikey", deployMethod, req)
+
+		_, err := newInterceptor().UnaryServerInterceptor(ctx, req, info, handler)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("invalid apikey", func(t *testing.T) {
+		req := deploymentRequest(t, "dev-gcp", "myapp:1")
+		ctx := signedIncomingContext(t, "invalid_apikey", deployMethod, req)
+
+		_, err := newInterceptor().UnaryServerInterceptor(ctx, req, info, handler)
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("got %v, want PermissionDenied", err)
+		}
+	})
+
+	for name, tamper := range map[string]func(req *pb.DeploymentRequest){
+		"different cluster":   func(req *pb.DeploymentRequest) { req.Cluster = "prod-gcp" },
+		"different resources": func(req *pb.DeploymentRequest) { *req = *deploymentRequest(t, "dev-gcp", "evil:1") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := deploymentRequest(t, "dev-gcp", "myapp:1")
+			ctx := signedIncomingContext(t, "apikey", deployMethod, req)
+			tamper(req)
+
+			_, err := newInterceptor().UnaryServerInterceptor(ctx, req, info, handler)
+			if status.Code(err) != codes.PermissionDenied {
+				t.Fatalf("got %v, want PermissionDenied", err)
+			}
+		})
+	}
+
+	t.Run("different method", func(t *testing.T) {
+		req := deploymentRequest(t, "dev-gcp", "myapp:1")
+		ctx := signedIncomingContext(t, "apikey", "/pb.Deploy/Rollback", req)
+
+		_, err := newInterceptor().UnaryServerInterceptor(ctx, req, info, handler)
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("got %v, want PermissionDenied", err)
+		}
+	})
+
+	t.Run("replayed request", func(t *testing.T) {
+		i := newInterceptor()
+		req := deploymentRequest(t, "dev-gcp", "myapp:1")
+		ctx := signedIncomingContext(t, "apikey", deployMethod, req)
+
+		_, err := i.UnaryServerInterceptor(ctx, req, info, handler)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = i.UnaryServerInterceptor(ctx, req, info, handler)
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("got %v, want PermissionDenied", err)
+		}
+	})
+
+	t.Run("legacy signature rejected", func(t *testing.T) {
+		timestamp := time.Now().Format(time.RFC3339Nano)
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.MD{
+			"authorization": []string{sign([]byte(timestamp), []byte("apikey"))},
+			"timestamp":     []string{timestamp},
+			"team":          []string{"team"},
+		})
+
+		_, err := newInterceptor().UnaryServerInterceptor(ctx, deploymentRequest(t, "dev-gcp", "myapp:1"), info, handler)
+		if status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("got %v, want Unauthenticated", err)
+		}
+	})
+}
+
+func TestMemoryNonceCache(t *testing.T) {
+	cache := NewMemoryNonceCache(time.Minute)
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, test := range []struct {
+		name  string
+		nonce string
+		now   time.Time
+		added bool
+	}{
+		{name: "first use of nonce", nonce: "foo", now: now, added: true},
+		{name: "replayed nonce", nonce: "foo", now: now.Add(30 * time.Second), added: false},
+		{name: "different nonce", nonce: "bar", now: now, added: true},
+		{name: "expired nonce", nonce: "foo", now: now.Add(2 * time.Minute), added: true},
+	} {
+		added, err := cache.Add(ctx, test.nonce, test.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if added != test.added {
+			t.Fatalf("%s: got %v, want %v", test.name, added, test.added)
+		}
+	}
+}
+
+func TestDatabaseNonceCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	store := database.NewMockNonceStore(t)
+	store.On("AddNonce", ctx, "foo", now.Add(NonceTTL)).Return(false, nil).Once()
+
+	cache := &DatabaseNonceCache{Store: store, TTL: NonceTTL}
+	added, err := cache.Add(ctx, "foo", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added {
+		t.Fatal("nonce stored by another replica accepted")
+	}
+}
@@ -105,6 +105,9 @@ func New(cfg Config) chi.Router {
 				r.Use(cfg.PSKValidator)
 				r.Get("/apikey/{team}", apiKeyHandler.GetTeamApiKey)
 				r.Post("/apikey/{team}", apiKeyHandler.RotateTeamApiKey)
+				r.Get("/apikeys/{team}", apiKeyHandler.ListTeamApiKeys)
+				r.Post("/apikeys/{team}", apiKeyHandler.CreateTeamApiKey)
+				r.Delete("/apikeys/{team}/{id}", apiKeyHandler.RevokeTeamApiKey)
 				r.Get("/deployments", deploymentHandler.Deployments)
 			})
 		}
@@ -3,8 +3,11 @@ package api_v1_apikey
 import (
 	"encoding/json"
 	"net/http"
+	"path"
+	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
 	api_v1 "github.com/nais/deploy/pkg/hookd/api/v1"
 	"github.com/nais/deploy/pkg/hookd/database"
 	"github.com/nais/deploy/pkg/hookd/middleware"
@@ -14,6 +17,30 @@ import (
 type ApiKeyHandler interface {
 	GetTeamApiKey(w http.ResponseWriter, r *http.Request)
 	RotateTeamApiKey(w http.ResponseWriter, r *http.Request)
+	ListTeamApiKeys(w http.ResponseWriter, r *http.Request)
+	CreateTeamApiKey(w http.ResponseWriter, r *http.Request)
+	RevokeTeamApiKey(w http.ResponseWriter, r *http.Request)
+}
+
+// ApiKeyInfo describes a named API key, without the key itself.
+type ApiKeyInfo struct {
+	ID       string     `json:"id"`
+	Team     string     `json:"team"`
+	Name     string     `json:"name"`
+	Clusters []string   `json:"clusters"`
+	Created  time.Time  `json:"created"`
+	Expires  time.Time  `json:"expires"`
+	LastUsed *time.Time `json:"lastUsed"`
+	Revoked  *time.Time `json:"revoked"`
+	Valid    bool       `json:"valid"`
+}
+
+type CreateApiKeyRequest struct {
+	Name string `json:"name"`
+	// Clusters the key may deploy to, as patterns such as `dev-*`. Empty means all clusters.
+	Clusters []string `json:"clusters"`
+	// Expires defaults to five years from now.
+	Expires *time.Time `json:"expires"`
 }
 
 type DefaultApiKeyHandler struct {
@@ -40,6 +67,10 @@ func (d *DefaultApiKeyHandler) GetTeamApiKey(w http.ResponseWriter, r *http.Requ
 	}
 
 	keys = keys.Valid()
+	// Teams with several named keys are given the one that is rotated through this API.
+	if len(keys) > 1 {
+		keys = keys.Named(database.DefaultApiKeyName)
+	}
 	if len(keys) != 1 {
 		w.WriteHeader(http.StatusBadGateway)
 		logger.Errorf("expected exactly one valid key, got %d", len(keys))
@@ -78,3 +109,138 @@ func (d *DefaultApiKeyHandler) RotateTeamApiKey(w http.ResponseWriter, r *http.R
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// ListTeamApiKeys returns all API keys of a team, including expired and revoked ones, but without the keys themselves.
+func (d *DefaultApiKeyHandler) ListTeamApiKeys(w http.ResponseWriter, r *http.Request) {
+	fields := middleware.RequestLogFields(r)
+	logger := log.WithFields(fields)
+
+	team := chi.URLParam(r, "team")
+
+	keys, err := d.APIKeyStorage.ApiKeys(r.Context(), team)
+	if err != nil && !database.IsErrNotFound(err) {
+		w.WriteHeader(http.StatusBadGateway)
+		logger.Errorf("%s: %s", "unable to communicate with team API key backend", err)
+		return
+	}
+
+	infos := make([]ApiKeyInfo, len(keys))
+	for i, key := range keys {
+		infos[i] = ApiKeyInfo{
+			ID:       key.ID,
+			Team:     key.Team,
+			Name:     key.Name,
+			Clusters: key.Clusters,
+			Created:  key.Created,
+			Expires:  key.Expires,
+			LastUsed: key.LastUsed,
+			Revoked:  key.Revoked,
+			Valid:    key.Valid(),
+		}
+		if infos[i].Clusters == nil {
+			infos[i].Clusters = []string{}
+		}
+	}
+
+	render.JSON(w, r, infos)
+}
+
+// CreateTeamApiKey creates a new named API key for a team, and returns it.
+// This is the only time the key itself is returned.
+func (d *DefaultApiKeyHandler) CreateTeamApiKey(w http.ResponseWriter, r *http.Request) {
+	fields := middleware.RequestLogFields(r)
+	logger := log.WithFields(fields)
+
+	team := chi.URLParam(r, "team")
+
+	request := &CreateApiKeyRequest{}
+	err := json.NewDecoder(r.Body).Decode(request)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		logger.Errorf("unable to decode request: %s", err)
+		return
+	}
+
+	if len(request.Name) == 0 {
+		http.Error(w, "API key name must be set", http.StatusBadRequest)
+		return
+	}
+	for _, pattern := range request.Clusters {
+		if _, err := path.Match(pattern, ""); err != nil {
+			http.Error(w, "invalid cluster pattern: "+pattern, http.StatusBadRequest)
+			return
+		}
+	}
+	if request.Expires != nil && !request.Expires.After(time.Now()) {
+		http.Error(w, "API key expiry must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	keys, err := d.APIKeyStorage.ApiKeys(r.Context(), team)
+	if err != nil && !database.IsErrNotFound(err) {
+		w.WriteHeader(http.StatusBadGateway)
+		logger.Errorf("%s: %s", "unable to communicate with team API key backend", err)
+		return
+	}
+	if len(keys.Valid().Named(request.Name)) > 0 {
+		http.Error(w, "team already has a valid API key with this name", http.StatusConflict)
+		return
+	}
+
+	key, err := api_v1.Keygen(api_v1.KeySize)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		logger.Errorf("unable to generate API key: %s", err)
+		return
+	}
+
+	apiKey := database.ApiKey{
+		Team:     team,
+		Key:      key,
+		Name:     request.Name,
+		Clusters: request.Clusters,
+	}
+	if request.Expires != nil {
+		apiKey.Expires = *request.Expires
+	}
+
+	created, err := d.APIKeyStorage.CreateApiKey(r.Context(), apiKey)
+	if err != nil && database.IsErrUniqueViolation(err) {
+		// Another key with the same name was created since the check above.
+		http.Error(w, "team already has a valid API key with this name", http.StatusConflict)
+		return
+	} else if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		logger.Errorf("unable to create API key: %s", err)
+		return
+	}
+
+	logger.Infof("Created API key %q (%s) for team %s", created.Name, created.ID, team)
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, created)
+}
+
+// RevokeTeamApiKey revokes a single API key of a team.
+func (d *DefaultApiKeyHandler) RevokeTeamApiKey(w http.ResponseWriter, r *http.Request) {
+	fields := middleware.RequestLogFields(r)
+	logger := log.WithFields(fields)
+
+	team := chi.URLParam(r, "team")
+	id := chi.URLParam(r, "id")
+
+	err := d.APIKeyStorage.RevokeApiKey(r.Context(), team, id)
+	if err != nil {
+		if database.IsErrNotFound(err) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		logger.Errorf("unable to revoke API key: %s", err)
+		return
+	}
+
+	logger.Infof("Revoked API key %s for team %s", id, team)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,15 +2,18 @@ package api_v1_apikey_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/nais/deploy/pkg/hookd/api"
 	api_v1 "github.com/nais/deploy/pkg/hookd/api/v1"
+	api_v1_apikey "github.com/nais/deploy/pkg/hookd/api/v1/apikey"
 	"github.com/nais/deploy/pkg/hookd/database"
 	"github.com/stretchr/testify/assert"
 )
@@ -37,6 +40,36 @@ func (a *apiKeyStorage) ApiKeys(ctx context.Context, id string) (database.ApiKey
 			Key:     key2,
 			Expires: time.Now().Add(1 * time.Minute),
 		}}, nil
+	case "team3":
+		revoked := time.Now().Add(-1 * time.Minute)
+		return database.ApiKeys{
+			{
+				ID:      "id-default",
+				Team:    "team3",
+				Name:    database.DefaultApiKeyName,
+				Key:     key1,
+				Created: time.Now().Add(-1 * time.Hour),
+				Expires: time.Now().Add(1 * time.Minute),
+			},
+			{
+				ID:       "id-pipeline",
+				Team:     "team3",
+				Name:     "pipeline",
+				Key:      key2,
+				Clusters: []string{"dev-*"},
+				Created:  time.Now().Add(-1 * time.Hour),
+				Expires:  time.Now().Add(1 * time.Minute),
+			},
+			{
+				ID:      "id-revoked",
+				Team:    "team3",
+				Name:    "old",
+				Key:     key2,
+				Created: time.Now().Add(-1 * time.Hour),
+				Expires: time.Now().Add(1 * time.Minute),
+				Revoked: &revoked,
+			},
+		}, nil
 	case "team4":
 		return database.ApiKeys{{
 			Team:    "team4",
@@ -56,6 +89,28 @@ func (a *apiKeyStorage) RotateApiKey(ctx context.Context, team string, key api_v
 	return fmt.Errorf("err")
 }
 
+func (a *apiKeyStorage) CreateApiKey(ctx context.Context, apiKey database.ApiKey) (*database.ApiKey, error) {
+	if apiKey.Team == "team2" {
+		return nil, fmt.Errorf("err")
+	}
+	if apiKey.Name == "concurrent" {
+		return nil, fmt.Errorf(`ERROR: duplicate key value violates unique constraint "apikey_team_name_index" (SQLSTATE 23505)`)
+	}
+	apiKey.ID = "new-id"
+	return &apiKey, nil
+}
+
+func (a *apiKeyStorage) RevokeApiKey(ctx context.Context, team, id string) error {
+	if team == "team3" && id == "id-pipeline" {
+		return nil
+	}
+	return database.ErrNotFound
+}
+
+func (a *apiKeyStorage) MarkApiKeyUsed(ctx context.Context, id string) error {
+	return nil
+}
+
 func TestApiKeyHandler(t *testing.T) {
 	apiKeyStore := apiKeyStorage{}
 	handler := api.New(api.Config{
@@ -78,6 +133,102 @@ func TestApiKeyHandler(t *testing.T) {
 		assert.Regexp(t, regexp.MustCompile(`{"team":"team2","key":"313233343536","expires":"\d{4}-\d{2}-[^"]+","created":"0001-01-01T00:00:00Z"}`), body)
 	})
 
+	t.Run("get default apikey for team with several keys", func(t *testing.T) {
+		request := httptest.NewRequest("GET", "/internal/api/v1/console/apikey/team3", nil)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"id":"id-default"`)
+	})
+
+	t.Run("list apikeys for team", func(t *testing.T) {
+		request := httptest.NewRequest("GET", "/internal/api/v1/console/apikeys/team3", nil)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.NotContains(t, recorder.Body.String(), `"key"`)
+
+		keys := make([]api_v1_apikey.ApiKeyInfo, 0)
+		err := json.Unmarshal(recorder.Body.Bytes(), &keys)
+		assert.NoError(t, err)
+		assert.Len(t, keys, 3)
+		assert.Equal(t, "pipeline", keys[1].Name)
+		assert.Equal(t, []string{"dev-*"}, keys[1].Clusters)
+		assert.True(t, keys[1].Valid)
+		assert.False(t, keys[2].Valid)
+	})
+
+	t.Run("create apikey", func(t *testing.T) {
+		body := `{"name":"staging","clusters":["dev-*"],"expires":"` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`
+		request := httptest.NewRequest("POST", "/internal/api/v1/console/apikeys/team3", strings.NewReader(body))
+		request.Header.Set("content-type", "application/json")
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+
+		created := &database.ApiKey{}
+		err := json.Unmarshal(recorder.Body.Bytes(), created)
+		assert.NoError(t, err)
+		assert.Equal(t, "new-id", created.ID)
+		assert.Equal(t, "staging", created.Name)
+		assert.Equal(t, "team3", created.Team)
+		assert.Equal(t, []string{"dev-*"}, created.Clusters)
+		assert.Len(t, created.Key, api_v1.KeySize)
+	})
+
+	t.Run("create apikey with name in use", func(t *testing.T) {
+		// "concurrent" is taken by a key created after the handler has listed the team's keys.
+		for _, name := range []string{"pipeline", "concurrent"} {
+			request := httptest.NewRequest("POST", "/internal/api/v1/console/apikeys/team3", strings.NewReader(`{"name":"`+name+`"}`))
+			request.Header.Set("content-type", "application/json")
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			assert.Equal(t, http.StatusConflict, recorder.Code, name)
+		}
+	})
+
+	t.Run("create apikey with invalid request", func(t *testing.T) {
+		for _, body := range []string{
+			`{}`,
+			`{"name":"x","clusters":["["]}`,
+			`{"name":"x","expires":"2000-01-01T00:00:00Z"}`,
+		} {
+			request := httptest.NewRequest("POST", "/internal/api/v1/console/apikeys/team3", strings.NewReader(body))
+			request.Header.Set("content-type", "application/json")
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			assert.Equal(t, http.StatusBadRequest, recorder.Code, body)
+		}
+	})
+
+	t.Run("revoke apikey", func(t *testing.T) {
+		request := httptest.NewRequest("DELETE", "/internal/api/v1/console/apikeys/team3/id-pipeline", nil)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+	})
+
+	t.Run("revoke apikey of another team", func(t *testing.T) {
+		request := httptest.NewRequest("DELETE", "/internal/api/v1/console/apikeys/team1/id-pipeline", nil)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
 	// t.Run("get apikey for team", func(t *testing.T) {
 	// 	request := httptest.NewRequest("GET", "/internal/api/v1/apikey/team2", nil)
 	// 	request = request.WithContext(middleware.WithGroups(request.Context(), []string{"team1", "team2", "team6"}))
@@ -22,7 +22,7 @@ func (k *Key) UnmarshalJSON(b []byte) error {
 	if err != nil {
 		return err
 	}
-	_, err = hex.DecodeString(str)
+	*k, err = hex.DecodeString(str)
 	if err != nil {
 		return fmt.Errorf("expecting hex string: %s", err)
 	}
@@ -66,6 +66,18 @@ func (a *apiKeyStorage) RotateApiKey(ctx context.Context, team string, key api_v
 	}
 }
 
+func (a *apiKeyStorage) CreateApiKey(ctx context.Context, apiKey database.ApiKey) (*database.ApiKey, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (a *apiKeyStorage) RevokeApiKey(ctx context.Context, team, id string) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (a *apiKeyStorage) MarkApiKeyUsed(ctx context.Context, id string) error {
+	return nil
+}
+
 func testStatusResponse(t *testing.T, recorder *httptest.ResponseRecorder, response response) {
 	assert.Equal(t, response.StatusCode, recorder.Code)
 	if response.StatusCode == http.StatusNoContent {
@@ -2,29 +2,52 @@ package database
 
 import (
 	"context"
-	"encoding/hex"
 	"fmt"
+	"path"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v4"
 	"github.com/nais/deploy/pkg/crypto"
 	api_v1 "github.com/nais/deploy/pkg/hookd/api/v1"
 )
 
+const (
+	// DefaultApiKeyName is the name of the key that is rotated by RotateApiKey.
+	DefaultApiKeyName = "default"
+	// DefaultApiKeyLifetime is used for keys created without an explicit expiry.
+	DefaultApiKeyLifetime = 5 * 365 * 24 * time.Hour
+)
+
 type ApiKey struct {
 	Team    string     `json:"team"`
 	Key     api_v1.Key `json:"key"`
 	Expires time.Time  `json:"expires"`
 	Created time.Time  `json:"created"`
+	// Non-secret identifier of the key, used to revoke it.
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	// Clusters the key may deploy to, as patterns such as `dev-*`. Keys without clusters may deploy anywhere.
+	Clusters []string   `json:"clusters,omitempty"`
+	LastUsed *time.Time `json:"lastUsed,omitempty"`
+	// Revoked is also set, to the expiry time, when an expired key is replaced by a new key with the same name.
+	Revoked *time.Time `json:"revoked,omitempty"`
 }
 
 type ApiKeyStore interface {
 	ApiKeys(ctx context.Context, id string) (ApiKeys, error)
 	RotateApiKey(ctx context.Context, team string, key api_v1.Key) error
+	CreateApiKey(ctx context.Context, apiKey ApiKey) (*ApiKey, error)
+	RevokeApiKey(ctx context.Context, team, id string) error
+	MarkApiKeyUsed(ctx context.Context, id string) error
 }
 
 var _ ApiKeyStore = &Database{}
 
+// Only one key per team and name can be unrevoked; see apikey_team_name_index.
+// Expired keys are revoked as of their expiry, so that they don't prevent a new key from taking their name.
+const revokeExpiredApiKeysQuery = `UPDATE apikey SET revoked = expires WHERE team = $1 AND name = $2 AND revoked IS NULL AND expires <= NOW()`
+
 type ApiKeys []ApiKey
 
 func (apikeys ApiKeys) Keys() []api_v1.Key {
@@ -38,13 +61,42 @@ func (apikeys ApiKeys) Keys() []api_v1.Key {
 func (apikeys ApiKeys) Valid() ApiKeys {
 	valid := make(ApiKeys, 0, len(apikeys))
 	for _, apikey := range apikeys {
-		if apikey.Expires.After(time.Now()) {
+		if apikey.Valid() {
 			valid = append(valid, apikey)
 		}
 	}
 	return valid
 }
 
+// Named returns the keys with the given name.
+func (apikeys ApiKeys) Named(name string) ApiKeys {
+	named := make(ApiKeys, 0, len(apikeys))
+	for _, apikey := range apikeys {
+		if apikey.Name == name {
+			named = append(named, apikey)
+		}
+	}
+	return named
+}
+
+// Valid returns true if the key has neither expired nor been revoked.
+func (apikey ApiKey) Valid() bool {
+	return apikey.Revoked == nil && apikey.Expires.After(time.Now())
+}
+
+// AllowsCluster returns true if the key may be used for requests to the given cluster.
+func (apikey ApiKey) AllowsCluster(cluster string) bool {
+	if len(apikey.Clusters) == 0 {
+		return true
+	}
+	for _, pattern := range apikey.Clusters {
+		if ok, _ := path.Match(pattern, cluster); ok {
+			return true
+		}
+	}
+	return false
+}
+
 func (apikeys ApiKeys) ValidKeys() []api_v1.Key {
 	keys := make([]api_v1.Key, 0, len(apikeys))
 	for _, apiKey := range apikeys.Valid() {
@@ -55,14 +107,23 @@ func (apikeys ApiKeys) ValidKeys() []api_v1.Key {
 	return keys
 }
 
-const selectApiKeyFields = `key, team, created, expires`
+const selectApiKeyFields = `key, team, created, expires, id, name, clusters, last_used, revoked`
 
-func (db *Database) decrypt(encrypted string) ([]byte, error) {
-	decoded, err := hex.DecodeString(encrypted)
-	if err != nil {
-		return nil, fmt.Errorf("decode hex: %s", err)
-	}
-	return crypto.Decrypt(decoded, db.encryptionKey)
+// Purposes of encrypted columns, which are part of the additional data that ciphertexts are bound to.
+const (
+	purposeApiKey            = "apikey"
+	purposeDeploymentPayload = "deployment_payload"
+	purposeDispatchQueue     = "dispatch_queue"
+	purposePubSub            = "pubsub"
+)
+
+// encrypt a plaintext, bound to its purpose and owner, such as the team of an API key.
+func (db *Database) encrypt(plaintext []byte, purpose, owner string) (string, error) {
+	return db.keyring.Encrypt(plaintext, crypto.AdditionalData(purpose, owner))
+}
+
+func (db *Database) decrypt(encrypted string, purpose, owner string) ([]byte, error) {
+	return db.keyring.Decrypt(encrypted, crypto.AdditionalData(purpose, owner))
 }
 
 func (db *Database) scanApiKeyRows(rows pgx.Rows) (ApiKeys, error) {
@@ -74,12 +135,12 @@ func (db *Database) scanApiKeyRows(rows pgx.Rows) (ApiKeys, error) {
 		var encrypted string
 
 		// see selectApiKeyFields
-		err := rows.Scan(&encrypted, &apiKey.Team, &apiKey.Created, &apiKey.Expires)
+		err := rows.Scan(&encrypted, &apiKey.Team, &apiKey.Created, &apiKey.Expires, &apiKey.ID, &apiKey.Name, &apiKey.Clusters, &apiKey.LastUsed, &apiKey.Revoked)
 		if err != nil {
 			return nil, err
 		}
 
-		apiKey.Key, err = db.decrypt(encrypted)
+		apiKey.Key, err = db.decrypt(encrypted, purposeApiKey, apiKey.Team)
 		if err != nil {
 			return nil, err
 		}
@@ -107,10 +168,11 @@ func (db *Database) ApiKeys(ctx context.Context, team string) (ApiKeys, error) {
 	return db.scanApiKeyRows(rows)
 }
 
+// RotateApiKey replaces the team's default key with a new one. Other named keys are left as they are.
 func (db *Database) RotateApiKey(ctx context.Context, team string, key api_v1.Key) error {
 	var query string
 
-	encrypted, err := crypto.Encrypt(key, db.encryptionKey)
+	encrypted, err := db.encrypt(key, purposeApiKey, team)
 	if err != nil {
 		return fmt.Errorf("encrypt api key: %s", err)
 	}
@@ -119,21 +181,94 @@ func (db *Database) RotateApiKey(ctx context.Context, team string, key api_v1.Ke
 	if err != nil {
 		return fmt.Errorf("unable to start transaction: %s", err)
 	}
+	defer tx.Rollback(ctx)
+
+	query = `UPDATE apikey SET expires = NOW() WHERE expires > NOW() AND team = $1 AND name = $2`
+	_, err = tx.Exec(ctx, query, team, DefaultApiKeyName)
+	if err != nil {
+		return err
+	}
 
-	query = `UPDATE apikey SET expires = NOW() WHERE expires > NOW() AND team = $1`
-	_, err = tx.Exec(ctx, query, team)
+	_, err = tx.Exec(ctx, revokeExpiredApiKeysQuery, team, DefaultApiKeyName)
 	if err != nil {
 		return err
 	}
 
 	query = `
-INSERT INTO apikey (key, team, created, expires)
-VALUES ($1, $2, NOW(), NOW()+MAKE_INTERVAL(years := 5));
+INSERT INTO apikey (key, team, created, expires, id, name)
+VALUES ($1, $2, NOW(), NOW()+MAKE_INTERVAL(years := 5), $3, $4);
 `
-	_, err = tx.Exec(ctx, query, hex.EncodeToString(encrypted), team)
+	_, err = tx.Exec(ctx, query, encrypted, team, uuid.NewString(), DefaultApiKeyName)
 	if err != nil {
 		return err
 	}
 
 	return tx.Commit(ctx)
 }
+
+// CreateApiKey stores a new named key for a team, without affecting the team's other valid keys.
+// The ID and creation time are set by the database layer, and the stored key is returned.
+// If the team already has a valid key with the same name, a unique violation is returned; see IsErrUniqueViolation.
+func (db *Database) CreateApiKey(ctx context.Context, apiKey ApiKey) (*ApiKey, error) {
+	encrypted, err := db.encrypt(apiKey.Key, purposeApiKey, apiKey.Team)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt api key: %s", err)
+	}
+
+	apiKey.ID = uuid.NewString()
+	apiKey.Created = time.Now()
+	if apiKey.Expires.IsZero() {
+		apiKey.Expires = apiKey.Created.Add(DefaultApiKeyLifetime)
+	}
+	if apiKey.Clusters == nil {
+		apiKey.Clusters = []string{}
+	}
+
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start transaction: %s", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, revokeExpiredApiKeysQuery, apiKey.Team, apiKey.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+INSERT INTO apikey (key, team, created, expires, id, name, clusters)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+`
+	_, err = tx.Exec(ctx, query, encrypted, apiKey.Team, apiKey.Created, apiKey.Expires, apiKey.ID, apiKey.Name, apiKey.Clusters)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiKey, nil
+}
+
+// RevokeApiKey makes a key unusable immediately. Revoking a key that is already revoked has no effect.
+func (db *Database) RevokeApiKey(ctx context.Context, team, id string) error {
+	query := `UPDATE apikey SET revoked = COALESCE(revoked, NOW()) WHERE team = $1 AND id = $2`
+	tag, err := db.conn.Exec(ctx, query, team, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MarkApiKeyUsed records that a key was used. The timestamp is only updated once per minute,
+// so that busy keys don't cause a write for every request.
+func (db *Database) MarkApiKeyUsed(ctx context.Context, id string) error {
+	query := `UPDATE apikey SET last_used = NOW() WHERE id = $1 AND (last_used IS NULL OR last_used < NOW() - INTERVAL '1 minute')`
+	_, err := db.conn.Exec(ctx, query, id)
+	return err
+}
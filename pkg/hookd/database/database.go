@@ -8,6 +8,7 @@ import (
 
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/nais/deploy/pkg/crypto"
 	"github.com/nais/deploy/pkg/hookd/metrics"
 	log "github.com/sirupsen/logrus"
 )
@@ -15,28 +16,33 @@ import (
 var ErrNotFound = fmt.Errorf("database row not found")
 
 type Database struct {
-	conn          *pgxpool.Pool
-	encryptionKey []byte
+	conn    *pgxpool.Pool
+	keyring *crypto.Keyring
 }
 
 func IsErrNotFound(err error) bool {
 	return err == ErrNotFound
 }
 
+// Returns true if the error message is a unique constraint violation
+func IsErrUniqueViolation(err error) bool {
+	return strings.Contains(err.Error(), "SQLSTATE 23505")
+}
+
 // Returns true if the error message is a foreign key constraint violation
 func IsErrForeignKeyViolation(err error) bool {
 	return strings.Contains(err.Error(), "SQLSTATE 23503")
 }
 
-func New(ctx context.Context, dsn string, encryptionKey []byte) (*Database, error) {
+func New(ctx context.Context, dsn string, keyring *crypto.Keyring) (*Database, error) {
 	conn, err := pgxpool.Connect(ctx, dsn)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Database{
-		conn:          conn,
-		encryptionKey: encryptionKey,
+		conn:    conn,
+		keyring: keyring,
 	}, nil
 }
 
@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/jackc/pgx/v4"
@@ -16,6 +17,7 @@ type Deployment struct {
 	GitHubRepository *string   `json:"githubRepository"`
 	Cluster          *string   `json:"cluster"`
 	State            *string   `json:"state"`
+	RollbackOf       *string   `json:"rollbackOf"`
 }
 
 type DeploymentStatus struct {
@@ -37,15 +39,32 @@ type DeploymentResource struct {
 	Namespace    string `json:"namespace"`
 }
 
+// DeploymentFilter selects deployments belonging to a single team.
+// Empty fields and zero times are not used for filtering.
+type DeploymentFilter struct {
+	Team       string
+	Clusters   []string
+	Repository string
+	States     []string
+	From       time.Time
+	To         time.Time
+	Limit      int
+}
+
 type DeploymentStore interface {
 	Deployments(ctx context.Context, teams, clusters, ignoreTeams []string, limit int) ([]*Deployment, error)
+	FilteredDeployments(ctx context.Context, filter DeploymentFilter) ([]*Deployment, error)
 	Deployment(ctx context.Context, id string) (*Deployment, error)
 	HistoricDeployments(ctx context.Context, cluster string, timestamp time.Time) ([]*Deployment, error)
 	WriteDeployment(ctx context.Context, deployment Deployment) error
 	DeploymentStatus(ctx context.Context, deploymentID string) ([]DeploymentStatus, error)
+	DeploymentStatusByIDs(ctx context.Context, deploymentIDs []string) (map[string][]DeploymentStatus, error)
 	WriteDeploymentStatus(ctx context.Context, status DeploymentStatus) error
 	DeploymentResources(ctx context.Context, deploymentID string) ([]DeploymentResource, error)
+	DeploymentResourcesByIDs(ctx context.Context, deploymentIDs []string) (map[string][]DeploymentResource, error)
 	WriteDeploymentResource(ctx context.Context, resource DeploymentResource) error
+	DeploymentPayload(ctx context.Context, deploymentID string) ([]byte, error)
+	WriteDeploymentPayload(ctx context.Context, deploymentID string, payload []byte) error
 }
 
 var _ DeploymentStore = &Database{}
@@ -60,16 +79,23 @@ func scanDeployment(rows pgx.Rows) (*Deployment, error) {
 		&deployment.GitHubID,
 		&deployment.GitHubRepository,
 		&deployment.Cluster,
+		&deployment.State,
+		&deployment.RollbackOf,
 	)
 
 	return deployment, err
 }
 
+// HistoricDeployments returns unfinished deployments to a cluster created before the given time,
+// i.e. deployments that were lost by a deployd instance that has since restarted.
+// Deployments in the dispatch queue are not included. They are either waiting to be delivered,
+// or leased to a deployd instance that is still working on them, and are leased again if that instance disappears.
 func (db *Database) HistoricDeployments(ctx context.Context, cluster string, timestamp time.Time) ([]*Deployment, error) {
 	query := `
-SELECT id, team, created, github_id, github_repository, cluster
+SELECT id, team, created, github_id, github_repository, cluster, state, rollback_of
 FROM deployment
-WHERE (cluster = $1 AND created < $2 AND (state = 'in_progress' OR state = 'queued'));
+WHERE (cluster = $1 AND created < $2 AND (state = 'in_progress' OR state = 'queued'))
+AND id NOT IN (SELECT deployment_id FROM dispatch_queue);
 `
 	rows, err := db.timedQuery(ctx, query, cluster, timestamp)
 	if err != nil {
@@ -92,7 +118,7 @@ WHERE (cluster = $1 AND created < $2 AND (state = 'in_progress' OR state = 'queu
 
 func (db *Database) Deployments(ctx context.Context, teams, clusters, ignoreTeams []string, limit int) ([]*Deployment, error) {
 	query := `
-SELECT id, team, created, github_id, github_repository, cluster
+SELECT id, team, created, github_id, github_repository, cluster, state, rollback_of
 FROM deployment
 WHERE (ARRAY_LENGTH($1::VARCHAR[], 1) IS NULL OR team = ANY($1))
 AND (ARRAY_LENGTH($2::VARCHAR[], 1) IS NULL OR cluster = ANY($2))
@@ -119,8 +145,55 @@ LIMIT $4;
 	return deployments, nil
 }
 
+func (db *Database) FilteredDeployments(ctx context.Context, filter DeploymentFilter) ([]*Deployment, error) {
+	query := `
+SELECT id, team, created, github_id, github_repository, cluster, state, rollback_of
+FROM deployment
+WHERE team = $1
+AND (ARRAY_LENGTH($2::VARCHAR[], 1) IS NULL OR cluster = ANY($2))
+AND ($3::VARCHAR = '' OR github_repository = $3)
+AND (ARRAY_LENGTH($4::VARCHAR[], 1) IS NULL OR state = ANY($4))
+AND ($5::TIMESTAMP WITH TIME ZONE IS NULL OR created >= $5)
+AND ($6::TIMESTAMP WITH TIME ZONE IS NULL OR created < $6)
+ORDER BY created DESC
+LIMIT $7;
+`
+	nullableTime := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+		return &t
+	}
+
+	rows, err := db.timedQuery(ctx, query,
+		filter.Team,
+		pq.Array(filter.Clusters),
+		filter.Repository,
+		pq.Array(filter.States),
+		nullableTime(filter.From),
+		nullableTime(filter.To),
+		filter.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	deployments := make([]*Deployment, 0)
+	defer rows.Close()
+	for rows.Next() {
+		deployment, err := scanDeployment(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		deployments = append(deployments, deployment)
+	}
+
+	return deployments, nil
+}
+
 func (db *Database) Deployment(ctx context.Context, id string) (*Deployment, error) {
-	query := `SELECT id, team, created, github_id, github_repository, cluster FROM deployment WHERE id = $1;`
+	query := `SELECT id, team, created, github_id, github_repository, cluster, state, rollback_of FROM deployment WHERE id = $1;`
 	rows, err := db.timedQuery(ctx, query, id)
 	if err != nil {
 		return nil, err
@@ -141,8 +214,8 @@ func (db *Database) Deployment(ctx context.Context, id string) (*Deployment, err
 
 func (db *Database) WriteDeployment(ctx context.Context, deployment Deployment) error {
 	query := `
-INSERT INTO deployment (id, team, created, github_id, github_repository, cluster)
-VALUES ($1, $2, $3, $4, $5, $6)
+INSERT INTO deployment (id, team, created, github_id, github_repository, cluster, rollback_of)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
 ON CONFLICT (id) DO UPDATE
 SET github_id = EXCLUDED.github_id, github_repository = EXCLUDED.github_repository;
 `
@@ -153,6 +226,7 @@ SET github_id = EXCLUDED.github_id, github_repository = EXCLUDED.github_reposito
 		deployment.GitHubID,
 		deployment.GitHubRepository,
 		deployment.Cluster,
+		deployment.RollbackOf,
 	)
 
 	return err
@@ -165,6 +239,41 @@ func (db *Database) DeploymentStatus(ctx context.Context, deploymentID string) (
 		return nil, err
 	}
 
+	statuses, err := scanDeploymentStatuses(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(statuses) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return statuses, nil
+}
+
+// DeploymentStatusByIDs returns the statuses of several deployments with a single query, newest first.
+// Deployments without any statuses are left out of the map.
+func (db *Database) DeploymentStatusByIDs(ctx context.Context, deploymentIDs []string) (map[string][]DeploymentStatus, error) {
+	query := `SELECT id, deployment_id, status, message, created FROM deployment_status WHERE deployment_id = ANY($1) ORDER BY created DESC;`
+	rows, err := db.timedQuery(ctx, query, deploymentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses, err := scanDeploymentStatuses(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string][]DeploymentStatus)
+	for _, status := range statuses {
+		byID[status.DeploymentID] = append(byID[status.DeploymentID], status)
+	}
+
+	return byID, nil
+}
+
+func scanDeploymentStatuses(rows pgx.Rows) ([]DeploymentStatus, error) {
 	statuses := make([]DeploymentStatus, 0)
 
 	defer rows.Close()
@@ -186,11 +295,7 @@ func (db *Database) DeploymentStatus(ctx context.Context, deploymentID string) (
 		statuses = append(statuses, status)
 	}
 
-	if len(statuses) == 0 {
-		return nil, ErrNotFound
-	}
-
-	return statuses, nil
+	return statuses, rows.Err()
 }
 
 func (db *Database) WriteDeploymentStatus(ctx context.Context, status DeploymentStatus) error {
@@ -227,6 +332,32 @@ func (db *Database) DeploymentResources(ctx context.Context, deploymentID string
 		return nil, err
 	}
 
+	return scanDeploymentResources(rows)
+}
+
+// DeploymentResourcesByIDs returns the resources of several deployments with a single query, in the order they were deployed.
+// Deployments without any resources are left out of the map.
+func (db *Database) DeploymentResourcesByIDs(ctx context.Context, deploymentIDs []string) (map[string][]DeploymentResource, error) {
+	query := `SELECT id, deployment_id, index, "group", version, kind, name, namespace FROM deployment_resource WHERE deployment_id = ANY($1) ORDER BY index ASC;`
+	rows, err := db.timedQuery(ctx, query, deploymentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	resources, err := scanDeploymentResources(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string][]DeploymentResource)
+	for _, resource := range resources {
+		byID[resource.DeploymentID] = append(byID[resource.DeploymentID], resource)
+	}
+
+	return byID, nil
+}
+
+func scanDeploymentResources(rows pgx.Rows) ([]DeploymentResource, error) {
 	resources := make([]DeploymentResource, 0)
 
 	defer rows.Close()
@@ -251,7 +382,7 @@ func (db *Database) DeploymentResources(ctx context.Context, deploymentID string
 		resources = append(resources, resource)
 	}
 
-	return resources, nil
+	return resources, rows.Err()
 }
 
 func (db *Database) WriteDeploymentResource(ctx context.Context, resource DeploymentResource) error {
@@ -272,3 +403,48 @@ VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
 
 	return err
 }
+
+// DeploymentPayload returns the decrypted payload of a deployment.
+func (db *Database) DeploymentPayload(ctx context.Context, deploymentID string) ([]byte, error) {
+	query := `SELECT payload FROM deployment_payload WHERE deployment_id = $1;`
+	rows, err := db.timedQuery(ctx, query, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, ErrNotFound
+	}
+
+	var encrypted string
+	err = rows.Scan(&encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := db.decrypt(encrypted, purposeDeploymentPayload, deploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt payload: %s", err)
+	}
+
+	return payload, nil
+}
+
+// WriteDeploymentPayload encrypts the payload of a deployment with the database encryption key and stores it.
+func (db *Database) WriteDeploymentPayload(ctx context.Context, deploymentID string, payload []byte) error {
+	encrypted, err := db.encrypt(payload, purposeDeploymentPayload, deploymentID)
+	if err != nil {
+		return fmt.Errorf("encrypt payload: %s", err)
+	}
+
+	query := `
+INSERT INTO deployment_payload (deployment_id, payload)
+VALUES ($1, $2)
+ON CONFLICT (deployment_id) DO UPDATE
+SET payload = EXCLUDED.payload;
+`
+	_, err = db.conn.Exec(ctx, query, deploymentID, encrypted)
+
+	return err
+}
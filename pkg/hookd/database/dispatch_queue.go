@@ -0,0 +1,185 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// QueuedDeploymentRequest is a deployment request waiting to be delivered to a cluster.
+type QueuedDeploymentRequest struct {
+	DeploymentID string
+	Cluster      string
+	// Request is the serialized deployment request, stored encrypted.
+	Request  []byte
+	Created  time.Time
+	Deadline time.Time
+}
+
+// DispatchQueueStore persists deployment requests until they have finished.
+// Each request is leased to a single deployd instance at a time. The instance renews its leases while it is
+// connected, also after reconnecting, and the requests are leased to another instance if it disappears.
+type DispatchQueueStore interface {
+	EnqueueDeploymentRequest(ctx context.Context, request QueuedDeploymentRequest) error
+	LeaseDeploymentRequest(ctx context.Context, cluster, holder string, ttl time.Duration) (*QueuedDeploymentRequest, error)
+	RenewDeploymentLeases(ctx context.Context, holder string, ttl time.Duration) error
+	WithdrawDeploymentRequest(ctx context.Context, deploymentID string) (*QueuedDeploymentRequest, error)
+	DequeueDeploymentRequest(ctx context.Context, deploymentID string) error
+	ExpireDeploymentRequests(ctx context.Context, now time.Time) ([]*QueuedDeploymentRequest, error)
+	QueuedDeploymentIDs(ctx context.Context) ([]string, error)
+}
+
+var _ DispatchQueueStore = &Database{}
+
+const selectQueuedDeploymentRequestFields = `deployment_id, cluster, request, created, deadline`
+
+func (db *Database) scanQueuedDeploymentRequests(rows pgx.Rows) ([]*QueuedDeploymentRequest, error) {
+	requests := make([]*QueuedDeploymentRequest, 0)
+
+	defer rows.Close()
+	for rows.Next() {
+		var encrypted string
+		request := &QueuedDeploymentRequest{}
+		err := rows.Scan(&request.DeploymentID, &request.Cluster, &encrypted, &request.Created, &request.Deadline)
+		if err != nil {
+			return nil, err
+		}
+
+		request.Request, err = db.decrypt(encrypted, purposeDispatchQueue, request.DeploymentID)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt queued request: %s", err)
+		}
+
+		requests = append(requests, request)
+	}
+
+	return requests, rows.Err()
+}
+
+// EnqueueDeploymentRequest encrypts a deployment request and appends it to the queue of its cluster.
+func (db *Database) EnqueueDeploymentRequest(ctx context.Context, request QueuedDeploymentRequest) error {
+	encrypted, err := db.encrypt(request.Request, purposeDispatchQueue, request.DeploymentID)
+	if err != nil {
+		return fmt.Errorf("encrypt queued request: %s", err)
+	}
+
+	query := `
+INSERT INTO dispatch_queue (deployment_id, cluster, request, created, deadline)
+VALUES ($1, $2, $3, $4, $5);
+`
+	_, err = db.conn.Exec(ctx, query, request.DeploymentID, request.Cluster, encrypted, request.Created, request.Deadline)
+
+	return err
+}
+
+// LeaseDeploymentRequest leases the oldest queued request of a cluster that isn't leased to another deployd instance,
+// and returns it. Returns ErrNotFound if there are no requests to lease.
+func (db *Database) LeaseDeploymentRequest(ctx context.Context, cluster, holder string, ttl time.Duration) (*QueuedDeploymentRequest, error) {
+	query := `
+UPDATE dispatch_queue
+SET leased_to = $2, lease_expires = NOW() + MAKE_INTERVAL(secs => $3)
+WHERE sequence = (
+	SELECT sequence FROM dispatch_queue
+	WHERE cluster = $1 AND deadline > NOW() AND (leased_to IS NULL OR lease_expires < NOW())
+	ORDER BY sequence ASC
+	LIMIT 1
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING ` + selectQueuedDeploymentRequestFields + `;
+`
+	rows, err := db.timedQuery(ctx, query, cluster, holder, ttl.Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	requests, err := db.scanQueuedDeploymentRequests(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return requests[0], nil
+}
+
+// RenewDeploymentLeases extends all leases held by a deployd instance.
+func (db *Database) RenewDeploymentLeases(ctx context.Context, holder string, ttl time.Duration) error {
+	query := `UPDATE dispatch_queue SET lease_expires = NOW() + MAKE_INTERVAL(secs => $2) WHERE leased_to = $1;`
+	_, err := db.conn.Exec(ctx, query, holder, ttl.Seconds())
+
+	return err
+}
+
+// WithdrawDeploymentRequest removes and returns a request that hasn't been leased to deployd yet,
+// e.g. because it has been cancelled. Returns ErrNotFound if the request isn't queued, or is leased.
+func (db *Database) WithdrawDeploymentRequest(ctx context.Context, deploymentID string) (*QueuedDeploymentRequest, error) {
+	query := `
+DELETE FROM dispatch_queue
+WHERE deployment_id = $1 AND (leased_to IS NULL OR lease_expires < NOW())
+RETURNING ` + selectQueuedDeploymentRequestFields + `;
+`
+	rows, err := db.timedQuery(ctx, query, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	requests, err := db.scanQueuedDeploymentRequests(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return requests[0], nil
+}
+
+// DequeueDeploymentRequest removes a request from the queue after it has finished.
+func (db *Database) DequeueDeploymentRequest(ctx context.Context, deploymentID string) error {
+	query := `DELETE FROM dispatch_queue WHERE deployment_id = $1;`
+	_, err := db.conn.Exec(ctx, query, deploymentID)
+
+	return err
+}
+
+// ExpireDeploymentRequests removes and returns every queued request whose deadline is before now,
+// and that isn't leased to a deployd instance. Leased requests are finished by deployd, which enforces the deadline itself.
+func (db *Database) ExpireDeploymentRequests(ctx context.Context, now time.Time) ([]*QueuedDeploymentRequest, error) {
+	query := `
+DELETE FROM dispatch_queue
+WHERE deadline < $1 AND (leased_to IS NULL OR lease_expires < $1)
+RETURNING ` + selectQueuedDeploymentRequestFields + `;
+`
+	rows, err := db.timedQuery(ctx, query, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.scanQueuedDeploymentRequests(rows)
+}
+
+// QueuedDeploymentIDs returns the deployment IDs of all queued requests, for all clusters.
+func (db *Database) QueuedDeploymentIDs(ctx context.Context) ([]string, error) {
+	query := `SELECT deployment_id FROM dispatch_queue ORDER BY sequence ASC;`
+	rows, err := db.timedQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0)
+
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
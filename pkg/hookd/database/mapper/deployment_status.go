@@ -32,10 +32,30 @@ func PbRequest(deploy database.Deployment) *pb.DeploymentRequest {
 	if deploy.Cluster != nil {
 		cluster = *deploy.Cluster
 	}
+	var repository *pb.GithubRepository
+	if deploy.GitHubRepository != nil {
+		repository = pb.GithubRepositoryFromFullName(*deploy.GitHubRepository)
+	}
+	var rollbackOf string
+	if deploy.RollbackOf != nil {
+		rollbackOf = *deploy.RollbackOf
+	}
 	return &pb.DeploymentRequest{
-		ID:      deploy.ID,
-		Time:    pb.TimeAsTimestamp(deploy.Created),
-		Cluster: cluster,
-		Team:    deploy.Team,
+		ID:         deploy.ID,
+		Time:       pb.TimeAsTimestamp(deploy.Created),
+		Cluster:    cluster,
+		Team:       deploy.Team,
+		Repository: repository,
+		RollbackOf: rollbackOf,
+	}
+}
+
+func PbResource(resource database.DeploymentResource) *pb.DeploymentResource {
+	return &pb.DeploymentResource{
+		Group:     resource.Group,
+		Version:   resource.Version,
+		Kind:      resource.Kind,
+		Name:      resource.Name,
+		Namespace: resource.Namespace,
 	}
 }
@@ -41,6 +41,60 @@ func (_m *MockApiKeyStore) ApiKeys(ctx context.Context, id string) (ApiKeys, err
 	return r0, r1
 }
 
+// CreateApiKey provides a mock function with given fields: ctx, apiKey
+func (_m *MockApiKeyStore) CreateApiKey(ctx context.Context, apiKey ApiKey) (*ApiKey, error) {
+	ret := _m.Called(ctx, apiKey)
+
+	var r0 *ApiKey
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, ApiKey) (*ApiKey, error)); ok {
+		return rf(ctx, apiKey)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, ApiKey) *ApiKey); ok {
+		r0 = rf(ctx, apiKey)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*ApiKey)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, ApiKey) error); ok {
+		r1 = rf(ctx, apiKey)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkApiKeyUsed provides a mock function with given fields: ctx, id
+func (_m *MockApiKeyStore) MarkApiKeyUsed(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeApiKey provides a mock function with given fields: ctx, team, id
+func (_m *MockApiKeyStore) RevokeApiKey(ctx context.Context, team string, id string) error {
+	ret := _m.Called(ctx, team, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, team, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // RotateApiKey provides a mock function with given fields: ctx, team, key
 func (_m *MockApiKeyStore) RotateApiKey(ctx context.Context, team string, key api_v1.Key) error {
 	ret := _m.Called(ctx, team, key)
@@ -40,6 +40,32 @@ func (_m *MockDeploymentStore) Deployment(ctx context.Context, id string) (*Depl
 	return r0, r1
 }
 
+// DeploymentPayload provides a mock function with given fields: ctx, deploymentID
+func (_m *MockDeploymentStore) DeploymentPayload(ctx context.Context, deploymentID string) ([]byte, error) {
+	ret := _m.Called(ctx, deploymentID)
+
+	var r0 []byte
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]byte, error)); ok {
+		return rf(ctx, deploymentID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []byte); ok {
+		r0 = rf(ctx, deploymentID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deploymentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DeploymentResources provides a mock function with given fields: ctx, deploymentID
 func (_m *MockDeploymentStore) DeploymentResources(ctx context.Context, deploymentID string) ([]DeploymentResource, error) {
 	ret := _m.Called(ctx, deploymentID)
@@ -66,6 +92,32 @@ func (_m *MockDeploymentStore) DeploymentResources(ctx context.Context, deployme
 	return r0, r1
 }
 
+// DeploymentResourcesByIDs provides a mock function with given fields: ctx, deploymentIDs
+func (_m *MockDeploymentStore) DeploymentResourcesByIDs(ctx context.Context, deploymentIDs []string) (map[string][]DeploymentResource, error) {
+	ret := _m.Called(ctx, deploymentIDs)
+
+	var r0 map[string][]DeploymentResource
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string) (map[string][]DeploymentResource, error)); ok {
+		return rf(ctx, deploymentIDs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []string) map[string][]DeploymentResource); ok {
+		r0 = rf(ctx, deploymentIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string][]DeploymentResource)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, deploymentIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DeploymentStatus provides a mock function with given fields: ctx, deploymentID
 func (_m *MockDeploymentStore) DeploymentStatus(ctx context.Context, deploymentID string) ([]DeploymentStatus, error) {
 	ret := _m.Called(ctx, deploymentID)
@@ -92,6 +144,32 @@ func (_m *MockDeploymentStore) DeploymentStatus(ctx context.Context, deploymentI
 	return r0, r1
 }
 
+// DeploymentStatusByIDs provides a mock function with given fields: ctx, deploymentIDs
+func (_m *MockDeploymentStore) DeploymentStatusByIDs(ctx context.Context, deploymentIDs []string) (map[string][]DeploymentStatus, error) {
+	ret := _m.Called(ctx, deploymentIDs)
+
+	var r0 map[string][]DeploymentStatus
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string) (map[string][]DeploymentStatus, error)); ok {
+		return rf(ctx, deploymentIDs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []string) map[string][]DeploymentStatus); ok {
+		r0 = rf(ctx, deploymentIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string][]DeploymentStatus)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, deploymentIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Deployments provides a mock function with given fields: ctx, teams, clusters, ignoreTeams, limit
 func (_m *MockDeploymentStore) Deployments(ctx context.Context, teams []string, clusters []string, ignoreTeams []string, limit int) ([]*Deployment, error) {
 	ret := _m.Called(ctx, teams, clusters, ignoreTeams, limit)
@@ -118,6 +196,32 @@ func (_m *MockDeploymentStore) Deployments(ctx context.Context, teams []string,
 	return r0, r1
 }
 
+// FilteredDeployments provides a mock function with given fields: ctx, filter
+func (_m *MockDeploymentStore) FilteredDeployments(ctx context.Context, filter DeploymentFilter) ([]*Deployment, error) {
+	ret := _m.Called(ctx, filter)
+
+	var r0 []*Deployment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, DeploymentFilter) ([]*Deployment, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, DeploymentFilter) []*Deployment); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*Deployment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, DeploymentFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // HistoricDeployments provides a mock function with given fields: ctx, cluster, timestamp
 func (_m *MockDeploymentStore) HistoricDeployments(ctx context.Context, cluster string, timestamp time.Time) ([]*Deployment, error) {
 	ret := _m.Called(ctx, cluster, timestamp)
@@ -158,6 +262,20 @@ func (_m *MockDeploymentStore) WriteDeployment(ctx context.Context, deployment D
 	return r0
 }
 
+// WriteDeploymentPayload provides a mock function with given fields: ctx, deploymentID, payload
+func (_m *MockDeploymentStore) WriteDeploymentPayload(ctx context.Context, deploymentID string, payload []byte) error {
+	ret := _m.Called(ctx, deploymentID, payload)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []byte) error); ok {
+		r0 = rf(ctx, deploymentID, payload)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // WriteDeploymentResource provides a mock function with given fields: ctx, resource
 func (_m *MockDeploymentStore) WriteDeploymentResource(ctx context.Context, resource DeploymentResource) error {
 	ret := _m.Called(ctx, resource)
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// NonceStore remembers nonces until they expire, across all hookd replicas.
+type NonceStore interface {
+	AddNonce(ctx context.Context, nonce string, expires time.Time) (bool, error)
+}
+
+var _ NonceStore = &Database{}
+
+// AddNonce stores a nonce, and returns false if it is already stored and has not expired.
+// Expired nonces are deleted at the same time.
+func (db *Database) AddNonce(ctx context.Context, nonce string, expires time.Time) (bool, error) {
+	query := `
+WITH expired AS (
+	DELETE FROM signature_nonce WHERE expires < NOW() AND nonce <> $1
+)
+INSERT INTO signature_nonce (nonce, expires)
+VALUES ($1, $2)
+ON CONFLICT (nonce) DO UPDATE SET expires = EXCLUDED.expires
+WHERE signature_nonce.expires < NOW()
+RETURNING nonce;
+`
+	rows, err := db.timedQuery(ctx, query, nonce, expires)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	added := rows.Next()
+	return added, rows.Err()
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v4"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/nais/deploy/pkg/hookd/pubsub"
+)
+
+var _ pubsub.PubSub = &Database{}
+
+// Publish stores an encrypted message and notifies listeners on the channel of its ID.
+// Messages older than a few minutes are deleted at the same time; by then they have been read by all subscribers.
+// Messages are not re-encrypted by Reencrypt, as they are deleted long before the old key can be removed.
+func (db *Database) Publish(ctx context.Context, channel string, payload []byte) error {
+	encrypted, err := db.encrypt(payload, purposePubSub, channel)
+	if err != nil {
+		return fmt.Errorf("encrypt message: %s", err)
+	}
+
+	query := `
+WITH expired AS (
+	DELETE FROM pubsub_message WHERE created < NOW() - INTERVAL '5 minutes'
+), message AS (
+	INSERT INTO pubsub_message (channel, payload, created)
+	VALUES ($1, $2, NOW())
+	RETURNING id
+)
+SELECT pg_notify($1, id::text) FROM message;
+`
+	_, err = db.conn.Exec(ctx, query, channel, encrypted)
+
+	return err
+}
+
+// Subscribe listens for notifications on a dedicated database connection, which is released when
+// the context is cancelled. If the connection fails, the returned channel is closed, and the caller
+// must subscribe again.
+func (db *Database) Subscribe(ctx context.Context, channels ...string) (<-chan pubsub.Message, error) {
+	conn, err := db.conn.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, channel := range channels {
+		_, err = conn.Exec(ctx, `LISTEN `+pgx.Identifier{channel}.Sanitize())
+		if err != nil {
+			conn.Release()
+			return nil, fmt.Errorf("listen on %s: %w", channel, err)
+		}
+	}
+
+	messages := make(chan pubsub.Message)
+
+	go func() {
+		defer close(messages)
+		defer func() {
+			// Don't return a listening connection to the pool.
+			_, _ = conn.Exec(context.Background(), `UNLISTEN *`)
+			conn.Release()
+		}()
+
+		for {
+			notification, err := conn.Conn().WaitForNotification(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Errorf("Wait for database notification: %s", err)
+				}
+				return
+			}
+
+			msg, err := db.message(ctx, notification.Channel, notification.Payload)
+			if err != nil {
+				log.Errorf("Read message %s published on %s: %s", notification.Payload, notification.Channel, err)
+				continue
+			}
+
+			select {
+			case messages <- *msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return messages, nil
+}
+
+func (db *Database) message(ctx context.Context, channel, id string) (*pubsub.Message, error) {
+	messageID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message ID: %s", err)
+	}
+
+	query := `SELECT payload FROM pubsub_message WHERE id = $1 AND channel = $2;`
+	rows, err := db.timedQuery(ctx, query, messageID, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, ErrNotFound
+	}
+
+	var encrypted string
+	err = rows.Scan(&encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := db.decrypt(encrypted, purposePubSub, channel)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt message: %s", err)
+	}
+
+	return &pubsub.Message{
+		Channel: channel,
+		Payload: payload,
+	}, nil
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// encryptedColumn is a table column holding ciphertexts, which are re-encrypted when the active key changes.
+// Ciphertexts are bound to their purpose and the value of the owner column.
+type encryptedColumn struct {
+	table   string
+	id      string
+	column  string
+	purpose string
+	owner   string
+}
+
+var encryptedColumns = []encryptedColumn{
+	{table: "apikey", id: "id", column: "key", purpose: purposeApiKey, owner: "team"},
+	{table: "deployment_payload", id: "deployment_id", column: "payload", purpose: purposeDeploymentPayload, owner: "deployment_id"},
+	{table: "dispatch_queue", id: "deployment_id", column: "request", purpose: purposeDispatchQueue, owner: "deployment_id"},
+}
+
+// Reencrypt rewrites every ciphertext that is not encrypted with the active key in the current format,
+// in batches of batchSize rows. Version 1 ciphertexts, which are not bound to their owner, are upgraded as well.
+// Each batch is committed separately, so the rotation can be interrupted and resumed while hookd is running.
+// Returns the number of rewritten rows.
+func (db *Database) Reencrypt(ctx context.Context, batchSize int) (int, error) {
+	total := 0
+	for _, column := range encryptedColumns {
+		for {
+			n, err := db.reencryptBatch(ctx, column, batchSize)
+			total += n
+			if err != nil {
+				return total, fmt.Errorf("%s.%s: %w", column.table, column.column, err)
+			}
+			if n < batchSize {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+func (db *Database) reencryptBatch(ctx context.Context, column encryptedColumn, batchSize int) (int, error) {
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("unable to start transaction: %s", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Ciphertexts written with the active key are prefixed with its ID and the format version.
+	query := fmt.Sprintf(`SELECT %s, %s, %s FROM %s WHERE %s NOT LIKE $1 LIMIT %d FOR UPDATE SKIP LOCKED`,
+		column.id, column.owner, column.column, column.table, column.column, batchSize)
+	rows, err := tx.Query(ctx, query, db.keyring.CurrentPrefix()+"%")
+	if err != nil {
+		return 0, err
+	}
+
+	type row struct {
+		id        string
+		owner     string
+		encrypted string
+	}
+	batch := make([]row, 0, batchSize)
+	for rows.Next() {
+		var r row
+		err = rows.Scan(&r.id, &r.owner, &r.encrypted)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return 0, rows.Err()
+	}
+
+	update := fmt.Sprintf(`UPDATE %s SET %s = $1 WHERE %s = $2`, column.table, column.column, column.id)
+	for _, r := range batch {
+		plaintext, err := db.decrypt(r.encrypted, column.purpose, r.owner)
+		if err != nil {
+			return 0, fmt.Errorf("decrypt row %s: %w", r.id, err)
+		}
+		encrypted, err := db.encrypt(plaintext, column.purpose, r.owner)
+		if err != nil {
+			return 0, fmt.Errorf("encrypt row %s: %w", r.id, err)
+		}
+		_, err = tx.Exec(ctx, update, encrypted, r.id)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(batch), nil
+}
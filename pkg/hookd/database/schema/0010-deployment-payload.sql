@@ -0,0 +1,19 @@
+-- Run the entire migration as an atomic operation.
+START TRANSACTION ISOLATION LEVEL SERIALIZABLE READ WRITE;
+
+-- Table deployment_payload holds the encrypted Kubernetes resources of each deployment,
+-- so that a previous deployment can be dispatched again when rolling back.
+CREATE TABLE deployment_payload
+(
+    "deployment_id" varchar primary key references deployment (id) not null,
+    "payload"       varchar                                         not null
+);
+
+-- Deployments created by a rollback refer to the deployment they were copied from.
+ALTER TABLE deployment
+ADD COLUMN "rollback_of" VARCHAR NULL REFERENCES deployment (id);
+
+-- Mark this database migration as completed.
+INSERT INTO migrations (version, created)
+VALUES (10, now());
+COMMIT;
@@ -0,0 +1,41 @@
+-- Run the entire migration as an atomic operation.
+START TRANSACTION ISOLATION LEVEL SERIALIZABLE READ WRITE;
+
+-- API keys are identified by a non-secret ID, so that they can be listed and revoked without exposing the key.
+-- Existing keys are given an ID derived from the encrypted key.
+ALTER TABLE apikey
+ADD COLUMN "id" VARCHAR NULL;
+
+UPDATE apikey SET id = md5(key);
+
+ALTER TABLE apikey
+ALTER COLUMN "id" SET NOT NULL;
+
+CREATE UNIQUE INDEX apikey_id_index ON apikey (id);
+
+-- A team can have several named keys, e.g. one for each pipeline.
+-- Keys created before this migration, and keys created by rotation, are named "default".
+ALTER TABLE apikey
+ADD COLUMN "name" VARCHAR NOT NULL DEFAULT 'default';
+
+-- Keys can be limited to a set of clusters. Keys without clusters may deploy to any cluster.
+ALTER TABLE apikey
+ADD COLUMN "clusters" VARCHAR[] NOT NULL DEFAULT '{}';
+
+ALTER TABLE apikey
+ADD COLUMN "last_used" TIMESTAMP WITH TIME ZONE NULL;
+
+ALTER TABLE apikey
+ADD COLUMN "revoked" TIMESTAMP WITH TIME ZONE NULL;
+
+-- A team can only have one key in use with a given name.
+-- Index predicates can't refer to the current time, so keys that have expired are marked as revoked
+-- at their expiry time before a new key with the same name is created.
+UPDATE apikey SET revoked = expires WHERE expires <= NOW();
+
+CREATE UNIQUE INDEX apikey_team_name_index ON apikey (team, name) WHERE revoked IS NULL;
+
+-- Mark this database migration as completed.
+INSERT INTO migrations (version, created)
+VALUES (11, now());
+COMMIT;
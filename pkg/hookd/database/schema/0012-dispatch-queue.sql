@@ -0,0 +1,22 @@
+-- Run the entire migration as an atomic operation.
+START TRANSACTION ISOLATION LEVEL SERIALIZABLE READ WRITE;
+
+-- Table dispatch_queue holds encrypted deployment requests that have not yet been delivered to deployd,
+-- so that they survive while a cluster is offline, and are delivered in order once it reconnects.
+CREATE TABLE dispatch_queue
+(
+    "sequence"      bigserial primary key                    not null,
+    "deployment_id" varchar unique references deployment (id) not null,
+    "cluster"       varchar                                  not null,
+    "request"       varchar                                  not null,
+    "created"       timestamp with time zone                 not null,
+    "deadline"      timestamp with time zone                 not null
+);
+
+CREATE INDEX dispatch_queue_cluster_index ON dispatch_queue (cluster, sequence);
+CREATE INDEX dispatch_queue_deadline_index ON dispatch_queue (deadline);
+
+-- Mark this database migration as completed.
+INSERT INTO migrations (version, created)
+VALUES (12, now());
+COMMIT;
@@ -0,0 +1,20 @@
+-- Run the entire migration as an atomic operation.
+START TRANSACTION ISOLATION LEVEL SERIALIZABLE READ WRITE;
+
+-- Table pubsub_message holds encrypted messages between hookd replicas.
+-- Replicas are notified of new messages through LISTEN/NOTIFY, which limits the size of its payload,
+-- so only the message ID is sent as a notification. Messages are deleted shortly after being published.
+CREATE TABLE pubsub_message
+(
+    "id"      bigserial primary key    not null,
+    "channel" varchar                  not null,
+    "payload" varchar                  not null,
+    "created" timestamp with time zone not null
+);
+
+CREATE INDEX pubsub_message_created_index ON pubsub_message (created);
+
+-- Mark this database migration as completed.
+INSERT INTO migrations (version, created)
+VALUES (13, now());
+COMMIT;
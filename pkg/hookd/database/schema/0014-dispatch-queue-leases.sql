@@ -0,0 +1,17 @@
+-- Run the entire migration as an atomic operation.
+START TRANSACTION ISOLATION LEVEL SERIALIZABLE READ WRITE;
+
+-- Queued deployment requests are leased to a single deployd instance, and stay in the queue until finished.
+-- Requests are leased again if the instance doesn't renew the lease before it expires, e.g. because it disappeared.
+ALTER TABLE dispatch_queue
+ADD COLUMN "leased_to" VARCHAR NULL;
+
+ALTER TABLE dispatch_queue
+ADD COLUMN "lease_expires" TIMESTAMP WITH TIME ZONE NULL;
+
+CREATE INDEX dispatch_queue_leased_to_index ON dispatch_queue (leased_to);
+
+-- Mark this database migration as completed.
+INSERT INTO migrations (version, created)
+VALUES (14, now());
+COMMIT;
@@ -0,0 +1,18 @@
+-- Run the entire migration as an atomic operation.
+START TRANSACTION ISOLATION LEVEL SERIALIZABLE READ WRITE;
+
+-- Table signature_nonce holds the nonces of API key request signatures, shared between hookd replicas,
+-- so that a signed request cannot be replayed against another replica.
+-- Nonces are deleted once the signature has expired.
+CREATE TABLE signature_nonce
+(
+    "nonce"   varchar primary key      not null,
+    "expires" timestamp with time zone not null
+);
+
+CREATE INDEX signature_nonce_expires_index ON signature_nonce (expires);
+
+-- Mark this database migration as completed.
+INSERT INTO migrations (version, created)
+VALUES (15, now());
+COMMIT;
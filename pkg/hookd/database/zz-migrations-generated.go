@@ -12,4 +12,10 @@ var migrations = []string{
 	"-- Run the entire migration as an atomic operation.\nSTART TRANSACTION ISOLATION LEVEL SERIALIZABLE READ WRITE;\n\n-- Add cluster field to deployment table.\nALTER TABLE deployment\nADD COLUMN \"state\" VARCHAR NULL;\n\n-- Enable fast lookups on cluster and state\nCREATE INDEX deployment_state ON deployment (state);\nCREATE INDEX deployment_cluster ON deployment (cluster);\n\n-- Mark this database migration as completed.\nINSERT INTO migrations (version, created)\nVALUES (7, now());\nCOMMIT;\n",
 	"-- Run the entire migration as an atomic operation.\nSTART TRANSACTION ISOLATION LEVEL SERIALIZABLE READ WRITE;\n\n-- Enable fast lookups on team\nCREATE INDEX deployment_team ON deployment (team);\n\n-- Mark this database migration as completed.\nINSERT INTO migrations (version, created)\nVALUES (8, now());\nCOMMIT;\n",
 	"-- Run the entire migration as an atomic operation.\nSTART TRANSACTION ISOLATION LEVEL SERIALIZABLE READ WRITE;\n\n-- Remove no longer used Azure column / index\nDROP INDEX apikey_team_azure_id_index;\nALTER TABLE apikey DROP COLUMN \"team_azure_id\";\n\n-- Mark this database migration as completed.\nINSERT INTO migrations (version, created)\nVALUES (9, now());\nCOMMIT;\n",
+	"-- Run the entire migration as an atomic operation.\nSTART TRANSACTION ISOLATION LEVEL SERIALIZABLE READ WRITE;\n\n-- Table deployment_payload holds the encrypted Kubernetes resources of each deployment,\n-- so that a previous deployment can be dispatched again when rolling back.\nCREATE TABLE deployment_payload\n(\n    \"deployment_id\" varchar primary key references deployment (id) not null,\n    \"payload\"       varchar                                         not null\n);\n\n-- Deployments created by a rollback refer to the deployment they were copied from.\nALTER TABLE deployment\nADD COLUMN \"rollback_of\" VARCHAR NULL REFERENCES deployment (id);\n\n-- Mark this database migration as completed.\nINSERT INTO migrations (version, created)\nVALUES (10, now());\nCOMMIT;\n",
+	"-- Run the entire migration as an atomic operation.\nSTART TRANSACTION ISOLATION LEVEL SERIALIZABLE READ WRITE;\n\n-- API keys are identified by a non-secret ID, so that they can be listed and revoked without exposing the key.\n-- Existing keys are given an ID derived from the encrypted key.\nALTER TABLE apikey\nADD COLUMN \"id\" VARCHAR NULL;\n\nUPDATE apikey SET id = md5(key);\n\nALTER TABLE apikey\nALTER COLUMN \"id\" SET NOT NULL;\n\nCREATE UNIQUE INDEX apikey_id_index ON apikey (id);\n\n-- A team can have several named keys, e.g. one for each pipeline.\n-- Keys created before this migration, and keys created by rotation, are named \"default\".\nALTER TABLE apikey\nADD COLUMN \"name\" VARCHAR NOT NULL DEFAULT 'default';\n\n-- Keys can be limited to a set of clusters. Keys without clusters may deploy to any cluster.\nALTER TABLE apikey\nADD COLUMN \"clusters\" VARCHAR[] NOT NULL DEFAULT '{}';\n\nALTER TABLE apikey\nADD COLUMN \"last_used\" TIMESTAMP WITH TIME ZONE NULL;\n\nALTER TABLE apikey\nADD COLUMN \"revoked\" TIMESTAMP WITH TIME ZONE NULL;\n\n-- A team can only have one key in use with a given name.\n-- Index predicates can't refer to the current time, so keys that have expired are marked as revoked\n-- at their expiry time before a new key with the same name is created.\nUPDATE apikey SET revoked = expires WHERE expires <= NOW();\n\nCREATE UNIQUE INDEX apikey_team_name_index ON apikey (team, name) WHERE revoked IS NULL;\n\n-- Mark this database migration as completed.\nINSERT INTO migrations (version, created)\nVALUES (11, now());\nCOMMIT;\n",
+	"-- Run the entire migration as an atomic operation.\nSTART TRANSACTION ISOLATION LEVEL SERIALIZABLE READ WRITE;\n\n-- Table dispatch_queue holds encrypted deployment requests that have not yet been delivered to deployd,\n-- so that they survive while a cluster is offline, and are delivered in order once it reconnects.\nCREATE TABLE dispatch_queue\n(\n    \"sequence\"      bigserial primary key                    not null,\n    \"deployment_id\" varchar unique references deployment (id) not null,\n    \"cluster\"       varchar                                  not null,\n    \"request\"       varchar                                  not null,\n    \"created\"       timestamp with time zone                 not null,\n    \"deadline\"      timestamp with time zone                 not null\n);\n\nCREATE INDEX dispatch_queue_cluster_index ON dispatch_queue (cluster, sequence);\nCREATE INDEX dispatch_queue_deadline_index ON dispatch_queue (deadline);\n\n-- Mark this database migration as completed.\nINSERT INTO migrations (version, created)\nVALUES (12, now());\nCOMMIT;\n",
+	"-- Run the entire migration as an atomic operation.\nSTART TRANSACTION ISOLATION LEVEL SERIALIZABLE READ WRITE;\n\n-- Table pubsub_message holds encrypted messages between hookd replicas.\n-- Replicas are notified of new messages through LISTEN/NOTIFY, which limits the size of its payload,\n-- so only the message ID is sent as a notification. Messages are deleted shortly after being published.\nCREATE TABLE pubsub_message\n(\n    \"id\"      bigserial primary key    not null,\n    \"channel\" varchar                  not null,\n    \"payload\" varchar                  not null,\n    \"created\" timestamp with time zone not null\n);\n\nCREATE INDEX pubsub_message_created_index ON pubsub_message (created);\n\n-- Mark this database migration as completed.\nINSERT INTO migrations (version, created)\nVALUES (13, now());\nCOMMIT;\n",
+	"-- Run the entire migration as an atomic operation.\nSTART TRANSACTION ISOLATION LEVEL SERIALIZABLE READ WRITE;\n\n-- Queued deployment requests are leased to a single deployd instance, and stay in the queue until finished.\n-- Requests are leased again if the instance doesn't renew the lease before it expires, e.g. because it disappeared.\nALTER TABLE dispatch_queue\nADD COLUMN \"leased_to\" VARCHAR NULL;\n\nALTER TABLE dispatch_queue\nADD COLUMN \"lease_expires\" TIMESTAMP WITH TIME ZONE NULL;\n\nCREATE INDEX dispatch_queue_leased_to_index ON dispatch_queue (leased_to);\n\n-- Mark this database migration as completed.\nINSERT INTO migrations (version, created)\nVALUES (14, now());\nCOMMIT;\n",
+	"-- Run the entire migration as an atomic operation.\nSTART TRANSACTION ISOLATION LEVEL SERIALIZABLE READ WRITE;\n\n-- Table signature_nonce holds the nonces of API key request signatures, shared between hookd replicas,\n-- so that a signed request cannot be replayed against another replica.\n-- Nonces are deleted once the signature has expired.\nCREATE TABLE signature_nonce\n(\n    \"nonce\"   varchar primary key      not null,\n    \"expires\" timestamp with time zone not null\n);\n\nCREATE INDEX signature_nonce_expires_index ON signature_nonce (expires);\n\n-- Mark this database migration as completed.\nINSERT INTO migrations (version, created)\nVALUES (15, now());\nCOMMIT;\n",
 }
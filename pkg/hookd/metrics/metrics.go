@@ -22,8 +22,12 @@ const (
 	Team                 = "team"
 	Cluster              = "cluster"
 
-	LabelType  = "type"
-	LabelError = "error"
+	LabelType   = "type"
+	LabelError  = "error"
+	LabelResult = "result"
+
+	AuthorizationCacheHit  = "hit"
+	AuthorizationCacheMiss = "miss"
 )
 
 var (
@@ -99,6 +103,31 @@ var (
 	},
 		[]string{LabelType, LabelError},
 	)
+
+	authorizationCache = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:      "authorization_cache_lookups",
+		Help:      "Number of repository authorization lookups in cache, by result",
+		Namespace: namespace,
+		Subsystem: subsystem,
+	},
+		[]string{LabelResult},
+	)
+
+	authorizationErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:      "authorization_backend_errors",
+		Help:      "Number of failed repository authorization requests to nais-api",
+		Namespace: namespace,
+		Subsystem: subsystem,
+	})
+
+	authorizationFallbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:      "authorization_fallbacks",
+		Help:      "Number of repository authorizations answered by the team repositories table while nais-api is unavailable",
+		Namespace: namespace,
+		Subsystem: subsystem,
+	},
+		[]string{LabelStatus},
+	)
 )
 
 func init() {
@@ -108,6 +137,9 @@ func init() {
 	prometheus.MustRegister(leadTime)
 	prometheus.MustRegister(clusterStatus)
 	prometheus.MustRegister(interceptorRequests)
+	prometheus.MustRegister(authorizationCache)
+	prometheus.MustRegister(authorizationErrors)
+	prometheus.MustRegister(authorizationFallbacks)
 }
 
 func SetConnectedClusters(clusters []string) {
@@ -157,7 +189,7 @@ func UpdateQueue(status *pb.DeploymentStatus) {
 
 	switch status.GetState() {
 
-	// These three states are definite and signify the end of a deployment.
+	// These states are definite and signify the end of a deployment.
 	case pb.DeploymentState_success:
 
 		// In case of successful deployment, report the lead time.
@@ -167,6 +199,8 @@ func UpdateQueue(status *pb.DeploymentStatus) {
 		fallthrough
 	case pb.DeploymentState_inactive:
 		fallthrough
+	case pb.DeploymentState_cancelled:
+		fallthrough
 	case pb.DeploymentState_error:
 		fallthrough
 	case pb.DeploymentState_failure:
@@ -180,9 +214,38 @@ func UpdateQueue(status *pb.DeploymentStatus) {
 	queueSize.Set(float64(len(deployQueue)))
 }
 
+// RestoreQueue counts deployments waiting in the dispatch queue as unfinished,
+// as the in-memory queue is lost when hookd restarts.
+func RestoreQueue(deploymentIDs []string) {
+	qlock.Lock()
+	defer qlock.Unlock()
+
+	for _, id := range deploymentIDs {
+		deployQueue[id] = new(interface{})
+	}
+
+	queueSize.Set(float64(len(deployQueue)))
+}
+
 func InterceptorRequest(requestType string, errType string) {
 	interceptorRequests.With(prometheus.Labels{
 		LabelType:  requestType,
 		LabelError: errType,
 	}).Inc()
 }
+
+func AuthorizationCacheLookup(result string) {
+	authorizationCache.With(prometheus.Labels{
+		LabelResult: result,
+	}).Inc()
+}
+
+func AuthorizationBackendError() {
+	authorizationErrors.Inc()
+}
+
+func AuthorizationFallback(err error) {
+	authorizationFallbacks.With(prometheus.Labels{
+		LabelStatus: statusLabel(err),
+	}).Inc()
+}
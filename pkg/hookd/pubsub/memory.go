@@ -0,0 +1,97 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory is an in-process pub/sub, for running several hookd servers in the same process, e.g. in tests.
+type Memory struct {
+	lock        sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+var _ PubSub = &Memory{}
+
+// subscriber buffers messages without limit, so that publishing never blocks on a slow subscriber.
+type subscriber struct {
+	channels map[string]bool
+	lock     sync.Mutex
+	pending  []Message
+	wake     chan struct{}
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+func (m *Memory) Publish(_ context.Context, channel string, payload []byte) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for sub := range m.subscribers {
+		if !sub.channels[channel] {
+			continue
+		}
+		sub.lock.Lock()
+		sub.pending = append(sub.pending, Message{Channel: channel, Payload: payload})
+		sub.lock.Unlock()
+
+		select {
+		case sub.wake <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) Subscribe(ctx context.Context, channels ...string) (<-chan Message, error) {
+	sub := &subscriber{
+		channels: make(map[string]bool),
+		wake:     make(chan struct{}, 1),
+	}
+	for _, channel := range channels {
+		sub.channels[channel] = true
+	}
+
+	m.lock.Lock()
+	m.subscribers[sub] = struct{}{}
+	m.lock.Unlock()
+
+	messages := make(chan Message)
+
+	go func() {
+		defer close(messages)
+		defer func() {
+			m.lock.Lock()
+			delete(m.subscribers, sub)
+			m.lock.Unlock()
+		}()
+
+		for {
+			sub.lock.Lock()
+			pending := sub.pending
+			sub.pending = nil
+			sub.lock.Unlock()
+
+			for _, msg := range pending {
+				select {
+				case messages <- msg:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-sub.wake:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return messages, nil
+}
@@ -0,0 +1,58 @@
+package pubsub_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nais/deploy/pkg/hookd/pubsub"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	bus := pubsub.NewMemory()
+
+	first, err := bus.Subscribe(ctx, "foo", "bar")
+	assert.NoError(t, err)
+	second, err := bus.Subscribe(ctx, "bar")
+	assert.NoError(t, err)
+
+	// Publishing doesn't wait for subscribers to read their messages.
+	for _, msg := range []pubsub.Message{
+		{Channel: "foo", Payload: []byte("1")},
+		{Channel: "bar", Payload: []byte("2")},
+		{Channel: "baz", Payload: []byte("3")},
+		{Channel: "bar", Payload: []byte("4")},
+	} {
+		err := bus.Publish(ctx, msg.Channel, msg.Payload)
+		assert.NoError(t, err)
+	}
+
+	receive := func(messages <-chan pubsub.Message) string {
+		select {
+		case msg := <-messages:
+			return msg.Channel + ":" + string(msg.Payload)
+		case <-ctx.Done():
+			return "timeout"
+		}
+	}
+
+	assert.Equal(t, "foo:1", receive(first))
+	assert.Equal(t, "bar:2", receive(first))
+	assert.Equal(t, "bar:4", receive(first))
+	assert.Equal(t, "bar:2", receive(second))
+	assert.Equal(t, "bar:4", receive(second))
+
+	t.Run("channel is closed when subscription ends", func(t *testing.T) {
+		subCtx, subCancel := context.WithCancel(ctx)
+		messages, err := bus.Subscribe(subCtx, "foo")
+		assert.NoError(t, err)
+
+		subCancel()
+		_, ok := <-messages
+		assert.False(t, ok)
+	})
+}
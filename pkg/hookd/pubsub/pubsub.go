@@ -0,0 +1,22 @@
+// Package pubsub distributes messages between hookd replicas.
+package pubsub
+
+import (
+	"context"
+)
+
+// Message is a payload published on a channel.
+type Message struct {
+	Channel string
+	Payload []byte
+}
+
+// PubSub delivers every message published on a channel to all subscribers of that channel,
+// including subscribers in the publishing process. Messages are delivered in the order they were published,
+// but are not persisted; subscribers only receive messages published while they are subscribed.
+type PubSub interface {
+	Publish(ctx context.Context, channel string, payload []byte) error
+	// Subscribe returns a channel of messages published on any of the given channels.
+	// The returned channel is closed when the context is cancelled, or when the subscription is lost.
+	Subscribe(ctx context.Context, channels ...string) (<-chan Message, error)
+}
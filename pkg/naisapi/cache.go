@@ -0,0 +1,185 @@
+package naisapi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/nais/deploy/pkg/hookd/database"
+	"github.com/nais/deploy/pkg/hookd/metrics"
+)
+
+// Authorizer decides whether a repository is allowed to deploy on behalf of a team.
+type Authorizer interface {
+	IsAuthorized(ctx context.Context, repo, team string) (bool, error)
+}
+
+var _ Authorizer = &Client{}
+
+type authorizationKey struct {
+	repo string
+	team string
+}
+
+type authorizationEntry struct {
+	authorized bool
+	expires    time.Time
+}
+
+// CachedClient remembers authorization decisions from nais-api, so that every deployment and status
+// request doesn't have to wait for it.
+//
+// If a fallback store is configured, decisions from nais-api are written to the legacy team repositories
+// table, which is used to answer requests when nais-api is unavailable.
+type CachedClient struct {
+	client      Authorizer
+	ttl         time.Duration
+	negativeTTL time.Duration
+	fallback    database.RepositoryTeamStore
+	now         func() time.Time
+
+	lock       sync.Mutex
+	entries    map[authorizationKey]authorizationEntry
+	lastPurged time.Time
+}
+
+var _ Authorizer = &CachedClient{}
+
+// NewCachedClient caches positive decisions for ttl, and negative decisions for negativeTTL.
+// A zero duration disables caching of that kind of decision. The fallback store is optional.
+func NewCachedClient(client Authorizer, ttl, negativeTTL time.Duration, fallback database.RepositoryTeamStore) *CachedClient {
+	return &CachedClient{
+		client:      client,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		fallback:    fallback,
+		now:         time.Now,
+		entries:     make(map[authorizationKey]authorizationEntry),
+	}
+}
+
+func (c *CachedClient) IsAuthorized(ctx context.Context, repo, team string) (bool, error) {
+	key := authorizationKey{repo: repo, team: team}
+
+	authorized, ok := c.get(key)
+	if ok {
+		metrics.AuthorizationCacheLookup(metrics.AuthorizationCacheHit)
+		return authorized, nil
+	}
+	metrics.AuthorizationCacheLookup(metrics.AuthorizationCacheMiss)
+
+	authorized, err := c.client.IsAuthorized(ctx, repo, team)
+	if err != nil {
+		metrics.AuthorizationBackendError()
+		return c.fallbackAuthorized(ctx, key, err)
+	}
+
+	c.set(key, authorized)
+	c.syncFallback(ctx, key, authorized)
+
+	return authorized, nil
+}
+
+func (c *CachedClient) get(key authorizationKey) (bool, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || !c.now().Before(entry.expires) {
+		return false, false
+	}
+	return entry.authorized, true
+}
+
+func (c *CachedClient) set(key authorizationKey, authorized bool) {
+	ttl := c.ttl
+	if !authorized {
+		ttl = c.negativeTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := c.now()
+	if now.Sub(c.lastPurged) > c.ttl {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastPurged = now
+	}
+
+	c.entries[key] = authorizationEntry{
+		authorized: authorized,
+		expires:    now.Add(ttl),
+	}
+}
+
+// fallbackAuthorized answers from the team repositories table when nais-api fails.
+// The original error is returned if there is no fallback, or if the fallback fails as well.
+func (c *CachedClient) fallbackAuthorized(ctx context.Context, key authorizationKey, backendErr error) (bool, error) {
+	if c.fallback == nil {
+		return false, backendErr
+	}
+
+	teams, err := c.fallback.ReadRepositoryTeams(ctx, key.repo)
+	if err != nil && !database.IsErrNotFound(err) {
+		metrics.AuthorizationFallback(err)
+		log.WithError(err).Errorf("Read authorized teams for repository %s from fallback", key.repo)
+		return false, backendErr
+	}
+	metrics.AuthorizationFallback(nil)
+
+	authorized := contains(teams, key.team)
+	log.WithError(backendErr).Warnf("nais-api unavailable; repository %s authorized by team %s from fallback: %t", key.repo, key.team, authorized)
+
+	return authorized, nil
+}
+
+// syncFallback keeps the team repositories table in sync with decisions from nais-api.
+// Failures are only logged, as the decision itself is already known.
+func (c *CachedClient) syncFallback(ctx context.Context, key authorizationKey, authorized bool) {
+	if c.fallback == nil {
+		return
+	}
+
+	teams, err := c.fallback.ReadRepositoryTeams(ctx, key.repo)
+	if err != nil && !database.IsErrNotFound(err) {
+		log.WithError(err).Errorf("Read authorized teams for repository %s from fallback", key.repo)
+		return
+	}
+
+	if contains(teams, key.team) == authorized {
+		return
+	}
+
+	updated := make([]string, 0, len(teams)+1)
+	for _, team := range teams {
+		if team != key.team {
+			updated = append(updated, team)
+		}
+	}
+	if authorized {
+		updated = append(updated, key.team)
+	}
+
+	err = c.fallback.WriteRepositoryTeams(ctx, key.repo, updated)
+	if err != nil {
+		log.WithError(err).Errorf("Write authorized teams for repository %s to fallback", key.repo)
+	}
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
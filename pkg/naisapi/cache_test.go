@@ -0,0 +1,142 @@
+package naisapi
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/nais/deploy/pkg/hookd/database"
+)
+
+type fakeAuthorizer struct {
+	authorized map[string]string
+	err        error
+	calls      int
+}
+
+func (f *fakeAuthorizer) IsAuthorized(ctx context.Context, repo, team string) (bool, error) {
+	f.calls++
+	if f.err != nil {
+		return false, f.err
+	}
+	return f.authorized[repo] == team, nil
+}
+
+func TestCachedClient(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	backend := &fakeAuthorizer{authorized: map[string]string{"nais/deploy": "aura"}}
+	client := NewCachedClient(backend, time.Minute, 10*time.Second, nil)
+	client.now = func() time.Time { return now }
+
+	authorized, err := client.IsAuthorized(ctx, "nais/deploy", "aura")
+	assert.NoError(t, err)
+	assert.True(t, authorized)
+
+	authorized, err = client.IsAuthorized(ctx, "nais/deploy", "other")
+	assert.NoError(t, err)
+	assert.False(t, authorized)
+	assert.Equal(t, 2, backend.calls)
+
+	// Both decisions are cached, and the backend is not asked again.
+	backend.authorized = map[string]string{"nais/deploy": "other"}
+	authorized, _ = client.IsAuthorized(ctx, "nais/deploy", "aura")
+	assert.True(t, authorized)
+	authorized, _ = client.IsAuthorized(ctx, "nais/deploy", "other")
+	assert.False(t, authorized)
+	assert.Equal(t, 2, backend.calls)
+
+	// Negative decisions expire first.
+	now = now.Add(30 * time.Second)
+	authorized, _ = client.IsAuthorized(ctx, "nais/deploy", "aura")
+	assert.True(t, authorized)
+	authorized, _ = client.IsAuthorized(ctx, "nais/deploy", "other")
+	assert.True(t, authorized)
+	assert.Equal(t, 3, backend.calls)
+
+	now = now.Add(time.Minute)
+	authorized, _ = client.IsAuthorized(ctx, "nais/deploy", "aura")
+	assert.False(t, authorized)
+	assert.Equal(t, 4, backend.calls)
+}
+
+func TestCachedClientBackendError(t *testing.T) {
+	ctx := context.Background()
+	backendErr := fmt.Errorf("nais-api is down")
+
+	t.Run("without fallback", func(t *testing.T) {
+		backend := &fakeAuthorizer{err: backendErr}
+		client := NewCachedClient(backend, time.Minute, time.Minute, nil)
+
+		_, err := client.IsAuthorized(ctx, "nais/deploy", "aura")
+		assert.ErrorIs(t, err, backendErr)
+
+		// Errors are not cached.
+		_, err = client.IsAuthorized(ctx, "nais/deploy", "aura")
+		assert.ErrorIs(t, err, backendErr)
+		assert.Equal(t, 2, backend.calls)
+	})
+
+	t.Run("with fallback", func(t *testing.T) {
+		fallback := database.NewMockRepositoryTeamStore(t)
+		fallback.On("ReadRepositoryTeams", mock.Anything, "nais/deploy").Return([]string{"aura"}, nil)
+		fallback.On("ReadRepositoryTeams", mock.Anything, "nais/unknown").Return(nil, database.ErrNotFound)
+
+		backend := &fakeAuthorizer{err: backendErr}
+		client := NewCachedClient(backend, time.Minute, time.Minute, fallback)
+
+		authorized, err := client.IsAuthorized(ctx, "nais/deploy", "aura")
+		assert.NoError(t, err)
+		assert.True(t, authorized)
+
+		authorized, err = client.IsAuthorized(ctx, "nais/deploy", "other")
+		assert.NoError(t, err)
+		assert.False(t, authorized)
+
+		authorized, err = client.IsAuthorized(ctx, "nais/unknown", "aura")
+		assert.NoError(t, err)
+		assert.False(t, authorized)
+	})
+
+	t.Run("fallback fails", func(t *testing.T) {
+		fallback := database.NewMockRepositoryTeamStore(t)
+		fallback.On("ReadRepositoryTeams", mock.Anything, "nais/deploy").Return(nil, fmt.Errorf("database is down"))
+
+		client := NewCachedClient(&fakeAuthorizer{err: backendErr}, time.Minute, time.Minute, fallback)
+
+		_, err := client.IsAuthorized(ctx, "nais/deploy", "aura")
+		assert.ErrorIs(t, err, backendErr)
+	})
+}
+
+func TestCachedClientSyncsFallback(t *testing.T) {
+	ctx := context.Background()
+
+	fallback := database.NewMockRepositoryTeamStore(t)
+	fallback.On("ReadRepositoryTeams", mock.Anything, "nais/deploy").Return([]string{"aura", "other"}, nil)
+	fallback.On("WriteRepositoryTeams", mock.Anything, "nais/deploy", []string{"aura", "other", "new"}).Return(nil).Once()
+	fallback.On("WriteRepositoryTeams", mock.Anything, "nais/deploy", []string{"other"}).Return(nil).Once()
+
+	backend := &fakeAuthorizer{authorized: map[string]string{"nais/deploy": "new"}}
+	client := NewCachedClient(backend, time.Minute, time.Minute, fallback)
+
+	// Authorized by nais-api, but not in the table.
+	authorized, err := client.IsAuthorized(ctx, "nais/deploy", "new")
+	assert.NoError(t, err)
+	assert.True(t, authorized)
+
+	// Not authorized by nais-api, but in the table.
+	authorized, err = client.IsAuthorized(ctx, "nais/deploy", "aura")
+	assert.NoError(t, err)
+	assert.False(t, authorized)
+
+	// Already in sync.
+	authorized, err = client.IsAuthorized(ctx, "nais/deploy", "unknown")
+	assert.NoError(t, err)
+	assert.False(t, authorized)
+}
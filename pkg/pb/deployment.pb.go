@@ -32,6 +32,7 @@ const (
 	DeploymentState_in_progress DeploymentState = 4
 	DeploymentState_queued      DeploymentState = 5
 	DeploymentState_pending     DeploymentState = 6
+	DeploymentState_cancelled   DeploymentState = 7
 )
 
 // Enum value maps for DeploymentState.
@@ -44,6 +45,7 @@ var (
 		4: "in_progress",
 		5: "queued",
 		6: "pending",
+		7: "cancelled",
 	}
 	DeploymentState_value = map[string]int32{
 		"success":     0,
@@ -53,6 +55,7 @@ var (
 		"in_progress": 4,
 		"queued":      5,
 		"pending":     6,
+		"cancelled":   7,
 	}
 )
 
@@ -83,6 +86,104 @@ func (DeploymentState) EnumDescriptor() ([]byte, []int) {
 	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{0}
 }
 
+type DeploymentAction int32
+
+const (
+	DeploymentAction_deploy DeploymentAction = 0
+	DeploymentAction_diff   DeploymentAction = 1
+	DeploymentAction_cancel DeploymentAction = 2
+)
+
+// Enum value maps for DeploymentAction.
+var (
+	DeploymentAction_name = map[int32]string{
+		0: "deploy",
+		1: "diff",
+		2: "cancel",
+	}
+	DeploymentAction_value = map[string]int32{
+		"deploy": 0,
+		"diff":   1,
+		"cancel": 2,
+	}
+)
+
+func (x DeploymentAction) Enum() *DeploymentAction {
+	p := new(DeploymentAction)
+	*p = x
+	return p
+}
+
+func (x DeploymentAction) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (DeploymentAction) Descriptor() protoreflect.EnumDescriptor {
+	return file_pkg_pb_deployment_proto_enumTypes[1].Descriptor()
+}
+
+func (DeploymentAction) Type() protoreflect.EnumType {
+	return &file_pkg_pb_deployment_proto_enumTypes[1]
+}
+
+func (x DeploymentAction) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use DeploymentAction.Descriptor instead.
+func (DeploymentAction) EnumDescriptor() ([]byte, []int) {
+	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{1}
+}
+
+type ResourceChange int32
+
+const (
+	ResourceChange_unchanged ResourceChange = 0
+	ResourceChange_created   ResourceChange = 1
+	ResourceChange_updated   ResourceChange = 2
+)
+
+// Enum value maps for ResourceChange.
+var (
+	ResourceChange_name = map[int32]string{
+		0: "unchanged",
+		1: "created",
+		2: "updated",
+	}
+	ResourceChange_value = map[string]int32{
+		"unchanged": 0,
+		"created":   1,
+		"updated":   2,
+	}
+)
+
+func (x ResourceChange) Enum() *ResourceChange {
+	p := new(ResourceChange)
+	*p = x
+	return p
+}
+
+func (x ResourceChange) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ResourceChange) Descriptor() protoreflect.EnumDescriptor {
+	return file_pkg_pb_deployment_proto_enumTypes[2].Descriptor()
+}
+
+func (ResourceChange) Type() protoreflect.EnumType {
+	return &file_pkg_pb_deployment_proto_enumTypes[2]
+}
+
+func (x ResourceChange) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ResourceChange.Descriptor instead.
+func (ResourceChange) EnumDescriptor() ([]byte, []int) {
+	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{2}
+}
+
 type GithubRepository struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -119,50 +220,550 @@ func (x *GithubRepository) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GithubRepository.ProtoReflect.Descriptor instead.
-func (*GithubRepository) Descriptor() ([]byte, []int) {
-	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{0}
+// Deprecated: Use GithubRepository.ProtoReflect.Descriptor instead.
+func (*GithubRepository) Descriptor() ([]byte, []int) {
+	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GithubRepository) GetOwner() string {
+	if x != nil {
+		return x.Owner
+	}
+	return ""
+}
+
+func (x *GithubRepository) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type Kubernetes struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Resources []*structpb.Struct `protobuf:"bytes,1,rep,name=resources,proto3" json:"resources,omitempty"`
+}
+
+func (x *Kubernetes) Reset() {
+	*x = Kubernetes{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_pb_deployment_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Kubernetes) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Kubernetes) ProtoMessage() {}
+
+func (x *Kubernetes) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pb_deployment_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Kubernetes.ProtoReflect.Descriptor instead.
+func (*Kubernetes) Descriptor() ([]byte, []int) {
+	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Kubernetes) GetResources() []*structpb.Struct {
+	if x != nil {
+		return x.Resources
+	}
+	return nil
+}
+
+type DeploymentRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ID                string                 `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	Time              *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"`
+	Deadline          *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=deadline,proto3" json:"deadline,omitempty"`
+	Cluster           string                 `protobuf:"bytes,4,opt,name=cluster,proto3" json:"cluster,omitempty"`
+	Team              string                 `protobuf:"bytes,5,opt,name=team,proto3" json:"team,omitempty"`
+	GitRefSha         string                 `protobuf:"bytes,6,opt,name=gitRefSha,proto3" json:"gitRefSha,omitempty"`
+	Kubernetes        *Kubernetes            `protobuf:"bytes,7,opt,name=kubernetes,proto3" json:"kubernetes,omitempty"`
+	Repository        *GithubRepository      `protobuf:"bytes,8,opt,name=repository,proto3" json:"repository,omitempty"`
+	GithubEnvironment string                 `protobuf:"bytes,9,opt,name=GithubEnvironment,proto3" json:"GithubEnvironment,omitempty"`
+	TraceParent       string                 `protobuf:"bytes,10,opt,name=traceParent,proto3" json:"traceParent,omitempty"`
+	DryRun            bool                   `protobuf:"varint,11,opt,name=dryRun,proto3" json:"dryRun,omitempty"`
+	Action            DeploymentAction       `protobuf:"varint,12,opt,name=action,proto3,enum=pb.DeploymentAction" json:"action,omitempty"`
+	RollbackOf        string                 `protobuf:"bytes,13,opt,name=rollbackOf,proto3" json:"rollbackOf,omitempty"`
+}
+
+func (x *DeploymentRequest) Reset() {
+	*x = DeploymentRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_pb_deployment_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeploymentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeploymentRequest) ProtoMessage() {}
+
+func (x *DeploymentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pb_deployment_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeploymentRequest.ProtoReflect.Descriptor instead.
+func (*DeploymentRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *DeploymentRequest) GetID() string {
+	if x != nil {
+		return x.ID
+	}
+	return ""
+}
+
+func (x *DeploymentRequest) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *DeploymentRequest) GetDeadline() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Deadline
+	}
+	return nil
+}
+
+func (x *DeploymentRequest) GetCluster() string {
+	if x != nil {
+		return x.Cluster
+	}
+	return ""
+}
+
+func (x *DeploymentRequest) GetTeam() string {
+	if x != nil {
+		return x.Team
+	}
+	return ""
+}
+
+func (x *DeploymentRequest) GetGitRefSha() string {
+	if x != nil {
+		return x.GitRefSha
+	}
+	return ""
+}
+
+func (x *DeploymentRequest) GetKubernetes() *Kubernetes {
+	if x != nil {
+		return x.Kubernetes
+	}
+	return nil
+}
+
+func (x *DeploymentRequest) GetRepository() *GithubRepository {
+	if x != nil {
+		return x.Repository
+	}
+	return nil
+}
+
+func (x *DeploymentRequest) GetGithubEnvironment() string {
+	if x != nil {
+		return x.GithubEnvironment
+	}
+	return ""
+}
+
+func (x *DeploymentRequest) GetTraceParent() string {
+	if x != nil {
+		return x.TraceParent
+	}
+	return ""
+}
+
+func (x *DeploymentRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+func (x *DeploymentRequest) GetAction() DeploymentAction {
+	if x != nil {
+		return x.Action
+	}
+	return DeploymentAction_deploy
+}
+
+func (x *DeploymentRequest) GetRollbackOf() string {
+	if x != nil {
+		return x.RollbackOf
+	}
+	return ""
+}
+
+type DeploymentStatus struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Request *DeploymentRequest     `protobuf:"bytes,1,opt,name=request,proto3" json:"request,omitempty"`
+	Time    *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"`
+	State   DeploymentState        `protobuf:"varint,3,opt,name=state,proto3,enum=pb.DeploymentState" json:"state,omitempty"`
+	Message string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (x *DeploymentStatus) Reset() {
+	*x = DeploymentStatus{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_pb_deployment_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeploymentStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeploymentStatus) ProtoMessage() {}
+
+func (x *DeploymentStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pb_deployment_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeploymentStatus.ProtoReflect.Descriptor instead.
+func (*DeploymentStatus) Descriptor() ([]byte, []int) {
+	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *DeploymentStatus) GetRequest() *DeploymentRequest {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+func (x *DeploymentStatus) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *DeploymentStatus) GetState() DeploymentState {
+	if x != nil {
+		return x.State
+	}
+	return DeploymentState_success
+}
+
+func (x *DeploymentStatus) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type ResourceDiff struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ApiVersion string         `protobuf:"bytes,1,opt,name=apiVersion,proto3" json:"apiVersion,omitempty"`
+	Kind       string         `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`
+	Namespace  string         `protobuf:"bytes,3,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Name       string         `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
+	Change     ResourceChange `protobuf:"varint,5,opt,name=change,proto3,enum=pb.ResourceChange" json:"change,omitempty"`
+	Diff       string         `protobuf:"bytes,6,opt,name=diff,proto3" json:"diff,omitempty"`
+	Error      string         `protobuf:"bytes,7,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (x *ResourceDiff) Reset() {
+	*x = ResourceDiff{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_pb_deployment_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ResourceDiff) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResourceDiff) ProtoMessage() {}
+
+func (x *ResourceDiff) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pb_deployment_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResourceDiff.ProtoReflect.Descriptor instead.
+func (*ResourceDiff) Descriptor() ([]byte, []int) {
+	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ResourceDiff) GetApiVersion() string {
+	if x != nil {
+		return x.ApiVersion
+	}
+	return ""
+}
+
+func (x *ResourceDiff) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *ResourceDiff) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *ResourceDiff) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ResourceDiff) GetChange() ResourceChange {
+	if x != nil {
+		return x.Change
+	}
+	return ResourceChange_unchanged
+}
+
+func (x *ResourceDiff) GetDiff() string {
+	if x != nil {
+		return x.Diff
+	}
+	return ""
+}
+
+func (x *ResourceDiff) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type DiffResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Request   *DeploymentRequest `protobuf:"bytes,1,opt,name=request,proto3" json:"request,omitempty"`
+	Resources []*ResourceDiff    `protobuf:"bytes,2,rep,name=resources,proto3" json:"resources,omitempty"`
+	Error     string             `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (x *DiffResult) Reset() {
+	*x = DiffResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_pb_deployment_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DiffResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiffResult) ProtoMessage() {}
+
+func (x *DiffResult) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pb_deployment_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiffResult.ProtoReflect.Descriptor instead.
+func (*DiffResult) Descriptor() ([]byte, []int) {
+	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DiffResult) GetRequest() *DeploymentRequest {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+func (x *DiffResult) GetResources() []*ResourceDiff {
+	if x != nil {
+		return x.Resources
+	}
+	return nil
+}
+
+func (x *DiffResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type DeploymentResource struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Group     string `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	Version   string `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	Kind      string `protobuf:"bytes,3,opt,name=kind,proto3" json:"kind,omitempty"`
+	Name      string `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
+	Namespace string `protobuf:"bytes,5,opt,name=namespace,proto3" json:"namespace,omitempty"`
+}
+
+func (x *DeploymentResource) Reset() {
+	*x = DeploymentResource{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_pb_deployment_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeploymentResource) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeploymentResource) ProtoMessage() {}
+
+func (x *DeploymentResource) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pb_deployment_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeploymentResource.ProtoReflect.Descriptor instead.
+func (*DeploymentResource) Descriptor() ([]byte, []int) {
+	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DeploymentResource) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *DeploymentResource) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
 }
 
-func (x *GithubRepository) GetOwner() string {
+func (x *DeploymentResource) GetKind() string {
 	if x != nil {
-		return x.Owner
+		return x.Kind
 	}
 	return ""
 }
 
-func (x *GithubRepository) GetName() string {
+func (x *DeploymentResource) GetName() string {
 	if x != nil {
 		return x.Name
 	}
 	return ""
 }
 
-type Kubernetes struct {
+func (x *DeploymentResource) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+type DeploymentRecord struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Resources []*structpb.Struct `protobuf:"bytes,1,rep,name=resources,proto3" json:"resources,omitempty"`
+	Request   *DeploymentRequest    `protobuf:"bytes,1,opt,name=request,proto3" json:"request,omitempty"`
+	Statuses  []*DeploymentStatus   `protobuf:"bytes,2,rep,name=statuses,proto3" json:"statuses,omitempty"`
+	Resources []*DeploymentResource `protobuf:"bytes,3,rep,name=resources,proto3" json:"resources,omitempty"`
 }
 
-func (x *Kubernetes) Reset() {
-	*x = Kubernetes{}
+func (x *DeploymentRecord) Reset() {
+	*x = DeploymentRecord{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_pb_deployment_proto_msgTypes[1]
+		mi := &file_pkg_pb_deployment_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *Kubernetes) String() string {
+func (x *DeploymentRecord) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Kubernetes) ProtoMessage() {}
+func (*DeploymentRecord) ProtoMessage() {}
 
-func (x *Kubernetes) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_pb_deployment_proto_msgTypes[1]
+func (x *DeploymentRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pb_deployment_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -173,52 +774,63 @@ func (x *Kubernetes) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Kubernetes.ProtoReflect.Descriptor instead.
-func (*Kubernetes) Descriptor() ([]byte, []int) {
-	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use DeploymentRecord.ProtoReflect.Descriptor instead.
+func (*DeploymentRecord) Descriptor() ([]byte, []int) {
+	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{7}
 }
 
-func (x *Kubernetes) GetResources() []*structpb.Struct {
+func (x *DeploymentRecord) GetRequest() *DeploymentRequest {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+func (x *DeploymentRecord) GetStatuses() []*DeploymentStatus {
+	if x != nil {
+		return x.Statuses
+	}
+	return nil
+}
+
+func (x *DeploymentRecord) GetResources() []*DeploymentResource {
 	if x != nil {
 		return x.Resources
 	}
 	return nil
 }
 
-type DeploymentRequest struct {
+type ListDeploymentsRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	ID                string                 `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
-	Time              *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"`
-	Deadline          *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=deadline,proto3" json:"deadline,omitempty"`
-	Cluster           string                 `protobuf:"bytes,4,opt,name=cluster,proto3" json:"cluster,omitempty"`
-	Team              string                 `protobuf:"bytes,5,opt,name=team,proto3" json:"team,omitempty"`
-	GitRefSha         string                 `protobuf:"bytes,6,opt,name=gitRefSha,proto3" json:"gitRefSha,omitempty"`
-	Kubernetes        *Kubernetes            `protobuf:"bytes,7,opt,name=kubernetes,proto3" json:"kubernetes,omitempty"`
-	Repository        *GithubRepository      `protobuf:"bytes,8,opt,name=repository,proto3" json:"repository,omitempty"`
-	GithubEnvironment string                 `protobuf:"bytes,9,opt,name=GithubEnvironment,proto3" json:"GithubEnvironment,omitempty"`
-	TraceParent       string                 `protobuf:"bytes,10,opt,name=traceParent,proto3" json:"traceParent,omitempty"`
+	Team       string                 `protobuf:"bytes,1,opt,name=team,proto3" json:"team,omitempty"`
+	Clusters   []string               `protobuf:"bytes,2,rep,name=clusters,proto3" json:"clusters,omitempty"`
+	Repository string                 `protobuf:"bytes,3,opt,name=repository,proto3" json:"repository,omitempty"`
+	States     []DeploymentState      `protobuf:"varint,4,rep,packed,name=states,proto3,enum=pb.DeploymentState" json:"states,omitempty"`
+	From       *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=from,proto3" json:"from,omitempty"`
+	To         *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=to,proto3" json:"to,omitempty"`
+	Limit      int32                  `protobuf:"varint,7,opt,name=limit,proto3" json:"limit,omitempty"`
 }
 
-func (x *DeploymentRequest) Reset() {
-	*x = DeploymentRequest{}
+func (x *ListDeploymentsRequest) Reset() {
+	*x = ListDeploymentsRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_pb_deployment_proto_msgTypes[2]
+		mi := &file_pkg_pb_deployment_proto_msgTypes[8]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *DeploymentRequest) String() string {
+func (x *ListDeploymentsRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*DeploymentRequest) ProtoMessage() {}
+func (*ListDeploymentsRequest) ProtoMessage() {}
 
-func (x *DeploymentRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_pb_deployment_proto_msgTypes[2]
+func (x *ListDeploymentsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pb_deployment_proto_msgTypes[8]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -229,109 +841,137 @@ func (x *DeploymentRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use DeploymentRequest.ProtoReflect.Descriptor instead.
-func (*DeploymentRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use ListDeploymentsRequest.ProtoReflect.Descriptor instead.
+func (*ListDeploymentsRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{8}
 }
 
-func (x *DeploymentRequest) GetID() string {
+func (x *ListDeploymentsRequest) GetTeam() string {
 	if x != nil {
-		return x.ID
+		return x.Team
 	}
 	return ""
 }
 
-func (x *DeploymentRequest) GetTime() *timestamppb.Timestamp {
+func (x *ListDeploymentsRequest) GetClusters() []string {
 	if x != nil {
-		return x.Time
+		return x.Clusters
 	}
 	return nil
 }
 
-func (x *DeploymentRequest) GetDeadline() *timestamppb.Timestamp {
+func (x *ListDeploymentsRequest) GetRepository() string {
 	if x != nil {
-		return x.Deadline
+		return x.Repository
 	}
-	return nil
+	return ""
 }
 
-func (x *DeploymentRequest) GetCluster() string {
+func (x *ListDeploymentsRequest) GetStates() []DeploymentState {
 	if x != nil {
-		return x.Cluster
+		return x.States
 	}
-	return ""
+	return nil
 }
 
-func (x *DeploymentRequest) GetTeam() string {
+func (x *ListDeploymentsRequest) GetFrom() *timestamppb.Timestamp {
 	if x != nil {
-		return x.Team
+		return x.From
 	}
-	return ""
+	return nil
 }
 
-func (x *DeploymentRequest) GetGitRefSha() string {
+func (x *ListDeploymentsRequest) GetTo() *timestamppb.Timestamp {
 	if x != nil {
-		return x.GitRefSha
+		return x.To
 	}
-	return ""
+	return nil
 }
 
-func (x *DeploymentRequest) GetKubernetes() *Kubernetes {
+func (x *ListDeploymentsRequest) GetLimit() int32 {
 	if x != nil {
-		return x.Kubernetes
+		return x.Limit
 	}
-	return nil
+	return 0
 }
 
-func (x *DeploymentRequest) GetRepository() *GithubRepository {
-	if x != nil {
-		return x.Repository
+type ListDeploymentsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Deployments []*DeploymentRecord `protobuf:"bytes,1,rep,name=deployments,proto3" json:"deployments,omitempty"`
+}
+
+func (x *ListDeploymentsResponse) Reset() {
+	*x = ListDeploymentsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_pb_deployment_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	return nil
 }
 
-func (x *DeploymentRequest) GetGithubEnvironment() string {
-	if x != nil {
-		return x.GithubEnvironment
+func (x *ListDeploymentsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDeploymentsResponse) ProtoMessage() {}
+
+func (x *ListDeploymentsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pb_deployment_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return ""
+	return mi.MessageOf(x)
 }
 
-func (x *DeploymentRequest) GetTraceParent() string {
+// Deprecated: Use ListDeploymentsResponse.ProtoReflect.Descriptor instead.
+func (*ListDeploymentsResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListDeploymentsResponse) GetDeployments() []*DeploymentRecord {
 	if x != nil {
-		return x.TraceParent
+		return x.Deployments
 	}
-	return ""
+	return nil
 }
 
-type DeploymentStatus struct {
+type RollbackRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Request *DeploymentRequest     `protobuf:"bytes,1,opt,name=request,proto3" json:"request,omitempty"`
-	Time    *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"`
-	State   DeploymentState        `protobuf:"varint,3,opt,name=state,proto3,enum=pb.DeploymentState" json:"state,omitempty"`
-	Message string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	Team         string                 `protobuf:"bytes,1,opt,name=team,proto3" json:"team,omitempty"`
+	Cluster      string                 `protobuf:"bytes,2,opt,name=cluster,proto3" json:"cluster,omitempty"`
+	Repository   *GithubRepository      `protobuf:"bytes,3,opt,name=repository,proto3" json:"repository,omitempty"`
+	DeploymentID string                 `protobuf:"bytes,4,opt,name=deploymentID,proto3" json:"deploymentID,omitempty"`
+	Deadline     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=deadline,proto3" json:"deadline,omitempty"`
+	TraceParent  string                 `protobuf:"bytes,6,opt,name=traceParent,proto3" json:"traceParent,omitempty"`
 }
 
-func (x *DeploymentStatus) Reset() {
-	*x = DeploymentStatus{}
+func (x *RollbackRequest) Reset() {
+	*x = RollbackRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_pb_deployment_proto_msgTypes[3]
+		mi := &file_pkg_pb_deployment_proto_msgTypes[10]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *DeploymentStatus) String() string {
+func (x *RollbackRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*DeploymentStatus) ProtoMessage() {}
+func (*RollbackRequest) ProtoMessage() {}
 
-func (x *DeploymentStatus) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_pb_deployment_proto_msgTypes[3]
+func (x *RollbackRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pb_deployment_proto_msgTypes[10]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -342,35 +982,49 @@ func (x *DeploymentStatus) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use DeploymentStatus.ProtoReflect.Descriptor instead.
-func (*DeploymentStatus) Descriptor() ([]byte, []int) {
-	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{3}
+// Deprecated: Use RollbackRequest.ProtoReflect.Descriptor instead.
+func (*RollbackRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{10}
 }
 
-func (x *DeploymentStatus) GetRequest() *DeploymentRequest {
+func (x *RollbackRequest) GetTeam() string {
 	if x != nil {
-		return x.Request
+		return x.Team
 	}
-	return nil
+	return ""
 }
 
-func (x *DeploymentStatus) GetTime() *timestamppb.Timestamp {
+func (x *RollbackRequest) GetCluster() string {
 	if x != nil {
-		return x.Time
+		return x.Cluster
+	}
+	return ""
+}
+
+func (x *RollbackRequest) GetRepository() *GithubRepository {
+	if x != nil {
+		return x.Repository
 	}
 	return nil
 }
 
-func (x *DeploymentStatus) GetState() DeploymentState {
+func (x *RollbackRequest) GetDeploymentID() string {
 	if x != nil {
-		return x.State
+		return x.DeploymentID
 	}
-	return DeploymentState_success
+	return ""
 }
 
-func (x *DeploymentStatus) GetMessage() string {
+func (x *RollbackRequest) GetDeadline() *timestamppb.Timestamp {
 	if x != nil {
-		return x.Message
+		return x.Deadline
+	}
+	return nil
+}
+
+func (x *RollbackRequest) GetTraceParent() string {
+	if x != nil {
+		return x.TraceParent
 	}
 	return ""
 }
@@ -382,12 +1036,18 @@ type GetDeploymentOpts struct {
 
 	Cluster     string                 `protobuf:"bytes,1,opt,name=cluster,proto3" json:"cluster,omitempty"`
 	StartupTime *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=startupTime,proto3" json:"startupTime,omitempty"`
+	// Identifies the deployd instance, so that a stale connection from the same instance can be replaced.
+	Instance string `protobuf:"bytes,3,opt,name=instance,proto3" json:"instance,omitempty"`
+	// Actions supported by deployd. Older versions don't send this, and only support deployments.
+	Actions []DeploymentAction `protobuf:"varint,4,rep,packed,name=actions,proto3,enum=pb.DeploymentAction" json:"actions,omitempty"`
+	// Whether deployd supports dry runs.
+	DryRun bool `protobuf:"varint,5,opt,name=dryRun,proto3" json:"dryRun,omitempty"`
 }
 
 func (x *GetDeploymentOpts) Reset() {
 	*x = GetDeploymentOpts{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_pb_deployment_proto_msgTypes[4]
+		mi := &file_pkg_pb_deployment_proto_msgTypes[11]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -400,7 +1060,7 @@ func (x *GetDeploymentOpts) String() string {
 func (*GetDeploymentOpts) ProtoMessage() {}
 
 func (x *GetDeploymentOpts) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_pb_deployment_proto_msgTypes[4]
+	mi := &file_pkg_pb_deployment_proto_msgTypes[11]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -413,7 +1073,7 @@ func (x *GetDeploymentOpts) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetDeploymentOpts.ProtoReflect.Descriptor instead.
 func (*GetDeploymentOpts) Descriptor() ([]byte, []int) {
-	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{4}
+	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *GetDeploymentOpts) GetCluster() string {
@@ -430,6 +1090,27 @@ func (x *GetDeploymentOpts) GetStartupTime() *timestamppb.Timestamp {
 	return nil
 }
 
+func (x *GetDeploymentOpts) GetInstance() string {
+	if x != nil {
+		return x.Instance
+	}
+	return ""
+}
+
+func (x *GetDeploymentOpts) GetActions() []DeploymentAction {
+	if x != nil {
+		return x.Actions
+	}
+	return nil
+}
+
+func (x *GetDeploymentOpts) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
 type ReportStatusOpts struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -439,7 +1120,7 @@ type ReportStatusOpts struct {
 func (x *ReportStatusOpts) Reset() {
 	*x = ReportStatusOpts{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_pb_deployment_proto_msgTypes[5]
+		mi := &file_pkg_pb_deployment_proto_msgTypes[12]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -452,7 +1133,7 @@ func (x *ReportStatusOpts) String() string {
 func (*ReportStatusOpts) ProtoMessage() {}
 
 func (x *ReportStatusOpts) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_pb_deployment_proto_msgTypes[5]
+	mi := &file_pkg_pb_deployment_proto_msgTypes[12]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -465,7 +1146,7 @@ func (x *ReportStatusOpts) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ReportStatusOpts.ProtoReflect.Descriptor instead.
 func (*ReportStatusOpts) Descriptor() ([]byte, []int) {
-	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{5}
+	return file_pkg_pb_deployment_proto_rawDescGZIP(), []int{12}
 }
 
 var File_pkg_pb_deployment_proto protoreflect.FileDescriptor
@@ -485,7 +1166,7 @@ var file_pkg_pb_deployment_proto_rawDesc = []byte{
 	0x75, 0x72, 0x63, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x67, 0x6f,
 	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x53, 0x74,
 	0x72, 0x75, 0x63, 0x74, 0x52, 0x09, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x73, 0x22,
-	0x8d, 0x03, 0x0a, 0x11, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x65,
+	0xf3, 0x03, 0x0a, 0x11, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x65,
 	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x49, 0x44, 0x18, 0x01, 0x20, 0x01, 0x28,
 	0x09, 0x52, 0x02, 0x49, 0x44, 0x12, 0x2e, 0x0a, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x02, 0x20,
 	0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
@@ -509,55 +1190,172 @@ var file_pkg_pb_deployment_proto_rawDesc = []byte{
 	0x6d, 0x65, 0x6e, 0x74, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x11, 0x47, 0x69, 0x74, 0x68,
 	0x75, 0x62, 0x45, 0x6e, 0x76, 0x69, 0x72, 0x6f, 0x6e, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x20, 0x0a,
 	0x0b, 0x74, 0x72, 0x61, 0x63, 0x65, 0x50, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x18, 0x0a, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x0b, 0x74, 0x72, 0x61, 0x63, 0x65, 0x50, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x22,
-	0xb8, 0x01, 0x0a, 0x10, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x53, 0x74,
-	0x61, 0x74, 0x75, 0x73, 0x12, 0x2f, 0x0a, 0x07, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f,
-	0x79, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x52, 0x07, 0x72, 0x65,
-	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x2e, 0x0a, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52,
-	0x04, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x29, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x03,
-	0x20, 0x01, 0x28, 0x0e, 0x32, 0x13, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79,
-	0x6d, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65,
-	0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x6b, 0x0a, 0x11, 0x47, 0x65,
-	0x74, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x4f, 0x70, 0x74, 0x73, 0x12,
-	0x18, 0x0a, 0x07, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x07, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x12, 0x3c, 0x0a, 0x0b, 0x73, 0x74, 0x61,
-	0x72, 0x74, 0x75, 0x70, 0x54, 0x69, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a,
-	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
-	0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0b, 0x73, 0x74, 0x61, 0x72,
-	0x74, 0x75, 0x70, 0x54, 0x69, 0x6d, 0x65, 0x22, 0x12, 0x0a, 0x10, 0x52, 0x65, 0x70, 0x6f, 0x72,
-	0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x4f, 0x70, 0x74, 0x73, 0x2a, 0x6e, 0x0a, 0x0f, 0x44,
-	0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x0b,
-	0x0a, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x10, 0x00, 0x12, 0x09, 0x0a, 0x05, 0x65,
-	0x72, 0x72, 0x6f, 0x72, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x66, 0x61, 0x69, 0x6c, 0x75, 0x72,
-	0x65, 0x10, 0x02, 0x12, 0x0c, 0x0a, 0x08, 0x69, 0x6e, 0x61, 0x63, 0x74, 0x69, 0x76, 0x65, 0x10,
-	0x03, 0x12, 0x0f, 0x0a, 0x0b, 0x69, 0x6e, 0x5f, 0x70, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73,
-	0x10, 0x04, 0x12, 0x0a, 0x0a, 0x06, 0x71, 0x75, 0x65, 0x75, 0x65, 0x64, 0x10, 0x05, 0x12, 0x0b,
-	0x0a, 0x07, 0x70, 0x65, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x10, 0x06, 0x32, 0x89, 0x01, 0x0a, 0x08,
-	0x44, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x12, 0x3f, 0x0a, 0x0b, 0x44, 0x65, 0x70, 0x6c,
-	0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x15, 0x2e, 0x70, 0x62, 0x2e, 0x47, 0x65, 0x74,
-	0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x4f, 0x70, 0x74, 0x73, 0x1a, 0x15,
-	0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x65,
-	0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x00, 0x30, 0x01, 0x12, 0x3c, 0x0a, 0x0c, 0x52, 0x65, 0x70,
-	0x6f, 0x72, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x14, 0x2e, 0x70, 0x62, 0x2e, 0x44,
-	0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x1a,
-	0x14, 0x2e, 0x70, 0x62, 0x2e, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75,
-	0x73, 0x4f, 0x70, 0x74, 0x73, 0x22, 0x00, 0x32, 0x7c, 0x0a, 0x06, 0x44, 0x65, 0x70, 0x6c, 0x6f,
-	0x79, 0x12, 0x37, 0x0a, 0x06, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x12, 0x15, 0x2e, 0x70, 0x62,
+	0x28, 0x09, 0x52, 0x0b, 0x74, 0x72, 0x61, 0x63, 0x65, 0x50, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x12,
+	0x16, 0x0a, 0x06, 0x64, 0x72, 0x79, 0x52, 0x75, 0x6e, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x06, 0x64, 0x72, 0x79, 0x52, 0x75, 0x6e, 0x12, 0x2c, 0x0a, 0x06, 0x61, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x14, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x70,
+	0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x06, 0x61,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1e, 0x0a, 0x0a, 0x72, 0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63,
+	0x6b, 0x4f, 0x66, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x72, 0x6f, 0x6c, 0x6c, 0x62,
+	0x61, 0x63, 0x6b, 0x4f, 0x66, 0x22, 0xb8, 0x01, 0x0a, 0x10, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79,
+	0x6d, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x2f, 0x0a, 0x07, 0x72, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x70, 0x62,
 	0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65,
-	0x73, 0x74, 0x1a, 0x14, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65,
-	0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x22, 0x00, 0x12, 0x39, 0x0a, 0x06, 0x53, 0x74,
-	0x61, 0x74, 0x75, 0x73, 0x12, 0x15, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79,
-	0x6d, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x14, 0x2e, 0x70, 0x62,
-	0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75,
-	0x73, 0x22, 0x00, 0x30, 0x01, 0x42, 0x39, 0x0a, 0x18, 0x6e, 0x6f, 0x2e, 0x6e, 0x61, 0x76, 0x2e,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x64, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e,
-	0x74, 0x5a, 0x1d, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6e, 0x61,
-	0x69, 0x73, 0x2f, 0x64, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x70, 0x62,
-	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x73, 0x74, 0x52, 0x07, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x2e, 0x0a, 0x04, 0x74,
+	0x69, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x29, 0x0a, 0x05, 0x73,
+	0x74, 0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x13, 0x2e, 0x70, 0x62, 0x2e,
+	0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52,
+	0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x22, 0xca, 0x01, 0x0a, 0x0c, 0x52, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x44, 0x69, 0x66,
+	0x66, 0x12, 0x1e, 0x0a, 0x0a, 0x61, 0x70, 0x69, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x61, 0x70, 0x69, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x12, 0x12, 0x0a, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x6b, 0x69, 0x6e, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61,
+	0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70,
+	0x61, 0x63, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x2a, 0x0a, 0x06, 0x63, 0x68, 0x61, 0x6e, 0x67,
+	0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x12, 0x2e, 0x70, 0x62, 0x2e, 0x52, 0x65, 0x73,
+	0x6f, 0x75, 0x72, 0x63, 0x65, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x06, 0x63, 0x68, 0x61,
+	0x6e, 0x67, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x69, 0x66, 0x66, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x64, 0x69, 0x66, 0x66, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72,
+	0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x22, 0x83, 0x01,
+	0x0a, 0x0a, 0x44, 0x69, 0x66, 0x66, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x2f, 0x0a, 0x07,
+	0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e,
+	0x70, 0x62, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x52, 0x07, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x2e, 0x0a,
+	0x09, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x10, 0x2e, 0x70, 0x62, 0x2e, 0x52, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x44, 0x69,
+	0x66, 0x66, 0x52, 0x09, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x73, 0x12, 0x14, 0x0a,
+	0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72,
+	0x72, 0x6f, 0x72, 0x22, 0x8a, 0x01, 0x0a, 0x12, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65,
+	0x6e, 0x74, 0x52, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x67, 0x72,
+	0x6f, 0x75, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x67, 0x72, 0x6f, 0x75, 0x70,
+	0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x6b, 0x69,
+	0x6e, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x12, 0x12,
+	0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61,
+	0x6d, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65,
+	0x22, 0xab, 0x01, 0x0a, 0x10, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x52,
+	0x65, 0x63, 0x6f, 0x72, 0x64, 0x12, 0x2f, 0x0a, 0x07, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x70, 0x6c,
+	0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x52, 0x07, 0x72,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x30, 0x0a, 0x08, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65,
+	0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x08,
+	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x65, 0x73, 0x12, 0x34, 0x0a, 0x09, 0x72, 0x65, 0x73, 0x6f,
+	0x75, 0x72, 0x63, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x70, 0x62,
+	0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x73, 0x6f, 0x75,
+	0x72, 0x63, 0x65, 0x52, 0x09, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x73, 0x22, 0x87,
+	0x02, 0x0a, 0x16, 0x4c, 0x69, 0x73, 0x74, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e,
+	0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65, 0x61,
+	0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x65, 0x61, 0x6d, 0x12, 0x1a, 0x0a,
+	0x08, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52,
+	0x08, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x72, 0x65, 0x70,
+	0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x72,
+	0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x2b, 0x0a, 0x06, 0x73, 0x74, 0x61,
+	0x74, 0x65, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0e, 0x32, 0x13, 0x2e, 0x70, 0x62, 0x2e, 0x44,
+	0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x06,
+	0x73, 0x74, 0x61, 0x74, 0x65, 0x73, 0x12, 0x2e, 0x0a, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
+	0x52, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x12, 0x2a, 0x0a, 0x02, 0x74, 0x6f, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x02,
+	0x74, 0x6f, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x22, 0x51, 0x0a, 0x17, 0x4c, 0x69, 0x73, 0x74,
+	0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x36, 0x0a, 0x0b, 0x64, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e,
+	0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65,
+	0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x52, 0x0b,
+	0x64, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x22, 0xf3, 0x01, 0x0a, 0x0f,
+	0x52, 0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x12, 0x0a, 0x04, 0x74, 0x65, 0x61, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74,
+	0x65, 0x61, 0x6d, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x12, 0x34, 0x0a,
+	0x0a, 0x72, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x14, 0x2e, 0x70, 0x62, 0x2e, 0x47, 0x69, 0x74, 0x68, 0x75, 0x62, 0x52, 0x65, 0x70,
+	0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x0a, 0x72, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74,
+	0x6f, 0x72, 0x79, 0x12, 0x22, 0x0a, 0x0c, 0x64, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e,
+	0x74, 0x49, 0x44, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x64, 0x65, 0x70, 0x6c, 0x6f,
+	0x79, 0x6d, 0x65, 0x6e, 0x74, 0x49, 0x44, 0x12, 0x36, 0x0a, 0x08, 0x64, 0x65, 0x61, 0x64, 0x6c,
+	0x69, 0x6e, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x08, 0x64, 0x65, 0x61, 0x64, 0x6c, 0x69, 0x6e, 0x65, 0x12,
+	0x20, 0x0a, 0x0b, 0x74, 0x72, 0x61, 0x63, 0x65, 0x50, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x74, 0x72, 0x61, 0x63, 0x65, 0x50, 0x61, 0x72, 0x65, 0x6e,
+	0x74, 0x22, 0xcf, 0x01, 0x0a, 0x11, 0x47, 0x65, 0x74, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d,
+	0x65, 0x6e, 0x74, 0x4f, 0x70, 0x74, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6c, 0x75, 0x73, 0x74,
+	0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65,
+	0x72, 0x12, 0x3c, 0x0a, 0x0b, 0x73, 0x74, 0x61, 0x72, 0x74, 0x75, 0x70, 0x54, 0x69, 0x6d, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61,
+	0x6d, 0x70, 0x52, 0x0b, 0x73, 0x74, 0x61, 0x72, 0x74, 0x75, 0x70, 0x54, 0x69, 0x6d, 0x65, 0x12,
+	0x1a, 0x0a, 0x08, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x08, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x12, 0x2e, 0x0a, 0x07, 0x61,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0e, 0x32, 0x14, 0x2e, 0x70,
+	0x62, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x41, 0x63, 0x74, 0x69,
+	0x6f, 0x6e, 0x52, 0x07, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x64,
+	0x72, 0x79, 0x52, 0x75, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x64, 0x72, 0x79,
+	0x52, 0x75, 0x6e, 0x22, 0x12, 0x0a, 0x10, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x53, 0x74, 0x61,
+	0x74, 0x75, 0x73, 0x4f, 0x70, 0x74, 0x73, 0x2a, 0x7d, 0x0a, 0x0f, 0x44, 0x65, 0x70, 0x6c, 0x6f,
+	0x79, 0x6d, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x0b, 0x0a, 0x07, 0x73, 0x75,
+	0x63, 0x63, 0x65, 0x73, 0x73, 0x10, 0x00, 0x12, 0x09, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72,
+	0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x66, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x10, 0x02, 0x12,
+	0x0c, 0x0a, 0x08, 0x69, 0x6e, 0x61, 0x63, 0x74, 0x69, 0x76, 0x65, 0x10, 0x03, 0x12, 0x0f, 0x0a,
+	0x0b, 0x69, 0x6e, 0x5f, 0x70, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x10, 0x04, 0x12, 0x0a,
+	0x0a, 0x06, 0x71, 0x75, 0x65, 0x75, 0x65, 0x64, 0x10, 0x05, 0x12, 0x0b, 0x0a, 0x07, 0x70, 0x65,
+	0x6e, 0x64, 0x69, 0x6e, 0x67, 0x10, 0x06, 0x12, 0x0d, 0x0a, 0x09, 0x63, 0x61, 0x6e, 0x63, 0x65,
+	0x6c, 0x6c, 0x65, 0x64, 0x10, 0x07, 0x2a, 0x34, 0x0a, 0x10, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79,
+	0x6d, 0x65, 0x6e, 0x74, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x0a, 0x0a, 0x06, 0x64, 0x65,
+	0x70, 0x6c, 0x6f, 0x79, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x64, 0x69, 0x66, 0x66, 0x10, 0x01,
+	0x12, 0x0a, 0x0a, 0x06, 0x63, 0x61, 0x6e, 0x63, 0x65, 0x6c, 0x10, 0x02, 0x2a, 0x39, 0x0a, 0x0e,
+	0x52, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x0d,
+	0x0a, 0x09, 0x75, 0x6e, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x64, 0x10, 0x00, 0x12, 0x0b, 0x0a,
+	0x07, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x75, 0x70,
+	0x64, 0x61, 0x74, 0x65, 0x64, 0x10, 0x02, 0x32, 0xbf, 0x01, 0x0a, 0x08, 0x44, 0x69, 0x73, 0x70,
+	0x61, 0x74, 0x63, 0x68, 0x12, 0x3f, 0x0a, 0x0b, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65,
+	0x6e, 0x74, 0x73, 0x12, 0x15, 0x2e, 0x70, 0x62, 0x2e, 0x47, 0x65, 0x74, 0x44, 0x65, 0x70, 0x6c,
+	0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x4f, 0x70, 0x74, 0x73, 0x1a, 0x15, 0x2e, 0x70, 0x62, 0x2e,
+	0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x22, 0x00, 0x30, 0x01, 0x12, 0x3c, 0x0a, 0x0c, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x53,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x14, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f,
+	0x79, 0x6d, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x1a, 0x14, 0x2e, 0x70, 0x62,
+	0x2e, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x4f, 0x70, 0x74,
+	0x73, 0x22, 0x00, 0x12, 0x34, 0x0a, 0x0a, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x44, 0x69, 0x66,
+	0x66, 0x12, 0x0e, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x69, 0x66, 0x66, 0x52, 0x65, 0x73, 0x75, 0x6c,
+	0x74, 0x1a, 0x14, 0x2e, 0x70, 0x62, 0x2e, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x53, 0x74, 0x61,
+	0x74, 0x75, 0x73, 0x4f, 0x70, 0x74, 0x73, 0x22, 0x00, 0x32, 0xed, 0x02, 0x0a, 0x06, 0x44, 0x65,
+	0x70, 0x6c, 0x6f, 0x79, 0x12, 0x37, 0x0a, 0x06, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x12, 0x15,
+	0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x14, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f,
+	0x79, 0x6d, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x22, 0x00, 0x12, 0x39, 0x0a,
+	0x06, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x15, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x70,
+	0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x14,
+	0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x53, 0x74,
+	0x61, 0x74, 0x75, 0x73, 0x22, 0x00, 0x30, 0x01, 0x12, 0x2f, 0x0a, 0x04, 0x44, 0x69, 0x66, 0x66,
+	0x12, 0x15, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0e, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x69, 0x66,
+	0x66, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x22, 0x00, 0x12, 0x37, 0x0a, 0x06, 0x43, 0x61, 0x6e,
+	0x63, 0x65, 0x6c, 0x12, 0x15, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d,
+	0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x14, 0x2e, 0x70, 0x62, 0x2e,
+	0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x22, 0x00, 0x12, 0x4c, 0x0a, 0x0f, 0x4c, 0x69, 0x73, 0x74, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79,
+	0x6d, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x1a, 0x2e, 0x70, 0x62, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x44,
+	0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x1b, 0x2e, 0x70, 0x62, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x44, 0x65, 0x70, 0x6c, 0x6f,
+	0x79, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00,
+	0x12, 0x37, 0x0a, 0x08, 0x52, 0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x12, 0x13, 0x2e, 0x70,
+	0x62, 0x2e, 0x52, 0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x14, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e,
+	0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x22, 0x00, 0x42, 0x39, 0x0a, 0x18, 0x6e, 0x6f, 0x2e,
+	0x6e, 0x61, 0x76, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x64, 0x65, 0x70, 0x6c, 0x6f,
+	0x79, 0x6d, 0x65, 0x6e, 0x74, 0x5a, 0x1d, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f,
+	0x6d, 0x2f, 0x6e, 0x61, 0x69, 0x73, 0x2f, 0x64, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x2f, 0x70, 0x6b,
+	0x67, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -572,42 +1370,75 @@ func file_pkg_pb_deployment_proto_rawDescGZIP() []byte {
 	return file_pkg_pb_deployment_proto_rawDescData
 }
 
-var file_pkg_pb_deployment_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_pkg_pb_deployment_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_pkg_pb_deployment_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_pkg_pb_deployment_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
 var file_pkg_pb_deployment_proto_goTypes = []any{
-	(DeploymentState)(0),          // 0: pb.DeploymentState
-	(*GithubRepository)(nil),      // 1: pb.GithubRepository
-	(*Kubernetes)(nil),            // 2: pb.Kubernetes
-	(*DeploymentRequest)(nil),     // 3: pb.DeploymentRequest
-	(*DeploymentStatus)(nil),      // 4: pb.DeploymentStatus
-	(*GetDeploymentOpts)(nil),     // 5: pb.GetDeploymentOpts
-	(*ReportStatusOpts)(nil),      // 6: pb.ReportStatusOpts
-	(*structpb.Struct)(nil),       // 7: google.protobuf.Struct
-	(*timestamppb.Timestamp)(nil), // 8: google.protobuf.Timestamp
+	(DeploymentState)(0),            // 0: pb.DeploymentState
+	(DeploymentAction)(0),           // 1: pb.DeploymentAction
+	(ResourceChange)(0),             // 2: pb.ResourceChange
+	(*GithubRepository)(nil),        // 3: pb.GithubRepository
+	(*Kubernetes)(nil),              // 4: pb.Kubernetes
+	(*DeploymentRequest)(nil),       // 5: pb.DeploymentRequest
+	(*DeploymentStatus)(nil),        // 6: pb.DeploymentStatus
+	(*ResourceDiff)(nil),            // 7: pb.ResourceDiff
+	(*DiffResult)(nil),              // 8: pb.DiffResult
+	(*DeploymentResource)(nil),      // 9: pb.DeploymentResource
+	(*DeploymentRecord)(nil),        // 10: pb.DeploymentRecord
+	(*ListDeploymentsRequest)(nil),  // 11: pb.ListDeploymentsRequest
+	(*ListDeploymentsResponse)(nil), // 12: pb.ListDeploymentsResponse
+	(*RollbackRequest)(nil),         // 13: pb.RollbackRequest
+	(*GetDeploymentOpts)(nil),       // 14: pb.GetDeploymentOpts
+	(*ReportStatusOpts)(nil),        // 15: pb.ReportStatusOpts
+	(*structpb.Struct)(nil),         // 16: google.protobuf.Struct
+	(*timestamppb.Timestamp)(nil),   // 17: google.protobuf.Timestamp
 }
 var file_pkg_pb_deployment_proto_depIdxs = []int32{
-	7,  // 0: pb.Kubernetes.resources:type_name -> google.protobuf.Struct
-	8,  // 1: pb.DeploymentRequest.time:type_name -> google.protobuf.Timestamp
-	8,  // 2: pb.DeploymentRequest.deadline:type_name -> google.protobuf.Timestamp
-	2,  // 3: pb.DeploymentRequest.kubernetes:type_name -> pb.Kubernetes
-	1,  // 4: pb.DeploymentRequest.repository:type_name -> pb.GithubRepository
-	3,  // 5: pb.DeploymentStatus.request:type_name -> pb.DeploymentRequest
-	8,  // 6: pb.DeploymentStatus.time:type_name -> google.protobuf.Timestamp
-	0,  // 7: pb.DeploymentStatus.state:type_name -> pb.DeploymentState
-	8,  // 8: pb.GetDeploymentOpts.startupTime:type_name -> google.protobuf.Timestamp
-	5,  // 9: pb.Dispatch.Deployments:input_type -> pb.GetDeploymentOpts
-	4,  // 10: pb.Dispatch.ReportStatus:input_type -> pb.DeploymentStatus
-	3,  // 11: pb.Deploy.Deploy:input_type -> pb.DeploymentRequest
-	3,  // 12: pb.Deploy.Status:input_type -> pb.DeploymentRequest
-	3,  // 13: pb.Dispatch.Deployments:output_type -> pb.DeploymentRequest
-	6,  // 14: pb.Dispatch.ReportStatus:output_type -> pb.ReportStatusOpts
-	4,  // 15: pb.Deploy.Deploy:output_type -> pb.DeploymentStatus
-	4,  // 16: pb.Deploy.Status:output_type -> pb.DeploymentStatus
-	13, // [13:17] is the sub-list for method output_type
-	9,  // [9:13] is the sub-list for method input_type
-	9,  // [9:9] is the sub-list for extension type_name
-	9,  // [9:9] is the sub-list for extension extendee
-	0,  // [0:9] is the sub-list for field type_name
+	16, // 0: pb.Kubernetes.resources:type_name -> google.protobuf.Struct
+	17, // 1: pb.DeploymentRequest.time:type_name -> google.protobuf.Timestamp
+	17, // 2: pb.DeploymentRequest.deadline:type_name -> google.protobuf.Timestamp
+	4,  // 3: pb.DeploymentRequest.kubernetes:type_name -> pb.Kubernetes
+	3,  // 4: pb.DeploymentRequest.repository:type_name -> pb.GithubRepository
+	1,  // 5: pb.DeploymentRequest.action:type_name -> pb.DeploymentAction
+	5,  // 6: pb.DeploymentStatus.request:type_name -> pb.DeploymentRequest
+	17, // 7: pb.DeploymentStatus.time:type_name -> google.protobuf.Timestamp
+	0,  // 8: pb.DeploymentStatus.state:type_name -> pb.DeploymentState
+	2,  // 9: pb.ResourceDiff.change:type_name -> pb.ResourceChange
+	5,  // 10: pb.DiffResult.request:type_name -> pb.DeploymentRequest
+	7,  // 11: pb.DiffResult.resources:type_name -> pb.ResourceDiff
+	5,  // 12: pb.DeploymentRecord.request:type_name -> pb.DeploymentRequest
+	6,  // 13: pb.DeploymentRecord.statuses:type_name -> pb.DeploymentStatus
+	9,  // 14: pb.DeploymentRecord.resources:type_name -> pb.DeploymentResource
+	0,  // 15: pb.ListDeploymentsRequest.states:type_name -> pb.DeploymentState
+	17, // 16: pb.ListDeploymentsRequest.from:type_name -> google.protobuf.Timestamp
+	17, // 17: pb.ListDeploymentsRequest.to:type_name -> google.protobuf.Timestamp
+	10, // 18: pb.ListDeploymentsResponse.deployments:type_name -> pb.DeploymentRecord
+	3,  // 19: pb.RollbackRequest.repository:type_name -> pb.GithubRepository
+	17, // 20: pb.RollbackRequest.deadline:type_name -> google.protobuf.Timestamp
+	17, // 21: pb.GetDeploymentOpts.startupTime:type_name -> google.protobuf.Timestamp
+	1,  // 22: pb.GetDeploymentOpts.actions:type_name -> pb.DeploymentAction
+	14, // 23: pb.Dispatch.Deployments:input_type -> pb.GetDeploymentOpts
+	6,  // 24: pb.Dispatch.ReportStatus:input_type -> pb.DeploymentStatus
+	8,  // 25: pb.Dispatch.ReportDiff:input_type -> pb.DiffResult
+	5,  // 26: pb.Deploy.Deploy:input_type -> pb.DeploymentRequest
+	5,  // 27: pb.Deploy.Status:input_type -> pb.DeploymentRequest
+	5,  // 28: pb.Deploy.Diff:input_type -> pb.DeploymentRequest
+	5,  // 29: pb.Deploy.Cancel:input_type -> pb.DeploymentRequest
+	11, // 30: pb.Deploy.ListDeployments:input_type -> pb.ListDeploymentsRequest
+	13, // 31: pb.Deploy.Rollback:input_type -> pb.RollbackRequest
+	5,  // 32: pb.Dispatch.Deployments:output_type -> pb.DeploymentRequest
+	15, // 33: pb.Dispatch.ReportStatus:output_type -> pb.ReportStatusOpts
+	15, // 34: pb.Dispatch.ReportDiff:output_type -> pb.ReportStatusOpts
+	6,  // 35: pb.Deploy.Deploy:output_type -> pb.DeploymentStatus
+	6,  // 36: pb.Deploy.Status:output_type -> pb.DeploymentStatus
+	8,  // 37: pb.Deploy.Diff:output_type -> pb.DiffResult
+	6,  // 38: pb.Deploy.Cancel:output_type -> pb.DeploymentStatus
+	12, // 39: pb.Deploy.ListDeployments:output_type -> pb.ListDeploymentsResponse
+	6,  // 40: pb.Deploy.Rollback:output_type -> pb.DeploymentStatus
+	32, // [32:41] is the sub-list for method output_type
+	23, // [23:32] is the sub-list for method input_type
+	23, // [23:23] is the sub-list for extension type_name
+	23, // [23:23] is the sub-list for extension extendee
+	0,  // [0:23] is the sub-list for field type_name
 }
 
 func init() { file_pkg_pb_deployment_proto_init() }
@@ -665,7 +1496,7 @@ func file_pkg_pb_deployment_proto_init() {
 			}
 		}
 		file_pkg_pb_deployment_proto_msgTypes[4].Exporter = func(v any, i int) any {
-			switch v := v.(*GetDeploymentOpts); i {
+			switch v := v.(*ResourceDiff); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -677,6 +1508,90 @@ func file_pkg_pb_deployment_proto_init() {
 			}
 		}
 		file_pkg_pb_deployment_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*DiffResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_pb_deployment_proto_msgTypes[6].Exporter = func(v any, i int) any {
+			switch v := v.(*DeploymentResource); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_pb_deployment_proto_msgTypes[7].Exporter = func(v any, i int) any {
+			switch v := v.(*DeploymentRecord); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_pb_deployment_proto_msgTypes[8].Exporter = func(v any, i int) any {
+			switch v := v.(*ListDeploymentsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_pb_deployment_proto_msgTypes[9].Exporter = func(v any, i int) any {
+			switch v := v.(*ListDeploymentsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_pb_deployment_proto_msgTypes[10].Exporter = func(v any, i int) any {
+			switch v := v.(*RollbackRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_pb_deployment_proto_msgTypes[11].Exporter = func(v any, i int) any {
+			switch v := v.(*GetDeploymentOpts); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_pb_deployment_proto_msgTypes[12].Exporter = func(v any, i int) any {
 			switch v := v.(*ReportStatusOpts); i {
 			case 0:
 				return &v.state
@@ -694,8 +1609,8 @@ func file_pkg_pb_deployment_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_pkg_pb_deployment_proto_rawDesc,
-			NumEnums:      1,
-			NumMessages:   6,
+			NumEnums:      3,
+			NumMessages:   13,
 			NumExtensions: 0,
 			NumServices:   2,
 		},
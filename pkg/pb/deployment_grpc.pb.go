@@ -21,14 +21,21 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	Dispatch_Deployments_FullMethodName  = "/pb.Dispatch/Deployments"
 	Dispatch_ReportStatus_FullMethodName = "/pb.Dispatch/ReportStatus"
+	Dispatch_ReportDiff_FullMethodName   = "/pb.Dispatch/ReportDiff"
 )
 
 // DispatchClient is the client API for Dispatch service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// This service is used by deployd.
 type DispatchClient interface {
+	// Continuous streaming of deployments that should be processed by deployd.
 	Deployments(ctx context.Context, in *GetDeploymentOpts, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DeploymentRequest], error)
+	// Deployd returns back statuses for deploys using this API.
 	ReportStatus(ctx context.Context, in *DeploymentStatus, opts ...grpc.CallOption) (*ReportStatusOpts, error)
+	// Deployd returns the result of diff requests using this API.
+	ReportDiff(ctx context.Context, in *DiffResult, opts ...grpc.CallOption) (*ReportStatusOpts, error)
 }
 
 type dispatchClient struct {
@@ -68,12 +75,28 @@ func (c *dispatchClient) ReportStatus(ctx context.Context, in *DeploymentStatus,
 	return out, nil
 }
 
+func (c *dispatchClient) ReportDiff(ctx context.Context, in *DiffResult, opts ...grpc.CallOption) (*ReportStatusOpts, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReportStatusOpts)
+	err := c.cc.Invoke(ctx, Dispatch_ReportDiff_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // DispatchServer is the server API for Dispatch service.
 // All implementations must embed UnimplementedDispatchServer
 // for forward compatibility.
+//
+// This service is used by deployd.
 type DispatchServer interface {
+	// Continuous streaming of deployments that should be processed by deployd.
 	Deployments(*GetDeploymentOpts, grpc.ServerStreamingServer[DeploymentRequest]) error
+	// Deployd returns back statuses for deploys using this API.
 	ReportStatus(context.Context, *DeploymentStatus) (*ReportStatusOpts, error)
+	// Deployd returns the result of diff requests using this API.
+	ReportDiff(context.Context, *DiffResult) (*ReportStatusOpts, error)
 	mustEmbedUnimplementedDispatchServer()
 }
 
@@ -90,6 +113,9 @@ func (UnimplementedDispatchServer) Deployments(*GetDeploymentOpts, grpc.ServerSt
 func (UnimplementedDispatchServer) ReportStatus(context.Context, *DeploymentStatus) (*ReportStatusOpts, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ReportStatus not implemented")
 }
+func (UnimplementedDispatchServer) ReportDiff(context.Context, *DiffResult) (*ReportStatusOpts, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportDiff not implemented")
+}
 func (UnimplementedDispatchServer) mustEmbedUnimplementedDispatchServer() {}
 func (UnimplementedDispatchServer) testEmbeddedByValue()                  {}
 
@@ -140,6 +166,24 @@ func _Dispatch_ReportStatus_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Dispatch_ReportDiff_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DiffResult)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DispatchServer).ReportDiff(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Dispatch_ReportDiff_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DispatchServer).ReportDiff(ctx, req.(*DiffResult))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Dispatch_ServiceDesc is the grpc.ServiceDesc for Dispatch service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -151,6 +195,10 @@ var Dispatch_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ReportStatus",
 			Handler:    _Dispatch_ReportStatus_Handler,
 		},
+		{
+			MethodName: "ReportDiff",
+			Handler:    _Dispatch_ReportDiff_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -163,16 +211,26 @@ var Dispatch_ServiceDesc = grpc.ServiceDesc{
 }
 
 const (
-	Deploy_Deploy_FullMethodName = "/pb.Deploy/Deploy"
-	Deploy_Status_FullMethodName = "/pb.Deploy/Status"
+	Deploy_Deploy_FullMethodName          = "/pb.Deploy/Deploy"
+	Deploy_Status_FullMethodName          = "/pb.Deploy/Status"
+	Deploy_Diff_FullMethodName            = "/pb.Deploy/Diff"
+	Deploy_Cancel_FullMethodName          = "/pb.Deploy/Cancel"
+	Deploy_ListDeployments_FullMethodName = "/pb.Deploy/ListDeployments"
+	Deploy_Rollback_FullMethodName        = "/pb.Deploy/Rollback"
 )
 
 // DeployClient is the client API for Deploy service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// This service is used by end-users in their CI pipelines.
 type DeployClient interface {
 	Deploy(ctx context.Context, in *DeploymentRequest, opts ...grpc.CallOption) (*DeploymentStatus, error)
 	Status(ctx context.Context, in *DeploymentRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DeploymentStatus], error)
+	Diff(ctx context.Context, in *DeploymentRequest, opts ...grpc.CallOption) (*DiffResult, error)
+	Cancel(ctx context.Context, in *DeploymentRequest, opts ...grpc.CallOption) (*DeploymentStatus, error)
+	ListDeployments(ctx context.Context, in *ListDeploymentsRequest, opts ...grpc.CallOption) (*ListDeploymentsResponse, error)
+	Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*DeploymentStatus, error)
 }
 
 type deployClient struct {
@@ -212,12 +270,58 @@ func (c *deployClient) Status(ctx context.Context, in *DeploymentRequest, opts .
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Deploy_StatusClient = grpc.ServerStreamingClient[DeploymentStatus]
 
+func (c *deployClient) Diff(ctx context.Context, in *DeploymentRequest, opts ...grpc.CallOption) (*DiffResult, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DiffResult)
+	err := c.cc.Invoke(ctx, Deploy_Diff_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deployClient) Cancel(ctx context.Context, in *DeploymentRequest, opts ...grpc.CallOption) (*DeploymentStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeploymentStatus)
+	err := c.cc.Invoke(ctx, Deploy_Cancel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deployClient) ListDeployments(ctx context.Context, in *ListDeploymentsRequest, opts ...grpc.CallOption) (*ListDeploymentsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDeploymentsResponse)
+	err := c.cc.Invoke(ctx, Deploy_ListDeployments_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deployClient) Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*DeploymentStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeploymentStatus)
+	err := c.cc.Invoke(ctx, Deploy_Rollback_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // DeployServer is the server API for Deploy service.
 // All implementations must embed UnimplementedDeployServer
 // for forward compatibility.
+//
+// This service is used by end-users in their CI pipelines.
 type DeployServer interface {
 	Deploy(context.Context, *DeploymentRequest) (*DeploymentStatus, error)
 	Status(*DeploymentRequest, grpc.ServerStreamingServer[DeploymentStatus]) error
+	Diff(context.Context, *DeploymentRequest) (*DiffResult, error)
+	Cancel(context.Context, *DeploymentRequest) (*DeploymentStatus, error)
+	ListDeployments(context.Context, *ListDeploymentsRequest) (*ListDeploymentsResponse, error)
+	Rollback(context.Context, *RollbackRequest) (*DeploymentStatus, error)
 	mustEmbedUnimplementedDeployServer()
 }
 
@@ -234,6 +338,18 @@ func (UnimplementedDeployServer) Deploy(context.Context, *DeploymentRequest) (*D
 func (UnimplementedDeployServer) Status(*DeploymentRequest, grpc.ServerStreamingServer[DeploymentStatus]) error {
 	return status.Errorf(codes.Unimplemented, "method Status not implemented")
 }
+func (UnimplementedDeployServer) Diff(context.Context, *DeploymentRequest) (*DiffResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Diff not implemented")
+}
+func (UnimplementedDeployServer) Cancel(context.Context, *DeploymentRequest) (*DeploymentStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Cancel not implemented")
+}
+func (UnimplementedDeployServer) ListDeployments(context.Context, *ListDeploymentsRequest) (*ListDeploymentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeployments not implemented")
+}
+func (UnimplementedDeployServer) Rollback(context.Context, *RollbackRequest) (*DeploymentStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rollback not implemented")
+}
 func (UnimplementedDeployServer) mustEmbedUnimplementedDeployServer() {}
 func (UnimplementedDeployServer) testEmbeddedByValue()                {}
 
@@ -284,6 +400,78 @@ func _Deploy_Status_Handler(srv interface{}, stream grpc.ServerStream) error {
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Deploy_StatusServer = grpc.ServerStreamingServer[DeploymentStatus]
 
+func _Deploy_Diff_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeploymentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeployServer).Diff(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Deploy_Diff_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeployServer).Diff(ctx, req.(*DeploymentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Deploy_Cancel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeploymentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeployServer).Cancel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Deploy_Cancel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeployServer).Cancel(ctx, req.(*DeploymentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Deploy_ListDeployments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDeploymentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeployServer).ListDeployments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Deploy_ListDeployments_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeployServer).ListDeployments(ctx, req.(*ListDeploymentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Deploy_Rollback_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RollbackRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeployServer).Rollback(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Deploy_Rollback_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeployServer).Rollback(ctx, req.(*RollbackRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Deploy_ServiceDesc is the grpc.ServiceDesc for Deploy service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -295,6 +483,22 @@ var Deploy_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Deploy",
 			Handler:    _Deploy_Deploy_Handler,
 		},
+		{
+			MethodName: "Diff",
+			Handler:    _Deploy_Diff_Handler,
+		},
+		{
+			MethodName: "Cancel",
+			Handler:    _Deploy_Cancel_Handler,
+		},
+		{
+			MethodName: "ListDeployments",
+			Handler:    _Deploy_ListDeployments_Handler,
+		},
+		{
+			MethodName: "Rollback",
+			Handler:    _Deploy_Rollback_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
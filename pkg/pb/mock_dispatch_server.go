@@ -27,6 +27,32 @@ func (_m *MockDispatchServer) Deployments(_a0 *GetDeploymentOpts, _a1 Dispatch_D
 	return r0
 }
 
+// ReportDiff provides a mock function with given fields: _a0, _a1
+func (_m *MockDispatchServer) ReportDiff(_a0 context.Context, _a1 *DiffResult) (*ReportStatusOpts, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *ReportStatusOpts
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *DiffResult) (*ReportStatusOpts, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *DiffResult) *ReportStatusOpts); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*ReportStatusOpts)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *DiffResult) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ReportStatus provides a mock function with given fields: _a0, _a1
 func (_m *MockDispatchServer) ReportStatus(_a0 context.Context, _a1 *DeploymentStatus) (*ReportStatusOpts, error) {
 	ret := _m.Called(_a0, _a1)
@@ -2,6 +2,7 @@ package pb
 
 import (
 	"fmt"
+	"strings"
 )
 
 func (m *GithubRepository) FullName() string {
@@ -24,3 +25,16 @@ func (m *GithubRepository) FullNamePtr() *string {
 func (m *GithubRepository) Valid() bool {
 	return len(m.GetOwner()) > 0 && len(m.GetName()) > 0
 }
+
+// GithubRepositoryFromFullName parses a full name such as "navikt/foobar".
+// Returns nil if the name is not on that form.
+func GithubRepositoryFromFullName(fullName string) *GithubRepository {
+	owner, name, found := strings.Cut(fullName, "/")
+	if !found || len(owner) == 0 || len(name) == 0 {
+		return nil
+	}
+	return &GithubRepository{
+		Owner: owner,
+		Name:  name,
+	}
+}
@@ -14,3 +14,13 @@ func TestGithubRepository_FullName(t *testing.T) {
 	}
 	assert.Equal(t, "foo/bar", repo.FullName())
 }
+
+func TestGithubRepositoryFromFullName(t *testing.T) {
+	repo := pb.GithubRepositoryFromFullName("foo/bar")
+	assert.Equal(t, "foo", repo.GetOwner())
+	assert.Equal(t, "bar", repo.GetName())
+
+	assert.Nil(t, pb.GithubRepositoryFromFullName("foo"))
+	assert.Nil(t, pb.GithubRepositoryFromFullName("/bar"))
+	assert.Nil(t, pb.GithubRepositoryFromFullName(""))
+}
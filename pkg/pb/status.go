@@ -11,6 +11,7 @@ func (x DeploymentState) Finished() bool {
 	case DeploymentState_error:
 	case DeploymentState_failure:
 	case DeploymentState_inactive:
+	case DeploymentState_cancelled:
 	default:
 		return false
 	}
@@ -22,6 +23,7 @@ func (x DeploymentState) IsError() bool {
 	case DeploymentState_error:
 	case DeploymentState_failure:
 	case DeploymentState_inactive:
+	case DeploymentState_cancelled:
 	default:
 		return false
 	}
@@ -38,6 +40,15 @@ func (x DeploymentState) StatusEmoji() rune {
 	return '❓'
 }
 
+func NewCancelledStatus(req *DeploymentRequest) *DeploymentStatus {
+	return &DeploymentStatus{
+		Request: req,
+		Message: "Deployment was cancelled.",
+		State:   DeploymentState_cancelled,
+		Time:    TimeAsTimestamp(time.Now()),
+	}
+}
+
 func NewErrorStatus(req *DeploymentRequest, err error) *DeploymentStatus {
 	return &DeploymentStatus{
 		Request: req,
@@ -91,3 +102,12 @@ func NewSuccessStatus(req *DeploymentRequest) *DeploymentStatus {
 		Time:    TimeAsTimestamp(time.Now()),
 	}
 }
+
+func NewDryRunSuccessStatus(req *DeploymentRequest, resources int) *DeploymentStatus {
+	return &DeploymentStatus{
+		Request: req,
+		Message: fmt.Sprintf("Dry run completed successfully; all %d resources passed validation.", resources),
+		State:   DeploymentState_success,
+		Time:    TimeAsTimestamp(time.Now()),
+	}
+}